@@ -9,7 +9,11 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
@@ -41,7 +45,7 @@ func getAppArmorVersion() string {
 	return string(found[1])
 }
 
-func (d apparmor) LoadProfile(profilePath string, cachePath string) (bool, *dbus.Error) {
+func (d apparmor) LoadProfile(sender dbus.Sender, profilePath string, cachePath string) (bool, *dbus.Error) {
 	logging.Info.Printf("Load AppArmor profile '%s'.", profilePath)
 
 	cmd := exec.Command(appArmorParserCmd, "--replace", "--write-cache", "--cache-loc", cachePath, profilePath)
@@ -51,10 +55,11 @@ func (d apparmor) LoadProfile(profilePath string, cachePath string) (bool, *dbus
 	}
 
 	logging.Info.Printf("Load profile '%s': %s", profilePath, out)
+	audit.Record(sender, "AppArmor.LoadProfile", "", profilePath)
 	return true, nil
 }
 
-func (d apparmor) UnloadProfile(profilePath string, cachePath string) (bool, *dbus.Error) {
+func (d apparmor) UnloadProfile(sender dbus.Sender, profilePath string, cachePath string) (bool, *dbus.Error) {
 	logging.Info.Printf("Unload AppArmor profile '%s'.", profilePath)
 
 	cmd := exec.Command(appArmorParserCmd, "--remove", "--write-cache", "--cache-loc", cachePath, profilePath)
@@ -65,9 +70,15 @@ func (d apparmor) UnloadProfile(profilePath string, cachePath string) (bool, *db
 	}
 
 	logging.Info.Printf("Unload profile '%s': %s", profilePath, out)
+	audit.Record(sender, "AppArmor.UnloadProfile", profilePath, "")
 	return true, nil
 }
 
+var methodArgNames = map[string][]string{
+	"LoadProfile":   {"profile_path", "cache_path", "success"},
+	"UnloadProfile": {"profile_path", "cache_path", "success"},
+}
+
 func InitializeDBus(conn *dbus.Conn) {
 	d := apparmor{
 		conn: conn,
@@ -84,13 +95,13 @@ func InitializeDBus(conn *dbus.Conn) {
 		},
 	}
 
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 	d.props = props
 
-	err = conn.Export(d, objectPath, ifaceName)
+	err = recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -102,7 +113,7 @@ func InitializeDBus(conn *dbus.Conn) {
 			prop.IntrospectData,
 			{
 				Name:       ifaceName,
-				Methods:    introspect.Methods(d),
+				Methods:    introspection.Methods(d, methodArgNames),
 				Properties: props.Introspection(ifaceName),
 			},
 		},
@@ -114,4 +125,5 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
 }
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath        = "/io/hass/os/Audit"
+	ifaceName         = "io.hass.os.Audit"
+	overlayDirectory  = "/mnt/overlay"
+	journalName       = "os-agent/audit.log"
+	fallbackDirectory = "/var/lib"
+	unknownCaller     = "unknown"
+	maxJournalSize    = 1 << 20
+	rotatedSuffix     = ".1"
+)
+
+// Entry is a single configuration change performed by the agent.
+type Entry struct {
+	Time   int64  `json:"time"`
+	Caller string `json:"caller"`
+	Action string `json:"action"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+var (
+	lock sync.Mutex
+)
+
+type audit struct {
+	conn *dbus.Conn
+}
+
+func journalPath() string {
+	if _, err := os.Stat(overlayDirectory); err == nil {
+		return filepath.Join(overlayDirectory, journalName)
+	}
+	return filepath.Join(fallbackDirectory, journalName)
+}
+
+// Record appends a change to the audit journal. Failures are logged but
+// never block the change itself.
+func Record(caller dbus.Sender, action string, oldValue interface{}, newValue interface{}) {
+	entry := Entry{
+		Time:   time.Now().Unix(),
+		Caller: string(caller),
+		Action: action,
+		Old:    fmt.Sprint(oldValue),
+		New:    fmt.Sprint(newValue),
+	}
+	if entry.Caller == "" {
+		entry.Caller = unknownCaller
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logging.Error.Printf("Failed to encode audit entry: %s", err)
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	path := journalPath()
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logging.Error.Printf("Failed to create audit journal directory: %s", err)
+		return
+	}
+
+	// Keep one rotated journal, the history is capped at twice the limit
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxJournalSize {
+		if err = os.Rename(path, path+rotatedSuffix); err != nil {
+			logging.Error.Printf("Failed to rotate audit journal %s: %s", path, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logging.Error.Printf("Failed to open audit journal %s: %s", path, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		logging.Error.Printf("Failed to write audit journal: %s", err)
+	}
+}
+
+// readEntries appends the entries of a journal file, keeping the last count.
+func readEntries(path string, entries []Entry, count uint32) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if count > 0 && len(entries) > int(count) {
+			entries = entries[1:]
+		}
+	}
+	return entries, nil
+}
+
+// GetEntries returns the last count entries of the journal, oldest first.
+// A count of 0 returns the whole journal.
+func (d audit) GetEntries(count uint32) ([]Entry, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	path := journalPath()
+	entries, err := readEntries(path+rotatedSuffix, []Entry{}, count)
+	if err == nil {
+		entries, err = readEntries(path, entries, count)
+	}
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return entries, nil
+}
+
+var methodArgNames = map[string][]string{
+	"GetEntries": {"count", "entries"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := audit{
+		conn: conn,
+	}
+
+	err := recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:    ifaceName,
+				Methods: introspection.Methods(d, methodArgNames),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, nil, ifaceName)
+}
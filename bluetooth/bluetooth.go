@@ -0,0 +1,239 @@
+package bluetooth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath     = "/io/hass/os/Bluetooth"
+	ifaceName      = "io.hass.os.Bluetooth"
+	bluetoothClass = "/sys/class/bluetooth"
+	rfkillClass    = "/sys/class/rfkill"
+	powerCycleWait = 2 * time.Second
+)
+
+var adapterRegex = regexp.MustCompile(`^hci([0-9]+)$`)
+
+type bluetooth struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func readAttribute(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func adapterID(adapter string) (int, error) {
+	match := adapterRegex.FindStringSubmatch(adapter)
+	if match == nil {
+		return 0, fmt.Errorf("Invalid Bluetooth adapter '%s'", adapter)
+	}
+	if _, err := ioutil.ReadDir(filepath.Join(bluetoothClass, adapter)); err != nil {
+		return 0, fmt.Errorf("Bluetooth adapter %s not found", adapter)
+	}
+	return strconv.Atoi(match[1])
+}
+
+// rfkillSwitch returns the rfkill switch of an adapter, e.g.
+// /sys/class/rfkill/rfkill0.
+func rfkillSwitch(adapter string) string {
+	matches, _ := filepath.Glob(filepath.Join(bluetoothClass, adapter, "rfkill*"))
+	if len(matches) == 0 {
+		return ""
+	}
+	return filepath.Join(rfkillClass, filepath.Base(matches[0]))
+}
+
+func getAdapters() map[string]map[string]string {
+	adapters := map[string]map[string]string{}
+
+	entries, err := ioutil.ReadDir(bluetoothClass)
+	if err != nil {
+		return adapters
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !adapterRegex.MatchString(name) {
+			continue
+		}
+
+		adapter := map[string]string{
+			"address":      readAttribute(filepath.Join(bluetoothClass, name, "address")),
+			"type":         readAttribute(filepath.Join(bluetoothClass, name, "type")),
+			"bus":          readAttribute(filepath.Join(bluetoothClass, name, "bus")),
+			"soft_blocked": "false",
+			"hard_blocked": "false",
+		}
+		if rfkill := rfkillSwitch(name); rfkill != "" {
+			adapter["soft_blocked"] = strconv.FormatBool(readAttribute(filepath.Join(rfkill, "soft")) == "1")
+			adapter["hard_blocked"] = strconv.FormatBool(readAttribute(filepath.Join(rfkill, "hard")) == "1")
+		}
+		if driver, err := filepath.EvalSymlinks(filepath.Join(bluetoothClass, name, "device", "driver")); err == nil {
+			adapter["driver"] = filepath.Base(driver)
+		}
+		adapters[name] = adapter
+	}
+	return adapters
+}
+
+func setSoftBlock(rfkill string, blocked bool) error {
+	value := "0"
+	if blocked {
+		value = "1"
+	}
+	return ioutil.WriteFile(filepath.Join(rfkill, "soft"), []byte(value), 0644)
+}
+
+// PowerCycleAdapter blocks and unblocks the radio of an adapter, which also
+// reloads its firmware on most USB and UART controllers.
+func (d bluetooth) PowerCycleAdapter(sender dbus.Sender, adapter string) (bool, *dbus.Error) {
+	devID, err := adapterID(adapter)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	rfkill := rfkillSwitch(adapter)
+	if rfkill == "" {
+		return false, dbus.MakeFailedError(fmt.Errorf("Bluetooth adapter %s has no rfkill switch", adapter))
+	}
+
+	logging.Info.Printf("Power cycle Bluetooth adapter %s.", adapter)
+	if err = setSoftBlock(rfkill, true); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't block %s: %s", adapter, err))
+	}
+	time.Sleep(powerCycleWait)
+	if err = setSoftBlock(rfkill, false); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't unblock %s: %s", adapter, err))
+	}
+	time.Sleep(powerCycleWait)
+
+	// The adapter may come back with a new index
+	if _, err = adapterID(adapter); err == nil {
+		if err = hciControl(hciDevUp, devID); err != nil {
+			logging.Warning.Printf("Can't bring up %s after power cycle: %s", adapter, err)
+		}
+	}
+
+	audit.Record(sender, "Bluetooth.PowerCycleAdapter", "", adapter)
+	d.props.SetMust(ifaceName, "Adapters", getAdapters())
+	return true, nil
+}
+
+// ResetAdapter resets the controller through the HCI management socket and
+// brings it up again.
+func (d bluetooth) ResetAdapter(sender dbus.Sender, adapter string) (bool, *dbus.Error) {
+	devID, err := adapterID(adapter)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	logging.Info.Printf("Reset Bluetooth adapter %s.", adapter)
+	for _, request := range []uintptr{hciDevDown, hciDevReset, hciDevUp} {
+		if err = hciControl(request, devID); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't reset %s: %s", adapter, err))
+		}
+	}
+
+	audit.Record(sender, "Bluetooth.ResetAdapter", "", adapter)
+	d.props.SetMust(ifaceName, "Adapters", getAdapters())
+	return true, nil
+}
+
+func (d bluetooth) SetAdapterPowered(sender dbus.Sender, adapter string, powered bool) (bool, *dbus.Error) {
+	devID, err := adapterID(adapter)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	request := uintptr(hciDevDown)
+	if powered {
+		if rfkill := rfkillSwitch(adapter); rfkill != "" {
+			if err = setSoftBlock(rfkill, false); err != nil {
+				return false, dbus.MakeFailedError(fmt.Errorf("Can't unblock %s: %s", adapter, err))
+			}
+		}
+		request = hciDevUp
+	}
+	if err = hciControl(request, devID); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't power %s: %s", adapter, err))
+	}
+
+	logging.Info.Printf("Set Bluetooth adapter %s powered to %t.", adapter, powered)
+	audit.Record(sender, "Bluetooth.SetAdapterPowered", adapter, powered)
+	d.props.SetMust(ifaceName, "Adapters", getAdapters())
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"PowerCycleAdapter": {"adapter", "success"},
+	"ResetAdapter":      {"adapter", "success"},
+	"SetAdapterPowered": {"adapter", "powered", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := bluetooth{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Adapters": {
+				Value:    getAdapters(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
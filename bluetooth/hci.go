@@ -0,0 +1,30 @@
+package bluetooth
+
+import (
+	"syscall"
+)
+
+// HCI device control from bluez lib/hci.h
+const (
+	afBluetooth = 31
+	btProtoHCI  = 1
+	hciDevUp    = 0x400448c9
+	hciDevDown  = 0x400448ca
+	hciDevReset = 0x400448cb
+)
+
+// hciControl issues a device control request on a raw HCI socket.
+func hciControl(request uintptr, devID int) error {
+	fd, err := syscall.Socket(afBluetooth, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, btProtoHCI)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(devID))
+	// Bringing up an adapter which is already up is fine
+	if errno != 0 && !(request == hciDevUp && errno == syscall.EALREADY) {
+		return errno
+	}
+	return nil
+}
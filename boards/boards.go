@@ -5,9 +5,11 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
-	"github.com/home-assistant/os-agent/boards/supervised"
-	"github.com/home-assistant/os-agent/boards/yellow"
+	"github.com/home-assistant/os-agent/boards/registry"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
@@ -20,11 +22,28 @@ type boards struct {
 	props *prop.Properties
 }
 
+var methodArgNames = map[string][]string{
+	"FlashSPIBootloader": {"success"},
+}
+
 func InitializeDBus(conn *dbus.Conn, board string) {
 	d := boards{
 		conn: conn,
 	}
 
+	// Init base value
+	modules := registry.Select(board)
+	if getLEDController(modules) == nil {
+		// Generic LED control for boards without LED support of their own
+		modules = append(modules, registry.Fallbacks(board)...)
+	}
+	moduleNames := []string{}
+	for _, module := range modules {
+		moduleNames = append(moduleNames, module.Name())
+	}
+	leds = getLEDController(modules)
+	ledsState = loadLEDsConfig()
+
 	propsSpec := map[string]map[string]*prop.Prop{
 		ifaceName: {
 			"Board": {
@@ -33,16 +52,28 @@ func InitializeDBus(conn *dbus.Conn, board string) {
 				Emit:     prop.EmitInvalidates,
 				Callback: nil,
 			},
+			"Modules": {
+				Value:    moduleNames,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"LEDsDisabled": {
+				Value:    ledsState.Disabled,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setLEDsDisabled,
+			},
 		},
 	}
 
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 	d.props = props
 
-	err = conn.Export(d, objectPath, ifaceName)
+	err = recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -54,7 +85,7 @@ func InitializeDBus(conn *dbus.Conn, board string) {
 			prop.IntrospectData,
 			{
 				Name:       ifaceName,
-				Methods:    introspect.Methods(d),
+				Methods:    introspection.Methods(d, methodArgNames),
 				Properties: props.Introspection(ifaceName),
 			},
 		},
@@ -66,13 +97,14 @@ func InitializeDBus(conn *dbus.Conn, board string) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
 
-	// Initialize the board
-	if board == "Yellow" {
-		yellow.InitializeDBus(conn)
-	} else if board == "Supervised" {
-		supervised.InitializeDBus(conn)
-	} else {
+	// Initialize the board modules
+	for _, module := range modules {
+		logging.Info.Printf("Initialize %s board module", module.Name())
+		module.InitializeDBus(conn)
+	}
+	if len(modules) == 0 {
 		logging.Info.Printf("No specific Board features for %s", board)
 	}
 }
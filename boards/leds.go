@@ -0,0 +1,154 @@
+package boards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/boards/registry"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	ledsConfigFile = "/etc/os-agent/leds.json"
+)
+
+type ledsConfig struct {
+	Disabled bool            `json:"disabled"`
+	Saved    map[string]bool `json:"saved,omitempty"`
+}
+
+var (
+	ledsMutex sync.Mutex
+	leds      registry.LEDController
+	ledsState ledsConfig
+)
+
+// moduleLEDs combines the LEDs of all active board modules, names are
+// prefixed with the module name, e.g. Yellow.PowerLED.
+type moduleLEDs map[string]registry.LEDController
+
+func (m moduleLEDs) LEDs() map[string]bool {
+	states := map[string]bool{}
+	for module, controller := range m {
+		for name, on := range controller.LEDs() {
+			states[module+"."+name] = on
+		}
+	}
+	return states
+}
+
+func (m moduleLEDs) SetLED(name string, on bool) error {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || m[parts[0]] == nil {
+		return fmt.Errorf("Unknown LED %s", name)
+	}
+	return m[parts[0]].SetLED(parts[1], on)
+}
+
+func getLEDController(modules []registry.Module) registry.LEDController {
+	controllers := moduleLEDs{}
+	for _, module := range modules {
+		if controller, ok := module.(registry.LEDController); ok {
+			controllers[module.Name()] = controller
+		}
+	}
+	if len(controllers) == 0 {
+		return nil
+	}
+	return controllers
+}
+
+func loadLEDsConfig() ledsConfig {
+	config := ledsConfig{}
+
+	data, err := ioutil.ReadFile(ledsConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid LED config in %s", ledsConfigFile)
+		return ledsConfig{}
+	}
+	return config
+}
+
+func saveLEDsConfig(config ledsConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(ledsConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ledsConfigFile, data, 0644)
+}
+
+// restoreLEDs switches LEDs back to the given states, errors are only logged
+// as it runs to undo a failed change.
+func restoreLEDs(states map[string]bool, names []string) {
+	for _, name := range names {
+		if err := leds.SetLED(name, states[name]); err != nil {
+			logging.Warning.Printf("Can't restore %s: %s", name, err)
+		}
+	}
+}
+
+// setLEDsDisabled turns all LEDs of the board off and remembers their state,
+// or restores the remembered state. Disabling is undone if it fails, so the
+// remembered state never gets lost.
+func setLEDsDisabled(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set LEDs disabled to %t", c.Value)
+
+	ledsMutex.Lock()
+	defer ledsMutex.Unlock()
+
+	if leds == nil {
+		return apierror.Failed(apierror.New(apierror.CodeUnsupported, "Board has no controllable LEDs"))
+	}
+
+	disabled := c.Value.(bool)
+	if disabled == ledsState.Disabled {
+		return nil
+	}
+
+	config := ledsConfig{Disabled: disabled}
+	var switched []string
+	if disabled {
+		config.Saved = leds.LEDs()
+		for name := range config.Saved {
+			if err := leds.SetLED(name, false); err != nil {
+				restoreLEDs(config.Saved, switched)
+				return dbus.MakeFailedError(fmt.Errorf("Can't switch off %s: %s", name, err))
+			}
+			switched = append(switched, name)
+		}
+	} else {
+		for name, on := range ledsState.Saved {
+			if err := leds.SetLED(name, on); err != nil {
+				return dbus.MakeFailedError(fmt.Errorf("Can't restore %s: %s", name, err))
+			}
+		}
+	}
+
+	if err := saveLEDsConfig(config); err != nil {
+		restoreLEDs(config.Saved, switched)
+		return dbus.MakeFailedError(fmt.Errorf("Can't persist LED state: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "Boards.LEDsDisabled", ledsState.Disabled, disabled)
+	ledsState = config
+	return nil
+}
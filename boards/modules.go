@@ -0,0 +1,12 @@
+package boards
+
+// Board modules register themselves with the registry when imported.
+import (
+	_ "github.com/home-assistant/os-agent/boards/odroid"
+	_ "github.com/home-assistant/os-agent/boards/raspberrypi"
+	_ "github.com/home-assistant/os-agent/boards/rpi5"
+	_ "github.com/home-assistant/os-agent/boards/supervised"
+	_ "github.com/home-assistant/os-agent/boards/sysfsleds"
+	_ "github.com/home-assistant/os-agent/boards/x86"
+	_ "github.com/home-assistant/os-agent/boards/yellow"
+)
@@ -0,0 +1,35 @@
+package odroid
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "ODROID"
+}
+
+func (module) Match(board string) bool {
+	return strings.HasPrefix(board, "ODROID")
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
+
+func (module) LEDs() map[string]bool {
+	return LEDs()
+}
+
+func (module) SetLED(name string, on bool) error {
+	return SetLED(name, on)
+}
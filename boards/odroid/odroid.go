@@ -0,0 +1,380 @@
+package odroid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/cmdline"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath       = "/io/hass/os/Boards/ODROID"
+	ifaceName        = "io.hass.os.Boards.ODROID"
+	odroidConfigFile = "/etc/os-agent/odroid.json"
+	modelPath        = "/proc/device-tree/model"
+	ledsPath         = "/sys/class/leds"
+	thermalPath      = "/sys/class/thermal"
+	fanCoolingType   = "pwm-fan"
+	userGovernor     = "user_space"
+	defaultGovernor  = "step_wise"
+	kernelCmdline    = "/proc/cmdline"
+	statusLEDTrigger = "heartbeat"
+)
+
+type odroidConfig struct {
+	StatusLED *bool   `json:"status_led,omitempty"`
+	FanSpeed  *uint32 `json:"fan_speed,omitempty"`
+}
+
+var (
+	odroidMutex  sync.Mutex
+	optStatusLED bool
+	optFanSpeed  uint32
+	odroidProps  *prop.Properties
+
+	// Governors of the thermal zones cooled by the fan, before a fixed
+	// speed took them over
+	zoneGovernors = map[string]string{}
+)
+
+type odroid struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func getModel() string {
+	data, err := ioutil.ReadFile(modelPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(data), "\x00\n")
+}
+
+// getStatusLEDPath returns the sysfs directory of the blue status LED, its
+// name differs between the boards.
+func getStatusLEDPath() string {
+	paths, _ := filepath.Glob(filepath.Join(ledsPath, "blue*"))
+	if len(paths) == 0 {
+		return ""
+	}
+	return paths[0]
+}
+
+// getFanPath returns the thermal cooling device of the PWM fan, empty if the
+// board has none.
+func getFanPath() string {
+	paths, _ := filepath.Glob(filepath.Join(thermalPath, "cooling_device*"))
+	for _, path := range paths {
+		name, err := ioutil.ReadFile(filepath.Join(path, "type"))
+		if err == nil && strings.TrimSpace(string(name)) == fanCoolingType {
+			return path
+		}
+	}
+	return ""
+}
+
+// getFanZones returns the thermal zones the fan is bound to as cooling
+// device.
+func getFanZones(fan string) []string {
+	var zones []string
+	links, _ := filepath.Glob(filepath.Join(thermalPath, "thermal_zone*", "cdev[0-9]*"))
+	for _, link := range links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil || target != fan {
+			continue
+		}
+		zone := filepath.Dir(link)
+		if len(zones) == 0 || zones[len(zones)-1] != zone {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// isPetitbootBoot returns true if the system was not started by the U-Boot
+// of the OS, e.g. by Petitboot from SPI flash when the boot switch of an
+// N2/N2+ selects SPI. The boot script sets the RAUC slot, Petitboot
+// doesn't, so updates and slot switching won't work.
+func isPetitbootBoot() bool {
+	line, err := cmdline.Read(kernelCmdline)
+	if err != nil {
+		return false
+	}
+	_, ok := line.Get("rauc.slot")
+	return !ok
+}
+
+func getStatusLED() bool {
+	data, err := ioutil.ReadFile(filepath.Join(getStatusLEDPath(), "trigger"))
+	if err != nil {
+		return false
+	}
+	return !strings.Contains(string(data), "[none]")
+}
+
+func applyStatusLED(on bool) error {
+	path := getStatusLEDPath()
+	if path == "" {
+		return fmt.Errorf("No status LED found")
+	}
+
+	trigger := "none"
+	if on {
+		trigger = statusLEDTrigger
+	}
+	return ioutil.WriteFile(filepath.Join(path, "trigger"), []byte(trigger), 0644)
+}
+
+// applyFanSpeed sets the fan to a fixed speed in percent, 0 hands it back
+// to the kernel. The thermal governor sets the fan's cooling state on every
+// trip point change, so for a fixed speed the zones cooled by the fan switch
+// to the user_space governor, which leaves the cooling state alone. Critical
+// trip points keep shutting down the board regardless of the governor. The
+// speed is rounded up to the next of the fan's cooling levels.
+func applyFanSpeed(speed uint32) error {
+	fan := getFanPath()
+	if fan == "" {
+		return fmt.Errorf("No fan found")
+	}
+
+	zones := getFanZones(fan)
+	if speed == 0 {
+		for _, zone := range zones {
+			governor, ok := zoneGovernors[zone]
+			if !ok {
+				governor = defaultGovernor
+			}
+			if err := ioutil.WriteFile(filepath.Join(zone, "policy"), []byte(governor), 0644); err != nil {
+				return err
+			}
+			delete(zoneGovernors, zone)
+		}
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(fan, "max_state"))
+	if err != nil {
+		return err
+	}
+	maxState, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		data, err := ioutil.ReadFile(filepath.Join(zone, "policy"))
+		if err != nil {
+			return err
+		}
+		if governor := strings.TrimSpace(string(data)); governor != userGovernor {
+			zoneGovernors[zone] = governor
+		}
+		if err = ioutil.WriteFile(filepath.Join(zone, "policy"), []byte(userGovernor), 0644); err != nil {
+			return err
+		}
+	}
+
+	state := (uint64(speed)*maxState + 99) / 100
+	return ioutil.WriteFile(filepath.Join(fan, "cur_state"), []byte(strconv.FormatUint(state, 10)), 0644)
+}
+
+func loadConfig() odroidConfig {
+	config := odroidConfig{}
+
+	data, err := ioutil.ReadFile(odroidConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid ODROID config in %s", odroidConfigFile)
+		return odroidConfig{}
+	}
+	return config
+}
+
+func saveConfig(config odroidConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(odroidConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(odroidConfigFile, data, 0644)
+}
+
+func setStatusLED(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set ODROID status LED to %t", c.Value)
+
+	odroidMutex.Lock()
+	defer odroidMutex.Unlock()
+
+	on := c.Value.(bool)
+	if err := applyStatusLED(on); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't set status LED: %s", err))
+	}
+
+	config := loadConfig()
+	config.StatusLED = &on
+	if err := saveConfig(config); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't persist status LED: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "ODROID.StatusLED", optStatusLED, on)
+	optStatusLED = on
+	return nil
+}
+
+func setFanSpeed(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set ODROID fan speed to %d%%", c.Value)
+
+	odroidMutex.Lock()
+	defer odroidMutex.Unlock()
+
+	speed := c.Value.(uint32)
+	if speed > 100 {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid fan speed %d, must be 0-100", speed))
+	}
+	if err := applyFanSpeed(speed); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't set fan speed: %s", err))
+	}
+
+	config := loadConfig()
+	config.FanSpeed = &speed
+	if err := saveConfig(config); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't persist fan speed: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "ODROID.FanSpeed", optFanSpeed, speed)
+	optFanSpeed = speed
+	return nil
+}
+
+// LEDs returns the state of the status LED by property name.
+func LEDs() map[string]bool {
+	return map[string]bool{"StatusLED": optStatusLED}
+}
+
+// SetLED switches the status LED by property name, like a client setting
+// the property would.
+func SetLED(name string, on bool) error {
+	if odroidProps == nil {
+		return fmt.Errorf("ODROID board is not initialized")
+	}
+	if dbuserr := odroidProps.Set(ifaceName, name, dbus.MakeVariant(on)); dbuserr != nil {
+		return dbuserr
+	}
+	return nil
+}
+
+// restoreConfig applies the settings made through the agent, sysfs forgets
+// them on reboot.
+func restoreConfig() {
+	config := loadConfig()
+	if config.StatusLED != nil {
+		if err := applyStatusLED(*config.StatusLED); err != nil {
+			logging.Warning.Printf("Can't restore ODROID status LED: %s", err)
+		}
+	}
+	if config.FanSpeed != nil {
+		if err := applyFanSpeed(*config.FanSpeed); err != nil {
+			logging.Warning.Printf("Can't restore ODROID fan speed: %s", err)
+		}
+		optFanSpeed = *config.FanSpeed
+	}
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := odroid{
+		conn: conn,
+	}
+
+	// Init base value
+	restoreConfig()
+	optStatusLED = getStatusLED()
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Model": {
+				Value:    getModel(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"PetitbootBoot": {
+				Value:    isPetitbootBoot(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"HasFan": {
+				Value:    getFanPath() != "",
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"StatusLED": {
+				Value:    optStatusLED,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setStatusLED,
+			},
+			"FanSpeed": {
+				Value:    optFanSpeed,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setFanSpeed,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+	odroidProps = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, nil),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
@@ -0,0 +1,75 @@
+// Package raspberrypi adds the features common to all Raspberry Pi boards,
+// including carrier boards of the Compute Module like Yellow, whose module
+// stacks on top of this one. It has no object of its own, its LEDs are
+// switched through the Boards object. It is the only owner of the ACT and
+// PWR LED options, carrier modules go through GetLED and ApplyLED.
+package raspberrypi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+	"github.com/home-assistant/os-agent/utils/bootfile"
+)
+
+const (
+	bootConfig = "/mnt/boot/config.txt"
+)
+
+// ACT and PWR LED options in config.txt, they change on the next boot
+var ledOptions = map[string]string{
+	"PowerLED": "dtparam=pwr_led_trigger",
+	"DiskLED":  "dtparam=act_led_trigger",
+}
+
+var bootFile = bootfile.Editor{FilePath: bootConfig, Delimiter: "="}
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "RaspberryPi"
+}
+
+func (module) Match(board string) bool {
+	return strings.HasPrefix(board, "RaspberryPi") || board == "Yellow"
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {}
+
+// GetLED returns the configured state of the ACT ("DiskLED") or PWR
+// ("PowerLED") LED.
+func GetLED(name string) bool {
+	value, _ := bootFile.ReadOption(ledOptions[name], "default")
+	return value != "none"
+}
+
+// ApplyLED configures the ACT or PWR LED for the next boot.
+func ApplyLED(name string, on bool) error {
+	option, ok := ledOptions[name]
+	if !ok {
+		return fmt.Errorf("Unknown LED %s", name)
+	}
+	if on {
+		return bootFile.DisableOption(option)
+	}
+	return bootFile.SetOption(option, "none")
+}
+
+func (module) LEDs() map[string]bool {
+	states := map[string]bool{}
+	for name := range ledOptions {
+		states[name] = GetLED(name)
+	}
+	return states
+}
+
+func (module) SetLED(name string, on bool) error {
+	return ApplyLED(name, on)
+}
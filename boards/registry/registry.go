@@ -0,0 +1,74 @@
+// Package registry holds the board modules. Each module registers itself
+// from init() and is imported for its side effect by the boards package, so
+// adding a board doesn't touch the wiring in main. All modules matching the
+// board are started, which allows stacking, e.g. the generic Raspberry Pi
+// module under the Yellow carrier board. Fallback modules provide generic
+// features for boards whose modules lack them.
+package registry
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Module is a board implementation.
+type Module interface {
+	// Name identifies the module in logs and the Modules property.
+	Name() string
+	// Match reports whether the module applies to the board the OS was
+	// built for.
+	Match(board string) bool
+	// InitializeDBus exports the objects of the module.
+	InitializeDBus(conn *dbus.Conn)
+}
+
+// LEDController is implemented by modules with switchable status LEDs.
+type LEDController interface {
+	LEDs() map[string]bool
+	SetLED(name string, on bool) error
+}
+
+var (
+	lock      sync.Mutex
+	modules   []Module
+	fallbacks []Module
+)
+
+// Register adds a module, modules are matched in registration order.
+func Register(module Module) {
+	lock.Lock()
+	defer lock.Unlock()
+	modules = append(modules, module)
+}
+
+// RegisterFallback adds a generic module, see Fallbacks.
+func RegisterFallback(module Module) {
+	lock.Lock()
+	defer lock.Unlock()
+	fallbacks = append(fallbacks, module)
+}
+
+// Select returns the modules matching board.
+func Select(board string) []Module {
+	lock.Lock()
+	defer lock.Unlock()
+	return match(modules, board)
+}
+
+// Fallbacks returns the fallback modules matching board.
+func Fallbacks(board string) []Module {
+	lock.Lock()
+	defer lock.Unlock()
+	return match(fallbacks, board)
+}
+
+func match(candidates []Module, board string) []Module {
+	var selected []Module
+	for _, module := range candidates {
+		if module.Match(board) {
+			selected = append(selected, module)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,72 @@
+package rpi5
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	inputClassPath   = "/sys/class/input"
+	powerButtonName  = "pwr_button"
+	inputEventSize   = 24
+	inputEventKey    = 1
+	inputKeyPower    = 116
+	inputKeyPressed  = 1
+	inputEventOffset = 16
+)
+
+// findPowerButton returns the event device of the power button.
+func findPowerButton() string {
+	paths, _ := filepath.Glob(filepath.Join(inputClassPath, "event*"))
+	for _, path := range paths {
+		name, err := ioutil.ReadFile(filepath.Join(path, "device", "name"))
+		if err == nil && strings.TrimSpace(string(name)) == powerButtonName {
+			return filepath.Join("/dev/input", filepath.Base(path))
+		}
+	}
+	return ""
+}
+
+// watchPowerButton emits PowerButtonPressed for each press. systemd-logind
+// keeps handling the button, a double press shuts down as before.
+func (d rpi5) watchPowerButton() {
+	device := findPowerButton()
+	if device == "" {
+		logging.Warning.Printf("No power button found")
+		return
+	}
+
+	file, err := os.Open(device)
+	if err != nil {
+		logging.Warning.Printf("Can't watch power button: %s", err)
+		return
+	}
+	defer file.Close()
+
+	// struct input_event on arm64, the only architecture of the Pi 5 images:
+	// timeval, then type, code and value
+	event := make([]byte, inputEventSize)
+	for {
+		if _, err = io.ReadFull(file, event); err != nil {
+			logging.Warning.Printf("Can't read power button events: %s", err)
+			return
+		}
+		eventType := binary.LittleEndian.Uint16(event[inputEventOffset:])
+		code := binary.LittleEndian.Uint16(event[inputEventOffset+2:])
+		value := int32(binary.LittleEndian.Uint32(event[inputEventOffset+4:]))
+		if eventType != inputEventKey || code != inputKeyPower || value != inputKeyPressed {
+			continue
+		}
+
+		logging.Info.Printf("Power button pressed.")
+		if err = d.conn.Emit(objectPath, ifaceName+".PowerButtonPressed"); err != nil {
+			logging.Warning.Printf("Can't emit PowerButtonPressed signal: %s", err)
+		}
+	}
+}
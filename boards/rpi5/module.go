@@ -0,0 +1,28 @@
+package rpi5
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "RaspberryPi5"
+}
+
+// Match stacks the module on top of the generic Raspberry Pi one.
+func (module) Match(board string) bool {
+	return strings.HasPrefix(board, "RaspberryPi5")
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
@@ -0,0 +1,341 @@
+package rpi5
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/bootfile"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Boards/RaspberryPi5"
+	ifaceName  = "io.hass.os.Boards.RaspberryPi5"
+	bootConfig = "/mnt/boot/config.txt"
+	hwmonPath  = "/sys/class/hwmon"
+	rtcPath    = "/sys/class/rtc"
+
+	fanCurvePoints      = 4
+	maxFanSpeed         = 255
+	rtcChargingVoltage  = "3000000"
+	optionRTCCharging   = "dtparam=rtc_bbat_vchg="
+	optionPCIe          = "dtparam=pciex1="
+	optionPCIeGen       = "dtparam=pciex1_gen="
+	defaultPCIeGen      = 2
+	fanTempOptionFormat = "dtparam=fan_temp%d="
+	fanSpeedOptFormat   = "dtparam=fan_temp%d_speed="
+)
+
+// Option names include the "=", several of them are prefixes of others
+var bootFile = bootfile.Editor{FilePath: bootConfig, Delimiter: ""}
+
+// Firmware default of the fan curve, millidegree Celsius to PWM speed
+var defaultFanCurve = map[uint32]uint32{
+	50000: 75,
+	60000: 125,
+	67500: 175,
+	75000: 250,
+}
+
+var (
+	rpi5Mutex          sync.Mutex
+	optFanCurve        map[uint32]uint32
+	optRTCCharging     bool
+	optPCIeEnabled     bool
+	optPCIeGen         uint32
+	powerButtonSignals = []introspect.Signal{
+		{
+			Name: "PowerButtonPressed",
+			Args: []introspect.Arg{},
+		},
+	}
+)
+
+type rpi5 struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func getFanCurve() map[uint32]uint32 {
+	curve := map[uint32]uint32{}
+	for i := 0; i < fanCurvePoints; i++ {
+		temp, _ := bootFile.ReadOption(fmt.Sprintf(fanTempOptionFormat, i), "")
+		speed, _ := bootFile.ReadOption(fmt.Sprintf(fanSpeedOptFormat, i), "")
+		tempValue, err := strconv.ParseUint(temp, 10, 32)
+		if err != nil {
+			continue
+		}
+		speedValue, err := strconv.ParseUint(speed, 10, 32)
+		if err != nil {
+			continue
+		}
+		curve[uint32(tempValue)] = uint32(speedValue)
+	}
+	if len(curve) == 0 {
+		return defaultFanCurve
+	}
+	return curve
+}
+
+func getRTCCharging() bool {
+	value, _ := bootFile.ReadOption(optionRTCCharging, "0")
+	return value != "0"
+}
+
+func getPCIeEnabled() bool {
+	value, _ := bootFile.ReadOption(optionPCIe, "off")
+	return value == "on"
+}
+
+func getPCIeGen() uint32 {
+	value, _ := bootFile.ReadOption(optionPCIeGen, "")
+	gen, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return defaultPCIeGen
+	}
+	return uint32(gen)
+}
+
+// setFanCurve writes up to four temperature (millidegree Celsius) to speed
+// (0-255) points, the firmware applies them on the next boot.
+func setFanCurve(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set Raspberry Pi 5 fan curve to %v", c.Value)
+
+	curve := c.Value.(map[uint32]uint32)
+	if len(curve) == 0 || len(curve) > fanCurvePoints {
+		return dbus.MakeFailedError(fmt.Errorf("Fan curve needs 1-%d points", fanCurvePoints))
+	}
+
+	temps := []uint32{}
+	for temp, speed := range curve {
+		if speed > maxFanSpeed {
+			return dbus.MakeFailedError(fmt.Errorf("Invalid fan speed %d, must be 0-%d", speed, maxFanSpeed))
+		}
+		temps = append(temps, temp)
+	}
+	sort.Slice(temps, func(i, j int) bool { return temps[i] < temps[j] })
+
+	rpi5Mutex.Lock()
+	defer rpi5Mutex.Unlock()
+
+	for i := 0; i < fanCurvePoints; i++ {
+		tempOption := fmt.Sprintf(fanTempOptionFormat, i)
+		speedOption := fmt.Sprintf(fanSpeedOptFormat, i)
+
+		var err error
+		if i < len(temps) {
+			err = bootFile.SetOption(tempOption, strconv.FormatUint(uint64(temps[i]), 10))
+			if err == nil {
+				err = bootFile.SetOption(speedOption, strconv.FormatUint(uint64(curve[temps[i]]), 10))
+			}
+		} else {
+			err = bootFile.DisableOption(tempOption)
+			if err == nil {
+				err = bootFile.DisableOption(speedOption)
+			}
+		}
+		if err != nil {
+			return dbus.MakeFailedError(fmt.Errorf("Can't write fan curve: %s", err))
+		}
+	}
+
+	audit.Record(recovery.Sender(c), "RaspberryPi5.FanCurve", optFanCurve, curve)
+	optFanCurve = curve
+	return nil
+}
+
+func setRTCCharging(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set Raspberry Pi 5 RTC battery charging to %t", c.Value)
+
+	rpi5Mutex.Lock()
+	defer rpi5Mutex.Unlock()
+
+	var err error
+	if c.Value.(bool) {
+		err = bootFile.SetOption(optionRTCCharging, rtcChargingVoltage)
+	} else {
+		err = bootFile.DisableOption(optionRTCCharging)
+	}
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "RaspberryPi5.RTCBatteryCharging", optRTCCharging, c.Value)
+	optRTCCharging = c.Value.(bool)
+	return nil
+}
+
+func setPCIeEnabled(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set Raspberry Pi 5 PCIe connector to %t", c.Value)
+
+	rpi5Mutex.Lock()
+	defer rpi5Mutex.Unlock()
+
+	var err error
+	if c.Value.(bool) {
+		err = bootFile.SetOption(optionPCIe, "on")
+	} else {
+		err = bootFile.DisableOption(optionPCIe)
+	}
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "RaspberryPi5.PCIeEnabled", optPCIeEnabled, c.Value)
+	optPCIeEnabled = c.Value.(bool)
+	return nil
+}
+
+// setPCIeGen selects the link speed, Gen 3 isn't certified but works with
+// most NVMe HATs.
+func setPCIeGen(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set Raspberry Pi 5 PCIe generation to %d", c.Value)
+
+	gen := c.Value.(uint32)
+	if gen != 2 && gen != 3 {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid PCIe generation %d, must be 2 or 3", gen))
+	}
+
+	rpi5Mutex.Lock()
+	defer rpi5Mutex.Unlock()
+
+	var err error
+	if gen == defaultPCIeGen {
+		err = bootFile.DisableOption(optionPCIeGen)
+	} else {
+		err = bootFile.SetOption(optionPCIeGen, strconv.FormatUint(uint64(gen), 10))
+	}
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "RaspberryPi5.PCIeGen", optPCIeGen, gen)
+	optPCIeGen = gen
+	return nil
+}
+
+func readUint(path string) (uint32, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	return uint32(value), err
+}
+
+// GetFanSpeed returns the speed of the fan in RPM.
+func (d rpi5) GetFanSpeed() (uint32, *dbus.Error) {
+	paths, _ := filepath.Glob(filepath.Join(hwmonPath, "hwmon*", "fan1_input"))
+	for _, path := range paths {
+		if rpm, err := readUint(path); err == nil {
+			return rpm, nil
+		}
+	}
+	return 0, dbus.MakeFailedError(fmt.Errorf("No fan found"))
+}
+
+// GetRTCBatteryVoltage returns the voltage of the RTC battery in
+// microvolts.
+func (d rpi5) GetRTCBatteryVoltage() (uint32, *dbus.Error) {
+	paths, _ := filepath.Glob(filepath.Join(rtcPath, "rtc*", "battery_voltage"))
+	for _, path := range paths {
+		if voltage, err := readUint(path); err == nil {
+			return voltage, nil
+		}
+	}
+	return 0, dbus.MakeFailedError(fmt.Errorf("No RTC battery voltage found"))
+}
+
+var methodArgNames = map[string][]string{
+	"GetFanSpeed":          {"rpm"},
+	"GetRTCBatteryVoltage": {"microvolts"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := rpi5{
+		conn: conn,
+	}
+
+	// Init base value
+	optFanCurve = getFanCurve()
+	optRTCCharging = getRTCCharging()
+	optPCIeEnabled = getPCIeEnabled()
+	optPCIeGen = getPCIeGen()
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"FanCurve": {
+				Value:    optFanCurve,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setFanCurve,
+			},
+			"RTCBatteryCharging": {
+				Value:    optRTCCharging,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setRTCCharging,
+			},
+			"PCIeEnabled": {
+				Value:    optPCIeEnabled,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setPCIeEnabled,
+			},
+			"PCIeGen": {
+				Value:    optPCIeGen,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setPCIeGen,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+				Signals:    powerButtonSignals,
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchPowerButton()
+}
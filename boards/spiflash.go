@@ -0,0 +1,206 @@
+package boards
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	actionFlashBootloader = "io.hass.os.flash-bootloader"
+	compatiblePath        = "/proc/device-tree/compatible"
+	mtdClassPath          = "/sys/class/mtd"
+	spiBootloaderImage    = "/usr/share/bootloader/u-boot-rockchip-spi.bin"
+
+	// MEMERASE from mtd/mtd-abi.h, _IOW('M', 2, struct erase_info_user)
+	memEraseIoctl = 0x40084d02
+)
+
+var (
+	spiFlashLock    sync.Mutex
+	spiFlashRunning bool
+)
+
+type eraseInfo struct {
+	Start  uint32
+	Length uint32
+}
+
+type mtdDevice struct {
+	path      string
+	size      uint64
+	eraseSize uint64
+}
+
+func isRockchip() bool {
+	data, err := ioutil.ReadFile(compatiblePath)
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(data, []byte("rockchip,"))
+}
+
+func readMTDAttribute(mtd string, name string) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(mtdClassPath, mtd, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// findSPIFlash returns the whole SPI NOR flash, the boot ROM loads the
+// bootloader from its start.
+func findSPIFlash() (mtdDevice, error) {
+	paths, _ := filepath.Glob(filepath.Join(mtdClassPath, "mtd[0-9]*"))
+	for _, path := range paths {
+		mtd := filepath.Base(path)
+		if strings.HasSuffix(mtd, "ro") {
+			continue
+		}
+		mtdType, err := ioutil.ReadFile(filepath.Join(path, "type"))
+		if err != nil || strings.TrimSpace(string(mtdType)) != "nor" {
+			continue
+		}
+		if offset, err := readMTDAttribute(mtd, "offset"); err == nil && offset != 0 {
+			continue
+		}
+
+		size, err := readMTDAttribute(mtd, "size")
+		if err != nil {
+			return mtdDevice{}, err
+		}
+		eraseSize, err := readMTDAttribute(mtd, "erasesize")
+		if err != nil {
+			return mtdDevice{}, err
+		}
+		return mtdDevice{path: filepath.Join("/dev", mtd), size: size, eraseSize: eraseSize}, nil
+	}
+	return mtdDevice{}, apierror.New(apierror.CodeNotFound, "No SPI flash found")
+}
+
+// flashSPI writes image to the start of the flash in erase block steps and
+// reads it back for verification. Progress is split 40/40/20 between erase,
+// write and verify.
+func flashSPI(job *jobs.Job, device mtdDevice, image []byte) error {
+	file, err := os.OpenFile(device.path, os.O_RDWR, 0)
+	if err != nil {
+		return apierror.Wrap(apierror.CodeDeviceError, err).WithDevice(device.path)
+	}
+	defer file.Close()
+
+	current := make([]byte, len(image))
+	if _, err = io.ReadFull(file, current); err != nil {
+		return apierror.Wrap(apierror.CodeDeviceError, err).WithDevice(device.path)
+	}
+	if bytes.Equal(current, image) {
+		logging.Info.Printf("SPI bootloader on %s is up to date.", device.path)
+		return nil
+	}
+
+	blocks := (uint64(len(image)) + device.eraseSize - 1) / device.eraseSize
+
+	job.SetStage("erase")
+	for block := uint64(0); block < blocks; block++ {
+		erase := eraseInfo{Start: uint32(block * device.eraseSize), Length: uint32(device.eraseSize)}
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), memEraseIoctl, uintptr(unsafe.Pointer(&erase)))
+		if errno != 0 {
+			return apierror.New(apierror.CodeDeviceError, "Can't erase block %d: %s", block, errno).WithDevice(device.path)
+		}
+		job.SetProgress(uint32(block * 40 / blocks))
+	}
+
+	job.SetStage("write")
+	for block := uint64(0); block < blocks; block++ {
+		start := block * device.eraseSize
+		end := start + device.eraseSize
+		if end > uint64(len(image)) {
+			end = uint64(len(image))
+		}
+		if _, err = file.WriteAt(image[start:end], int64(start)); err != nil {
+			return apierror.Wrap(apierror.CodeDeviceError, err).WithDevice(device.path)
+		}
+		job.SetProgress(40 + uint32(block*40/blocks))
+	}
+
+	job.SetStage("verify")
+	job.SetProgress(80)
+	if _, err = file.ReadAt(current, 0); err != nil {
+		return apierror.Wrap(apierror.CodeDeviceError, err).WithDevice(device.path)
+	}
+	if !bytes.Equal(current, image) {
+		return apierror.New(apierror.CodeDeviceError, "Verification of written bootloader failed").
+			WithDevice(device.path).WithRemediation(apierror.RemedyReportIssue)
+	}
+	return nil
+}
+
+// FlashSPIBootloader writes the bootloader shipped with the OS to the SPI
+// flash of Rockchip boards. It runs as spi-flash job, interrupting it would
+// leave the board without a working SPI loader, so it isn't cancellable.
+func (d boards) FlashSPIBootloader(sender dbus.Sender) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionFlashBootloader); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	if !isRockchip() {
+		return false, apierror.Failed(apierror.New(apierror.CodeUnsupported, "SPI bootloader flashing is only supported on Rockchip boards"))
+	}
+
+	device, err := findSPIFlash()
+	if err != nil {
+		return false, apierror.Failed(fmt.Errorf("Can't find SPI flash: %w", err))
+	}
+	image, err := ioutil.ReadFile(spiBootloaderImage)
+	if err != nil {
+		return false, apierror.Failed(apierror.New(apierror.CodeNotFound, "Can't read bootloader image: %s", err))
+	}
+	if uint64(len(image)) > device.size {
+		return false, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Bootloader image doesn't fit into SPI flash %s", device.path).
+			WithDevice(device.path))
+	}
+
+	spiFlashLock.Lock()
+	defer spiFlashLock.Unlock()
+
+	if spiFlashRunning {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "SPI flashing is already running").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+	spiFlashRunning = true
+
+	logging.Info.Printf("Flashing SPI bootloader to %s.", device.path)
+	job := jobs.Start("spi-flash", false)
+
+	go func() {
+		err := flashSPI(job, device, image)
+		job.Finish(err)
+
+		spiFlashLock.Lock()
+		spiFlashRunning = false
+		spiFlashLock.Unlock()
+
+		if err != nil {
+			logging.Error.Printf("Can't flash SPI bootloader: %s", err)
+			return
+		}
+		audit.Record(sender, "Boards.FlashSPIBootloader", "", device.path)
+	}()
+
+	return true, nil
+}
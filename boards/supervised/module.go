@@ -0,0 +1,25 @@
+package supervised
+
+import (
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "Supervised"
+}
+
+func (module) Match(board string) bool {
+	return board == "Supervised"
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
@@ -22,7 +25,7 @@ func InitializeDBus(conn *dbus.Conn) {
 		conn: conn,
 	}
 
-	err := conn.Export(d, objectPath, ifaceName)
+	err := recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -34,7 +37,7 @@ func InitializeDBus(conn *dbus.Conn) {
 			prop.IntrospectData,
 			{
 				Name:    ifaceName,
-				Methods: introspect.Methods(d),
+				Methods: introspection.Methods(d, nil),
 			},
 		},
 	}
@@ -45,4 +48,5 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, nil, ifaceName)
 }
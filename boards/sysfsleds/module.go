@@ -0,0 +1,34 @@
+package sysfsleds
+
+import (
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.RegisterFallback(module{})
+}
+
+func (module) Name() string {
+	return "SysfsLEDs"
+}
+
+// Match leaves the LEDs of Supervised installations to the host OS.
+func (module) Match(board string) bool {
+	return board != "Supervised"
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
+
+func (module) LEDs() map[string]bool {
+	return LEDs()
+}
+
+func (module) SetLED(name string, on bool) error {
+	return SetLED(name, on)
+}
@@ -0,0 +1,306 @@
+// Package sysfsleds controls the LEDs of boards without a dedicated module
+// through the standard LED class in sysfs.
+package sysfsleds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath     = "/io/hass/os/Boards/LEDs"
+	ifaceName      = "io.hass.os.Boards.LEDs"
+	ledsPath       = "/sys/class/leds"
+	ledsConfigFile = "/etc/os-agent/sysfs-leds.json"
+	triggerNone    = "none"
+)
+
+// ledState is what the agent applies to a LED, sysfs forgets it on reboot.
+type ledState struct {
+	Trigger    string `json:"trigger"`
+	Brightness uint32 `json:"brightness"`
+}
+
+type ledsConfig struct {
+	LEDs map[string]ledState `json:"leds"`
+	// State before SetLED switched a LED off
+	Saved map[string]ledState `json:"saved"`
+}
+
+var (
+	ledsMutex sync.Mutex
+	config    ledsConfig
+)
+
+type sysfsLEDs struct {
+	conn *dbus.Conn
+}
+
+func ledPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/") || name == "." || name == ".." {
+		return "", apierror.New(apierror.CodeInvalidArgument, "Invalid LED name '%s'", name)
+	}
+	path := filepath.Join(ledsPath, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", apierror.New(apierror.CodeNotFound, "No LED %s", name)
+	}
+	return path, nil
+}
+
+func getLEDNames() []string {
+	paths, _ := filepath.Glob(filepath.Join(ledsPath, "*"))
+	names := []string{}
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return names
+}
+
+func readAttribute(path string, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(path, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readTriggers returns the active and all available triggers, the kernel
+// lists them with the active one in brackets.
+func readTriggers(path string) (string, []string) {
+	active := ""
+	triggers := []string{}
+	for _, trigger := range strings.Fields(readAttribute(path, "trigger")) {
+		if strings.HasPrefix(trigger, "[") && strings.HasSuffix(trigger, "]") {
+			trigger = strings.Trim(trigger, "[]")
+			active = trigger
+		}
+		triggers = append(triggers, trigger)
+	}
+	return active, triggers
+}
+
+func readState(path string) ledState {
+	trigger, _ := readTriggers(path)
+	brightness, _ := strconv.ParseUint(readAttribute(path, "brightness"), 10, 32)
+	return ledState{Trigger: trigger, Brightness: uint32(brightness)}
+}
+
+// applyState sets the trigger first, setting it resets the brightness.
+func applyState(path string, state ledState) error {
+	_, triggers := readTriggers(path)
+	valid := false
+	for _, trigger := range triggers {
+		valid = valid || trigger == state.Trigger
+	}
+	if !valid {
+		return apierror.New(apierror.CodeInvalidArgument, "Invalid trigger '%s' for %s", state.Trigger, filepath.Base(path))
+	}
+
+	err := ioutil.WriteFile(filepath.Join(path, "trigger"), []byte(state.Trigger), 0644)
+	if err != nil {
+		return err
+	}
+	if state.Trigger != triggerNone {
+		return nil
+	}
+	return ioutil.WriteFile(filepath.Join(path, "brightness"), []byte(strconv.FormatUint(uint64(state.Brightness), 10)), 0644)
+}
+
+func loadConfig() ledsConfig {
+	loaded := ledsConfig{}
+
+	data, err := ioutil.ReadFile(ledsConfigFile)
+	if err == nil {
+		if err = json.Unmarshal(data, &loaded); err != nil {
+			logging.Error.Printf("Ignoring invalid LED config in %s", ledsConfigFile)
+			loaded = ledsConfig{}
+		}
+	}
+	if loaded.LEDs == nil {
+		loaded.LEDs = map[string]ledState{}
+	}
+	if loaded.Saved == nil {
+		loaded.Saved = map[string]ledState{}
+	}
+	return loaded
+}
+
+func saveConfig() error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(ledsConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ledsConfigFile, data, 0644)
+}
+
+// setState applies and persists the state of a LED, call with ledsMutex
+// held.
+func setState(name string, state ledState) error {
+	path, err := ledPath(name)
+	if err != nil {
+		return err
+	}
+	if err = applyState(path, state); err != nil {
+		return err
+	}
+
+	config.LEDs[name] = state
+	if err = saveConfig(); err != nil {
+		return fmt.Errorf("Can't persist LED state: %w", err)
+	}
+	return nil
+}
+
+// restoreConfig applies the states set through the agent.
+func restoreConfig() {
+	for name, state := range config.LEDs {
+		path, err := ledPath(name)
+		if err == nil {
+			err = applyState(path, state)
+		}
+		if err != nil {
+			logging.Warning.Printf("Can't restore LED %s: %s", name, err)
+		}
+	}
+}
+
+func (d sysfsLEDs) ListLEDs() ([]map[string]string, *dbus.Error) {
+	leds := []map[string]string{}
+	for _, name := range getLEDNames() {
+		path := filepath.Join(ledsPath, name)
+		trigger, triggers := readTriggers(path)
+		leds = append(leds, map[string]string{
+			"name":           name,
+			"brightness":     readAttribute(path, "brightness"),
+			"max_brightness": readAttribute(path, "max_brightness"),
+			"trigger":        trigger,
+			"triggers":       strings.Join(triggers, " "),
+		})
+	}
+	return leds, nil
+}
+
+// SetBrightness switches a LED to a fixed brightness, up to its
+// max_brightness.
+func (d sysfsLEDs) SetBrightness(sender dbus.Sender, name string, brightness uint32) (bool, *dbus.Error) {
+	ledsMutex.Lock()
+	defer ledsMutex.Unlock()
+
+	if err := setState(name, ledState{Trigger: triggerNone, Brightness: brightness}); err != nil {
+		return false, apierror.Failed(fmt.Errorf("Can't set brightness of LED %s: %w", name, err))
+	}
+
+	audit.Record(sender, "LEDs.SetBrightness", name, brightness)
+	return true, nil
+}
+
+// SetTrigger hands a LED to a kernel trigger, e.g. heartbeat or mmc0.
+func (d sysfsLEDs) SetTrigger(sender dbus.Sender, name string, trigger string) (bool, *dbus.Error) {
+	ledsMutex.Lock()
+	defer ledsMutex.Unlock()
+
+	if err := setState(name, ledState{Trigger: trigger}); err != nil {
+		return false, apierror.Failed(fmt.Errorf("Can't set trigger of LED %s: %w", name, err))
+	}
+
+	audit.Record(sender, "LEDs.SetTrigger", name, trigger)
+	return true, nil
+}
+
+// LEDs returns whether each LED is lit or driven by a trigger.
+func LEDs() map[string]bool {
+	states := map[string]bool{}
+	for _, name := range getLEDNames() {
+		state := readState(filepath.Join(ledsPath, name))
+		states[name] = state.Trigger != triggerNone || state.Brightness > 0
+	}
+	return states
+}
+
+// SetLED switches a LED off, remembering its state, or back to the
+// remembered state.
+func SetLED(name string, on bool) error {
+	ledsMutex.Lock()
+	defer ledsMutex.Unlock()
+
+	path, err := ledPath(name)
+	if err != nil {
+		return err
+	}
+
+	if !on {
+		config.Saved[name] = readState(path)
+		return setState(name, ledState{Trigger: triggerNone})
+	}
+
+	state, ok := config.Saved[name]
+	if !ok {
+		maxBrightness, _ := strconv.ParseUint(readAttribute(path, "max_brightness"), 10, 32)
+		state = ledState{Trigger: triggerNone, Brightness: uint32(maxBrightness)}
+	}
+	delete(config.Saved, name)
+	return setState(name, state)
+}
+
+var methodArgNames = map[string][]string{
+	"ListLEDs":      {"leds"},
+	"SetBrightness": {"name", "brightness", "success"},
+	"SetTrigger":    {"name", "trigger", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := sysfsLEDs{
+		conn: conn,
+	}
+
+	// Init base value
+	config = loadConfig()
+	restoreConfig()
+
+	err := recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:    ifaceName,
+				Methods: introspection.Methods(d, methodArgNames),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, nil, ifaceName)
+}
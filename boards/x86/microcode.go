@@ -0,0 +1,184 @@
+package x86
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	cpuInfoPath     = "/proc/cpuinfo"
+	intelUcodePath  = "/lib/firmware/intel-ucode"
+	amdUcodePath    = "/lib/firmware/amd-ucode"
+	intelHeaderSize = 48
+	intelDefaultLen = 2048
+	amdMagic        = 0x00414d44
+	amdEquivTable   = 0
+	amdPatch        = 1
+)
+
+type cpuInfo struct {
+	vendor    string
+	family    uint32
+	model     uint32
+	stepping  uint32
+	microcode uint32
+}
+
+func readCPUInfo() (cpuInfo, error) {
+	info := cpuInfo{}
+
+	file, err := os.Open(cpuInfoPath)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+
+	// Only the first processor, microcode is loaded on all of them alike
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		var number uint64
+		switch key {
+		case "vendor_id":
+			info.vendor = value
+		case "cpu family":
+			number, err = strconv.ParseUint(value, 10, 32)
+			info.family = uint32(number)
+		case "model":
+			number, err = strconv.ParseUint(value, 10, 32)
+			info.model = uint32(number)
+		case "stepping":
+			number, err = strconv.ParseUint(value, 10, 32)
+			info.stepping = uint32(number)
+		case "microcode":
+			number, err = strconv.ParseUint(value, 0, 32)
+			info.microcode = uint32(number)
+		}
+		if err != nil {
+			return info, fmt.Errorf("Can't parse %s in %s: %w", key, cpuInfoPath, err)
+		}
+	}
+	return info, scanner.Err()
+}
+
+// signature returns the CPUID leaf 1 EAX value, which microcode updates are
+// matched against.
+func (c cpuInfo) signature() uint32 {
+	family := c.family
+	extFamily := uint32(0)
+	if family > 0xf {
+		extFamily = family - 0xf
+		family = 0xf
+	}
+	return c.stepping&0xf | (c.model&0xf)<<4 | family<<8 | (c.model>>4&0xf)<<16 | extFamily<<20
+}
+
+// bundledMicrocode returns the newest microcode revision shipped in the OS
+// for the CPU, false if there is none.
+func bundledMicrocode(c cpuInfo) (uint32, bool) {
+	switch c.vendor {
+	case "GenuineIntel":
+		data, err := ioutil.ReadFile(filepath.Join(intelUcodePath, fmt.Sprintf("%02x-%02x-%02x", c.family, c.model, c.stepping)))
+		if err != nil {
+			return 0, false
+		}
+		return intelRevision(data, c.signature())
+	case "AuthenticAMD":
+		name := "microcode_amd.bin"
+		if c.family >= 0x15 {
+			name = fmt.Sprintf("microcode_amd_fam%xh.bin", c.family)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(amdUcodePath, name))
+		if err != nil {
+			return 0, false
+		}
+		return amdRevision(data, c.signature())
+	}
+	return 0, false
+}
+
+// intelRevision parses concatenated Intel microcode updates, each starting
+// with a 48 byte header holding revision, signature and total size.
+func intelRevision(data []byte, signature uint32) (uint32, bool) {
+	var revision uint32
+	found := false
+
+	for len(data) >= intelHeaderSize {
+		size := binary.LittleEndian.Uint32(data[32:])
+		if size == 0 {
+			size = intelDefaultLen
+		}
+		if binary.LittleEndian.Uint32(data[12:]) == signature {
+			if r := binary.LittleEndian.Uint32(data[4:]); !found || r > revision {
+				revision = r
+				found = true
+			}
+		}
+		if uint64(size) > uint64(len(data)) {
+			break
+		}
+		data = data[size:]
+	}
+	return revision, found
+}
+
+// amdRevision parses an AMD microcode container, an equivalence table
+// mapping CPU signatures to IDs followed by patches for these IDs.
+func amdRevision(data []byte, signature uint32) (uint32, bool) {
+	if len(data) < 12 || binary.LittleEndian.Uint32(data) != amdMagic || binary.LittleEndian.Uint32(data[4:]) != amdEquivTable {
+		return 0, false
+	}
+
+	tableSize := binary.LittleEndian.Uint32(data[8:])
+	if uint64(tableSize)+12 > uint64(len(data)) {
+		return 0, false
+	}
+	table := data[12 : 12+tableSize]
+	data = data[12+tableSize:]
+
+	var equivID uint16
+	for ; len(table) >= 16; table = table[16:] {
+		if binary.LittleEndian.Uint32(table) == signature {
+			equivID = binary.LittleEndian.Uint16(table[12:])
+			break
+		}
+	}
+	if equivID == 0 {
+		return 0, false
+	}
+
+	var revision uint32
+	found := false
+	for len(data) >= 8 {
+		sectionType := binary.LittleEndian.Uint32(data)
+		size := binary.LittleEndian.Uint32(data[4:])
+		if uint64(size)+8 > uint64(len(data)) {
+			break
+		}
+		patch := data[8 : 8+size]
+		if sectionType == amdPatch && len(patch) >= 26 && binary.LittleEndian.Uint16(patch[24:]) == equivID {
+			if r := binary.LittleEndian.Uint32(patch[4:]); !found || r > revision {
+				revision = r
+				found = true
+			}
+		}
+		data = data[8+size:]
+	}
+	return revision, found
+}
@@ -0,0 +1,59 @@
+package x86
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	// Intel Core i5-8259U (NUC8) and AMD Ryzen 7 5700U
+	intel := cpuInfo{family: 6, model: 142, stepping: 10}
+	if s := intel.signature(); s != 0x806ea {
+		t.Errorf("expected 0x806ea, got 0x%x", s)
+	}
+	amd := cpuInfo{family: 0x17, model: 0x68, stepping: 1}
+	if s := amd.signature(); s != 0x860f81 {
+		t.Errorf("expected 0x860f81, got 0x%x", s)
+	}
+}
+
+func intelUpdate(revision, signature uint32, size int) []byte {
+	update := make([]byte, size)
+	binary.LittleEndian.PutUint32(update[4:], revision)
+	binary.LittleEndian.PutUint32(update[12:], signature)
+	binary.LittleEndian.PutUint32(update[32:], uint32(size))
+	return update
+}
+
+func TestIntelRevision(t *testing.T) {
+	data := append(intelUpdate(0xf0, 0x806ea, 64), intelUpdate(0xf4, 0x806ea, 96)...)
+	data = append(data, intelUpdate(0x100, 0x906ea, 64)...)
+
+	revision, ok := intelRevision(data, 0x806ea)
+	if !ok || revision != 0xf4 {
+		t.Errorf("expected 0xf4, got 0x%x (%t)", revision, ok)
+	}
+	if _, ok = intelRevision(data, 0x806ec); ok {
+		t.Error("found update for other signature")
+	}
+}
+
+func TestAMDRevision(t *testing.T) {
+	data := make([]byte, 12+16)
+	binary.LittleEndian.PutUint32(data, amdMagic)
+	binary.LittleEndian.PutUint32(data[8:], 16)
+	binary.LittleEndian.PutUint32(data[12:], 0x860f81)
+	binary.LittleEndian.PutUint16(data[24:], 0x8681)
+
+	patch := make([]byte, 8+32)
+	binary.LittleEndian.PutUint32(patch, amdPatch)
+	binary.LittleEndian.PutUint32(patch[4:], 32)
+	binary.LittleEndian.PutUint32(patch[8+4:], 0x8608103)
+	binary.LittleEndian.PutUint16(patch[8+24:], 0x8681)
+	data = append(data, patch...)
+
+	revision, ok := amdRevision(data, 0x860f81)
+	if !ok || revision != 0x8608103 {
+		t.Errorf("expected 0x8608103, got 0x%x (%t)", revision, ok)
+	}
+}
@@ -0,0 +1,27 @@
+package x86
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "X86"
+}
+
+func (module) Match(board string) bool {
+	return strings.Contains(strings.ToLower(board), "x86")
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
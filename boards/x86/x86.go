@@ -0,0 +1,139 @@
+package x86
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Boards/X86"
+	ifaceName  = "io.hass.os.Boards.X86"
+	dmiPath    = "/sys/class/dmi/id"
+)
+
+// DMI attributes reported as platform info
+var platformFields = map[string]string{
+	"system_vendor": "sys_vendor",
+	"product_name":  "product_name",
+	"board_vendor":  "board_vendor",
+	"board_name":    "board_name",
+	"bios_vendor":   "bios_vendor",
+	"bios_version":  "bios_version",
+	"bios_date":     "bios_date",
+}
+
+type x86 struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func getPlatform() map[string]string {
+	platform := map[string]string{}
+	for key, attribute := range platformFields {
+		data, err := ioutil.ReadFile(filepath.Join(dmiPath, attribute))
+		if err != nil {
+			continue
+		}
+		if value := strings.TrimSpace(string(data)); value != "" {
+			platform[key] = value
+		}
+	}
+	return platform
+}
+
+func formatRevision(revision uint32) string {
+	return fmt.Sprintf("0x%x", revision)
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := x86{
+		conn: conn,
+	}
+
+	// Init base value
+	info, err := readCPUInfo()
+	if err != nil {
+		logging.Warning.Printf("Can't read CPU info: %s", err)
+	}
+	running := ""
+	if info.microcode != 0 {
+		running = formatRevision(info.microcode)
+	}
+	bundled := ""
+	bundledRevision, ok := bundledMicrocode(info)
+	if ok {
+		bundled = formatRevision(bundledRevision)
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"MicrocodeRevision": {
+				Value:    running,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"BundledMicrocodeRevision": {
+				Value:    bundled,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"NewerMicrocodeBundled": {
+				Value:    ok && bundledRevision > info.microcode,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"Platform": {
+				Value:    getPlatform(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, nil),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
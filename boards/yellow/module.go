@@ -0,0 +1,33 @@
+package yellow
+
+import (
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/boards/registry"
+)
+
+type module struct{}
+
+func init() {
+	registry.Register(module{})
+}
+
+func (module) Name() string {
+	return "Yellow"
+}
+
+func (module) Match(board string) bool {
+	return board == "Yellow"
+}
+
+func (module) InitializeDBus(conn *dbus.Conn) {
+	InitializeDBus(conn)
+}
+
+func (module) LEDs() map[string]bool {
+	return LEDs()
+}
+
+func (module) SetLED(name string, on bool) error {
+	return SetLED(name, on)
+}
@@ -1,24 +1,42 @@
 package yellow
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/boards/raspberrypi"
+	"github.com/home-assistant/os-agent/gpio"
 	"github.com/home-assistant/os-agent/utils/bootfile"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/polkit"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
 	objectPath = "/io/hass/os/Boards/Yellow"
 	ifaceName  = "io.hass.os.Boards.Yellow"
 	bootConfig = "/mnt/boot/config.txt"
+
+	// Zigbee module (EFR32MG21) control lines, both active low
+	actionZigbee     = "io.hass.os.gpio"
+	zigbeeChip       = "gpiochip0"
+	zigbeeResetLine  = 24
+	zigbeeBootLine   = 25
+	zigbeeStepPeriod = 100 * time.Millisecond
 )
 
 var (
 	optLEDPower     bool
 	optLEDDisk      bool
 	optLEDHeartbeat bool
+	yellowProps     *prop.Properties
 	bootFile        = bootfile.Editor{FilePath: bootConfig, Delimiter: "="}
 )
 
@@ -27,16 +45,6 @@ type yellow struct {
 	props *prop.Properties
 }
 
-func getStatusLEDPower() bool {
-	value, _ := bootFile.ReadOption("dtparam=pwr_led_trigger", "default-on")
-	return value != "none"
-}
-
-func getStatusLEDDisk() bool {
-	value, _ := bootFile.ReadOption("dtparam=act_led_trigger", "activity")
-	return value != "none"
-}
-
 func getStatusLEDHeartbeat() bool {
 	value, _ := bootFile.ReadOption("dtparam=usr_led_trigger", "heartbeat")
 	return value != "none"
@@ -44,16 +52,10 @@ func getStatusLEDHeartbeat() bool {
 
 func setStatusLEDPower(c *prop.Change) *dbus.Error {
 	logging.Info.Printf("Set Yellow Power LED to %t", c.Value)
+	audit.Record(recovery.Sender(c), "Yellow.PowerLED", optLEDPower, c.Value)
 	optLEDPower = c.Value.(bool)
 
-	var err error
-	if c.Value.(bool) {
-		err = bootFile.DisableOption("dtparam=pwr_led_trigger")
-	} else {
-		err = bootFile.SetOption("dtparam=pwr_led_trigger", "none")
-	}
-
-	if err != nil {
+	if err := raspberrypi.ApplyLED("PowerLED", c.Value.(bool)); err != nil {
 		return dbus.MakeFailedError(err)
 	}
 	return nil
@@ -61,16 +63,10 @@ func setStatusLEDPower(c *prop.Change) *dbus.Error {
 
 func setStatusLEDDisk(c *prop.Change) *dbus.Error {
 	logging.Info.Printf("Set Yellow Disk LED to %t", c.Value)
+	audit.Record(recovery.Sender(c), "Yellow.DiskLED", optLEDDisk, c.Value)
 	optLEDDisk = c.Value.(bool)
 
-	var err error
-	if c.Value.(bool) {
-		err = bootFile.DisableOption("dtparam=act_led_trigger")
-	} else {
-		err = bootFile.SetOption("dtparam=act_led_trigger", "none")
-	}
-
-	if err != nil {
+	if err := raspberrypi.ApplyLED("DiskLED", c.Value.(bool)); err != nil {
 		return dbus.MakeFailedError(err)
 	}
 	return nil
@@ -78,6 +74,7 @@ func setStatusLEDDisk(c *prop.Change) *dbus.Error {
 
 func setStatusLEDHeartbeat(c *prop.Change) *dbus.Error {
 	logging.Info.Printf("Set Yellow Heartbeat LED to %t", c.Value)
+	audit.Record(recovery.Sender(c), "Yellow.HeartbeatLED", optLEDHeartbeat, c.Value)
 	optLEDHeartbeat = c.Value.(bool)
 
 	var err error
@@ -93,14 +90,85 @@ func setStatusLEDHeartbeat(c *prop.Change) *dbus.Error {
 	return nil
 }
 
+// LEDs returns the state of the LEDs owned by the Yellow module by property
+// name. The power and disk LEDs are the PWR and ACT LEDs of the Compute
+// Module, switched by the stacked Raspberry Pi module.
+func LEDs() map[string]bool {
+	return map[string]bool{
+		"HeartbeatLED": optLEDHeartbeat,
+	}
+}
+
+// SetLED switches a status LED by property name, like a client setting the
+// property would.
+func SetLED(name string, on bool) error {
+	if yellowProps == nil {
+		return fmt.Errorf("Yellow board is not initialized")
+	}
+	if _, ok := LEDs()[name]; !ok {
+		return fmt.Errorf("Unknown LED %s", name)
+	}
+	if dbuserr := yellowProps.Set(ifaceName, name, dbus.MakeVariant(on)); dbuserr != nil {
+		return dbuserr
+	}
+	return nil
+}
+
+// ResetZigbee hard-resets the Zigbee module into its application firmware.
+func (d yellow) ResetZigbee(sender dbus.Sender) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionZigbee); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Resetting Yellow Zigbee module.")
+	audit.Record(sender, "Yellow.ResetZigbee", "", "")
+
+	err := gpio.SendPattern(zigbeeChip, []uint32{zigbeeResetLine, zigbeeBootLine}, [][]bool{
+		{true, true},
+		{false, true},
+		{true, true},
+	}, zigbeeStepPeriod)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't reset Zigbee module: %w", err))
+	}
+	return true, nil
+}
+
+// ZigbeeBootloader resets the Zigbee module with the boot line held low, so
+// it stays in the bootloader ready for flashing new firmware.
+func (d yellow) ZigbeeBootloader(sender dbus.Sender) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionZigbee); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Starting Yellow Zigbee module in bootloader mode.")
+	audit.Record(sender, "Yellow.ZigbeeBootloader", "", "")
+
+	err := gpio.SendPattern(zigbeeChip, []uint32{zigbeeResetLine, zigbeeBootLine}, [][]bool{
+		{true, true},
+		{false, false},
+		{true, false},
+		{true, true},
+	}, zigbeeStepPeriod)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't start Zigbee bootloader: %w", err))
+	}
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"ResetZigbee":      {"success"},
+	"ZigbeeBootloader": {"success"},
+}
+
 func InitializeDBus(conn *dbus.Conn) {
 	d := yellow{
 		conn: conn,
 	}
 
 	// Init base value
-	optLEDPower = getStatusLEDPower()
-	optLEDDisk = getStatusLEDDisk()
+	optLEDPower = raspberrypi.GetLED("PowerLED")
+	optLEDDisk = raspberrypi.GetLED("DiskLED")
 	optLEDHeartbeat = getStatusLEDHeartbeat()
 
 	propsSpec := map[string]map[string]*prop.Prop{
@@ -126,13 +194,14 @@ func InitializeDBus(conn *dbus.Conn) {
 		},
 	}
 
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 	d.props = props
+	yellowProps = props
 
-	err = conn.Export(d, objectPath, ifaceName)
+	err = recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -144,7 +213,7 @@ func InitializeDBus(conn *dbus.Conn) {
 			prop.IntrospectData,
 			{
 				Name:       ifaceName,
-				Methods:    introspect.Methods(d),
+				Methods:    introspection.Methods(d, methodArgNames),
 				Properties: props.Introspection(ifaceName),
 			},
 		},
@@ -156,4 +225,5 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
 }
@@ -0,0 +1,279 @@
+package boot
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	bootDirectory    = "/mnt/boot"
+	backupDirectory  = "/mnt/data/os-agent"
+	bootBackupFile   = "boot-backup.tar.gz"
+	bootChecksumFile = "boot-backup.tar.gz.sha256"
+	manifestName     = "SHA256SUMS"
+)
+
+func bootBackupPath() string {
+	return filepath.Join(backupDirectory, bootBackupFile)
+}
+
+func bootChecksumPath() string {
+	return filepath.Join(backupDirectory, bootChecksumFile)
+}
+
+func getBootBackupTime() int64 {
+	info, err := os.Stat(bootBackupPath())
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().Unix()
+}
+
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// bootFiles lists all regular files of the boot partition, skipping our own
+// temporary files.
+func bootFiles() ([]string, error) {
+	var files []string
+	err := filepath.Walk(bootDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".tmp.") {
+			return nil
+		}
+		rel, err := filepath.Rel(bootDirectory, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+func addTarFile(writer *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := writer.Write(data)
+	return err
+}
+
+func writeBootBackup(path string) error {
+	files, err := bootFiles()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+
+	var manifest bytes.Buffer
+	for _, name := range files {
+		data, err := ioutil.ReadFile(filepath.Join(bootDirectory, name))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&manifest, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+
+		if err = addTarFile(writer, name, data, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if err = addTarFile(writer, manifestName, manifest.Bytes(), time.Now()); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// readBootBackup extracts the backup into memory and verifies every file
+// against the manifest stored inside the archive.
+func readBootBackup(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	reader := tar.NewReader(gz)
+
+	files := map[string][]byte{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Clean(header.Name)
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return nil, fmt.Errorf("Invalid path '%s' in backup", header.Name)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+
+	manifest, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("Backup has no %s manifest", manifestName)
+	}
+	delete(files, manifestName)
+
+	checked := 0
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "  ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		data, ok := files[fields[1]]
+		if !ok {
+			return nil, fmt.Errorf("File '%s' is missing in backup", fields[1])
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != fields[0] {
+			return nil, fmt.Errorf("Checksum mismatch for '%s'", fields[1])
+		}
+		checked++
+	}
+	if checked != len(files) {
+		return nil, fmt.Errorf("Backup contains files not listed in %s", manifestName)
+	}
+	return files, nil
+}
+
+// BackupBootPartition archives all files of the boot partition together with
+// a checksum manifest. Only the latest backup is kept.
+func (d boot) BackupBootPartition(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Create boot partition backup.")
+
+	err := os.MkdirAll(backupDirectory, 0700)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	tmpPath := bootBackupPath() + ".tmp"
+	if err = writeBootBackup(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't create boot partition backup: %s", err))
+	}
+
+	sum, err := sha256File(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return false, dbus.MakeFailedError(err)
+	}
+	if err = ioutil.WriteFile(bootChecksumPath(), []byte(sum+"\n"), 0600); err != nil {
+		os.Remove(tmpPath)
+		return false, dbus.MakeFailedError(err)
+	}
+	if err = os.Rename(tmpPath, bootBackupPath()); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	audit.Record(sender, "Boot.BackupBootPartition", "", sum)
+	d.props.SetMust(ifaceName, "BootBackup", getBootBackupTime())
+	return true, nil
+}
+
+// RestoreBootPartition writes all files of the last backup back to the boot
+// partition once both the archive and its content checksums are verified.
+// Files added since the backup are left in place.
+func (d boot) RestoreBootPartition(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Restore boot partition from backup.")
+
+	expected, err := ioutil.ReadFile(bootChecksumPath())
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("No boot partition backup available"))
+	}
+	sum, err := sha256File(bootBackupPath())
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if sum != strings.TrimSpace(string(expected)) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Boot partition backup is corrupted"))
+	}
+
+	files, err := readBootBackup(bootBackupPath())
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't read boot partition backup: %s", err))
+	}
+
+	for name, data := range files {
+		target := filepath.Join(bootDirectory, name)
+		tmpTarget := filepath.Join(filepath.Dir(target), ".tmp."+filepath.Base(target))
+
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return false, dbus.MakeFailedError(err)
+		}
+		if err = ioutil.WriteFile(tmpTarget, data, 0644); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't restore '%s': %s", name, err))
+		}
+		if err = os.Rename(tmpTarget, target); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't restore '%s': %s", name, err))
+		}
+	}
+
+	logging.Info.Printf("Restored %d files to boot partition.", len(files))
+	audit.Record(sender, "Boot.RestoreBootPartition", "", sum)
+	d.props.SetMust(ifaceName, "DebugBoot", getDebugBoot())
+	d.props.SetMust(ifaceName, "SafeModeScheduled", getSafeModeScheduled())
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package boot
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/bootenv"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Boot"
+	ifaceName  = "io.hass.os.Boot"
+)
+
+type boot struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"GetBootVariables":     {"variables"},
+	"GetBootVariable":      {"name", "value"},
+	"SetBootVariable":      {"name", "value", "success"},
+	"SetDefaultBootEntry":  {"entry", "success"},
+	"SetNextBootEntry":     {"entry", "success"},
+	"EnableDebugBoot":      {"success"},
+	"DisableDebugBoot":     {"success"},
+	"ScheduleSafeModeBoot": {"success"},
+	"CancelSafeModeBoot":   {"cancelled"},
+	"GetLastBootReport":    {"report"},
+	"FastReboot":           {"success"},
+	"BackupBootPartition":  {"success"},
+	"RestoreBootPartition": {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := boot{
+		conn: conn,
+	}
+
+	clearSafeModeBoot()
+	lastBootReport = collectLastBootReport(conn)
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Bootloader": {
+				Value:    bootenv.Detect(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"DebugBoot": {
+				Value:    getDebugBoot(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"BootBackup": {
+				Value:    getBootBackupTime(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"CanFastReboot": {
+				Value:    getCanFastReboot(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"SafeModeScheduled": {
+				Value:    getSafeModeScheduled(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"SafeMode": {
+				Value:    getSafeMode(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
@@ -0,0 +1,102 @@
+package boot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/cmdline"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	kernelCommandLine       = "/mnt/boot/cmdline.txt"
+	backupKernelCommandLine = "/mnt/boot/cmdline.txt.nodebug"
+)
+
+func serialConsole() string {
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "386" {
+		return "console=ttyS0,115200"
+	}
+	return "console=serial0,115200"
+}
+
+func getDebugBoot() bool {
+	_, err := os.Stat(backupKernelCommandLine)
+	return err == nil
+}
+
+// EnableDebugBoot applies the debug profile to the kernel command line:
+// serial console, verbose log level and no quiet boot.
+func (d boot) EnableDebugBoot(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Enable debug boot profile.")
+
+	if getDebugBoot() {
+		return false, dbus.MakeFailedError(fmt.Errorf("Debug boot profile is already active"))
+	}
+
+	data, err := ioutil.ReadFile(kernelCommandLine)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	err = ioutil.WriteFile(backupKernelCommandLine, data, 0644)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	args := cmdline.Parse(string(data))
+	args.Remove("quiet")
+	args.RemoveKey("loglevel")
+	args.Prepend(serialConsole())
+	args.Append("loglevel=7")
+
+	if err = args.Write(kernelCommandLine); err != nil {
+		os.Remove(backupKernelCommandLine)
+		return false, dbus.MakeFailedError(err)
+	}
+
+	audit.Record(sender, "Boot.DebugBoot", false, true)
+	d.props.SetMust(ifaceName, "DebugBoot", true)
+	return true, nil
+}
+
+// DisableDebugBoot removes the debug profile again. Only the arguments it
+// changed are restored from the snapshot, others added meanwhile, e.g. a
+// wipe or safe mode request, are kept.
+func (d boot) DisableDebugBoot(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Disable debug boot profile.")
+
+	saved, err := cmdline.Read(backupKernelCommandLine)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Debug boot profile is not active"))
+	}
+	args, err := cmdline.Read(kernelCommandLine)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	args.Remove(serialConsole())
+	if saved.Contains(serialConsole()) {
+		args.Prepend(serialConsole())
+	}
+	args.RemoveKey("loglevel")
+	for _, level := range saved.GetAll("loglevel") {
+		args.Append("loglevel=" + level)
+	}
+	if saved.Contains("quiet") && !args.Contains("quiet") {
+		args.Append("quiet")
+	}
+
+	if err = args.Write(kernelCommandLine); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	os.Remove(backupKernelCommandLine)
+
+	audit.Record(sender, "Boot.DebugBoot", true, false)
+	d.props.SetMust(ifaceName, "DebugBoot", false)
+	return true, nil
+}
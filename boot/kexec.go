@@ -0,0 +1,121 @@
+package boot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	kexecCmd          = "kexec"
+	kexecLoaded       = "/sys/kernel/kexec_loaded"
+	systemdBusName    = "org.freedesktop.systemd1"
+	systemdObjectPath = "/org/freedesktop/systemd1"
+	systemdIfaceName  = "org.freedesktop.systemd1.Manager"
+)
+
+type raucSlotStatus struct {
+	Name   string
+	Status map[string]dbus.Variant
+}
+
+var kernelImages = []string{
+	"/boot/bzImage",
+	"/boot/Image",
+	"/boot/zImage",
+}
+
+func getKernelImage() string {
+	for _, image := range kernelImages {
+		if _, err := os.Stat(image); err == nil {
+			return image
+		}
+	}
+	return ""
+}
+
+// getCanFastReboot is true if the kernel supports kexec, the kexec tool is
+// installed and a kernel image of the running OS is available.
+func getCanFastReboot() bool {
+	if _, err := os.Stat(kexecLoaded); err != nil {
+		return false
+	}
+	if _, err := exec.LookPath(kexecCmd); err != nil {
+		return false
+	}
+	return getKernelImage() != ""
+}
+
+// nextBootSlot returns the boot name of the slot the bootloader starts next
+// and of the booted slot.
+func nextBootSlot(conn *dbus.Conn) (string, string, error) {
+	obj := conn.Object(raucBusName, raucObjectPath)
+
+	var primary string
+	if err := obj.Call(raucIfaceName+".GetPrimary", 0).Store(&primary); err != nil {
+		return "", "", fmt.Errorf("Can't read primary slot from RAUC: %s", err)
+	}
+
+	var slots []raucSlotStatus
+	if err := obj.Call(raucIfaceName+".GetSlotStatus", 0).Store(&slots); err != nil {
+		return "", "", fmt.Errorf("Can't read slot status from RAUC: %s", err)
+	}
+	next := ""
+	for _, slot := range slots {
+		if slot.Name == primary {
+			next, _ = slot.Status["bootname"].Value().(string)
+		}
+	}
+
+	booted, err := obj.GetProperty(raucIfaceName + ".BootSlot")
+	if err != nil {
+		return "", "", fmt.Errorf("Can't read booted slot from RAUC: %s", err)
+	}
+	bootedSlot, _ := booted.Value().(string)
+	return next, bootedSlot, nil
+}
+
+// FastReboot loads the kernel of the running OS with kexec and reboots into
+// it directly, skipping firmware and bootloader initialization. The kernel
+// on disk belongs to the booted slot, so fast reboot is refused while the
+// bootloader would start the other slot, e.g. after an update.
+func (d boot) FastReboot(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Fast reboot using kexec.")
+
+	if !getCanFastReboot() {
+		return false, dbus.MakeFailedError(fmt.Errorf("Fast reboot is not supported on this system"))
+	}
+
+	next, booted, err := nextBootSlot(d.conn)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if next != booted {
+		return false, apierror.Failed(apierror.New(apierror.CodeUnsupported,
+			"Next boot slot %s differs from the booted slot %s, use a regular reboot", next, booted))
+	}
+
+	image := getKernelImage()
+	cmd := exec.Command(kexecCmd, "--load", image, "--reuse-cmdline")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't load kernel '%s': %s, output %s", image, err, out))
+	}
+
+	audit.Record(sender, "Boot.FastReboot", "", image)
+
+	var job dbus.ObjectPath
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	err = obj.Call(systemdIfaceName+".StartUnit", 0, "kexec.target", "replace-irreversibly").Store(&job)
+	if err != nil {
+		exec.Command(kexecCmd, "--unload").Run()
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't start kexec.target: %s", err))
+	}
+	return true, nil
+}
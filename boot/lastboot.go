@@ -0,0 +1,115 @@
+package boot
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	pstoreDirectory       = "/sys/fs/pstore"
+	watchdogBootStatus    = "/sys/class/watchdog/watchdog0/bootstatus"
+	journalctlCmd         = "journalctl"
+	journalTailLines      = "50"
+	raucBusName           = "de.pengutronix.rauc"
+	raucObjectPath        = "/"
+	raucIfaceName         = "de.pengutronix.rauc.Installer"
+	managerTailLines      = "20"
+	lastBootReportPstore  = "pstore"
+	lastBootReportWDReset = "watchdog_reset"
+	lastBootReportRauc    = "rauc_fallback"
+	lastBootReportClean   = "clean_shutdown"
+	lastBootReportJournal = "journal_tail"
+)
+
+var (
+	lastBootReport map[string]string
+
+	// systemd-shutdown runs after journald is gone, the last thing the
+	// journal sees of a clean shutdown is the manager reaching the
+	// shutdown target ("Shutdown" or "shutdown.target - System Shutdown").
+	cleanShutdownRegex = regexp.MustCompile(`Reached target (shutdown\.target - System )?Shutdown`)
+)
+
+func getPstoreEntries() []string {
+	files, _ := filepath.Glob(filepath.Join(pstoreDirectory, "*"))
+	entries := []string{}
+	for _, file := range files {
+		entries = append(entries, filepath.Base(file))
+	}
+	return entries
+}
+
+func getWatchdogReset() bool {
+	data, err := ioutil.ReadFile(watchdogBootStatus)
+	if err != nil {
+		return false
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return err == nil && status != 0
+}
+
+// getRaucFallback is true if RAUC booted a different slot than the primary
+// one, meaning the bootloader gave up on the primary slot.
+func getRaucFallback(conn *dbus.Conn) bool {
+	obj := conn.Object(raucBusName, raucObjectPath)
+
+	booted, err := obj.GetProperty(raucIfaceName + ".BootSlot")
+	if err != nil {
+		return false
+	}
+
+	var primary string
+	err = obj.Call(raucIfaceName+".GetPrimary", 0).Store(&primary)
+	if err != nil || primary == "" {
+		return false
+	}
+
+	bootSlot, _ := booted.Value().(string)
+	return !strings.HasSuffix(primary, "."+bootSlot) && primary != bootSlot
+}
+
+func getPreviousBootJournal() string {
+	out, err := exec.Command(journalctlCmd, "--boot=-1", "--lines="+journalTailLines, "--no-pager", "--output=short-iso").Output()
+	if err != nil {
+		logging.Warning.Printf("Can't read journal of previous boot: %s", err)
+		return ""
+	}
+	return string(out)
+}
+
+// getCleanShutdown checks the last messages of the service manager of the
+// previous boot for the shutdown target.
+func getCleanShutdown() bool {
+	out, err := exec.Command(journalctlCmd, "--boot=-1", "_PID=1", "--lines="+managerTailLines, "--no-pager", "--output=cat").Output()
+	if err != nil {
+		logging.Warning.Printf("Can't read service manager journal of previous boot: %s", err)
+		return false
+	}
+	return cleanShutdownRegex.Match(out)
+}
+
+func collectLastBootReport(conn *dbus.Conn) map[string]string {
+	journal := getPreviousBootJournal()
+
+	return map[string]string{
+		lastBootReportPstore:  strings.Join(getPstoreEntries(), ","),
+		lastBootReportWDReset: strconv.FormatBool(getWatchdogReset()),
+		lastBootReportRauc:    strconv.FormatBool(getRaucFallback(conn)),
+		lastBootReportClean:   strconv.FormatBool(getCleanShutdown()),
+		lastBootReportJournal: journal,
+	}
+}
+
+// GetLastBootReport returns the evidence collected at agent start about how
+// the previous boot ended.
+func (d boot) GetLastBootReport() (map[string]string, *dbus.Error) {
+	return lastBootReport, nil
+}
@@ -0,0 +1,92 @@
+package boot
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/cmdline"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	procCommandLine = "/proc/cmdline"
+	safeModeArg     = "haos.safemode=1"
+)
+
+func getSafeMode() bool {
+	args, err := cmdline.Read(procCommandLine)
+	if err != nil {
+		return false
+	}
+	return args.Contains(safeModeArg)
+}
+
+func getSafeModeScheduled() bool {
+	args, err := cmdline.Read(kernelCommandLine)
+	if err != nil {
+		return false
+	}
+	return args.Contains(safeModeArg)
+}
+
+func removeSafeModeArg() (bool, error) {
+	args, err := cmdline.Read(kernelCommandLine)
+	if err != nil {
+		return false, err
+	}
+
+	if args.Remove(safeModeArg) == 0 {
+		return false, nil
+	}
+	return true, args.Write(kernelCommandLine)
+}
+
+// clearSafeModeBoot makes the safe mode marker one-shot: once the OS booted
+// with it, remove it again so the next boot is a normal one.
+func clearSafeModeBoot() {
+	if !getSafeMode() {
+		return
+	}
+
+	logging.Warning.Printf("System booted in safe mode!")
+	if _, err := removeSafeModeArg(); err != nil {
+		logging.Error.Printf("Failed to remove safe mode marker from %s: %s", kernelCommandLine, err)
+	}
+}
+
+func (d boot) ScheduleSafeModeBoot(sender dbus.Sender) (bool, *dbus.Error) {
+	args, err := cmdline.Read(kernelCommandLine)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	if args.Contains(safeModeArg) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Safe mode boot is already scheduled"))
+	}
+
+	args.Append(safeModeArg)
+	if err = args.Write(kernelCommandLine); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	logging.Info.Printf("Device will boot into safe mode on next reboot!")
+	audit.Record(sender, "Boot.ScheduleSafeModeBoot", "", safeModeArg)
+	d.props.SetMust(ifaceName, "SafeModeScheduled", true)
+	return true, nil
+}
+
+func (d boot) CancelSafeModeBoot(sender dbus.Sender) (bool, *dbus.Error) {
+	removed, err := removeSafeModeArg()
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	if removed {
+		logging.Info.Printf("Safe mode boot cancelled.")
+		audit.Record(sender, "Boot.CancelSafeModeBoot", safeModeArg, "")
+		d.props.SetMust(ifaceName, "SafeModeScheduled", false)
+	}
+	return removed, nil
+}
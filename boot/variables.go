@@ -0,0 +1,100 @@
+package boot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/bootenv"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+// Variables which may be changed through the API, per bootloader. Slot
+// selection state is owned by RAUC and deliberately not part of it.
+var writableVariables = map[string][]string{
+	bootenv.UBoot: {"bootdelay", "console", "stdin", "stdout", "stderr", "boot_targets", "bootcmd_once"},
+	bootenv.GRUB:  {"timeout", grubDefaultEntry, grubNextEntry},
+}
+
+const (
+	grubDefaultEntry = "saved_entry"
+	grubNextEntry    = "next_entry"
+)
+
+func isWritable(name string) bool {
+	for _, variable := range writableVariables[bootenv.Detect()] {
+		if variable == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d boot) GetBootVariables() (map[string]string, *dbus.Error) {
+	env, err := bootenv.List()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return env, nil
+}
+
+func (d boot) GetBootVariable(name string) (string, *dbus.Error) {
+	env, err := bootenv.List()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+
+	value, ok := env[name]
+	if !ok {
+		return "", dbus.MakeFailedError(fmt.Errorf("Boot variable '%s' is not set", name))
+	}
+	return value, nil
+}
+
+func (d boot) SetBootVariable(sender dbus.Sender, name string, value string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set boot variable %s to '%s'.", name, value)
+
+	if !isWritable(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Boot variable '%s' can't be changed", name))
+	}
+	if strings.ContainsAny(value, "\n\x00") {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid value for boot variable '%s'", name))
+	}
+
+	previous, _ := bootenv.Get(name, "")
+	if err := bootenv.Set(name, value); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	audit.Record(sender, "Boot."+name, previous, value)
+	return true, nil
+}
+
+func (d boot) setGRUBEntry(sender dbus.Sender, variable string, entry string) (bool, *dbus.Error) {
+	if bootenv.Detect() != bootenv.GRUB {
+		return false, dbus.MakeFailedError(fmt.Errorf("Boot entries can only be selected with GRUB"))
+	}
+	if entry == "" {
+		if err := bootenv.Unset(variable); err != nil {
+			return false, dbus.MakeFailedError(err)
+		}
+		audit.Record(sender, "Boot."+variable, "", "")
+		return true, nil
+	}
+	return d.SetBootVariable(sender, variable, entry)
+}
+
+// SetDefaultBootEntry selects the GRUB menu entry booted by default, an empty
+// entry restores the OS default.
+func (d boot) SetDefaultBootEntry(sender dbus.Sender, entry string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set default boot entry to '%s'.", entry)
+	return d.setGRUBEntry(sender, grubDefaultEntry, entry)
+}
+
+// SetNextBootEntry selects the GRUB menu entry for the next boot only.
+func (d boot) SetNextBootEntry(sender dbus.Sender, entry string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set next boot entry to '%s'.", entry)
+	return d.setGRUBEntry(sender, grubNextEntry, entry)
+}
@@ -12,7 +12,11 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
@@ -34,7 +38,7 @@ type cgroup struct {
 	cgroupVersion CGroupVersion
 }
 
-func (d cgroup) AddDevicesAllowed(containerID string, permission string) (bool, *dbus.Error) {
+func (d cgroup) AddDevicesAllowed(sender dbus.Sender, containerID string, permission string) (bool, *dbus.Error) {
 	if d.cgroupVersion == CGroupV2 {
 		permissions := []string{permission}
 		resources, err := CreateDeviceUpdateResources(permissions)
@@ -72,6 +76,7 @@ func (d cgroup) AddDevicesAllowed(containerID string, permission string) (bool,
 		}
 
 		logging.Info.Printf("Permission '%s', granted for Container '%s' via runc", permission, containerID)
+		audit.Record(sender, "CGroup.AddDevicesAllowed", containerID, permission)
 		return true, nil
 	} else {
 		// Make sure path is relative to cgroupFSDockerDevices
@@ -99,10 +104,15 @@ func (d cgroup) AddDevicesAllowed(containerID string, permission string) (bool,
 		}
 
 		logging.Info.Printf("Permission '%s', granted for Container '%s' via CGroup devices.allow", permission, containerID)
+		audit.Record(sender, "CGroup.AddDevicesAllowed", containerID, permission)
 		return true, nil
 	}
 }
 
+var methodArgNames = map[string][]string{
+	"AddDevicesAllowed": {"container_id", "permission", "success"},
+}
+
 func InitializeDBus(conn *dbus.Conn) {
 	d := cgroup{
 		conn:          conn,
@@ -118,7 +128,7 @@ func InitializeDBus(conn *dbus.Conn) {
 		logging.Info.Printf("Detected CGroups Version 1")
 	}
 
-	err := conn.Export(d, objectPath, ifaceName)
+	err := recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -130,7 +140,7 @@ func InitializeDBus(conn *dbus.Conn) {
 			prop.IntrospectData,
 			{
 				Name:    ifaceName,
-				Methods: introspect.Methods(d),
+				Methods: introspection.Methods(d, methodArgNames),
 			},
 		},
 	}
@@ -141,4 +151,5 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, nil, ifaceName)
 }
@@ -0,0 +1,24 @@
+package client
+
+// AppArmor wraps the io.hass.os.AppArmor interface.
+type AppArmor struct {
+	object
+}
+
+func (c *Client) AppArmor() *AppArmor {
+	return &AppArmor{object{c, objectPath + "/AppArmor", busName + ".AppArmor"}}
+}
+
+func (a *AppArmor) ParserVersion() (string, error) {
+	var version string
+	err := a.property("ParserVersion", &version)
+	return version, err
+}
+
+func (a *AppArmor) LoadProfile(profilePath string, cachePath string) error {
+	return a.call("LoadProfile", profilePath, cachePath).Err
+}
+
+func (a *AppArmor) UnloadProfile(profilePath string, cachePath string) error {
+	return a.call("UnloadProfile", profilePath, cachePath).Err
+}
@@ -0,0 +1,38 @@
+package client
+
+// Boards wraps the io.hass.os.Boards interface.
+type Boards struct {
+	object
+}
+
+func (c *Client) Boards() *Boards {
+	return &Boards{object{c, objectPath + "/Boards", busName + ".Boards"}}
+}
+
+func (b *Boards) Board() (string, error) {
+	var board string
+	err := b.property("Board", &board)
+	return board, err
+}
+
+// Yellow wraps the io.hass.os.Boards.Yellow interface, only available on
+// Home Assistant Yellow.
+type Yellow struct {
+	object
+}
+
+func (b *Boards) Yellow() *Yellow {
+	return &Yellow{object{b.client, objectPath + "/Boards/Yellow", busName + ".Boards.Yellow"}}
+}
+
+// LED returns the state of the "Power", "Disk" or "Heartbeat" LED.
+func (y *Yellow) LED(name string) (bool, error) {
+	var enabled bool
+	err := y.property(name+"LED", &enabled)
+	return enabled, err
+}
+
+// SetLED enables or disables the "Power", "Disk" or "Heartbeat" LED.
+func (y *Yellow) SetLED(name string, enabled bool) error {
+	return y.setProperty(name+"LED", enabled)
+}
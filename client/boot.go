@@ -0,0 +1,90 @@
+package client
+
+// Boot wraps the io.hass.os.Boot interface.
+type Boot struct {
+	object
+}
+
+func (c *Client) Boot() *Boot {
+	return &Boot{object{c, objectPath + "/Boot", busName + ".Boot"}}
+}
+
+// Bootloader returns the bootloader in use, e.g. "grub" or "u-boot".
+func (b *Boot) Bootloader() (string, error) {
+	var bootloader string
+	err := b.property("Bootloader", &bootloader)
+	return bootloader, err
+}
+
+func (b *Boot) GetBootVariables() (map[string]string, error) {
+	var variables map[string]string
+	err := b.call("GetBootVariables").Store(&variables)
+	return variables, err
+}
+
+func (b *Boot) GetBootVariable(name string) (string, error) {
+	var value string
+	err := b.call("GetBootVariable", name).Store(&value)
+	return value, err
+}
+
+func (b *Boot) SetBootVariable(name string, value string) error {
+	return b.call("SetBootVariable", name, value).Err
+}
+
+func (b *Boot) SetNextBootEntry(entry string) error {
+	return b.call("SetNextBootEntry", entry).Err
+}
+
+func (b *Boot) SetDefaultBootEntry(entry string) error {
+	return b.call("SetDefaultBootEntry", entry).Err
+}
+
+func (b *Boot) GetLastBootReport() (map[string]string, error) {
+	var report map[string]string
+	err := b.call("GetLastBootReport").Store(&report)
+	return report, err
+}
+
+func (b *Boot) DebugBoot() (bool, error) {
+	var enabled bool
+	err := b.property("DebugBoot", &enabled)
+	return enabled, err
+}
+
+func (b *Boot) EnableDebugBoot() error {
+	return b.call("EnableDebugBoot").Err
+}
+
+func (b *Boot) DisableDebugBoot() error {
+	return b.call("DisableDebugBoot").Err
+}
+
+func (b *Boot) SafeModeScheduled() (bool, error) {
+	var scheduled bool
+	err := b.property("SafeModeScheduled", &scheduled)
+	return scheduled, err
+}
+
+func (b *Boot) ScheduleSafeModeBoot() error {
+	return b.call("ScheduleSafeModeBoot").Err
+}
+
+// CancelSafeModeBoot returns false if no safe mode boot was scheduled.
+func (b *Boot) CancelSafeModeBoot() (bool, error) {
+	var cancelled bool
+	err := b.call("CancelSafeModeBoot").Store(&cancelled)
+	return cancelled, err
+}
+
+func (b *Boot) BackupBootPartition() error {
+	return b.call("BackupBootPartition").Err
+}
+
+func (b *Boot) RestoreBootPartition() error {
+	return b.call("RestoreBootPartition").Err
+}
+
+func (b *Boot) FastReboot() error {
+	return b.call("FastReboot").Err
+}
@@ -0,0 +1,14 @@
+package client
+
+// CGroup wraps the io.hass.os.CGroup interface.
+type CGroup struct {
+	object
+}
+
+func (c *Client) CGroup() *CGroup {
+	return &CGroup{object{c, objectPath + "/CGroup", busName + ".CGroup"}}
+}
+
+func (g *CGroup) AddDevicesAllowed(containerID string, permission string) error {
+	return g.call("AddDevicesAllowed", containerID, permission).Err
+}
@@ -0,0 +1,85 @@
+// Package client provides typed access to the OS Agent D-Bus API. Methods
+// returning only a success flag on D-Bus return just an error here.
+package client
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName    = "io.hass.os"
+	objectPath = "/io/hass/os"
+	propsIface = "org.freedesktop.DBus.Properties"
+)
+
+// Client wraps a D-Bus connection to the OS Agent.
+type Client struct {
+	conn *dbus.Conn
+}
+
+// New creates a client on an existing system bus connection.
+func New(conn *dbus.Conn) *Client {
+	return &Client{conn: conn}
+}
+
+// Connect creates a client on the shared system bus connection.
+func Connect() (*Client, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// Conn returns the underlying D-Bus connection.
+func (c *Client) Conn() *dbus.Conn {
+	return c.conn
+}
+
+type object struct {
+	client *Client
+	path   dbus.ObjectPath
+	iface  string
+}
+
+func (o object) call(method string, args ...interface{}) *dbus.Call {
+	return o.client.conn.Object(busName, o.path).Call(o.iface+"."+method, 0, args...)
+}
+
+func (o object) property(name string, value interface{}) error {
+	return o.client.conn.Object(busName, o.path).StoreProperty(o.iface+"."+name, value)
+}
+
+func (o object) setProperty(name string, value interface{}) error {
+	return o.client.conn.Object(busName, o.path).Call(propsIface+".Set", 0, o.iface, name, dbus.MakeVariant(value)).Err
+}
+
+// Version returns the version of the running agent.
+func (c *Client) Version() (string, error) {
+	var version string
+	err := object{c, objectPath, busName}.property("Version", &version)
+	return version, err
+}
+
+// APIVersion returns the API version of the running agent. Agents older than
+// the versioned interfaces don't have the property and report 1.
+func (c *Client) APIVersion() (uint32, error) {
+	var apiVersion uint32
+	err := object{c, objectPath, busName}.property("APIVersion", &apiVersion)
+	if dbusErr, ok := err.(dbus.Error); ok && dbusErr.Name == "org.freedesktop.DBus.Error.InvalidArgs" {
+		return 1, nil
+	}
+	return apiVersion, err
+}
+
+// Diagnostics returns whether error reporting is enabled.
+func (c *Client) Diagnostics() (bool, error) {
+	var enabled bool
+	err := object{c, objectPath, busName}.property("Diagnostics", &enabled)
+	return enabled, err
+}
+
+// SetDiagnostics enables or disables error reporting.
+func (c *Client) SetDiagnostics(enabled bool) error {
+	return object{c, objectPath, busName}.setProperty("Diagnostics", enabled)
+}
@@ -0,0 +1,34 @@
+package client
+
+// DataDisk wraps the io.hass.os.DataDisk interface.
+type DataDisk struct {
+	object
+}
+
+func (c *Client) DataDisk() *DataDisk {
+	return &DataDisk{object{c, objectPath + "/DataDisk", busName + ".DataDisk"}}
+}
+
+func (d *DataDisk) CurrentDevice() (string, error) {
+	var device string
+	err := d.property("CurrentDevice", &device)
+	return device, err
+}
+
+func (d *DataDisk) ChangeDevice(device string) error {
+	return d.call("ChangeDevice", device).Err
+}
+
+func (d *DataDisk) ReloadDevice() error {
+	return d.call("ReloadDevice").Err
+}
+
+func (d *DataDisk) MarkDataMove() error {
+	return d.call("MarkDataMove").Err
+}
+
+func (d *DataDisk) DataMoveScheduled() (bool, error) {
+	var scheduled bool
+	err := d.property("DataMoveScheduled", &scheduled)
+	return scheduled, err
+}
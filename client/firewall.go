@@ -0,0 +1,43 @@
+package client
+
+import (
+	"time"
+)
+
+// FirewallRule allows or denies incoming traffic on a port.
+type FirewallRule struct {
+	Port     uint16
+	Protocol string
+	Action   string
+}
+
+// Firewall wraps the io.hass.os.Firewall interface.
+type Firewall struct {
+	object
+}
+
+func (c *Client) Firewall() *Firewall {
+	return &Firewall{object{c, objectPath + "/Firewall", busName + ".Firewall"}}
+}
+
+func (f *Firewall) Rules() ([]FirewallRule, error) {
+	var rules []FirewallRule
+	err := f.property("Rules", &rules)
+	return rules, err
+}
+
+// SetRules applies rules, which are reverted unless confirmed with
+// ConfirmRules within timeout.
+func (f *Firewall) SetRules(rules []FirewallRule, timeout time.Duration) error {
+	return f.call("SetRules", rules, uint32(timeout/time.Second)).Err
+}
+
+func (f *Firewall) ConfirmRules() error {
+	return f.call("ConfirmRules").Err
+}
+
+func (f *Firewall) PendingConfirmation() (bool, error) {
+	var pending bool
+	err := f.property("PendingConfirmation", &pending)
+	return pending, err
+}
@@ -0,0 +1,56 @@
+package client
+
+// Hardware wraps the io.hass.os.Hardware interface.
+type Hardware struct {
+	object
+}
+
+func (c *Client) Hardware() *Hardware {
+	return &Hardware{object{c, objectPath + "/Hardware", busName + ".Hardware"}}
+}
+
+func (h *Hardware) list(method string, args ...interface{}) ([]map[string]string, error) {
+	var devices []map[string]string
+	err := h.call(method, args...).Store(&devices)
+	return devices, err
+}
+
+func (h *Hardware) ListSerialPorts() ([]map[string]string, error) {
+	return h.list("ListSerialPorts")
+}
+
+func (h *Hardware) ListVideoDevices() ([]map[string]string, error) {
+	return h.list("ListVideoDevices")
+}
+
+func (h *Hardware) ListAudioDevices() ([]map[string]string, error) {
+	return h.list("ListAudioDevices")
+}
+
+func (h *Hardware) ScanI2CBus(bus uint32) ([]map[string]string, error) {
+	return h.list("ScanI2CBus", bus)
+}
+
+func (h *Hardware) RescanPCIBus() ([]map[string]string, error) {
+	return h.list("RescanPCIBus")
+}
+
+func (h *Hardware) ResetUSBDevice(devpath string) error {
+	return h.call("ResetUSBDevice", devpath).Err
+}
+
+func (h *Hardware) EnableSPI(enabled bool) error {
+	return h.call("EnableSPI", enabled).Err
+}
+
+func (h *Hardware) EnableCANOverlay(overlay string, oscillator uint32, interrupt uint32) error {
+	return h.call("EnableCANOverlay", overlay, oscillator, interrupt).Err
+}
+
+func (h *Hardware) DisableCANOverlay(overlay string) error {
+	return h.call("DisableCANOverlay", overlay).Err
+}
+
+func (h *Hardware) ConfigureCAN(iface string, bitrate uint32) error {
+	return h.call("ConfigureCAN", iface, bitrate).Err
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Jobs wraps the io.hass.os.Jobs interface.
+type Jobs struct {
+	object
+}
+
+func (c *Client) Jobs() *Jobs {
+	return &Jobs{object{c, objectPath + "/Jobs", busName + ".Jobs"}}
+}
+
+// Jobs returns the object paths of the running jobs.
+func (j *Jobs) Jobs() ([]dbus.ObjectPath, error) {
+	var jobs []dbus.ObjectPath
+	err := j.property("Jobs", &jobs)
+	return jobs, err
+}
+
+// Job wraps the io.hass.os.Job interface of a long running operation, as
+// returned by methods like CloneDataDisk. Subscribe to "Completed" to wait
+// for it.
+type Job struct {
+	object
+}
+
+func (j *Jobs) Job(path dbus.ObjectPath) *Job {
+	return &Job{object{j.client, path, busName + ".Job"}}
+}
+
+func (j *Job) Operation() (string, error) {
+	var operation string
+	err := j.property("Operation", &operation)
+	return operation, err
+}
+
+func (j *Job) State() (string, error) {
+	var state string
+	err := j.property("State", &state)
+	return state, err
+}
+
+// Progress returns the progress in percent.
+func (j *Job) Progress() (uint32, error) {
+	var progress uint32
+	err := j.property("Progress", &progress)
+	return progress, err
+}
+
+func (j *Job) Cancel() error {
+	return j.call("Cancel").Err
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Network wraps the io.hass.os.Network interface.
+type Network struct {
+	object
+}
+
+func (c *Client) Network() *Network {
+	return &Network{object{c, objectPath + "/Network", busName + ".Network"}}
+}
+
+func (n *Network) Hostname() (string, error) {
+	var hostname string
+	err := n.property("Hostname", &hostname)
+	return hostname, err
+}
+
+func (n *Network) SetHostname(hostname string) error {
+	return n.call("SetHostname", hostname).Err
+}
+
+func (n *Network) MDNSHostname() (string, error) {
+	var hostname string
+	err := n.property("MDNSHostname", &hostname)
+	return hostname, err
+}
+
+// CheckConnectivity probes the connectivity endpoints, the result holds
+// the per endpoint status.
+func (n *Network) CheckConnectivity() (map[string]dbus.Variant, error) {
+	var result map[string]dbus.Variant
+	err := n.call("CheckConnectivity").Store(&result)
+	return result, err
+}
+
+func (n *Network) SetConnectivityEndpoints(endpoints []string) error {
+	return n.call("SetConnectivityEndpoints", endpoints).Err
+}
+
+func (n *Network) SetFallbackIP(iface string, mode string, address string) error {
+	return n.call("SetFallbackIP", iface, mode, address).Err
+}
+
+func (n *Network) SetMTU(iface string, mtu uint32) error {
+	return n.call("SetMTU", iface, mtu).Err
+}
+
+func (n *Network) SetOffload(iface string, feature string, enabled bool) error {
+	return n.call("SetOffload", iface, feature, enabled).Err
+}
+
+func (n *Network) SetWakeOnLAN(iface string, mode string) error {
+	return n.call("SetWakeOnLAN", iface, mode).Err
+}
+
+func (n *Network) SetRegulatoryDomain(country string) error {
+	return n.call("SetRegulatoryDomain", country).Err
+}
+
+// Modems returns the modems known to ModemManager by index.
+func (n *Network) Modems() (map[string]map[string]string, error) {
+	var modems map[string]map[string]string
+	err := n.property("Modems", &modems)
+	return modems, err
+}
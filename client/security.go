@@ -0,0 +1,44 @@
+package client
+
+// Security wraps the io.hass.os.Security interface.
+type Security struct {
+	object
+}
+
+func (c *Client) Security() *Security {
+	return &Security{object{c, objectPath + "/Security", busName + ".Security"}}
+}
+
+func (s *Security) SecureBoot() (bool, error) {
+	var enabled bool
+	err := s.property("SecureBoot", &enabled)
+	return enabled, err
+}
+
+func (s *Security) TPMPresent() (bool, error) {
+	var present bool
+	err := s.property("TPMPresent", &present)
+	return present, err
+}
+
+func (s *Security) TPMOwned() (bool, error) {
+	var owned bool
+	err := s.property("TPMOwned", &owned)
+	return owned, err
+}
+
+func (s *Security) ClearTPM() error {
+	return s.call("ClearTPM").Err
+}
+
+func (s *Security) ProvisionTPM(ownerAuth string) error {
+	return s.call("ProvisionTPM", ownerAuth).Err
+}
+
+// Quote returns a TPM quote over the given PCRs, with the keys "quote",
+// "signature", "pcrs" and "ak".
+func (s *Security) Quote(nonce []byte, pcrs []uint32) (map[string][]byte, error) {
+	var quote map[string][]byte
+	err := s.call("Quote", nonce, pcrs).Store(&quote)
+	return quote, err
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Subscription delivers signals until Close is called.
+type Subscription struct {
+	C <-chan *dbus.Signal
+
+	conn    *dbus.Conn
+	ch      chan *dbus.Signal
+	options []dbus.MatchOption
+}
+
+// Close stops the subscription, no further signals are delivered to C.
+func (s *Subscription) Close() error {
+	s.conn.RemoveSignal(s.ch)
+	return s.conn.RemoveMatchSignal(s.options...)
+}
+
+func (c *Client) subscribe(options ...dbus.MatchOption) (*Subscription, error) {
+	err := c.conn.AddMatchSignal(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *dbus.Signal, 10)
+	c.conn.Signal(ch)
+	return &Subscription{C: ch, conn: c.conn, ch: ch, options: options}, nil
+}
+
+// Subscribe delivers a signal of the object, e.g. "PrepareForShutdown" of
+// System. Note that the channel receives all signals of the connection
+// matching any subscription, so filter on Name if subscribing repeatedly.
+func (o object) Subscribe(signal string) (*Subscription, error) {
+	return o.client.subscribe(
+		dbus.WithMatchObjectPath(o.path),
+		dbus.WithMatchInterface(o.iface),
+		dbus.WithMatchMember(signal),
+	)
+}
+
+// WatchProperties delivers PropertiesChanged signals of the object. Body[1]
+// of each signal holds the changed properties as map[string]dbus.Variant.
+func (o object) WatchProperties() (*Subscription, error) {
+	return o.client.subscribe(
+		dbus.WithMatchObjectPath(o.path),
+		dbus.WithMatchInterface(propsIface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchOption("arg0", o.iface),
+	)
+}
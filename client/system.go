@@ -0,0 +1,65 @@
+package client
+
+import (
+	"time"
+)
+
+// System wraps the io.hass.os.System interface.
+type System struct {
+	object
+}
+
+func (c *Client) System() *System {
+	return &System{object{c, objectPath + "/System", busName + ".System"}}
+}
+
+func (s *System) WipeDevice() error {
+	return s.call("WipeDevice").Err
+}
+
+func (s *System) ScheduleWipeDevice() error {
+	return s.call("ScheduleWipeDevice").Err
+}
+
+func (s *System) WipeScheduled() (bool, error) {
+	var scheduled bool
+	err := s.property("WipeScheduled", &scheduled)
+	return scheduled, err
+}
+
+func (s *System) AddSSHAuthKey(key string) error {
+	return s.call("AddSSHAuthKey", key).Err
+}
+
+// RemoveSSHAuthKey removes a key, returns false if it wasn't authorized.
+func (s *System) RemoveSSHAuthKey(key string) (bool, error) {
+	var removed bool
+	err := s.call("RemoveSSHAuthKey", key).Store(&removed)
+	return removed, err
+}
+
+func (s *System) ClearSSHAuthKeys() error {
+	return s.call("ClearSSHAuthKeys").Err
+}
+
+func (s *System) SSHAuthKeys() ([]string, error) {
+	var keys []string
+	err := s.property("SSHAuthKeys", &keys)
+	return keys, err
+}
+
+func (s *System) Reboot() error {
+	return s.call("Reboot").Err
+}
+
+func (s *System) PowerOff() error {
+	return s.call("PowerOff").Err
+}
+
+func (s *System) ScheduleReboot(when time.Time, reason string) error {
+	return s.call("ScheduleReboot", when.Unix(), reason).Err
+}
+
+func (s *System) CancelScheduledReboot() error {
+	return s.call("CancelScheduledReboot").Err
+}
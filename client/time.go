@@ -0,0 +1,62 @@
+package client
+
+import (
+	"time"
+)
+
+// Time wraps the io.hass.os.Time interface.
+type Time struct {
+	object
+}
+
+func (c *Client) Time() *Time {
+	return &Time{object{c, objectPath + "/Time", busName + ".Time"}}
+}
+
+func (t *Time) Timezone() (string, error) {
+	var timezone string
+	err := t.property("Timezone", &timezone)
+	return timezone, err
+}
+
+func (t *Time) SetTimezone(timezone string) error {
+	return t.call("SetTimezone", timezone).Err
+}
+
+func (t *Time) NTPSynchronized() (bool, error) {
+	var synchronized bool
+	err := t.property("NTPSynchronized", &synchronized)
+	return synchronized, err
+}
+
+func (t *Time) NTPServers() ([]string, error) {
+	var servers []string
+	err := t.property("NTPServers", &servers)
+	return servers, err
+}
+
+func (t *Time) SetNTPServers(servers []string) error {
+	return t.call("SetNTPServers", servers).Err
+}
+
+func (t *Time) ReloadNTPStatus() error {
+	return t.call("ReloadNTPStatus").Err
+}
+
+func (t *Time) ReadRTC() (time.Time, error) {
+	var timestamp int64
+	err := t.call("ReadRTC").Store(&timestamp)
+	return time.Unix(timestamp, 0), err
+}
+
+func (t *Time) WriteRTC() error {
+	return t.call("WriteRTC").Err
+}
+
+// GetRTCDrift returns how far the RTC is ahead of the system clock, the RTC
+// has a resolution of a second.
+func (t *Time) GetRTCDrift() (time.Duration, error) {
+	var drift int64
+	err := t.call("GetRTCDrift").Store(&drift)
+	return time.Duration(drift) * time.Second, err
+}
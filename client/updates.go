@@ -0,0 +1,39 @@
+package client
+
+// Updates wraps the io.hass.os.Updates interface.
+type Updates struct {
+	object
+}
+
+func (c *Client) Updates() *Updates {
+	return &Updates{object{c, objectPath + "/Updates", busName + ".Updates"}}
+}
+
+func (u *Updates) BootSlot() (string, error) {
+	var slot string
+	err := u.property("BootSlot", &slot)
+	return slot, err
+}
+
+// Slots returns the status of the RAUC slots by slot name.
+func (u *Updates) Slots() (map[string]map[string]string, error) {
+	var slots map[string]map[string]string
+	err := u.property("Slots", &slots)
+	return slots, err
+}
+
+func (u *Updates) ReloadSlotStatus() error {
+	return u.call("ReloadSlotStatus").Err
+}
+
+func (u *Updates) MarkSlotGood() error {
+	return u.call("MarkSlotGood").Err
+}
+
+func (u *Updates) MarkSlotBad(slot string) error {
+	return u.call("MarkSlotBad", slot).Err
+}
+
+func (u *Updates) RollbackOSSlot() error {
+	return u.call("RollbackOSSlot").Err
+}
@@ -0,0 +1,288 @@
+// Command mock-gen writes mocks for the interfaces declared in a Go source
+// file. Every method of a mock calls a function field of the same name with
+// a "Func" suffix, so tests set only the behavior they need, and mocks carry
+// no dependency on a mocking library.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const modulePath = "github.com/home-assistant/os-agent"
+
+var predeclared = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true,
+	"rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+}
+
+type generator struct {
+	// Name of the package declaring the interfaces
+	source string
+	// Import paths by the name they are referenced with
+	imports map[string]string
+	used    map[string]bool
+}
+
+// typeString renders a type expression, qualifying types of the source
+// package with its name.
+func (g *generator) typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if predeclared[t.Name] || !ast.IsExported(t.Name) {
+			return t.Name
+		}
+		g.used[g.source] = true
+		return g.source + "." + t.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			g.used[pkg.Name] = true
+		}
+		return g.typeString(t.X.(*ast.Ident)) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + g.typeString(t.X)
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return "[" + t.Len.(*ast.BasicLit).Value + "]" + g.typeString(t.Elt)
+		}
+		return "[]" + g.typeString(t.Elt)
+	case *ast.MapType:
+		return "map[" + g.typeString(t.Key) + "]" + g.typeString(t.Value)
+	case *ast.Ellipsis:
+		return "..." + g.typeString(t.Elt)
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + g.typeString(t.Value)
+		case ast.RECV:
+			return "<-chan " + g.typeString(t.Value)
+		}
+		return "chan " + g.typeString(t.Value)
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return "interface{}"
+		}
+	case *ast.FuncType:
+		params, _ := g.fieldList(t.Params, "")
+		results, _ := g.fieldList(t.Results, "")
+		return "func(" + params + ")" + resultString(results, t.Results)
+	}
+	fmt.Fprintf(os.Stderr, "Unsupported type %T\n", expr)
+	os.Exit(1)
+	return ""
+}
+
+// fieldList renders parameters, naming unnamed ones with prefix, and returns
+// the argument list to pass them on.
+func (g *generator) fieldList(fields *ast.FieldList, prefix string) (string, string) {
+	if fields == nil {
+		return "", ""
+	}
+
+	var decls, args []string
+	for i, field := range fields.List {
+		typ := g.typeString(field.Type)
+		spread := ""
+		if _, ok := field.Type.(*ast.Ellipsis); ok {
+			spread = "..."
+		}
+
+		names := field.Names
+		if len(names) == 0 && prefix != "" {
+			names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("%s%d", prefix, i))}
+		}
+		if len(names) == 0 {
+			decls = append(decls, typ)
+			continue
+		}
+		for _, name := range names {
+			decls = append(decls, name.Name+" "+typ)
+			args = append(args, name.Name+spread)
+		}
+	}
+	return strings.Join(decls, ", "), strings.Join(args, ", ")
+}
+
+func resultString(results string, fields *ast.FieldList) string {
+	if results == "" {
+		return ""
+	}
+	if len(fields.List) == 1 && len(fields.List[0].Names) == 0 {
+		return " " + results
+	}
+	return " (" + results + ")"
+}
+
+func (g *generator) writeMock(out *bytes.Buffer, name string, iface *ast.InterfaceType) {
+	mock := "Mock" + name
+
+	var methods []*ast.Field
+	for _, method := range iface.Methods.List {
+		if _, ok := method.Type.(*ast.FuncType); !ok || len(method.Names) == 0 {
+			fmt.Fprintf(os.Stderr, "Embedded interfaces are not supported in %s\n", name)
+			os.Exit(1)
+		}
+		methods = append(methods, method)
+	}
+
+	fmt.Fprintf(out, "// %s is a mock of %s.%s.\n", mock, g.source, name)
+	fmt.Fprintf(out, "type %s struct {\n", mock)
+	for _, method := range methods {
+		fmt.Fprintf(out, "\t%sFunc %s\n", method.Names[0].Name, g.typeString(method.Type))
+	}
+	fmt.Fprintf(out, "}\n\n")
+
+	for _, method := range methods {
+		fn := method.Type.(*ast.FuncType)
+		methodName := method.Names[0].Name
+		params, args := g.fieldList(fn.Params, "p")
+		results, _ := g.fieldList(fn.Results, "")
+
+		fmt.Fprintf(out, "func (m *%s) %s(%s)%s {\n", mock, methodName, params, resultString(results, fn.Results))
+		fmt.Fprintf(out, "\tif m.%sFunc == nil {\n", methodName)
+		fmt.Fprintf(out, "\t\tpanic(\"%s.%s called but not set\")\n", mock, methodName)
+		fmt.Fprintf(out, "\t}\n")
+		if results != "" {
+			fmt.Fprintf(out, "\treturn m.%sFunc(%s)\n", methodName, args)
+		} else {
+			fmt.Fprintf(out, "\tm.%sFunc(%s)\n", methodName, args)
+		}
+		fmt.Fprintf(out, "}\n\n")
+	}
+}
+
+// sourceImportPath derives the import path of the directory holding the
+// source file from the module root.
+func sourceImportPath(source string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(source))
+	if err != nil {
+		return "", err
+	}
+	for root := dir; root != filepath.Dir(root); root = filepath.Dir(root) {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			return filepath.ToSlash(filepath.Join(modulePath, rel)), nil
+		}
+	}
+	return "", fmt.Errorf("No go.mod found above %s", dir)
+}
+
+func main() {
+	source := flag.String("source", "", "Go source file declaring the interfaces")
+	destination := flag.String("destination", "", "File to write the mocks to")
+	pkg := flag.String("package", "mocks", "Package of the mocks")
+	flag.Parse()
+
+	if *source == "" || *destination == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, *source, nil, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	importPath, err := sourceImportPath(*source)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	g := &generator{
+		source:  file.Name.Name,
+		imports: map[string]string{file.Name.Name: importPath},
+		used:    map[string]bool{},
+	}
+	for _, spec := range file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		} else if strings.HasPrefix(name, "v") && strings.Trim(name[1:], "0123456789") == "" {
+			// Major version suffix, e.g. github.com/godbus/dbus/v5
+			name = filepath.Base(filepath.Dir(path))
+		}
+		g.imports[name] = path
+	}
+
+	var body bytes.Buffer
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if iface, ok := typeSpec.Type.(*ast.InterfaceType); ok && typeSpec.Name.IsExported() {
+				g.writeMock(&body, typeSpec.Name.Name, iface)
+			}
+		}
+	}
+
+	var names []string
+	for name := range g.used {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return g.imports[names[i]] < g.imports[names[j]] })
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by mock-gen from %s. DO NOT EDIT.\n\n", filepath.Base(*source))
+	fmt.Fprintf(&out, "package %s\n\n", *pkg)
+	fmt.Fprintf(&out, "import (\n")
+	standard := true
+	for _, name := range names {
+		path, ok := g.imports[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown package %s\n", name)
+			os.Exit(1)
+		}
+		// Standard library imports first, separated from the others
+		if standard && strings.Contains(strings.Split(path, "/")[0], ".") {
+			if out.Bytes()[out.Len()-2] != '(' {
+				fmt.Fprintf(&out, "\n")
+			}
+			standard = false
+		}
+		if filepath.Base(path) == name {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		} else {
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		}
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't format mocks: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(*destination), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = ioutil.WriteFile(*destination, formatted, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,254 @@
+// Command os-agent-ctl calls the OS Agent from the command line.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/home-assistant/os-agent/client"
+	"github.com/home-assistant/os-agent/utils/busproxy"
+)
+
+const usage = `Usage: os-agent-ctl [--json] <command> [arguments]
+
+Commands:
+  version                            Show agent version
+  wipe [--dry-run] [--now]           Wipe data on next reboot (or right now)
+  datadisk list                      Show the data disk
+  datadisk move <device>             Move the data disk to device
+  sshkeys list                       List authorized SSH keys
+  sshkeys add <key>                  Authorize an SSH key
+  sshkeys remove <key>               Remove an authorized SSH key
+  sshkeys clear                      Remove all authorized SSH keys
+  leds list                          Show LED states (Yellow only)
+  leds set <Power|Disk|Heartbeat> <on|off>
+  call <object> <interface> <method> [json args...]
+`
+
+var jsonOutput bool
+
+func output(value interface{}, human string) {
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(value)
+		return
+	}
+	fmt.Println(human)
+}
+
+func fail(err error) {
+	if jsonOutput {
+		json.NewEncoder(os.Stderr).Encode(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	}
+	os.Exit(1)
+}
+
+func usageError() {
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(2)
+}
+
+func wipe(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("wipe", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only show what would be wiped")
+	now := flags.Bool("now", false, "wipe immediately instead of on next reboot")
+	flags.Parse(args)
+
+	device, err := c.DataDisk().CurrentDevice()
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		output(map[string]interface{}{"data_device": device, "now": *now},
+			fmt.Sprintf("Would wipe data and overlay partitions (data on %s)", device))
+		return nil
+	}
+
+	if *now {
+		err = c.System().WipeDevice()
+	} else {
+		err = c.System().ScheduleWipeDevice()
+	}
+	if err != nil {
+		return err
+	}
+	output(map[string]bool{"success": true}, "Done")
+	return nil
+}
+
+func datadisk(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		usageError()
+	}
+
+	switch args[0] {
+	case "list":
+		device, err := c.DataDisk().CurrentDevice()
+		if err != nil {
+			return err
+		}
+		moving, err := c.DataDisk().DataMoveScheduled()
+		if err != nil {
+			return err
+		}
+		output(map[string]interface{}{"current_device": device, "move_scheduled": moving},
+			fmt.Sprintf("Current device: %s\nMove scheduled: %t", device, moving))
+	case "move":
+		if len(args) != 2 {
+			usageError()
+		}
+		if err := c.DataDisk().ChangeDevice(args[1]); err != nil {
+			return err
+		}
+		output(map[string]bool{"success": true}, "Data disk will be moved on next reboot")
+	default:
+		usageError()
+	}
+	return nil
+}
+
+func sshkeys(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		usageError()
+	}
+
+	system := c.System()
+	switch args[0] {
+	case "list":
+		keys, err := system.SSHAuthKeys()
+		if err != nil {
+			return err
+		}
+		output(keys, strings.Join(keys, "\n"))
+	case "add":
+		if len(args) != 2 {
+			usageError()
+		}
+		if err := system.AddSSHAuthKey(args[1]); err != nil {
+			return err
+		}
+		output(map[string]bool{"success": true}, "Key added")
+	case "remove":
+		if len(args) != 2 {
+			usageError()
+		}
+		removed, err := system.RemoveSSHAuthKey(args[1])
+		if err != nil {
+			return err
+		}
+		message := "Key removed"
+		if !removed {
+			message = "Key not found"
+		}
+		output(map[string]bool{"removed": removed}, message)
+	case "clear":
+		if err := system.ClearSSHAuthKeys(); err != nil {
+			return err
+		}
+		output(map[string]bool{"success": true}, "All keys removed")
+	default:
+		usageError()
+	}
+	return nil
+}
+
+func leds(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		usageError()
+	}
+
+	yellow := c.Boards().Yellow()
+	switch args[0] {
+	case "list":
+		states := map[string]bool{}
+		var lines []string
+		for _, name := range []string{"Power", "Disk", "Heartbeat"} {
+			enabled, err := yellow.LED(name)
+			if err != nil {
+				return err
+			}
+			states[name] = enabled
+			lines = append(lines, fmt.Sprintf("%s: %t", name, enabled))
+		}
+		output(states, strings.Join(lines, "\n"))
+	case "set":
+		if len(args) != 3 || (args[2] != "on" && args[2] != "off") {
+			usageError()
+		}
+		if err := yellow.SetLED(args[1], args[2] == "on"); err != nil {
+			return err
+		}
+		output(map[string]bool{"success": true}, "LED updated, effective after reboot")
+	default:
+		usageError()
+	}
+	return nil
+}
+
+func call(c *client.Client, args []string) error {
+	if len(args) < 3 {
+		usageError()
+	}
+
+	var callArgs []interface{}
+	for _, arg := range args[3:] {
+		var value interface{}
+		if err := json.Unmarshal([]byte(arg), &value); err != nil {
+			return fmt.Errorf("Invalid JSON argument '%s': %s", arg, err)
+		}
+		callArgs = append(callArgs, value)
+	}
+
+	result, err := busproxy.Call(c.Conn(), args[0], args[1], args[2], callArgs)
+	if err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	output(result, string(data))
+	return nil
+}
+
+func main() {
+	flag.BoolVar(&jsonOutput, "json", false, "print JSON output")
+	flag.Usage = usageError
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usageError()
+	}
+
+	c, err := client.Connect()
+	if err != nil {
+		fail(err)
+	}
+
+	switch args[0] {
+	case "version":
+		var version string
+		if version, err = c.Version(); err == nil {
+			output(map[string]string{"version": version}, version)
+		}
+	case "wipe":
+		err = wipe(c, args[1:])
+	case "datadisk":
+		err = datadisk(c, args[1:])
+	case "sshkeys":
+		err = sshkeys(c, args[1:])
+	case "leds":
+		err = leds(c, args[1:])
+	case "call":
+		err = call(c, args[1:])
+	default:
+		usageError()
+	}
+
+	if err != nil {
+		fail(err)
+	}
+}
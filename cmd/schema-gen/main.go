@@ -0,0 +1,764 @@
+// Command schema-gen writes a JSON description of the OS Agent D-Bus API,
+// derived from the Go sources, so bindings can be generated and API changes
+// show up in review.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	schemaVersion = 1
+	failedError   = "org.freedesktop.DBus.Error.Failed"
+	deniedError   = "org.freedesktop.DBus.Error.AccessDenied"
+)
+
+type Arg struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Direction string `json:"direction,omitempty"`
+}
+
+type Method struct {
+	Name   string   `json:"name"`
+	Args   []Arg    `json:"args"`
+	Errors []string `json:"errors"`
+}
+
+type Signal struct {
+	Name string `json:"name"`
+	Args []Arg  `json:"args"`
+}
+
+type Property struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Writable bool   `json:"writable"`
+}
+
+type Interface struct {
+	Name       string     `json:"name"`
+	Object     string     `json:"object"`
+	Methods    []Method   `json:"methods"`
+	Signals    []Signal   `json:"signals"`
+	Properties []Property `json:"properties"`
+}
+
+type Schema struct {
+	SchemaVersion int         `json:"schema_version"`
+	Interfaces    []Interface `json:"interfaces"`
+}
+
+// pkg holds the parsed declarations of one Go package. Variables declared
+// anywhere in the package are tracked by name, which is good enough to find
+// the types of property values.
+type pkg struct {
+	root    string
+	files   []*ast.File
+	imports map[string]string
+	consts  map[string]string
+	types   map[string]ast.Expr
+	vars    map[string]ast.Expr
+	values  map[string]ast.Expr
+	funcs   map[string]*ast.FuncDecl
+	methods []*ast.FuncDecl
+}
+
+const modulePath = "github.com/home-assistant/os-agent/"
+
+var packages = map[string]*pkg{}
+
+func parsePackage(root string, dir string) (*pkg, error) {
+	if p, ok := packages[dir]; ok {
+		return p, nil
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pkg{
+		root:    root,
+		imports: map[string]string{},
+		consts:  map[string]string{},
+		types:   map[string]ast.Expr{},
+		vars:    map[string]ast.Expr{},
+		values:  map[string]ast.Expr{},
+		funcs:   map[string]*ast.FuncDecl{},
+	}
+	packages[dir] = p
+	for _, parsed := range pkgs {
+		for _, file := range parsed.Files {
+			p.files = append(p.files, file)
+		}
+	}
+
+	for _, file := range p.files {
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := filepath.Base(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			p.imports[name] = path
+		}
+
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				p.funcs[fn.Name.Name] = fn
+				if fn.Recv != nil {
+					p.methods = append(p.methods, fn)
+				}
+				params := fn.Type.Params.List
+				if fn.Recv != nil {
+					params = append(append([]*ast.Field{}, fn.Recv.List...), params...)
+				}
+				for _, param := range params {
+					for _, name := range param.Names {
+						p.declare(name.Name, param.Type, nil)
+					}
+				}
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch d := n.(type) {
+			case *ast.TypeSpec:
+				p.types[d.Name.Name] = d.Type
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					s, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for i, name := range s.Names {
+						var value ast.Expr
+						if i < len(s.Values) {
+							value = s.Values[i]
+						}
+						if lit, ok := value.(*ast.BasicLit); ok && d.Tok == token.CONST && lit.Kind == token.STRING {
+							p.consts[name.Name], _ = strconv.Unquote(lit.Value)
+						}
+						p.declare(name.Name, s.Type, value)
+					}
+				}
+			case *ast.AssignStmt:
+				if d.Tok != token.DEFINE {
+					break
+				}
+				for i, lhs := range d.Lhs {
+					name, ok := lhs.(*ast.Ident)
+					if !ok || name.Name == "_" {
+						continue
+					}
+					if len(d.Rhs) == len(d.Lhs) {
+						p.declare(name.Name, nil, d.Rhs[i])
+					} else if call, ok := d.Rhs[0].(*ast.CallExpr); ok {
+						p.declare(name.Name, nil, resultOf(call, i))
+					}
+				}
+			}
+			return true
+		})
+	}
+	return p, nil
+}
+
+func (p *pkg) declare(name string, typ ast.Expr, value ast.Expr) {
+	if typ != nil {
+		if _, ok := p.vars[name]; !ok {
+			p.vars[name] = typ
+		}
+	} else if value != nil {
+		if _, ok := p.values[name]; !ok {
+			p.values[name] = value
+		}
+	}
+}
+
+func funcName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// resultOf stands for the i-th result of a multi-value call. It is resolved
+// lazily by typeOf, once all functions of the package are known.
+func resultOf(call *ast.CallExpr, i int) ast.Expr {
+	return &ast.IndexExpr{X: call, Index: &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(i)}}
+}
+
+// signature maps a Go type expression to its D-Bus signature.
+func (p *pkg) signature(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "bool":
+			return "b"
+		case "byte", "uint8":
+			return "y"
+		case "int16":
+			return "n"
+		case "uint16":
+			return "q"
+		case "int32", "int":
+			return "i"
+		case "uint32":
+			return "u"
+		case "int64":
+			return "x"
+		case "uint64":
+			return "t"
+		case "float64":
+			return "d"
+		case "string":
+			return "s"
+		}
+		if def, ok := p.types[t.Name]; ok {
+			return p.signature(def)
+		}
+	case *ast.StarExpr:
+		return p.signature(t.X)
+	case *ast.ArrayType:
+		return "a" + p.signature(t.Elt)
+	case *ast.MapType:
+		return "a{" + p.signature(t.Key) + p.signature(t.Value) + "}"
+	case *ast.StructType:
+		sig := "("
+		for _, field := range t.Fields.List {
+			for range field.Names {
+				sig += p.signature(field.Type)
+			}
+		}
+		return sig + ")"
+	case *ast.SelectorExpr:
+		switch t.Sel.Name {
+		case "Variant":
+			return "v"
+		case "ObjectPath":
+			return "o"
+		case "UnixFD":
+			return "h"
+		case "Signature":
+			return "g"
+		}
+	}
+	return "?"
+}
+
+func isSender(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Sender"
+}
+
+func isDBusError(expr ast.Expr) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Error"
+}
+
+func stringList(expr ast.Expr) []string {
+	var list []string
+	if lit, ok := expr.(*ast.CompositeLit); ok {
+		for _, elt := range lit.Elts {
+			if s, ok := elt.(*ast.BasicLit); ok {
+				value, _ := strconv.Unquote(s.Value)
+				list = append(list, value)
+			}
+		}
+	}
+	return list
+}
+
+// argNames returns the methodArgNames table of the package, merged with the
+// tables of child objects such as jobMethodArgNames.
+func (p *pkg) argNames() map[string][]string {
+	names := map[string][]string{}
+	for name, value := range p.values {
+		lit, ok := value.(*ast.CompositeLit)
+		if !ok || (name != "methodArgNames" && !strings.HasSuffix(name, "MethodArgNames")) {
+			continue
+		}
+		for _, elt := range lit.Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			key, _ := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
+			names[key] = stringList(kv.Value)
+		}
+	}
+	return names
+}
+
+func usesPolkit(fn *ast.FuncDecl) bool {
+	found := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "CheckAuthorization" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func (p *pkg) methodsOf(receiver string) []Method {
+	names := p.argNames()
+	methods := []Method{}
+
+	for _, fn := range p.methods {
+		recv, ok := fn.Recv.List[0].Type.(*ast.Ident)
+		if !ok || recv.Name != receiver || !fn.Name.IsExported() || fn.Type.Results == nil {
+			continue
+		}
+		results := fn.Type.Results.List
+		if !isDBusError(results[len(results)-1].Type) {
+			continue
+		}
+
+		args := []Arg{}
+		for _, param := range fn.Type.Params.List {
+			if isSender(param.Type) {
+				continue
+			}
+			for _, name := range param.Names {
+				args = append(args, Arg{Name: name.Name, Type: p.signature(param.Type), Direction: "in"})
+			}
+		}
+		for _, result := range results[:len(results)-1] {
+			args = append(args, Arg{Type: p.signature(result.Type), Direction: "out"})
+		}
+		if n, ok := names[fn.Name.Name]; ok && len(n) == len(args) {
+			for i := range args {
+				args[i].Name = n[i]
+			}
+		}
+
+		errors := []string{failedError}
+		if usesPolkit(fn) {
+			errors = append(errors, deniedError)
+		}
+		methods = append(methods, Method{Name: fn.Name.Name, Args: args, Errors: errors})
+	}
+
+	// Methods promoted from embedded structs
+	if st, ok := p.types[receiver].(*ast.StructType); ok {
+		for _, field := range st.Fields.List {
+			embedded, ok := field.Type.(*ast.Ident)
+			if !ok || len(field.Names) > 0 {
+				continue
+			}
+			for _, method := range p.methodsOf(embedded.Name) {
+				if !hasMethod(methods, method.Name) {
+					methods = append(methods, method)
+				}
+			}
+		}
+	}
+
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
+func hasMethod(methods []Method, name string) bool {
+	for _, method := range methods {
+		if method.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// valueType derives the D-Bus type of a property from its initial value.
+func (p *pkg) valueType(expr ast.Expr) string {
+	typ, owner := p.typeOf(expr)
+	if typ == nil {
+		return "?"
+	}
+	return owner.signature(typ)
+}
+
+var untypedTypes = map[token.Token]string{
+	token.STRING: "string",
+	token.INT:    "int",
+	token.FLOAT:  "float64",
+}
+
+// typeOf returns the type of an expression and the package it is declared in.
+func (p *pkg) typeOf(expr ast.Expr) (ast.Expr, *pkg) {
+	switch v := expr.(type) {
+	case *ast.BasicLit:
+		return ast.NewIdent(untypedTypes[v.Kind]), p
+	case *ast.Ident:
+		if v.Name == "true" || v.Name == "false" {
+			return ast.NewIdent("bool"), p
+		}
+		if typ, ok := p.vars[v.Name]; ok {
+			return typ, p
+		}
+		if value, ok := p.values[v.Name]; ok {
+			return p.typeOf(value)
+		}
+	case *ast.UnaryExpr:
+		return p.typeOf(v.X)
+	case *ast.BinaryExpr:
+		switch v.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+			return ast.NewIdent("bool"), p
+		}
+		return p.typeOf(v.X)
+	case *ast.CompositeLit:
+		return v.Type, p
+	case *ast.IndexExpr:
+		// Result of a multi-value call, see resultOf.
+		if call, ok := v.X.(*ast.CallExpr); ok {
+			index, _ := strconv.Atoi(v.Index.(*ast.BasicLit).Value)
+			return p.callResult(call, index)
+		}
+	case *ast.CallExpr:
+		return p.callResult(v, 0)
+	case *ast.SelectorExpr:
+		typ, owner := p.typeOf(v.X)
+		if typ == nil {
+			return nil, nil
+		}
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if ident, ok := typ.(*ast.Ident); ok {
+			if st, ok := owner.types[ident.Name].(*ast.StructType); ok {
+				for _, field := range st.Fields.List {
+					for _, name := range field.Names {
+						if name.Name == v.Sel.Name {
+							return field.Type, owner
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (p *pkg) callResult(call *ast.CallExpr, index int) (ast.Expr, *pkg) {
+	owner := p
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if path, ok := p.imports[x.Name]; ok {
+				if !strings.HasPrefix(path, modulePath) {
+					return nil, nil
+				}
+				other, err := parsePackage(p.root, filepath.Join(p.root, strings.TrimPrefix(path, modulePath)))
+				if err != nil {
+					return nil, nil
+				}
+				owner = other
+			}
+		}
+	}
+
+	// Type conversions
+	if ident, ok := call.Fun.(*ast.Ident); ok && owner.signature(ident) != "?" {
+		return ident, owner
+	}
+
+	fn, ok := owner.funcs[funcName(call)]
+	if !ok || fn.Type.Results == nil {
+		return nil, nil
+	}
+	i := 0
+	for _, result := range fn.Type.Results.List {
+		count := len(result.Names)
+		if count == 0 {
+			count = 1
+		}
+		if index < i+count {
+			return result.Type, owner
+		}
+		i += count
+	}
+	return nil, nil
+}
+
+func fieldValue(lit *ast.CompositeLit, name string) ast.Expr {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+				return kv.Value
+			}
+		}
+	}
+	return nil
+}
+
+// isChildIface reports whether a constant names the interface of a child
+// object, see main.
+func isChildIface(name string) bool {
+	return strings.HasSuffix(name, "IfaceName")
+}
+
+// properties collects the prop.Prop literals of an interface. Child object
+// interfaces only get the literals in the property map keyed by their
+// constant, the main interface all others.
+func (p *pkg) properties(iface string) []Property {
+	var props []Property
+	for _, file := range p.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if kv, ok := n.(*ast.KeyValueExpr); ok {
+				if ident, ok := kv.Key.(*ast.Ident); ok && isChildIface(ident.Name) {
+					if ident.Name == iface {
+						ast.Inspect(kv.Value, func(n ast.Node) bool { return p.property(n, &props) })
+					}
+					return false
+				}
+			}
+			return isChildIface(iface) || p.property(n, &props)
+		})
+	}
+
+	sort.Slice(props, func(i, j int) bool { return props[i].Name < props[j].Name })
+	return props
+}
+
+// property appends n to props if it is a prop.Prop literal keyed by its name.
+func (p *pkg) property(n ast.Node, props *[]Property) bool {
+	kv, ok := n.(*ast.KeyValueExpr)
+	if !ok {
+		return true
+	}
+	key, ok := kv.Key.(*ast.BasicLit)
+	lit, isLit := kv.Value.(*ast.CompositeLit)
+	if !ok || !isLit || fieldValue(lit, "Writable") == nil {
+		return true
+	}
+
+	name, _ := strconv.Unquote(key.Value)
+	writable := false
+	if ident, ok := fieldValue(lit, "Writable").(*ast.Ident); ok {
+		writable = ident.Name == "true"
+	}
+	*props = append(*props, Property{Name: name, Type: p.valueType(fieldValue(lit, "Value")), Writable: writable})
+	return true
+}
+
+// signals collects the introspect.Signal literals of the package, keyed by
+// the name of the package level variable holding them.
+func (p *pkg) signals() map[string][]Signal {
+	signals := map[string][]Signal{}
+	for _, file := range p.files {
+		for _, decl := range file.Decls {
+			holder := ""
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.VAR {
+				if spec, ok := gen.Specs[0].(*ast.ValueSpec); ok && len(gen.Specs) == 1 {
+					holder = spec.Names[0].Name
+				}
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				lit, ok := n.(*ast.CompositeLit)
+				if !ok || fieldValue(lit, "Name") == nil || fieldValue(lit, "Args") == nil {
+					return true
+				}
+				if sel, ok := lit.Type.(*ast.SelectorExpr); ok && sel.Sel.Name != "Signal" {
+					return true
+				}
+
+				name, _ := strconv.Unquote(fieldValue(lit, "Name").(*ast.BasicLit).Value)
+				signal := Signal{Name: name, Args: []Arg{}}
+				for _, elt := range fieldValue(lit, "Args").(*ast.CompositeLit).Elts {
+					arg := elt.(*ast.CompositeLit)
+					argName, _ := strconv.Unquote(fieldValue(arg, "Name").(*ast.BasicLit).Value)
+					argType, _ := strconv.Unquote(fieldValue(arg, "Type").(*ast.BasicLit).Value)
+					signal.Args = append(signal.Args, Arg{Name: argName, Type: argType})
+				}
+				signals[holder] = append(signals[holder], signal)
+				return false
+			})
+		}
+	}
+	return signals
+}
+
+// signalRefs returns the identifiers used in the Signals field of the
+// introspection data of an interface.
+func (p *pkg) signalRefs(iface string) map[string]bool {
+	refs := map[string]bool{}
+	for _, file := range p.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			name, ok := fieldValue(lit, "Name").(*ast.Ident)
+			if !ok || name.Name != iface || fieldValue(lit, "Signals") == nil {
+				return true
+			}
+			ast.Inspect(fieldValue(lit, "Signals"), func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok {
+					refs[ident.Name] = true
+				}
+				return true
+			})
+			return false
+		})
+	}
+	return refs
+}
+
+// interfaceSignals picks the signals of an interface. Versioned interfaces
+// only get the signals they reference, the base interface all others.
+func (p *pkg) interfaceSignals(iface string, versioned []string) []Signal {
+	refs := p.signalRefs(iface)
+	result := []Signal{}
+	for holder, signals := range p.signals() {
+		include := refs[holder]
+		if iface == "ifaceName" || iface == "busName" {
+			include = true
+			for _, other := range versioned {
+				if holder != "" && !refs[holder] && p.signalRefs(other)[holder] {
+					include = false
+				}
+			}
+		}
+		if include {
+			result = append(result, signals...)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// receiver finds the type exported on the bus, the struct holding the D-Bus
+// connection.
+func (p *pkg) receiver() string {
+	for name, def := range p.types {
+		st, ok := def.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range st.Fields.List {
+			if len(field.Names) > 0 && field.Names[0].Name == "conn" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func main() {
+	root := flag.String("root", ".", "repository root")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	var dirs []string
+	filepath.Walk(*root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && (path == *root || !strings.HasPrefix(info.Name(), ".")) {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	schema := Schema{SchemaVersion: schemaVersion, Interfaces: []Interface{}}
+	for _, dir := range dirs {
+		p, err := parsePackage(*root, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", dir, err)
+			os.Exit(1)
+		}
+
+		ifaceConst := "ifaceName"
+		if _, ok := p.consts[ifaceConst]; !ok {
+			ifaceConst = "busName"
+		}
+		iface, ok := p.consts[ifaceConst]
+		object, hasObject := p.consts["objectPath"]
+		// Only packages serving an object, not the client side.
+		_, serves := p.funcs["InitializeDBus"]
+		if !ok || !hasObject || !serves || !strings.HasPrefix(object, "/io/hass/os") {
+			continue
+		}
+
+		// Versioned interfaces, e.g. ifaceName2 served by the system2 type.
+		// Child objects below the package object, e.g. jobIfaceName served
+		// by the jobObject type on /io/hass/os/Jobs/N.
+		var versioned, children []string
+		for name := range p.consts {
+			suffix := strings.TrimPrefix(name, ifaceConst)
+			if _, err := strconv.Atoi(suffix); err == nil && suffix != name {
+				versioned = append(versioned, name)
+			}
+			if _, ok := p.types[strings.TrimSuffix(name, "IfaceName")+"Object"]; ok && isChildIface(name) {
+				children = append(children, name)
+			}
+		}
+
+		methods := []Method{}
+		receiver := p.receiver()
+		if receiver != "" {
+			methods = p.methodsOf(receiver)
+		}
+		schema.Interfaces = append(schema.Interfaces, Interface{
+			Name:       iface,
+			Object:     object,
+			Methods:    methods,
+			Signals:    p.interfaceSignals(ifaceConst, append(versioned, children...)),
+			Properties: append([]Property{}, p.properties(ifaceConst)...),
+		})
+
+		for _, name := range children {
+			schema.Interfaces = append(schema.Interfaces, Interface{
+				Name:       p.consts[name],
+				Object:     object + "/*",
+				Methods:    p.methodsOf(strings.TrimSuffix(name, "IfaceName") + "Object"),
+				Signals:    p.interfaceSignals(name, nil),
+				Properties: append([]Property{}, p.properties(name)...),
+			})
+		}
+
+		for _, name := range versioned {
+			schema.Interfaces = append(schema.Interfaces, Interface{
+				Name:       p.consts[name],
+				Object:     object,
+				Methods:    p.methodsOf(receiver + strings.TrimPrefix(name, ifaceConst)),
+				Signals:    p.interfaceSignals(name, nil),
+				Properties: []Property{},
+			})
+		}
+	}
+	sort.Slice(schema.Interfaces, func(i, j int) bool { return schema.Interfaces[i].Name < schema.Interfaces[j].Name })
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err = ioutil.WriteFile(*out, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
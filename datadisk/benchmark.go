@@ -0,0 +1,133 @@
+package datadisk
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	benchmarkSeqBlockSize  = 1024 * 1024
+	benchmarkSeqMaxBytes   = 512 * 1024 * 1024
+	benchmarkRandBlockSize = 4096
+	benchmarkMaxDuration   = 10 * time.Second
+)
+
+// alignedBuffer returns a page aligned buffer as required by O_DIRECT.
+func alignedBuffer(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func benchmarkSequential(file *os.File, size int64) (float64, error) {
+	buf, err := alignedBuffer(benchmarkSeqBlockSize)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Munmap(buf)
+
+	var total int64
+	start := time.Now()
+	for total+benchmarkSeqBlockSize <= size && total < benchmarkSeqMaxBytes && time.Since(start) < benchmarkMaxDuration {
+		n, err := file.ReadAt(buf, total)
+		total += int64(n)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return float64(total) / (1024 * 1024) / time.Since(start).Seconds(), nil
+}
+
+func benchmarkRandom(file *os.File, size int64) (float64, error) {
+	buf, err := alignedBuffer(benchmarkRandBlockSize)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Munmap(buf)
+
+	blocks := size / benchmarkRandBlockSize
+	if blocks == 0 {
+		return 0, fmt.Errorf("Device too small")
+	}
+
+	ops := 0
+	start := time.Now()
+	for time.Since(start) < benchmarkMaxDuration {
+		offset := rand.Int63n(blocks) * benchmarkRandBlockSize
+		if _, err := file.ReadAt(buf, offset); err != nil {
+			return 0, err
+		}
+		ops++
+	}
+
+	return float64(ops) / time.Since(start).Seconds(), nil
+}
+
+// BenchmarkDisk measures sequential read throughput (MB/s) and random 4k read
+// IOPS of a block device, bypassing the page cache. Only reads are performed
+// so it is safe to run against disks in use. Each test is bounded in time,
+// the run is tracked by a benchmark job.
+func (d datadisk) BenchmarkDisk(device string) (map[string]float64, *dbus.Error) {
+	logging.Info.Printf("Benchmark disk %s.", device)
+
+	if !strings.HasPrefix(device, "/dev/") {
+		return nil, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Invalid device '%s'", device).WithDevice(device))
+	}
+	info, err := os.Stat(device)
+	if err != nil {
+		return nil, apierror.Failed(apierror.Wrap(apierror.CodeNotFound, err).WithDevice(device))
+	}
+	if info.Mode()&os.ModeDevice == 0 || info.Mode()&os.ModeCharDevice != 0 {
+		return nil, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "'%s' is not a block device", device).WithDevice(device))
+	}
+
+	job := jobs.Start("benchmark", false)
+	results, err := benchmarkDisk(job, device)
+	job.Finish(err)
+	if err != nil {
+		return nil, apierror.Failed(deviceError(device, err))
+	}
+	return results, nil
+}
+
+func benchmarkDisk(job *jobs.Job, device string) (map[string]float64, error) {
+	file, err := os.OpenFile(device, os.O_RDONLY|syscall.O_DIRECT, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open '%s': %s", device, err)
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	job.SetStage("sequential_read")
+	seq, err := benchmarkSequential(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("Sequential read test failed: %s", err)
+	}
+	job.SetProgress(50)
+	job.SetStage("random_read")
+	iops, err := benchmarkRandom(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("Random read test failed: %s", err)
+	}
+
+	logging.Info.Printf("Disk %s: %.1f MB/s sequential, %.0f IOPS random.", device, seq, iops)
+	return map[string]float64{
+		"sequential_read_mbps": seq,
+		"random_read_iops":     iops,
+		"random_read_mbps":     iops * benchmarkRandBlockSize / (1024 * 1024),
+	}, nil
+}
@@ -0,0 +1,267 @@
+package datadisk
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/copier"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	cloneLabel          = "hassos-data-clone"
+	bootLabel           = "hassos-boot"
+	cloneMountDirectory = "/run/os-agent/clone"
+	cloneSnapshot       = "/mnt/data/.os-agent-clone"
+	cloneStateFile      = cloneMountDirectory + "/.os-agent-clone-state.json"
+	btrfsCmd            = "btrfs"
+	systemctlCmd        = "systemctl"
+)
+
+// Writers of the data disk, in stop order. They are stopped while cloning
+// a filesystem which can't be snapshotted.
+var dataWriterUnits = []string{"hassos-supervisor.service", "docker.service"}
+
+var (
+	cloneMutex   sync.Mutex
+	cloneRunning bool
+
+	cloneSignals = []introspect.Signal{
+		{
+			Name: "CloneProgress",
+			Args: []introspect.Arg{
+				{Name: "percentage", Type: "u"},
+			},
+		},
+		{
+			Name: "CloneFinished",
+			Args: []introspect.Arg{
+				{Name: "success", Type: "b"},
+				{Name: "message", Type: "s"},
+			},
+		},
+	}
+)
+
+func partitionDevice(device string, number int) string {
+	last := device[len(device)-1]
+	if last >= '0' && last <= '9' {
+		return fmt.Sprintf("%sp%d", device, number)
+	}
+	return fmt.Sprintf("%s%d", device, number)
+}
+
+func usedBytes(path string) uint64 {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0
+	}
+	return (stat.Blocks - stat.Bfree) * uint64(stat.Bsize)
+}
+
+// copyDataDisk copies source to the mounted clone and verifies the copy
+// against the checksums taken while copying. The copy state is kept on the
+// clone until then, so an interrupted clone can be resumed.
+func (d datadisk) copyDataDisk(job *jobs.Job, source string) error {
+	engine := copier.New(source, cloneMountDirectory, cloneStateFile)
+	engine.Progress = func(percentage uint32) {
+		// Never report 100% before the copy is verified
+		if percentage > 99 {
+			percentage = 99
+		}
+		job.SetProgress(percentage)
+		err := d.conn.Emit(objectPath, ifaceName+".CloneProgress", percentage)
+		if err != nil {
+			logging.Warning.Printf("Can't emit CloneProgress signal: %s", err)
+		}
+	}
+
+	if err := engine.Copy(job.Context()); err != nil {
+		return fmt.Errorf("Copy failed: %s", err)
+	}
+	job.SetStage("verify")
+	if err := engine.Verify(job.Context()); err != nil {
+		return fmt.Errorf("Verification failed: %s", err)
+	}
+	return engine.RemoveState()
+}
+
+// stopDataWriters stops the Supervisor and all containers, so databases on
+// the data disk are closed and don't change during the copy. The returned
+// function starts them again.
+func stopDataWriters() (func(), error) {
+	start := func() {
+		for i := len(dataWriterUnits) - 1; i >= 0; i-- {
+			if out, err := exec.Command(systemctlCmd, "start", dataWriterUnits[i]).CombinedOutput(); err != nil {
+				logging.Error.Printf("Can't start %s: %s, output %s", dataWriterUnits[i], err, out)
+			}
+		}
+	}
+
+	for _, unit := range dataWriterUnits {
+		logging.Info.Printf("Stopping %s for a consistent clone.", unit)
+		// Waits until the unit is stopped
+		if out, err := exec.Command(systemctlCmd, "stop", unit).CombinedOutput(); err != nil {
+			start()
+			return nil, fmt.Errorf("Can't stop %s: %s, output %s", unit, err, out)
+		}
+	}
+	return start, nil
+}
+
+// cloneDataDisk formats the partition on the target like the live data
+// partition and copies the data over. On btrfs a read-only snapshot is taken
+// first so the copy is consistent, other filesystems are copied with the
+// Supervisor and containers stopped. A resumed clone keeps the partition and
+// only copies what changed or wasn't copied yet.
+func (d datadisk) cloneDataDisk(job *jobs.Job, partition string, fsType string, resume bool) error {
+	var err error
+	if !resume {
+		job.SetStage("format")
+		udisks2helper := newUDisks2(d.conn)
+		err = udisks2helper.FormatPartitionFromDevicePath(partition, fsType, cloneLabel)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = os.MkdirAll(cloneMountDirectory, 0700); err != nil {
+		return err
+	}
+	if err = syscall.Mount(partition, cloneMountDirectory, fsType, 0, ""); err != nil {
+		return fmt.Errorf("Can't mount %s: %s", partition, err)
+	}
+	defer syscall.Unmount(cloneMountDirectory, 0)
+
+	if _, err = os.Stat(cloneStateFile); resume && err != nil {
+		return fmt.Errorf("No interrupted clone found on %s", partition)
+	}
+
+	source := dataMount
+	if fsType == "btrfs" {
+		job.SetStage("snapshot")
+		out, err := exec.Command(btrfsCmd, "subvolume", "snapshot", "-r", dataMount, cloneSnapshot).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Can't create snapshot: %s, output %s", err, out)
+		}
+		defer exec.Command(btrfsCmd, "subvolume", "delete", cloneSnapshot).Run()
+		source = cloneSnapshot
+	} else {
+		job.SetStage("stop")
+		start, err := stopDataWriters()
+		if err != nil {
+			return err
+		}
+		defer start()
+	}
+
+	job.SetStage("copy")
+	if err = d.copyDataDisk(job, source); err != nil {
+		return err
+	}
+
+	syscall.Sync()
+	return nil
+}
+
+// startClone runs a clone of the data disk to targetDevice in the
+// background, see CloneDataDisk and ResumeCloneDataDisk.
+func (d datadisk) startClone(sender dbus.Sender, targetDevice string, resume bool) (bool, *dbus.Error) {
+	mountInfo, err := GetDataMount()
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	udisks2helper := newUDisks2(d.conn)
+	dataDevice, err := udisks2helper.GetRootDeviceFromLabel("hassos-data")
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if *dataDevice == targetDevice {
+		return false, apierror.Failed(sameDeviceError(*dataDevice))
+	}
+	// Repartitioning the OS disk destroys the system, Supervised installs
+	// have no boot partition of ours
+	if osDevice, err := udisks2helper.GetRootDeviceFromLabel(bootLabel); err == nil && *osDevice == targetDevice {
+		return false, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Target device \"%s\" holds the operating system. Aborting.", targetDevice).
+			WithDevice(targetDevice).WithRemediation(apierror.RemedyChooseOtherDevice))
+	}
+
+	cloneMutex.Lock()
+	defer cloneMutex.Unlock()
+	if cloneRunning {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "Data disk clone already in progress").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+
+	action := "DataDisk.ResumeCloneDataDisk"
+	if !resume {
+		action = "DataDisk.CloneDataDisk"
+		err = udisks2helper.PartitionDeviceWithSinglePartition(targetDevice, linuxDataPartitionUUID, cloneLabel)
+		if err != nil {
+			return false, apierror.Failed(deviceError(targetDevice, err))
+		}
+		exec.Command("udevadm", "settle").Run()
+	}
+
+	cloneRunning = true
+
+	audit.Record(sender, action, *dataDevice, targetDevice)
+
+	job := jobs.Start("clone", true)
+
+	go func() {
+		message := ""
+		err := d.cloneDataDisk(job, partitionDevice(targetDevice, 1), mountInfo.FilesystemType, resume)
+		job.Finish(err)
+		if err != nil {
+			message = err.Error()
+			logging.Error.Printf("Clone of data disk to %s failed: %s", targetDevice, err)
+		} else {
+			logging.Info.Printf("Data disk cloned to %s.", targetDevice)
+		}
+
+		cloneMutex.Lock()
+		cloneRunning = false
+		cloneMutex.Unlock()
+
+		err = d.conn.Emit(objectPath, ifaceName+".CloneFinished", message == "", message)
+		if err != nil {
+			logging.Warning.Printf("Can't emit CloneFinished signal: %s", err)
+		}
+	}()
+	return true, nil
+}
+
+// CloneDataDisk copies the live data filesystem to a partition on
+// targetDevice, labelled hassos-data-clone so it doesn't clash with the live
+// data disk while both are attached. The copy runs in the background as a
+// cancellable clone job, reporting CloneProgress and finally CloneFinished.
+// Unless the data disk is btrfs, Home Assistant is stopped during the copy.
+//
+// The clone is not relabelled automatically, two hassos-data filesystems
+// would make the next boot mount either of them. To replace the data disk,
+// power off, detach the original disk and set the filesystem label of the
+// clone to hassos-data, e.g. with e2label or btrfs filesystem label, or use
+// ChangeDevice to move the data instead.
+func (d datadisk) CloneDataDisk(sender dbus.Sender, targetDevice string) (bool, *dbus.Error) {
+	logging.Info.Printf("Request to clone data disk to %s.", targetDevice)
+	return d.startClone(sender, targetDevice, false)
+}
+
+// ResumeCloneDataDisk continues a cancelled or interrupted clone to
+// targetDevice without formatting it again. Files already copied and
+// unchanged since are kept, the whole clone is verified at the end.
+func (d datadisk) ResumeCloneDataDisk(sender dbus.Sender, targetDevice string) (bool, *dbus.Error) {
+	logging.Info.Printf("Request to resume clone of data disk to %s.", targetDevice)
+	return d.startClone(sender, targetDevice, true)
+}
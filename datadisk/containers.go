@@ -0,0 +1,100 @@
+package datadisk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/utils/docker"
+)
+
+// Calculating the usage of large image stores takes long, give up before
+// the caller's D-Bus call times out after 25 seconds.
+const containerUsageTimeout = 20 * time.Second
+
+// dockerDiskUsage holds the parts of the runtime's /system/df we report.
+type dockerDiskUsage struct {
+	LayersSize int64 `json:"LayersSize"`
+	Containers []struct {
+		SizeRw int64 `json:"SizeRw"`
+	} `json:"Containers"`
+	Volumes []struct {
+		UsageData struct {
+			Size int64 `json:"Size"`
+		} `json:"UsageData"`
+	} `json:"Volumes"`
+	BuildCache []struct {
+		Size int64 `json:"Size"`
+	} `json:"BuildCache"`
+}
+
+func positive(size int64) uint64 {
+	// The runtime reports -1 for sizes it did not calculate
+	if size < 0 {
+		return 0
+	}
+	return uint64(size)
+}
+
+// getContainerLogSize sums up the json-file logs of all containers.
+func getContainerLogSize(ctx context.Context) (uint64, error) {
+	var info struct {
+		DockerRootDir string `json:"DockerRootDir"`
+	}
+	if err := docker.GetContext(ctx, "/info", &info); err != nil {
+		return 0, err
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(info.DockerRootDir, "containers", "*", "*-json.log*"))
+	var size uint64
+	for _, log := range logs {
+		if stat, err := os.Stat(log); err == nil {
+			size += uint64(stat.Size())
+		}
+	}
+	return size, nil
+}
+
+// GetContainerStorageUsage returns the bytes used by container images,
+// container layers, volumes, logs and the build cache on the data disk.
+func (d datadisk) GetContainerStorageUsage() (map[string]uint64, *dbus.Error) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerUsageTimeout)
+	defer cancel()
+
+	var df dockerDiskUsage
+	if err := docker.GetContext(ctx, "/system/df", &df); err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't get container storage usage: %s", err))
+	}
+
+	usage := map[string]uint64{
+		"images":      0,
+		"containers":  0,
+		"volumes":     0,
+		"logs":        0,
+		"build_cache": 0,
+	}
+
+	// Layers shared between images are counted only once
+	usage["images"] = positive(df.LayersSize)
+	for _, container := range df.Containers {
+		usage["containers"] += positive(container.SizeRw)
+	}
+	for _, volume := range df.Volumes {
+		usage["volumes"] += positive(volume.UsageData.Size)
+	}
+	for _, cache := range df.BuildCache {
+		usage["build_cache"] += positive(cache.Size)
+	}
+
+	logs, err := getContainerLogSize(ctx)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't get container log size: %s", err))
+	}
+	usage["logs"] = logs
+
+	return usage, nil
+}
@@ -0,0 +1,93 @@
+package datadisk
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	cryptEnrollCmd = "systemd-cryptenroll"
+	cryptSetupCmd  = "cryptsetup"
+)
+
+func checkLUKSDevice(device string) error {
+	if !strings.HasPrefix(device, "/dev/") {
+		return fmt.Errorf("Invalid device path '%s'", device)
+	}
+
+	err := exec.Command(cryptSetupCmd, "isLuks", device).Run()
+	if err != nil {
+		return fmt.Errorf("Device '%s' is not a LUKS volume", device)
+	}
+	return nil
+}
+
+func runCryptEnroll(device string, passphrase string, args ...string) error {
+	cmd := exec.Command(cryptEnrollCmd, append(args, device)...)
+	// systemd-cryptenroll picks up the existing unlock passphrase from $PASSWORD
+	cmd.Env = append(os.Environ(), "PASSWORD="+passphrase)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Can't update keyslots on '%s': %s, output %s", device, err, out)
+	}
+	return nil
+}
+
+func (d datadisk) EnrollFIDO2Key(sender dbus.Sender, device string, passphrase string) (bool, *dbus.Error) {
+	logging.Info.Printf("Enroll FIDO2 key on LUKS volume %s.", device)
+
+	if err := checkLUKSDevice(device); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	err := runCryptEnroll(device, passphrase, "--fido2-device=auto")
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	audit.Record(sender, "DataDisk.EnrollFIDO2Key", "", device)
+	return true, nil
+}
+
+func (d datadisk) EnrollPKCS11Token(sender dbus.Sender, device string, uri string, passphrase string) (bool, *dbus.Error) {
+	logging.Info.Printf("Enroll PKCS#11 token on LUKS volume %s.", device)
+
+	if err := checkLUKSDevice(device); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if !strings.HasPrefix(uri, "pkcs11:") && uri != "auto" {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid PKCS#11 URI '%s'", uri))
+	}
+
+	err := runCryptEnroll(device, passphrase, "--pkcs11-token-uri="+uri)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	audit.Record(sender, "DataDisk.EnrollPKCS11Token", "", device)
+	return true, nil
+}
+
+func (d datadisk) RemoveTokenKeyslots(sender dbus.Sender, device string, tokenType string, passphrase string) (bool, *dbus.Error) {
+	logging.Info.Printf("Remove %s keyslots from LUKS volume %s.", tokenType, device)
+
+	if tokenType != "fido2" && tokenType != "pkcs11" {
+		return false, dbus.MakeFailedError(fmt.Errorf("Unsupported token type '%s'", tokenType))
+	}
+	if err := checkLUKSDevice(device); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	err := runCryptEnroll(device, passphrase, "--wipe-slot="+tokenType)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	audit.Record(sender, "DataDisk.RemoveTokenKeyslots", device, tokenType)
+	return true, nil
+}
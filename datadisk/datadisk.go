@@ -2,7 +2,6 @@ package datadisk
 
 import (
 	"errors"
-	"fmt"
 	"os"
 
 	"github.com/fntlnz/mountinfo"
@@ -10,13 +9,21 @@ import (
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/audit"
 	"github.com/home-assistant/os-agent/udisks2"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/netboot"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
 )
 
 const (
 	dataMount              = "/mnt/data"
 	linuxDataPartitionUUID = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+	dataMoveMarker         = "/mnt/overlay/move-data"
 )
 
 func GetDataMount() (*mountinfo.Mountinfo, error) {
@@ -35,30 +42,52 @@ func GetDataMount() (*mountinfo.Mountinfo, error) {
 	return nil, errors.New("Can't find a data mount!")
 }
 
+// Replaced by tests to inject a mock
+var newUDisks2 = func(conn *dbus.Conn) udisks2.Helper { return udisks2.NewUDisks2(conn) }
+
 type datadisk struct {
 	conn  *dbus.Conn
 	props *prop.Properties
 }
 
+func getDataMoveScheduled() bool {
+	_, err := os.Stat(dataMoveMarker)
+	return err == nil
+}
+
 func (d datadisk) MarkDataMove() *dbus.Error {
 	/* Move request marker for hassos-data.service */
-	fileName := "/mnt/overlay/move-data"
-	_, err := os.Stat(fileName)
+	_, err := os.Stat(dataMoveMarker)
 	if os.IsNotExist(err) {
-		file, err := os.Create(fileName)
+		file, err := os.Create(dataMoveMarker)
 		if err != nil {
 			return dbus.MakeFailedError(err)
 		}
 		defer file.Close()
 	}
 
+	d.props.SetMust(ifaceName, "DataMoveScheduled", true)
 	return nil
 }
 
-func (d datadisk) ChangeDevice(newDevice string) (bool, *dbus.Error) {
+func sameDeviceError(device string) error {
+	return apierror.New(apierror.CodeInvalidArgument, "Current data device \"%s\" the same as target device. Aborting.", device).
+		WithDevice(device).WithRemediation(apierror.RemedyChooseOtherDevice)
+}
+
+func deviceError(device string, err error) error {
+	return apierror.Wrap(apierror.CodeDeviceError, err).WithDevice(device).WithRemediation(apierror.RemedyCheckDevice)
+}
+
+func (d datadisk) ChangeDevice(sender dbus.Sender, newDevice string) (bool, *dbus.Error) {
 	logging.Info.Printf("Request to change data disk to %s.", newDevice)
 
-	udisks2helper := udisks2.NewUDisks2(d.conn)
+	if bootType, server := netboot.Detect(); bootType != netboot.None {
+		return false, apierror.Failed(apierror.New(apierror.CodeUnsupported, "System booted via %s from %s, refusing to change the data disk", bootType, server).
+			WithRemediation(apierror.RemedyUseLocalStorage))
+	}
+
+	udisks2helper := newUDisks2(d.conn)
 	dataDevice, err := udisks2helper.GetRootDeviceFromLabel("hassos-data")
 	if err != nil {
 		return false, dbus.MakeFailedError(err)
@@ -66,12 +95,12 @@ func (d datadisk) ChangeDevice(newDevice string) (bool, *dbus.Error) {
 
 	logging.Info.Printf("Data partition is currently on device %s.", *dataDevice)
 	if *dataDevice == newDevice {
-		return false, dbus.MakeFailedError(fmt.Errorf("Current data device \"%s\" the same as target device. Aborting.", *dataDevice))
+		return false, apierror.Failed(sameDeviceError(*dataDevice))
 	}
 
 	err = udisks2helper.PartitionDeviceWithSinglePartition(newDevice, linuxDataPartitionUUID, "hassos-data-external")
 	if err != nil {
-		return false, dbus.MakeFailedError(err)
+		return false, apierror.Failed(deviceError(newDevice, err))
 	}
 
 	dbuserr := d.MarkDataMove()
@@ -79,6 +108,7 @@ func (d datadisk) ChangeDevice(newDevice string) (bool, *dbus.Error) {
 		return false, dbuserr
 	}
 
+	audit.Record(sender, "DataDisk.ChangeDevice", *dataDevice, newDevice)
 	return true, nil
 }
 
@@ -97,6 +127,21 @@ const (
 	ifaceName  = "io.hass.os.DataDisk"
 )
 
+var methodArgNames = map[string][]string{
+	"ChangeDevice":             {"new_device", "success"},
+	"ReloadDevice":             {"success"},
+	"MarkDataMove":             {},
+	"CloneDataDisk":            {"target_device", "success"},
+	"ResumeCloneDataDisk":      {"target_device", "success"},
+	"SetSelfTestSchedule":      {"type", "interval_hours", "success"},
+	"VerifyDataDisk":           {"success"},
+	"BenchmarkDisk":            {"device", "results"},
+	"EnrollFIDO2Key":           {"device", "passphrase", "success"},
+	"EnrollPKCS11Token":        {"device", "uri", "passphrase", "success"},
+	"RemoveTokenKeyslots":      {"device", "token_type", "passphrase", "success"},
+	"GetContainerStorageUsage": {"usage"},
+}
+
 func InitializeDBus(conn *dbus.Conn) {
 
 	// Try to read the current data mount point
@@ -112,6 +157,12 @@ func InitializeDBus(conn *dbus.Conn) {
 		conn: conn,
 	}
 
+	temperatureLimit = loadTemperatureLimit()
+	spaceConfig = loadSpaceThresholds()
+	freeSpace, _ := getFreeSpace()
+	driveTemperature := d.getDriveTemperature()
+	flashHealth := getFlashHealth()
+
 	propsSpec := map[string]map[string]*prop.Prop{
 		ifaceName: {
 			"CurrentDevice": {
@@ -120,19 +171,88 @@ func InitializeDBus(conn *dbus.Conn) {
 				Emit:     prop.EmitTrue,
 				Callback: nil,
 			},
+			"DataMoveScheduled": {
+				Value:    getDataMoveScheduled(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"DriveTemperature": {
+				Value:    driveTemperature,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"TemperatureLimit": {
+				Value:    temperatureLimit,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setTemperatureLimit,
+			},
+			"FreeSpace": {
+				Value:    freeSpace,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"LowSpacePercent": {
+				Value:    spaceConfig.Percent,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setLowSpacePercent,
+			},
+			"LowSpaceBytes": {
+				Value:    spaceConfig.Bytes,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setLowSpaceBytes,
+			},
+			"FlashHealth": {
+				Value:    flashHealth,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"LastVerifyReport": {
+				Value:    "",
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
 		},
 	}
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	for name, p := range selfTestProps() {
+		propsSpec[ifaceName][name] = p
+	}
+	for name, p := range maintenanceProps() {
+		propsSpec[ifaceName][name] = p
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 	d.props = props
+	selfTestWorker = worker.New(d.watchSelfTestSchedule)
+	maintenanceWorker = worker.New(d.watchMaintenanceWindow)
+	flashWorker = worker.New(d.watchFlashHealth)
+	temperatureWorker = worker.New(d.watchDriveTemperature)
+	spaceWorker = worker.New(d.watchDiskSpace)
 
-	err = conn.Export(d, objectPath, ifaceName)
+	err = recovery.Export(conn, d, objectPath, ifaceName)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 
+	var signals []introspect.Signal
+	signals = append(signals, cloneSignals...)
+	signals = append(signals, selfTestSignals...)
+	signals = append(signals, verifySignals...)
+	signals = append(signals, flashSignals...)
+	signals = append(signals, temperatureSignals...)
+	signals = append(signals, spaceSignals...)
+	signals = append(signals, maintenanceSignals...)
+
 	node := &introspect.Node{
 		Name: objectPath,
 		Interfaces: []introspect.Interface{
@@ -140,7 +260,8 @@ func InitializeDBus(conn *dbus.Conn) {
 			prop.IntrospectData,
 			{
 				Name:       ifaceName,
-				Methods:    introspect.Methods(d),
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    signals,
 				Properties: props.Introspection(ifaceName),
 			},
 		},
@@ -152,4 +273,11 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	selfTestWorker.Set(selfTestConfig.IntervalHours > 0)
+	maintenanceWorker.Set(maintenanceConfig.enabled())
+	flashWorker.Set(len(flashHealth) > 0)
+	temperatureWorker.Set(driveTemperature != 0)
+	spaceWorker.Set(currentDisk != "")
 }
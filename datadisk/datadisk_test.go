@@ -0,0 +1,58 @@
+package datadisk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/udisks2"
+	"github.com/home-assistant/os-agent/udisks2/mocks"
+)
+
+func mockUDisks2(t *testing.T, helper *mocks.MockHelper) {
+	original := newUDisks2
+	newUDisks2 = func(conn *dbus.Conn) udisks2.Helper { return helper }
+	t.Cleanup(func() { newUDisks2 = original })
+}
+
+func rootDevice(device string) func(string) (*string, error) {
+	return func(label string) (*string, error) {
+		return &device, nil
+	}
+}
+
+func TestChangeDeviceMissingLabel(t *testing.T) {
+	mockUDisks2(t, &mocks.MockHelper{
+		GetRootDeviceFromLabelFunc: func(label string) (*string, error) {
+			return nil, errors.New("Expected single block device with file system label")
+		},
+	})
+
+	if ok, err := (datadisk{}).ChangeDevice("", "/dev/sda"); ok || err == nil {
+		t.Fatal("expected an error for a missing data partition")
+	}
+}
+
+func TestChangeDeviceSameDevice(t *testing.T) {
+	mockUDisks2(t, &mocks.MockHelper{
+		GetRootDeviceFromLabelFunc: rootDevice("/dev/sda"),
+	})
+
+	if ok, err := (datadisk{}).ChangeDevice("", "/dev/sda"); ok || err == nil {
+		t.Fatal("expected an error for the current data device")
+	}
+}
+
+func TestChangeDevicePartitionFailure(t *testing.T) {
+	mockUDisks2(t, &mocks.MockHelper{
+		GetRootDeviceFromLabelFunc: rootDevice("/dev/mmcblk0"),
+		PartitionDeviceWithSinglePartitionFunc: func(devicePath string, uuid string, name string) error {
+			return errors.New("Error creating partition: Device or resource busy")
+		},
+	})
+
+	if ok, err := (datadisk{}).ChangeDevice("", "/dev/sda"); ok || err == nil {
+		t.Fatal("expected the partitioning error")
+	}
+}
@@ -0,0 +1,157 @@
+package datadisk
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	mmcBlockGlob     = "/sys/block/mmcblk[0-9]*"
+	flashCheckPeriod = time.Hour
+	flashGood        = "good"
+	flashDegraded    = "degraded"
+	flashCritical    = "critical"
+	lifeTimeDegraded = 0x08
+	lifeTimeCritical = 0x0A
+	preEOLWarning    = 0x02
+	preEOLUrgent     = 0x03
+	journalctlCmd    = "journalctl"
+)
+
+var (
+	flashWorker *worker.Worker
+
+	flashSignals = []introspect.Signal{
+		{
+			Name: "FlashHealthDegraded",
+			Args: []introspect.Arg{
+				{Name: "device", Type: "s"},
+				{Name: "status", Type: "s"},
+			},
+		},
+	}
+)
+
+func readMMCRegister(device string, name string) []int64 {
+	data, err := ioutil.ReadFile(filepath.Join(device, "device", name))
+	if err != nil {
+		return nil
+	}
+
+	var values []int64
+	for _, field := range strings.Fields(string(data)) {
+		value, err := strconv.ParseInt(field, 0, 64)
+		if err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// countMMCErrors counts kernel messages of the current boot reporting errors
+// on the device.
+func countMMCErrors(name string) int {
+	out, err := exec.Command(journalctlCmd, "--dmesg", "--boot", "--no-pager", "--output=cat", "--grep="+name+".*error").Output()
+	if err != nil {
+		return 0
+	}
+	return len(strings.Split(strings.TrimSpace(string(out)), "\n"))
+}
+
+// flashStatus rates the wear of eMMC devices from the JEDEC life time
+// estimates (in 10% steps) and the pre-EOL state of the reserved blocks. SD
+// cards don't provide those, so only I/O errors count for them.
+func flashStatus(lifeTime []int64, preEOL []int64, errors int) string {
+	status := flashGood
+	if errors > 0 {
+		status = flashDegraded
+	}
+
+	for _, value := range lifeTime {
+		if value >= lifeTimeCritical {
+			return flashCritical
+		} else if value >= lifeTimeDegraded {
+			status = flashDegraded
+		}
+	}
+	for _, value := range preEOL {
+		if value >= preEOLUrgent {
+			return flashCritical
+		} else if value >= preEOLWarning {
+			status = flashDegraded
+		}
+	}
+	return status
+}
+
+func getFlashHealth() map[string]map[string]string {
+	health := map[string]map[string]string{}
+
+	devices, _ := filepath.Glob(mmcBlockGlob)
+	for _, device := range devices {
+		name := filepath.Base(device)
+		if strings.Contains(name, "boot") || strings.Contains(name, "rpmb") {
+			continue
+		}
+
+		info := map[string]string{}
+		if data, err := ioutil.ReadFile(filepath.Join(device, "device", "type")); err == nil {
+			info["type"] = strings.TrimSpace(string(data))
+		}
+
+		lifeTime := readMMCRegister(device, "life_time")
+		preEOL := readMMCRegister(device, "pre_eol_info")
+		errors := countMMCErrors(name)
+
+		// eMMC reports separate estimates for SLC (type A) and MLC (type B) areas.
+		if len(lifeTime) == 2 {
+			info["life_time_a"] = strconv.FormatInt(lifeTime[0], 10)
+			info["life_time_b"] = strconv.FormatInt(lifeTime[1], 10)
+		}
+		if len(preEOL) == 1 {
+			info["pre_eol"] = strconv.FormatInt(preEOL[0], 10)
+		}
+		info["errors"] = strconv.Itoa(errors)
+		info["status"] = flashStatus(lifeTime, preEOL, errors)
+
+		health["/dev/"+name] = info
+	}
+	return health
+}
+
+// watchFlashHealth only runs on devices with eMMC or SD storage, see
+// flashWorker.
+func (d datadisk) watchFlashHealth(ctx context.Context) {
+	reported := map[string]string{}
+
+	for {
+		health := getFlashHealth()
+		d.props.SetMust(ifaceName, "FlashHealth", health)
+
+		for device, info := range health {
+			status := info["status"]
+			if status != flashGood && status != reported[device] {
+				logging.Warning.Printf("Flash storage %s is %s!", device, status)
+				err := d.conn.Emit(objectPath, ifaceName+".FlashHealthDegraded", device, status)
+				if err != nil {
+					logging.Warning.Printf("Can't emit FlashHealthDegraded signal: %s", err)
+				}
+			}
+			reported[device] = status
+		}
+
+		if !worker.Sleep(ctx, flashCheckPeriod) {
+			return
+		}
+	}
+}
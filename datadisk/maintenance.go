@@ -0,0 +1,331 @@
+package datadisk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	maintenanceConfigFile  = "/etc/os-agent/maintenance.json"
+	maintenanceCheckPeriod = time.Minute
+	fstrimCmd              = "fstrim"
+	journalVacuumTime      = "1month"
+	minutesPerDay          = 24 * 60
+)
+
+// Maintenance tasks in the order they run within the window
+const (
+	maintenanceTrim          = "fstrim"
+	maintenanceBalance       = "btrfs_balance"
+	maintenanceScrub         = "btrfs_scrub"
+	maintenanceSelfTest      = "smart_selftest"
+	maintenanceJournalVacuum = "journal_vacuum"
+)
+
+type maintenanceWindow struct {
+	// Minutes after local midnight
+	Start     uint32 `json:"start"`
+	Length    uint32 `json:"length"`
+	Frequency uint32 `json:"frequency_days"`
+	LastRun   int64  `json:"last_run"`
+}
+
+var (
+	maintenanceMutex  sync.Mutex
+	maintenanceConfig = maintenanceWindow{Start: 3 * 60, Length: 3 * 60, Frequency: 7}
+	maintenanceWorker *worker.Worker
+
+	maintenanceSignals = []introspect.Signal{
+		{
+			Name: "MaintenanceRun",
+			Args: []introspect.Arg{
+				{Name: "task", Type: "s"},
+				{Name: "success", Type: "b"},
+				{Name: "output", Type: "s"},
+			},
+		},
+		{
+			Name: "MaintenanceSkipped",
+			Args: []introspect.Arg{
+				{Name: "task", Type: "s"},
+				{Name: "reason", Type: "s"},
+			},
+		},
+	}
+)
+
+func loadMaintenanceWindow() maintenanceWindow {
+	config := maintenanceConfig
+
+	data, err := ioutil.ReadFile(maintenanceConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid maintenance window in %s", maintenanceConfigFile)
+		return maintenanceConfig
+	}
+	return config
+}
+
+func saveMaintenanceWindow(config maintenanceWindow) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(maintenanceConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(maintenanceConfigFile, data, 0644)
+}
+
+// windowEnd returns the end of the maintenance window the given time is in,
+// or the zero time outside of it.
+func (w maintenanceWindow) windowEnd(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// A window may span midnight, so check the one started yesterday too
+	for _, day := range []time.Time{midnight.AddDate(0, 0, -1), midnight} {
+		start := day.Add(time.Duration(w.Start) * time.Minute)
+		end := start.Add(time.Duration(w.Length) * time.Minute)
+		if !now.Before(start) && now.Before(end) {
+			return end
+		}
+	}
+	return time.Time{}
+}
+
+func (w maintenanceWindow) enabled() bool {
+	return w.Frequency > 0 && w.Length > 0
+}
+
+func (w maintenanceWindow) due(now time.Time) bool {
+	if !w.enabled() {
+		return false
+	}
+	return now.Unix() >= w.LastRun+int64(w.Frequency)*int64(24*time.Hour/time.Second)
+}
+
+func setMaintenanceValue(c *prop.Change, update func(*maintenanceWindow, uint32) error) *dbus.Error {
+	value, ok := c.Value.(uint32)
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid value %v", c.Value))
+	}
+
+	maintenanceMutex.Lock()
+	defer maintenanceMutex.Unlock()
+
+	config := maintenanceConfig
+	if err := update(&config, value); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if err := saveMaintenanceWindow(config); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't save maintenance window: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "DataDisk."+c.Name, maintenanceConfig, config)
+	maintenanceConfig = config
+	maintenanceWorker.Set(config.enabled())
+	return nil
+}
+
+func setMaintenanceStart(c *prop.Change) *dbus.Error {
+	return setMaintenanceValue(c, func(w *maintenanceWindow, value uint32) error {
+		if value >= minutesPerDay {
+			return fmt.Errorf("Invalid window start %d, must be minutes after midnight", value)
+		}
+		w.Start = value
+		return nil
+	})
+}
+
+func setMaintenanceLength(c *prop.Change) *dbus.Error {
+	return setMaintenanceValue(c, func(w *maintenanceWindow, value uint32) error {
+		if value > minutesPerDay {
+			return fmt.Errorf("Invalid window length %d, must be at most a day", value)
+		}
+		w.Length = value
+		return nil
+	})
+}
+
+func setMaintenanceFrequency(c *prop.Change) *dbus.Error {
+	return setMaintenanceValue(c, func(w *maintenanceWindow, value uint32) error {
+		w.Frequency = value
+		return nil
+	})
+}
+
+func (d datadisk) emitMaintenanceSkipped(task string, reason string) {
+	logging.Info.Printf("Skipping maintenance task %s: %s", task, reason)
+	err := d.conn.Emit(objectPath, ifaceName+".MaintenanceSkipped", task, reason)
+	if err != nil {
+		logging.Warning.Printf("Can't emit MaintenanceSkipped signal: %s", err)
+	}
+}
+
+// cancelBtrfs stops a balance or scrub which outlived the window. Killing
+// the btrfs tool doesn't stop the operation in the kernel.
+func cancelBtrfs(operation string) {
+	out, err := exec.Command(btrfsCmd, operation, "cancel", dataMount).CombinedOutput()
+	if err != nil {
+		logging.Warning.Printf("Can't cancel btrfs %s: %s, output %s", operation, err, out)
+	}
+}
+
+// runBtrfs runs a btrfs balance or scrub until it finishes or ctx is done.
+func runBtrfs(ctx context.Context, operation string, args ...string) (bool, string) {
+	args = append([]string{operation, "start"}, args...)
+	out, err := exec.CommandContext(ctx, btrfsCmd, append(args, dataMount)...).CombinedOutput()
+	if ctx.Err() != nil {
+		cancelBtrfs(operation)
+		return false, fmt.Sprintf("Cancelled at the end of the maintenance window, output %s", out)
+	}
+	return err == nil, string(out)
+}
+
+// runMaintenanceTask runs a task until it finishes or ctx, limited to the
+// window, is done.
+func (d datadisk) runMaintenanceTask(ctx context.Context, task string) (bool, string) {
+	switch task {
+	case maintenanceTrim:
+		out, err := exec.CommandContext(ctx, fstrimCmd, "--verbose", dataMount).CombinedOutput()
+		return err == nil, string(out)
+	case maintenanceBalance:
+		// Only compact chunks which are less than half full
+		return runBtrfs(ctx, "balance", "-dusage=50", "-musage=50")
+	case maintenanceScrub:
+		return runBtrfs(ctx, "scrub", "-B")
+	case maintenanceSelfTest:
+		result := d.runSelfTest("short")
+		return result == selfTestSuccess, result
+	case maintenanceJournalVacuum:
+		out, err := exec.CommandContext(ctx, journalctlCmd, "--vacuum-time="+journalVacuumTime).CombinedOutput()
+		return err == nil, string(out)
+	}
+	return false, fmt.Sprintf("Unknown maintenance task %s", task)
+}
+
+// runMaintenance runs the housekeeping tasks one after another, as long as
+// the window lasts. A task still running at the end of the window is
+// stopped.
+func (d datadisk) runMaintenance(ctx context.Context, end time.Time) {
+	logging.Info.Printf("Starting data disk maintenance until %s.", end.Format(time.Kitchen))
+
+	ctx, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+
+	mountInfo, err := GetDataMount()
+	btrfs := err == nil && mountInfo.FilesystemType == "btrfs"
+
+	tasks := []string{maintenanceTrim, maintenanceBalance, maintenanceScrub, maintenanceSelfTest, maintenanceJournalVacuum}
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			d.emitMaintenanceSkipped(task, "window_ended")
+			continue
+		}
+		if (task == maintenanceBalance || task == maintenanceScrub) && !btrfs {
+			d.emitMaintenanceSkipped(task, "not_btrfs")
+			continue
+		}
+		if task == maintenanceScrub {
+			verifyMutex.Lock()
+			running := verifyRunning
+			verifyMutex.Unlock()
+			if running {
+				d.emitMaintenanceSkipped(task, "verify_running")
+				continue
+			}
+		}
+		if task == maintenanceSelfTest {
+			if _, err := d.dataDriveAta(); err != nil {
+				d.emitMaintenanceSkipped(task, "smart_unsupported")
+				continue
+			}
+		}
+
+		success, output := d.runMaintenanceTask(ctx, task)
+		logging.Info.Printf("Maintenance task %s finished (success: %t): %s", task, success, output)
+		err := d.conn.Emit(objectPath, ifaceName+".MaintenanceRun", task, success, output)
+		if err != nil {
+			logging.Warning.Printf("Can't emit MaintenanceRun signal: %s", err)
+		}
+	}
+}
+
+// watchMaintenanceWindow only runs while a maintenance window is configured,
+// see maintenanceWorker.
+func (d datadisk) watchMaintenanceWindow(ctx context.Context) {
+	for worker.Sleep(ctx, maintenanceCheckPeriod) {
+		now := time.Now()
+		maintenanceMutex.Lock()
+		config := maintenanceConfig
+		maintenanceMutex.Unlock()
+
+		end := config.windowEnd(now)
+		if end.IsZero() || !config.due(now) {
+			continue
+		}
+
+		d.runMaintenance(ctx, end)
+
+		maintenanceMutex.Lock()
+		maintenanceConfig.LastRun = now.Unix()
+		if err := saveMaintenanceWindow(maintenanceConfig); err != nil {
+			logging.Warning.Printf("Can't save maintenance window: %s", err)
+		}
+		lastRun := maintenanceConfig.LastRun
+		maintenanceMutex.Unlock()
+
+		// Property callbacks take maintenanceMutex under the props lock
+		d.props.SetMust(ifaceName, "LastMaintenance", lastRun)
+	}
+}
+
+func maintenanceProps() map[string]*prop.Prop {
+	maintenanceConfig = loadMaintenanceWindow()
+
+	return map[string]*prop.Prop{
+		"MaintenanceWindowStart": {
+			Value:    maintenanceConfig.Start,
+			Writable: true,
+			Emit:     prop.EmitTrue,
+			Callback: setMaintenanceStart,
+		},
+		"MaintenanceWindowLength": {
+			Value:    maintenanceConfig.Length,
+			Writable: true,
+			Emit:     prop.EmitTrue,
+			Callback: setMaintenanceLength,
+		},
+		"MaintenanceFrequency": {
+			Value:    maintenanceConfig.Frequency,
+			Writable: true,
+			Emit:     prop.EmitTrue,
+			Callback: setMaintenanceFrequency,
+		},
+		"LastMaintenance": {
+			Value:    maintenanceConfig.LastRun,
+			Writable: false,
+			Emit:     prop.EmitTrue,
+			Callback: nil,
+		},
+	}
+}
@@ -0,0 +1,230 @@
+package datadisk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/udisks2"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	selfTestScheduleFile = "/etc/os-agent/smart.json"
+	selfTestCheckPeriod  = 10 * time.Minute
+	selfTestPollPeriod   = time.Minute
+	selfTestInProgress   = "inprogress"
+	selfTestSuccess      = "success"
+)
+
+type selfTestSchedule struct {
+	Type          string `json:"type"`
+	IntervalHours uint32 `json:"interval_hours"`
+	LastRun       int64  `json:"last_run"`
+	LastResult    string `json:"last_result"`
+}
+
+var (
+	selfTestMutex  sync.Mutex
+	selfTestConfig selfTestSchedule
+	selfTestWorker *worker.Worker
+
+	selfTestSignals = []introspect.Signal{
+		{
+			Name: "SelfTestFailed",
+			Args: []introspect.Arg{
+				{Name: "result", Type: "s"},
+			},
+		},
+	}
+)
+
+func loadSelfTestSchedule() selfTestSchedule {
+	schedule := selfTestSchedule{Type: "short"}
+
+	data, err := ioutil.ReadFile(selfTestScheduleFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warning.Printf("Can't read SMART self-test schedule %s: %s", selfTestScheduleFile, err)
+		}
+		return schedule
+	}
+
+	err = json.Unmarshal(data, &schedule)
+	if err != nil {
+		logging.Error.Printf("Ignoring invalid SMART self-test schedule in %s", selfTestScheduleFile)
+		return selfTestSchedule{Type: "short"}
+	}
+	return schedule
+}
+
+func saveSelfTestSchedule(schedule selfTestSchedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(selfTestScheduleFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(selfTestScheduleFile, data, 0644)
+}
+
+func (s selfTestSchedule) next() int64 {
+	if s.IntervalHours == 0 {
+		return 0
+	}
+	return s.LastRun + int64(s.IntervalHours)*int64(time.Hour/time.Second)
+}
+
+func (d datadisk) dataDriveAta() (*udisks2.DriveAta, error) {
+	udisks2helper := newUDisks2(d.conn)
+	drive, err := udisks2helper.GetDriveFromLabel("hassos-data")
+	if err != nil {
+		return nil, err
+	}
+
+	ata := udisks2.NewDriveAta(drive)
+	supported, err := ata.GetSmartSupported(context.Background())
+	if err != nil || !supported {
+		return nil, fmt.Errorf("Data disk drive doesn't support SMART self-tests")
+	}
+	return ata, nil
+}
+
+// SetSelfTestSchedule configures a periodic SMART self-test of the data disk
+// drive. testType is "short" or "extended", an interval of 0 disables it.
+func (d datadisk) SetSelfTestSchedule(sender dbus.Sender, testType string, intervalHours uint32) (bool, *dbus.Error) {
+	if testType != "short" && testType != "extended" {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid self-test type '%s'", testType))
+	}
+
+	selfTestMutex.Lock()
+	defer selfTestMutex.Unlock()
+
+	schedule := selfTestConfig
+	schedule.Type = testType
+	schedule.IntervalHours = intervalHours
+	if schedule.LastRun == 0 {
+		schedule.LastRun = time.Now().Unix()
+	}
+
+	if err := saveSelfTestSchedule(schedule); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save SMART self-test schedule: %s", err))
+	}
+
+	audit.Record(sender, "DataDisk.SetSelfTestSchedule", selfTestConfig, schedule)
+	selfTestConfig = schedule
+	selfTestWorker.Set(schedule.IntervalHours > 0)
+	d.props.SetMust(ifaceName, "SelfTestType", schedule.Type)
+	d.props.SetMust(ifaceName, "SelfTestInterval", schedule.IntervalHours)
+	d.props.SetMust(ifaceName, "NextSelfTest", schedule.next())
+	return true, nil
+}
+
+func (d datadisk) runSelfTest(testType string) string {
+	ata, err := d.dataDriveAta()
+	if err != nil {
+		logging.Warning.Printf("Can't run SMART self-test: %s", err)
+		return err.Error()
+	}
+
+	logging.Info.Printf("Starting SMART %s self-test of data disk drive.", testType)
+	err = ata.SmartSelftestStart(context.Background(), testType, map[string]dbus.Variant{})
+	if err != nil {
+		logging.Warning.Printf("Can't start SMART self-test: %s", err)
+		return err.Error()
+	}
+
+	for {
+		time.Sleep(selfTestPollPeriod)
+
+		ata.SmartUpdate(context.Background(), map[string]dbus.Variant{})
+		status, err := ata.GetSmartSelftestStatus(context.Background())
+		if err != nil {
+			return err.Error()
+		}
+		if status != selfTestInProgress {
+			return status
+		}
+	}
+}
+
+// watchSelfTestSchedule only runs while self-tests are scheduled, see
+// selfTestWorker.
+func (d datadisk) watchSelfTestSchedule(ctx context.Context) {
+	for {
+		selfTestMutex.Lock()
+		schedule := selfTestConfig
+		selfTestMutex.Unlock()
+
+		if schedule.IntervalHours > 0 && time.Now().Unix() >= schedule.next() {
+			result := d.runSelfTest(schedule.Type)
+			logging.Info.Printf("SMART self-test finished: %s", result)
+
+			selfTestMutex.Lock()
+			selfTestConfig.LastRun = time.Now().Unix()
+			selfTestConfig.LastResult = result
+			if err := saveSelfTestSchedule(selfTestConfig); err != nil {
+				logging.Warning.Printf("Can't save SMART self-test schedule: %s", err)
+			}
+			d.props.SetMust(ifaceName, "LastSelfTestResult", result)
+			d.props.SetMust(ifaceName, "NextSelfTest", selfTestConfig.next())
+			selfTestMutex.Unlock()
+
+			if result != selfTestSuccess {
+				err := d.conn.Emit(objectPath, ifaceName+".SelfTestFailed", result)
+				if err != nil {
+					logging.Warning.Printf("Can't emit SelfTestFailed signal: %s", err)
+				}
+			}
+		}
+
+		if !worker.Sleep(ctx, selfTestCheckPeriod) {
+			return
+		}
+	}
+}
+
+func selfTestProps() map[string]*prop.Prop {
+	selfTestConfig = loadSelfTestSchedule()
+
+	return map[string]*prop.Prop{
+		"SelfTestType": {
+			Value:    selfTestConfig.Type,
+			Writable: false,
+			Emit:     prop.EmitTrue,
+			Callback: nil,
+		},
+		"SelfTestInterval": {
+			Value:    selfTestConfig.IntervalHours,
+			Writable: false,
+			Emit:     prop.EmitTrue,
+			Callback: nil,
+		},
+		"LastSelfTestResult": {
+			Value:    selfTestConfig.LastResult,
+			Writable: false,
+			Emit:     prop.EmitTrue,
+			Callback: nil,
+		},
+		"NextSelfTest": {
+			Value:    selfTestConfig.next(),
+			Writable: false,
+			Emit:     prop.EmitTrue,
+			Callback: nil,
+		},
+	}
+}
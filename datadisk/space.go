@@ -0,0 +1,191 @@
+package datadisk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	spaceConfigFile  = "/etc/os-agent/disk-space.json"
+	spaceCheckPeriod = 5 * time.Minute
+	topConsumers     = 5
+)
+
+type spaceThresholds struct {
+	Percent uint32 `json:"percent"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+var (
+	spaceMutex  sync.Mutex
+	spaceConfig = spaceThresholds{Percent: 10, Bytes: 1024 * 1024 * 1024}
+	spaceWorker *worker.Worker
+
+	spaceSignals = []introspect.Signal{
+		{
+			Name: "LowDiskSpace",
+			Args: []introspect.Arg{
+				{Name: "free", Type: "t"},
+				{Name: "consumers", Type: "a{st}"},
+			},
+		},
+	}
+)
+
+func loadSpaceThresholds() spaceThresholds {
+	config := spaceConfig
+
+	data, err := ioutil.ReadFile(spaceConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid disk space thresholds in %s", spaceConfigFile)
+		return spaceConfig
+	}
+	return config
+}
+
+func saveSpaceThresholds(config spaceThresholds) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(spaceConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(spaceConfigFile, data, 0644)
+}
+
+func getFreeSpace() (uint64, uint64) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dataMount, &stat); err != nil {
+		return 0, 0
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize)
+}
+
+func directorySize(path string) uint64 {
+	var size uint64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// getTopConsumers returns the largest directories two levels below the data
+// mount, e.g. /mnt/data/supervisor/homeassistant.
+func getTopConsumers() map[string]uint64 {
+	var dirs []string
+	top, _ := filepath.Glob(filepath.Join(dataMount, "*"))
+	for _, dir := range top {
+		sub, _ := filepath.Glob(filepath.Join(dir, "*"))
+		for _, path := range sub {
+			if info, err := os.Lstat(path); err == nil && info.IsDir() {
+				dirs = append(dirs, path)
+			}
+		}
+	}
+
+	sizes := map[string]uint64{}
+	for _, dir := range dirs {
+		sizes[dir] = directorySize(dir)
+	}
+
+	sort.Slice(dirs, func(i, j int) bool { return sizes[dirs[i]] > sizes[dirs[j]] })
+	consumers := map[string]uint64{}
+	for i := 0; i < len(dirs) && i < topConsumers; i++ {
+		consumers[dirs[i]] = sizes[dirs[i]]
+	}
+	return consumers
+}
+
+func setLowSpacePercent(c *prop.Change) *dbus.Error {
+	percent, ok := c.Value.(uint32)
+	if !ok || percent > 100 {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid percentage %v", c.Value))
+	}
+
+	spaceMutex.Lock()
+	defer spaceMutex.Unlock()
+
+	config := spaceConfig
+	config.Percent = percent
+	if err := saveSpaceThresholds(config); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't save disk space thresholds: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "DataDisk.LowSpacePercent", spaceConfig.Percent, percent)
+	spaceConfig = config
+	return nil
+}
+
+func setLowSpaceBytes(c *prop.Change) *dbus.Error {
+	bytes, ok := c.Value.(uint64)
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid size %v", c.Value))
+	}
+
+	spaceMutex.Lock()
+	defer spaceMutex.Unlock()
+
+	config := spaceConfig
+	config.Bytes = bytes
+	if err := saveSpaceThresholds(config); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't save disk space thresholds: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "DataDisk.LowSpaceBytes", spaceConfig.Bytes, bytes)
+	spaceConfig = config
+	return nil
+}
+
+// watchDiskSpace only runs if there is a data partition, see spaceWorker.
+func (d datadisk) watchDiskSpace(ctx context.Context) {
+	lowSpace := false
+
+	for {
+		free, total := getFreeSpace()
+		d.props.SetMust(ifaceName, "FreeSpace", free)
+
+		spaceMutex.Lock()
+		config := spaceConfig
+		spaceMutex.Unlock()
+
+		low := total > 0 && (free*100/total < uint64(config.Percent) || free < config.Bytes)
+		if low && !lowSpace {
+			logging.Warning.Printf("Data disk is running out of space, %d bytes free!", free)
+			err := d.conn.Emit(objectPath, ifaceName+".LowDiskSpace", free, getTopConsumers())
+			if err != nil {
+				logging.Warning.Printf("Can't emit LowDiskSpace signal: %s", err)
+			}
+		}
+		lowSpace = low
+
+		if !worker.Sleep(ctx, spaceCheckPeriod) {
+			return
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package datadisk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/udisks2"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	temperatureConfigFile   = "/etc/os-agent/drive-temperature.json"
+	temperatureCheckPeriod  = time.Minute
+	defaultTemperatureLimit = 70.0
+	kelvinOffset            = 273.15
+)
+
+var (
+	temperatureMutex  sync.Mutex
+	temperatureLimit  = defaultTemperatureLimit
+	temperatureWorker *worker.Worker
+
+	temperatureSignals = []introspect.Signal{
+		{
+			Name: "OverTemperature",
+			Args: []introspect.Arg{
+				{Name: "temperature", Type: "d"},
+			},
+		},
+	}
+)
+
+func loadTemperatureLimit() float64 {
+	config := struct {
+		Limit float64 `json:"limit"`
+	}{defaultTemperatureLimit}
+
+	data, err := ioutil.ReadFile(temperatureConfigFile)
+	if err != nil {
+		return config.Limit
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid drive temperature config in %s", temperatureConfigFile)
+		return defaultTemperatureLimit
+	}
+	return config.Limit
+}
+
+func saveTemperatureLimit(limit float64) error {
+	data, err := json.Marshal(map[string]float64{"limit": limit})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(temperatureConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(temperatureConfigFile, data, 0644)
+}
+
+// readHwmonTemperature reads the drive sensor exposed by the nvme or
+// drivetemp kernel drivers for the disk holding the partition.
+func readHwmonTemperature(partition string) (float64, bool) {
+	disk, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", filepath.Base(partition)))
+	if err != nil {
+		return 0, false
+	}
+	if _, err = os.Stat(filepath.Join(disk, "partition")); err == nil {
+		disk = filepath.Dir(disk)
+	}
+
+	inputs, _ := filepath.Glob(filepath.Join(disk, "device", "hwmon", "hwmon*", "temp1_input"))
+	nvmeInputs, _ := filepath.Glob(filepath.Join(disk, "device", "hwmon*", "temp1_input"))
+	for _, input := range append(inputs, nvmeInputs...) {
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil {
+			return float64(value) / 1000, true
+		}
+	}
+	return 0, false
+}
+
+// getDriveTemperature returns the data disk drive temperature in degrees
+// Celsius, or 0 if the drive has no sensor.
+func (d datadisk) getDriveTemperature() float64 {
+	mountInfo, err := GetDataMount()
+	if err != nil {
+		return 0
+	}
+	if temperature, ok := readHwmonTemperature(mountInfo.MountSource); ok {
+		return temperature
+	}
+
+	// Fall back to SMART attribute 194 as reported by UDisks2 (in Kelvin).
+	udisks2helper := newUDisks2(d.conn)
+	drive, err := udisks2helper.GetDriveFromLabel("hassos-data")
+	if err != nil {
+		return 0
+	}
+	kelvin, err := udisks2.NewDriveAta(drive).GetSmartTemperature(context.Background())
+	if err != nil || kelvin == 0 {
+		return 0
+	}
+	return kelvin - kelvinOffset
+}
+
+func setTemperatureLimit(c *prop.Change) *dbus.Error {
+	limit, ok := c.Value.(float64)
+	if !ok || limit <= 0 {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid temperature limit %v", c.Value))
+	}
+
+	temperatureMutex.Lock()
+	defer temperatureMutex.Unlock()
+
+	if err := saveTemperatureLimit(limit); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't save temperature limit: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "DataDisk.TemperatureLimit", temperatureLimit, limit)
+	temperatureLimit = limit
+	return nil
+}
+
+// watchDriveTemperature only runs if the data disk has a temperature
+// sensor, see temperatureWorker.
+func (d datadisk) watchDriveTemperature(ctx context.Context) {
+	overTemperature := false
+
+	for {
+		temperature := d.getDriveTemperature()
+		d.props.SetMust(ifaceName, "DriveTemperature", temperature)
+
+		temperatureMutex.Lock()
+		limit := temperatureLimit
+		temperatureMutex.Unlock()
+
+		if temperature >= limit && !overTemperature {
+			logging.Warning.Printf("Data disk drive temperature %.1f°C exceeds %.1f°C!", temperature, limit)
+			err := d.conn.Emit(objectPath, ifaceName+".OverTemperature", temperature)
+			if err != nil {
+				logging.Warning.Printf("Can't emit OverTemperature signal: %s", err)
+			}
+		}
+		overTemperature = temperature >= limit
+
+		if !worker.Sleep(ctx, temperatureCheckPeriod) {
+			return
+		}
+	}
+}
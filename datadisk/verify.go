@@ -0,0 +1,201 @@
+package datadisk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	verifyProgressPeriod = 10 * time.Second
+)
+
+var (
+	verifyMutex   sync.Mutex
+	verifyRunning bool
+
+	badblocksProgressRegex = regexp.MustCompile(`([0-9.]+)% done`)
+	scrubBytesRegex        = regexp.MustCompile(`(?m)^(data|tree)_bytes_scrubbed: ([0-9]+)$`)
+
+	verifySignals = []introspect.Signal{
+		{
+			Name: "VerifyProgress",
+			Args: []introspect.Arg{
+				{Name: "percentage", Type: "u"},
+			},
+		},
+		{
+			Name: "VerifyFinished",
+			Args: []introspect.Arg{
+				{Name: "success", Type: "b"},
+				{Name: "report", Type: "s"},
+			},
+		},
+	}
+)
+
+func (d datadisk) emitVerifyProgress(job *jobs.Job, percentage uint32) {
+	job.SetProgress(percentage)
+	err := d.conn.Emit(objectPath, ifaceName+".VerifyProgress", percentage)
+	if err != nil {
+		logging.Warning.Printf("Can't emit VerifyProgress signal: %s", err)
+	}
+}
+
+// scrubData runs a btrfs scrub in the foreground, reading back all data and
+// metadata and verifying their checksums without repairing anything. The
+// scrub runs in the kernel, so cancelling the job cancels the scrub itself.
+func (d datadisk) scrubData(job *jobs.Job) (bool, string) {
+	total := usedBytes(dataMount)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(verifyProgressPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-job.Context().Done():
+				exec.Command(btrfsCmd, "scrub", "cancel", dataMount).Run()
+				return
+			case <-ticker.C:
+				out, _ := exec.Command(btrfsCmd, "scrub", "status", "-R", dataMount).Output()
+				var scrubbed uint64
+				for _, match := range scrubBytesRegex.FindAllStringSubmatch(string(out), -1) {
+					value, _ := strconv.ParseUint(match[2], 10, 64)
+					scrubbed += value
+				}
+				if total > 0 && scrubbed < total {
+					d.emitVerifyProgress(job, uint32(scrubbed*100/total))
+				}
+			}
+		}
+	}()
+
+	out, err := exec.Command(btrfsCmd, "scrub", "start", "-B", "-r", dataMount).CombinedOutput()
+	close(done)
+	return err == nil, string(out)
+}
+
+// readTestData runs a read-only badblocks pass over the data partition.
+func (d datadisk) readTestData(job *jobs.Job, device string) (bool, string) {
+	cmd := exec.CommandContext(job.Context(), "badblocks", "-b", "4096", "-s", "-v", device)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return false, err.Error()
+	}
+	if err = cmd.Start(); err != nil {
+		return false, err.Error()
+	}
+
+	var report []string
+	lastUpdate := time.Now()
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		match := badblocksProgressRegex.FindStringSubmatch(line)
+		if match == nil {
+			if line != "" {
+				report = append(report, line)
+			}
+			continue
+		}
+		if time.Since(lastUpdate) >= verifyProgressPeriod {
+			percentage, _ := strconv.ParseFloat(match[1], 64)
+			d.emitVerifyProgress(job, uint32(percentage))
+			lastUpdate = time.Now()
+		}
+	}
+
+	err = cmd.Wait()
+	if bad := strings.TrimSpace(stdout.String()); bad != "" {
+		report = append(report, "Bad blocks: "+strings.Join(strings.Fields(bad), ","))
+		return false, strings.Join(report, "\n")
+	}
+	return err == nil, strings.Join(report, "\n")
+}
+
+// scanProgressLines splits on carriage returns as well, badblocks redraws its
+// progress line in place.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// VerifyDataDisk starts a read-only integrity check of the data disk in the
+// background: a btrfs scrub or a badblocks read test for other filesystems.
+// Progress is reported through VerifyProgress and a cancellable verify job,
+// the result through VerifyFinished and the LastVerifyReport property.
+func (d datadisk) VerifyDataDisk() (bool, *dbus.Error) {
+	mountInfo, err := GetDataMount()
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	verifyMutex.Lock()
+	defer verifyMutex.Unlock()
+	if verifyRunning {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "Data disk verification already in progress").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+	verifyRunning = true
+
+	logging.Info.Printf("Verify data disk %s (%s).", mountInfo.MountSource, mountInfo.FilesystemType)
+
+	job := jobs.Start("verify", true)
+
+	go func() {
+		var success bool
+		var report string
+		if mountInfo.FilesystemType == "btrfs" {
+			job.SetStage("scrub")
+			success, report = d.scrubData(job)
+		} else {
+			job.SetStage("read_test")
+			success, report = d.readTestData(job, mountInfo.MountSource)
+		}
+
+		if success {
+			job.Finish(nil)
+		} else {
+			job.Finish(fmt.Errorf("%s", report))
+		}
+
+		logging.Info.Printf("Data disk verification finished (success: %t): %s", success, report)
+
+		verifyMutex.Lock()
+		verifyRunning = false
+		verifyMutex.Unlock()
+
+		d.props.SetMust(ifaceName, "LastVerifyReport", report)
+		err := d.conn.Emit(objectPath, ifaceName+".VerifyFinished", success, report)
+		if err != nil {
+			logging.Warning.Printf("Can't emit VerifyFinished signal: %s", err)
+		}
+	}()
+	return true, nil
+}
@@ -0,0 +1,159 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/unixfd"
+)
+
+const (
+	coreDumpConfigFile = "/etc/os-agent/coredump.json"
+	coreDumpDropIn     = "/etc/systemd/coredump.conf.d/os-agent.conf"
+	coreDumpDir        = "/var/lib/systemd/coredump"
+)
+
+// systemd-coredump names files core.<comm>.<uid>.<boot id>.<pid>.<usec>[.<compression>]
+var coreDumpRegex = regexp.MustCompile(`^core\.([^/]+)\.(\d+)\.([0-9a-f]{32})\.(\d+)\.(\d+)(\.[a-z0-9]+)?$`)
+
+type coreDumpLimits struct {
+	ProcessSizeMax uint64 `json:"process_size_max"`
+	MaxUse         uint64 `json:"max_use"`
+}
+
+var (
+	coreDumpMutex sync.Mutex
+
+	// Keep dumps of small host daemons without filling the data disk
+	defaultCoreDumpLimits = coreDumpLimits{
+		ProcessSizeMax: 512 * 1024 * 1024,
+		MaxUse:         1024 * 1024 * 1024,
+	}
+)
+
+func loadCoreDumpLimits() coreDumpLimits {
+	limits := defaultCoreDumpLimits
+
+	data, err := ioutil.ReadFile(coreDumpConfigFile)
+	if err != nil {
+		return limits
+	}
+	if err = json.Unmarshal(data, &limits); err != nil {
+		logging.Error.Printf("Ignoring invalid core dump limits in %s", coreDumpConfigFile)
+		return defaultCoreDumpLimits
+	}
+	return limits
+}
+
+func saveCoreDumpLimits(limits coreDumpLimits) error {
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(coreDumpConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(coreDumpConfigFile, data, 0644)
+}
+
+func getCoreDumpLimits() map[string]uint64 {
+	limits := loadCoreDumpLimits()
+	return map[string]uint64{
+		"ProcessSizeMax": limits.ProcessSizeMax,
+		"MaxUse":         limits.MaxUse,
+	}
+}
+
+// SetCoreDumpLimits configures how large a single core dump and all stored
+// core dumps may get in bytes. A process size of zero disables storing core
+// dumps.
+func (d diagnostics) SetCoreDumpLimits(sender dbus.Sender, processSizeMax uint64, maxUse uint64) (bool, *dbus.Error) {
+	coreDumpMutex.Lock()
+	defer coreDumpMutex.Unlock()
+
+	logging.Info.Printf("Set core dump limits to %d bytes per process and %d bytes in total.", processSizeMax, maxUse)
+
+	storage := "external"
+	if processSizeMax == 0 {
+		storage = "none"
+	}
+	content := fmt.Sprintf("[Coredump]\nStorage=%s\nCompress=yes\nProcessSizeMax=%d\nExternalSizeMax=%d\nMaxUse=%d\n",
+		storage, processSizeMax, processSizeMax, maxUse)
+
+	if err := managedfiles.Write(coreDumpDropIn, []byte(content), 0644, "diagnostics"); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't write %s: %s", coreDumpDropIn, err))
+	}
+
+	old := loadCoreDumpLimits()
+	limits := coreDumpLimits{ProcessSizeMax: processSizeMax, MaxUse: maxUse}
+	if err := saveCoreDumpLimits(limits); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't persist core dump limits: %s", err))
+	}
+
+	audit.Record(sender, "Diagnostics.SetCoreDumpLimits", old, limits)
+	d.props.SetMust(ifaceName, "CoreDumpLimits", getCoreDumpLimits())
+	return true, nil
+}
+
+// ListCoreDumps returns the stored core dumps of host processes with their
+// ID, executable, pid, uid, time and size in bytes.
+func (d diagnostics) ListCoreDumps() ([]map[string]string, *dbus.Error) {
+	entries, err := ioutil.ReadDir(coreDumpDir)
+	if os.IsNotExist(err) {
+		return []map[string]string{}, nil
+	} else if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't list core dumps: %s", err))
+	}
+
+	dumps := []map[string]string{}
+	for _, entry := range entries {
+		match := coreDumpRegex.FindStringSubmatch(entry.Name())
+		if match == nil || !entry.Mode().IsRegular() {
+			continue
+		}
+
+		usec, _ := strconv.ParseInt(match[5], 10, 64)
+		dumps = append(dumps, map[string]string{
+			"id":         entry.Name(),
+			"executable": match[1],
+			"uid":        match[2],
+			"pid":        match[4],
+			"time":       time.Unix(0, usec*int64(time.Microsecond)).UTC().Format(time.RFC3339),
+			"size":       strconv.FormatInt(entry.Size(), 10),
+		})
+	}
+	return dumps, nil
+}
+
+// FetchCoreDump returns a file descriptor to a core dump as stored by
+// systemd-coredump, usually compressed.
+func (d diagnostics) FetchCoreDump(dumpID string) (dbus.UnixFD, *dbus.Error) {
+	logging.Info.Printf("Fetch core dump %s.", dumpID)
+
+	if !coreDumpRegex.MatchString(dumpID) || filepath.Base(dumpID) != dumpID {
+		return -1, dbus.MakeFailedError(fmt.Errorf("Invalid core dump ID '%s'", dumpID))
+	}
+
+	// The directory is root owned, a symlink is never a dump
+	file, err := os.OpenFile(filepath.Join(coreDumpDir, dumpID), os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return -1, dbus.MakeFailedError(fmt.Errorf("Can't open core dump %s: %s", dumpID, err))
+	}
+
+	return unixfd.Export(file), nil
+}
@@ -0,0 +1,236 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/jobs"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/unixfd"
+)
+
+// Crash artifacts by source. pstore entries are single files which are
+// erased from the backend (e.g. ramoops or EFI variables) on removal,
+// systemd-pstore and kdump store one directory per crash.
+var crashSources = map[string]string{
+	"pstore":         "/sys/fs/pstore",
+	"systemd-pstore": "/var/lib/systemd/pstore",
+	"kdump":          "/var/crash",
+}
+
+// crashReportDirectory holds the archives being fetched.
+const crashReportDirectory = "/mnt/data/os-agent"
+
+var crashReportIDRegex = regexp.MustCompile(`^([a-z-]+)/([A-Za-z0-9._:-]+)$`)
+
+func crashReportPath(reportID string) (string, error) {
+	match := crashReportIDRegex.FindStringSubmatch(reportID)
+	if match == nil || match[2] == "." || match[2] == ".." {
+		return "", fmt.Errorf("Invalid crash report ID '%s'", reportID)
+	}
+	root, ok := crashSources[match[1]]
+	if !ok {
+		return "", fmt.Errorf("Unknown crash report source '%s'", match[1])
+	}
+	return filepath.Join(root, match[2]), nil
+}
+
+func reportSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// ListCrashReports returns the kernel crash reports left on the device with
+// their ID, source, time and size in bytes.
+func (d diagnostics) ListCrashReports() ([]map[string]string, *dbus.Error) {
+	reports := []map[string]string{}
+	for source, root := range crashSources {
+		entries, err := ioutil.ReadDir(root)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Can't list crash reports in %s: %s", root, err))
+		}
+
+		for _, entry := range entries {
+			reports = append(reports, map[string]string{
+				"id":     source + "/" + entry.Name(),
+				"source": source,
+				"time":   entry.ModTime().UTC().Format(time.RFC3339),
+				"size":   strconv.FormatInt(reportSize(filepath.Join(root, entry.Name())), 10),
+			})
+		}
+	}
+	return reports, nil
+}
+
+// writeCrashFile adds a regular file to the archive. Artifacts are streamed
+// with the size from the walk, only pstore entries are read whole first as
+// some backends report a size of zero.
+func writeCrashFile(writer *tar.Writer, header *tar.Header, file string, readWhole bool) error {
+	if readWhole {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		header.Size = int64(len(data))
+		if err = writer.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	}
+
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err = writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(writer, in, header.Size)
+	return err
+}
+
+func writeCrashReport(ctx context.Context, out io.Writer, path string) error {
+	gz := gzip.NewWriter(out)
+	writer := tar.NewWriter(gz)
+
+	readWhole := filepath.Dir(path) == crashSources["pstore"]
+	base := filepath.Dir(path)
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = strings.TrimPrefix(file, base+"/")
+		if info.IsDir() {
+			header.Name += "/"
+			return writer.WriteHeader(header)
+		}
+		return writeCrashFile(writer, header, file, readWhole)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// crashReportFiles creates an unlinked file on the data partition, kdump
+// vmcores don't fit into a tmpfs. It returns separate handles for writing
+// and reading so the reader's offset stays at the start.
+func crashReportFiles() (*os.File, *os.File, error) {
+	if err := os.MkdirAll(crashReportDirectory, 0700); err != nil {
+		return nil, nil, err
+	}
+	out, err := ioutil.TempFile(crashReportDirectory, "crash-")
+	if err != nil {
+		return nil, nil, err
+	}
+	// Only the descriptors are handed out, the file itself is not needed.
+	defer os.Remove(out.Name())
+
+	in, err := os.Open(out.Name())
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+	return out, in, nil
+}
+
+// FetchCrashReport returns a file descriptor to a gzipped tarball of a crash
+// report and a cancellable job writing it in the background. The tarball is
+// complete once the job succeeded.
+func (d diagnostics) FetchCrashReport(reportID string) (dbus.UnixFD, dbus.ObjectPath, *dbus.Error) {
+	logging.Info.Printf("Fetch crash report %s.", reportID)
+
+	path, err := crashReportPath(reportID)
+	if err != nil {
+		return -1, "/", dbus.MakeFailedError(err)
+	}
+	if _, err = os.Stat(path); err != nil {
+		return -1, "/", dbus.MakeFailedError(fmt.Errorf("Can't find crash report %s: %s", reportID, err))
+	}
+
+	out, in, err := crashReportFiles()
+	if err != nil {
+		return -1, "/", dbus.MakeFailedError(fmt.Errorf("Can't create crash report archive: %s", err))
+	}
+
+	job := jobs.Start("crash-report", true)
+	job.SetStage("archive")
+
+	go func() {
+		err := writeCrashReport(job.Context(), out, path)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			logging.Error.Printf("Can't fetch crash report %s: %s", reportID, err)
+		}
+		job.Finish(err)
+	}()
+
+	return unixfd.Export(in), job.Path(), nil
+}
+
+// PurgeCrashReports removes all crash reports, which also frees the space
+// of the pstore backend for the next crash.
+func (d diagnostics) PurgeCrashReports(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Purge crash reports.")
+
+	purged := []string{}
+	for source, root := range crashSources {
+		entries, err := ioutil.ReadDir(root)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't list crash reports in %s: %s", root, err))
+		}
+
+		for _, entry := range entries {
+			if err = os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+				return false, dbus.MakeFailedError(fmt.Errorf("Can't remove crash report %s/%s: %s", source, entry.Name(), err))
+			}
+			purged = append(purged, source+"/"+entry.Name())
+		}
+	}
+
+	audit.Record(sender, "Diagnostics.PurgeCrashReports", purged, "")
+	return true, nil
+}
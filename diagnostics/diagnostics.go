@@ -0,0 +1,84 @@
+package diagnostics
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Diagnostics"
+	ifaceName  = "io.hass.os.Diagnostics"
+)
+
+type diagnostics struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"ListCrashReports":  {"reports"},
+	"FetchCrashReport":  {"report_id", "fd", "job"},
+	"PurgeCrashReports": {"success"},
+	"GetOOMEvents":      {"events"},
+	"SetCoreDumpLimits": {"process_size_max", "max_use", "success"},
+	"ListCoreDumps":     {"dumps"},
+	"FetchCoreDump":     {"dump_id", "fd"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := diagnostics{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"CoreDumpLimits": {
+				Value:    getCoreDumpLimits(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    oomSignals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchOOM()
+}
@@ -0,0 +1,137 @@
+package diagnostics
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	kmsgDevice   = "/dev/kmsg"
+	oomKillEntry = "oom-kill:"
+	oomEventsMax = 50
+)
+
+var (
+	// Matches both cgroupfs (/docker/<id>) and systemd (docker-<id>.scope) layouts
+	containerIDRegex = regexp.MustCompile(`docker[/-]([0-9a-f]{64})`)
+
+	oomEventsLock sync.Mutex
+	oomEvents     = []map[string]string{}
+)
+
+var oomSignals = []introspect.Signal{
+	{
+		Name: "OOMKill",
+		Args: []introspect.Arg{
+			{Name: "task", Type: "s"},
+			{Name: "pid", Type: "u"},
+			{Name: "cgroup", Type: "s"},
+			{Name: "container", Type: "s"},
+		},
+	},
+}
+
+// parseOOMKill parses the summary line the kernel logs per OOM kill, e.g.
+// "oom-kill:constraint=CONSTRAINT_NONE,...,task_memcg=/docker/<id>,task=python3,pid=1234,uid=0".
+func parseOOMKill(message string) map[string]string {
+	index := strings.Index(message, oomKillEntry)
+	if index < 0 {
+		return nil
+	}
+
+	fields := map[string]string{}
+	for _, field := range strings.Split(message[index+len(oomKillEntry):], ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	event := map[string]string{
+		"time":       time.Now().UTC().Format(time.RFC3339),
+		"task":       fields["task"],
+		"pid":        fields["pid"],
+		"cgroup":     fields["task_memcg"],
+		"constraint": fields["constraint"],
+		"container":  "",
+	}
+	if match := containerIDRegex.FindStringSubmatch(fields["task_memcg"]); match != nil {
+		event["container"] = match[1]
+	}
+	return event
+}
+
+// GetOOMEvents returns the most recent OOM kills on the host.
+func (d diagnostics) GetOOMEvents() ([]map[string]string, *dbus.Error) {
+	oomEventsLock.Lock()
+	defer oomEventsLock.Unlock()
+
+	events := make([]map[string]string, len(oomEvents))
+	copy(events, oomEvents)
+	return events, nil
+}
+
+func (d diagnostics) recordOOMKill(event map[string]string) {
+	logging.Warning.Printf("OOM killer terminated %s (pid %s) in %s", event["task"], event["pid"], event["cgroup"])
+
+	oomEventsLock.Lock()
+	oomEvents = append(oomEvents, event)
+	if len(oomEvents) > oomEventsMax {
+		oomEvents = oomEvents[len(oomEvents)-oomEventsMax:]
+	}
+	oomEventsLock.Unlock()
+
+	pid, _ := strconv.ParseUint(event["pid"], 10, 32)
+	err := d.conn.Emit(objectPath, ifaceName+".OOMKill", event["task"], uint32(pid), event["cgroup"], event["container"])
+	if err != nil {
+		logging.Warning.Printf("Can't emit OOMKill signal: %s", err)
+	}
+}
+
+// watchOOM follows the kernel log for OOM kills.
+func (d diagnostics) watchOOM() {
+	kmsg, err := os.Open(kmsgDevice)
+	if err != nil {
+		logging.Warning.Printf("Can't open %s, OOM kills are not reported: %s", kmsgDevice, err)
+		return
+	}
+	defer kmsg.Close()
+
+	// Only report kills from now on
+	if _, err = kmsg.Seek(0, io.SeekEnd); err != nil {
+		logging.Warning.Printf("Can't seek %s: %s", kmsgDevice, err)
+	}
+
+	// Every read returns exactly one record: "prio,seq,time,flags;message"
+	buf := make([]byte, 8192)
+	for {
+		n, err := kmsg.Read(buf)
+		if err != nil {
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EPIPE {
+				// Records were overwritten before we read them
+				continue
+			}
+			logging.Warning.Printf("Can't read %s: %s", kmsgDevice, err)
+			return
+		}
+
+		record := string(buf[:n])
+		if index := strings.Index(record, ";"); index >= 0 {
+			record = record[index+1:]
+		}
+		if event := parseOOMKill(record); event != nil {
+			d.recordOOMKill(event)
+		}
+	}
+}
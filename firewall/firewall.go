@@ -0,0 +1,283 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath       = "/io/hass/os/Firewall"
+	ifaceName        = "io.hass.os.Firewall"
+	nftCmd           = "nft"
+	nftTable         = "os_agent"
+	rulesFile        = "/etc/os-agent/firewall.json"
+	maxRevertTimeout = 600
+)
+
+// Rule allows or drops incoming traffic to a host port. Traffic to ports
+// without a rule is dropped, except for the required services.
+type Rule struct {
+	Port     uint16
+	Protocol string
+	Action   string
+}
+
+// Services the host needs to stay reachable and configured, accepted after
+// the rules of the caller, so those can still drop them.
+var requiredServices = []Rule{
+	{Port: 22, Protocol: "tcp", Action: "accept"},    // SSH
+	{Port: 22222, Protocol: "tcp", Action: "accept"}, // Debug SSH
+	{Port: 4357, Protocol: "tcp", Action: "accept"},  // Observer
+	{Port: 8123, Protocol: "tcp", Action: "accept"},  // Home Assistant
+	{Port: 5353, Protocol: "udp", Action: "accept"},  // mDNS
+	{Port: 68, Protocol: "udp", Action: "accept"},    // DHCP client
+	{Port: 546, Protocol: "udp", Action: "accept"},   // DHCPv6 client
+}
+
+var (
+	lock         sync.Mutex
+	activeRules  = []Rule{}
+	pendingRules []Rule
+	revertTimer  *time.Timer
+)
+
+type firewall struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func validateRules(rules []Rule) error {
+	for _, rule := range rules {
+		if rule.Port == 0 {
+			return fmt.Errorf("Invalid port 0")
+		}
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return fmt.Errorf("Invalid protocol '%s'", rule.Protocol)
+		}
+		if rule.Action != "accept" && rule.Action != "drop" {
+			return fmt.Errorf("Invalid action '%s'", rule.Action)
+		}
+	}
+	return nil
+}
+
+func buildRuleset(rules []Rule) string {
+	var b strings.Builder
+
+	// Create and delete first, so loading the ruleset replaces the table atomically
+	fmt.Fprintf(&b, "table inet %s\ndelete table inet %s\n", nftTable, nftTable)
+	fmt.Fprintf(&b, "table inet %s {\n", nftTable)
+	b.WriteString("\tchain input {\n\t\ttype filter hook input priority 0; policy drop;\n")
+	b.WriteString("\t\tct state established,related accept\n")
+	b.WriteString("\t\tct state invalid drop\n")
+	b.WriteString("\t\tiif lo accept\n")
+	b.WriteString("\t\tiifname { \"hassio\", \"docker0\" } accept\n")
+	b.WriteString("\t\tmeta l4proto { icmp, ipv6-icmp } accept\n")
+	for _, rule := range append(append([]Rule{}, rules...), requiredServices...) {
+		fmt.Fprintf(&b, "\t\t%s dport %d %s\n", rule.Protocol, rule.Port, rule.Action)
+	}
+	b.WriteString("\t}\n}\n")
+
+	return b.String()
+}
+
+func applyRules(rules []Rule) error {
+	cmd := exec.Command(nftCmd, "-f", "-")
+	cmd.Stdin = strings.NewReader(buildRuleset(rules))
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Can't apply firewall rules: %s, output %s", err, out)
+	}
+	return nil
+}
+
+func loadRules() []Rule {
+	rules := []Rule{}
+
+	data, err := ioutil.ReadFile(rulesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warning.Printf("Can't read firewall rules %s: %s", rulesFile, err)
+		}
+		return rules
+	}
+
+	err = json.Unmarshal(data, &rules)
+	if err != nil || validateRules(rules) != nil {
+		logging.Error.Printf("Ignoring invalid firewall rules in %s", rulesFile)
+		return []Rule{}
+	}
+	return rules
+}
+
+func saveRules(rules []Rule) error {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(rulesFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(rulesFile, data, 0644)
+}
+
+// SetRules applies the rules immediately. Unless ConfirmRules is called
+// within timeout seconds, the previous rules are restored.
+func (d firewall) SetRules(sender dbus.Sender, rules []Rule, timeout uint32) (bool, *dbus.Error) {
+	logging.Info.Printf("Apply %d firewall rules, revert in %d seconds.", len(rules), timeout)
+
+	if err := validateRules(rules); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if timeout == 0 || timeout > maxRevertTimeout {
+		return false, dbus.MakeFailedError(fmt.Errorf("Revert timeout must be between 1 and %d seconds", maxRevertTimeout))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := applyRules(rules); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	if revertTimer != nil {
+		revertTimer.Stop()
+	}
+	pendingRules = rules
+	revertTimer = time.AfterFunc(time.Duration(timeout)*time.Second, d.revertRules)
+
+	d.props.SetMust(ifaceName, "PendingConfirmation", true)
+	audit.Record(sender, "Firewall.SetRules", activeRules, rules)
+	return true, nil
+}
+
+func (d firewall) revertRules() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if pendingRules == nil {
+		return
+	}
+
+	logging.Warning.Printf("Firewall rules not confirmed, reverting.")
+	if err := applyRules(activeRules); err != nil {
+		logging.Error.Printf("%s", err)
+	}
+	pendingRules = nil
+	revertTimer = nil
+
+	d.props.SetMust(ifaceName, "PendingConfirmation", false)
+}
+
+func (d firewall) ConfirmRules(sender dbus.Sender) (bool, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if pendingRules == nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("No firewall rules pending confirmation"))
+	}
+
+	// The revert stays armed until the rules are persisted
+	if err := saveRules(pendingRules); err != nil {
+		logging.Error.Printf("Failed to persist firewall rules to %s: %s", rulesFile, err)
+		return false, dbus.MakeFailedError(err)
+	}
+	revertTimer.Stop()
+	revertTimer = nil
+
+	logging.Info.Printf("Firewall rules confirmed.")
+	activeRules = pendingRules
+	pendingRules = nil
+
+	d.props.SetMust(ifaceName, "Rules", activeRules)
+	d.props.SetMust(ifaceName, "PendingConfirmation", false)
+	audit.Record(sender, "Firewall.ConfirmRules", "", activeRules)
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"SetRules":     {"rules", "timeout", "success"},
+	"ConfirmRules": {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := firewall{
+		conn: conn,
+	}
+
+	activeRules = loadRules()
+	if len(activeRules) > 0 {
+		if err := applyRules(activeRules); err != nil {
+			logging.Error.Printf("%s", err)
+		}
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Rules": {
+				Value:    activeRules,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"PendingConfirmation": {
+				Value:    false,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
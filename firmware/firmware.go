@@ -0,0 +1,235 @@
+package firmware
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath       = "/io/hass/os/Firmware"
+	ifaceName        = "io.hass.os.Firmware"
+	fwupdBusName     = "org.freedesktop.fwupd"
+	fwupdObjectPath  = "/"
+	fwupdIfaceName   = "org.freedesktop.fwupd"
+	fwupdMgrCmd      = "fwupdmgr"
+	propertiesIface  = "org.freedesktop.DBus.Properties"
+	propertiesSignal = propertiesIface + ".PropertiesChanged"
+)
+
+// fwupd device IDs are SHA1 hashes
+var deviceIDRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+var (
+	updateMutex sync.Mutex
+	// Job of the running update, nil if none
+	updateJob *jobs.Job
+)
+
+type firmware struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func flattenDevice(device map[string]dbus.Variant) map[string]string {
+	result := map[string]string{}
+	for _, key := range []string{"DeviceId", "Name", "Vendor", "Version", "Summary"} {
+		if value, ok := device[key]; ok {
+			result[key] = fmt.Sprint(value.Value())
+		}
+	}
+	return result
+}
+
+func (d firmware) ListDevices() ([]map[string]string, *dbus.Error) {
+	var devices []map[string]dbus.Variant
+
+	obj := d.conn.Object(fwupdBusName, fwupdObjectPath)
+	err := obj.Call(fwupdIfaceName+".GetDevices", 0).Store(&devices)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't list firmware devices: %s", err))
+	}
+
+	result := []map[string]string{}
+	for _, device := range devices {
+		result = append(result, flattenDevice(device))
+	}
+	return result, nil
+}
+
+func (d firmware) ListUpdates(deviceID string) ([]map[string]string, *dbus.Error) {
+	var releases []map[string]dbus.Variant
+
+	obj := d.conn.Object(fwupdBusName, fwupdObjectPath)
+	err := obj.Call(fwupdIfaceName+".GetUpgrades", 0, deviceID).Store(&releases)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't list firmware updates for %s: %s", deviceID, err))
+	}
+
+	result := []map[string]string{}
+	for _, release := range releases {
+		result = append(result, flattenDevice(release))
+	}
+	return result, nil
+}
+
+// UpdateDevice downloads and installs the latest firmware for a device in
+// the background. fwupd itself only installs local cabinet files, so the
+// download is left to fwupdmgr. Progress is reported through the Progress
+// signal and a firmware-update job, the result through the job.
+func (d firmware) UpdateDevice(sender dbus.Sender, deviceID string) (bool, *dbus.Error) {
+	logging.Info.Printf("Update firmware of device %s.", deviceID)
+
+	if !deviceIDRegex.MatchString(deviceID) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid device ID '%s'", deviceID))
+	}
+
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	if updateJob != nil {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "Firmware update already in progress").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+
+	// Interrupting a flash can brick the device, so the job isn't
+	// cancellable.
+	job := jobs.Start("firmware-update", false)
+	job.SetStage("install")
+	updateJob = job
+
+	go func() {
+		cmd := exec.Command(fwupdMgrCmd, "update", deviceID, "--assume-yes", "--no-reboot-check")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("Can't update firmware of %s: %s, output %s", deviceID, err, out)
+			logging.Error.Printf("%s", err)
+		} else {
+			logging.Info.Printf("Firmware update of %s: %s", deviceID, out)
+			audit.Record(sender, "Firmware.UpdateDevice", "", deviceID)
+		}
+
+		updateMutex.Lock()
+		updateJob = nil
+		updateMutex.Unlock()
+		job.Finish(err)
+	}()
+	return true, nil
+}
+
+// forwardProgress re-emits fwupd progress changes from our own object and
+// updates the running firmware-update job.
+func (d firmware) forwardProgress() {
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchSender(fwupdBusName),
+		dbus.WithMatchObjectPath(fwupdObjectPath),
+		dbus.WithMatchInterface(propertiesIface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchOption("arg0", fwupdIfaceName),
+	)
+	if err != nil {
+		logging.Warning.Printf("Can't subscribe to fwupd progress: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+
+	owner := ""
+	for signal := range signals {
+		// The channel gets all signals of the shared connection
+		if signal.Name != propertiesSignal || signal.Path != fwupdObjectPath || len(signal.Body) < 2 {
+			continue
+		}
+		if iface, _ := signal.Body[0].(string); iface != fwupdIfaceName {
+			continue
+		}
+		changed, ok := signal.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			continue
+		}
+		percentage, ok := changed["Percentage"]
+		if !ok {
+			continue
+		}
+		if signal.Sender != owner {
+			// fwupd restarted or another peer uses its path
+			owner = ""
+			d.conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, fwupdBusName).Store(&owner)
+			if signal.Sender != owner {
+				continue
+			}
+		}
+
+		value, _ := percentage.Value().(uint32)
+		updateMutex.Lock()
+		if updateJob != nil {
+			updateJob.SetProgress(value)
+		}
+		updateMutex.Unlock()
+
+		err = d.conn.Emit(objectPath, ifaceName+".Progress", value)
+		if err != nil {
+			logging.Warning.Printf("Can't emit Progress signal: %s", err)
+		}
+	}
+}
+
+var methodArgNames = map[string][]string{
+	"ListDevices":  {"devices"},
+	"ListUpdates":  {"device_id", "releases"},
+	"UpdateDevice": {"device_id", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := firmware{
+		conn: conn,
+	}
+
+	err := recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:    ifaceName,
+				Methods: introspection.Methods(d, methodArgNames),
+				Signals: []introspect.Signal{
+					{
+						Name: "Progress",
+						Args: []introspect.Arg{
+							{Name: "percentage", Type: "u"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, nil, ifaceName)
+
+	go d.forwardProgress()
+}
@@ -0,0 +1,392 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/polkit"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath       = "/io/hass/os/GPIO"
+	ifaceName        = "io.hass.os.GPIO"
+	actionClaimLine  = "io.hass.os.gpio"
+	devicePath       = "/dev"
+	consumerLabel    = "os-agent"
+	nameOwnerChanged = "org.freedesktop.DBus.NameOwnerChanged"
+)
+
+var chipRegex = regexp.MustCompile(`^gpiochip[0-9]+$`)
+
+var biasFlags = map[string]uint32{
+	"as-is":     0,
+	"disable":   handleFlagBiasOff,
+	"pull-up":   handleFlagBiasUp,
+	"pull-down": handleFlagBiasDown,
+}
+
+// claim is a line requested through the agent, held until the owner
+// releases it or disconnects from the bus.
+type claim struct {
+	owner  string
+	handle *os.File
+	flags  uint32
+}
+
+var (
+	lock   sync.Mutex
+	claims = map[string]*claim{}
+)
+
+type gpio struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func lineKey(chip string, offset uint32) string {
+	return chip + ":" + strconv.FormatUint(uint64(offset), 10)
+}
+
+func openChip(chip string) (*os.File, error) {
+	if !chipRegex.MatchString(chip) {
+		return nil, fmt.Errorf("Invalid GPIO chip '%s'", chip)
+	}
+	return os.Open(filepath.Join(devicePath, chip))
+}
+
+func getClaimedLines() map[string]string {
+	lines := map[string]string{}
+	for key, c := range claims {
+		lines[key] = c.owner
+	}
+	return lines
+}
+
+// ownedClaim returns the claim of a line if it is held by sender, call with
+// lock held.
+func ownedClaim(sender dbus.Sender, chip string, offset uint32) (*claim, error) {
+	c, ok := claims[lineKey(chip, offset)]
+	if !ok || c.owner != string(sender) {
+		return nil, fmt.Errorf("Line %d of %s is not claimed by %s", offset, chip, sender)
+	}
+	return c, nil
+}
+
+func (d gpio) ListChips() ([]map[string]string, *dbus.Error) {
+	paths, _ := filepath.Glob(filepath.Join(devicePath, "gpiochip*"))
+
+	chips := []map[string]string{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Can't open GPIO chip %s: %s", path, err))
+		}
+		info, err := readChipInfo(file)
+		file.Close()
+		if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Can't read GPIO chip %s: %s", path, err))
+		}
+
+		chips = append(chips, map[string]string{
+			"name":  cString(info.Name[:]),
+			"label": cString(info.Label[:]),
+			"lines": strconv.FormatUint(uint64(info.Lines), 10),
+		})
+	}
+	return chips, nil
+}
+
+func (d gpio) ListLines(chip string) ([]map[string]string, *dbus.Error) {
+	file, err := openChip(chip)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't open GPIO chip: %s", err))
+	}
+	defer file.Close()
+
+	info, err := readChipInfo(file)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't read GPIO chip %s: %s", chip, err))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	lines := []map[string]string{}
+	for offset := uint32(0); offset < info.Lines; offset++ {
+		line, err := readLineInfo(file, offset)
+		if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Can't read line %d of %s: %s", offset, chip, err))
+		}
+
+		direction := "input"
+		if line.Flags&lineFlagIsOut != 0 {
+			direction = "output"
+		}
+		bias := "as-is"
+		switch {
+		case line.Flags&lineFlagBiasUp != 0:
+			bias = "pull-up"
+		case line.Flags&lineFlagBiasDown != 0:
+			bias = "pull-down"
+		case line.Flags&lineFlagBiasOff != 0:
+			bias = "disable"
+		}
+
+		owner := ""
+		if c, ok := claims[lineKey(chip, offset)]; ok {
+			owner = c.owner
+		}
+
+		lines = append(lines, map[string]string{
+			"offset":     strconv.FormatUint(uint64(offset), 10),
+			"name":       cString(line.Name[:]),
+			"consumer":   cString(line.Consumer[:]),
+			"direction":  direction,
+			"bias":       bias,
+			"active_low": strconv.FormatBool(line.Flags&lineFlagActiveLow != 0),
+			"used":       strconv.FormatBool(line.Flags&lineFlagKernel != 0),
+			"owner":      owner,
+		})
+	}
+	return lines, nil
+}
+
+// ClaimLine requests a line for the caller. Direction is "input" or
+// "output", bias "as-is", "disable", "pull-up" or "pull-down".
+func (d gpio) ClaimLine(sender dbus.Sender, chip string, offset uint32, direction string, bias string) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionClaimLine); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	flags, ok := biasFlags[bias]
+	if !ok {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid bias '%s'", bias))
+	}
+	switch direction {
+	case "input":
+		flags |= handleFlagInput
+	case "output":
+		flags |= handleFlagOutput
+	default:
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid direction '%s'", direction))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	key := lineKey(chip, offset)
+	if c, ok := claims[key]; ok {
+		return false, dbus.MakeFailedError(fmt.Errorf("Line %s is already claimed by %s", key, c.owner))
+	}
+
+	file, err := openChip(chip)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't open GPIO chip: %s", err))
+	}
+	defer file.Close()
+
+	handle, err := requestLine(file, offset, flags, false, consumerLabel)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't claim line %s: %s", key, err))
+	}
+	claims[key] = &claim{owner: string(sender), handle: handle, flags: flags}
+
+	logging.Info.Printf("GPIO line %s claimed by %s as %s.", key, sender, direction)
+	audit.Record(sender, "GPIO.ClaimLine", "", key)
+	d.props.SetMust(ifaceName, "ClaimedLines", getClaimedLines())
+	return true, nil
+}
+
+func (d gpio) ReleaseLine(sender dbus.Sender, chip string, offset uint32) (bool, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, err := ownedClaim(sender, chip, offset)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	d.release(lineKey(chip, offset), c)
+
+	audit.Record(sender, "GPIO.ReleaseLine", lineKey(chip, offset), "")
+	return true, nil
+}
+
+// release frees a claimed line, call with lock held.
+func (d gpio) release(key string, c *claim) {
+	c.handle.Close()
+	delete(claims, key)
+
+	logging.Info.Printf("GPIO line %s released by %s.", key, c.owner)
+	d.props.SetMust(ifaceName, "ClaimedLines", getClaimedLines())
+}
+
+func (d gpio) ReadLine(sender dbus.Sender, chip string, offset uint32) (bool, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, err := ownedClaim(sender, chip, offset)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	value, err := getLineValue(c.handle)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't read line %d of %s: %s", offset, chip, err))
+	}
+	return value, nil
+}
+
+func (d gpio) WriteLine(sender dbus.Sender, chip string, offset uint32, value bool) (bool, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, err := ownedClaim(sender, chip, offset)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	if c.flags&handleFlagOutput == 0 {
+		return false, dbus.MakeFailedError(fmt.Errorf("Line %d of %s is not an output", offset, chip))
+	}
+
+	if err = setLineValue(c.handle, value); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't write line %d of %s: %s", offset, chip, err))
+	}
+
+	audit.Record(sender, "GPIO.WriteLine", lineKey(chip, offset), value)
+	return true, nil
+}
+
+func (d gpio) SetBias(sender dbus.Sender, chip string, offset uint32, bias string) (bool, *dbus.Error) {
+	biasFlag, ok := biasFlags[bias]
+	if !ok {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid bias '%s'", bias))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	c, err := ownedClaim(sender, chip, offset)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	flags := c.flags&(handleFlagInput|handleFlagOutput) | biasFlag
+	value := false
+	if flags&handleFlagOutput != 0 {
+		value, _ = getLineValue(c.handle)
+	}
+	if err = setLineConfig(c.handle, flags, value); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set bias of line %d of %s: %s", offset, chip, err))
+	}
+	c.flags = flags
+
+	audit.Record(sender, "GPIO.SetBias", lineKey(chip, offset), bias)
+	return true, nil
+}
+
+// watchOwners releases the lines of clients leaving the bus.
+func (d gpio) watchOwners() {
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+	)
+	if err != nil {
+		logging.Warning.Printf("Can't watch bus clients: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+
+	for signal := range signals {
+		if signal.Name != nameOwnerChanged || len(signal.Body) < 3 {
+			continue
+		}
+		name, _ := signal.Body[0].(string)
+		if newOwner, _ := signal.Body[2].(string); newOwner != "" {
+			continue
+		}
+
+		lock.Lock()
+		for key, c := range claims {
+			if c.owner == name {
+				d.release(key, c)
+			}
+		}
+		lock.Unlock()
+	}
+}
+
+var methodArgNames = map[string][]string{
+	"ListChips":   {"chips"},
+	"ListLines":   {"chip", "lines"},
+	"ClaimLine":   {"chip", "offset", "direction", "bias", "success"},
+	"ReleaseLine": {"chip", "offset", "success"},
+	"ReadLine":    {"chip", "offset", "value"},
+	"WriteLine":   {"chip", "offset", "value", "success"},
+	"SetBias":     {"chip", "offset", "bias", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := gpio{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"ClaimedLines": {
+				Value:    getClaimedLines(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchOwners()
+}
@@ -0,0 +1,45 @@
+package gpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// SendPattern drives the given lines of a chip as outputs through steps,
+// each step holding one value per line for delay. The lines are released
+// afterwards, so they must not be claimed by anyone else meanwhile.
+func SendPattern(chip string, offsets []uint32, steps [][]bool, delay time.Duration) error {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, offset := range offsets {
+		if c, ok := claims[lineKey(chip, offset)]; ok {
+			return fmt.Errorf("Line %s is claimed by %s", lineKey(chip, offset), c.owner)
+		}
+	}
+
+	file, err := openChip(chip)
+	if err != nil {
+		return fmt.Errorf("Can't open GPIO chip: %w", err)
+	}
+	defer file.Close()
+
+	handle, err := requestLines(file, offsets, handleFlagOutput, steps[0], consumerLabel)
+	if err != nil {
+		return fmt.Errorf("Can't request lines %v of %s: %w", offsets, chip, err)
+	}
+	defer handle.Close()
+
+	for _, values := range steps[1:] {
+		time.Sleep(delay)
+		if err = setLineValues(handle, values); err != nil {
+			return fmt.Errorf("Can't write lines %v of %s: %w", offsets, chip, err)
+		}
+	}
+	time.Sleep(delay)
+	return nil
+}
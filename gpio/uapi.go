@@ -0,0 +1,151 @@
+package gpio
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// GPIO character device uAPI (v1) from linux/gpio.h
+const (
+	gpioMaxNameSize    = 32
+	gpioHandlesMax     = 64
+	gpioIoctlType      = 0xB4
+	iocWrite           = 1
+	iocRead            = 2
+	lineFlagKernel     = 1 << 0
+	lineFlagIsOut      = 1 << 1
+	lineFlagActiveLow  = 1 << 2
+	lineFlagBiasUp     = 1 << 5
+	lineFlagBiasDown   = 1 << 6
+	lineFlagBiasOff    = 1 << 7
+	handleFlagInput    = 1 << 0
+	handleFlagOutput   = 1 << 1
+	handleFlagBiasUp   = 1 << 5
+	handleFlagBiasDown = 1 << 6
+	handleFlagBiasOff  = 1 << 7
+)
+
+type chipInfo struct {
+	Name  [gpioMaxNameSize]byte
+	Label [gpioMaxNameSize]byte
+	Lines uint32
+}
+
+type lineInfo struct {
+	Offset   uint32
+	Flags    uint32
+	Name     [gpioMaxNameSize]byte
+	Consumer [gpioMaxNameSize]byte
+}
+
+type handleRequest struct {
+	LineOffsets   [gpioHandlesMax]uint32
+	Flags         uint32
+	DefaultValues [gpioHandlesMax]uint8
+	ConsumerLabel [gpioMaxNameSize]byte
+	Lines         uint32
+	Fd            int32
+}
+
+type handleConfig struct {
+	Flags         uint32
+	DefaultValues [gpioHandlesMax]uint8
+	Padding       [4]uint32
+}
+
+type handleData struct {
+	Values [gpioHandlesMax]uint8
+}
+
+func ioc(dir uintptr, nr uintptr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | gpioIoctlType<<8 | nr
+}
+
+var (
+	getChipInfoIoctl     = ioc(iocRead, 0x01, unsafe.Sizeof(chipInfo{}))
+	getLineInfoIoctl     = ioc(iocRead|iocWrite, 0x02, unsafe.Sizeof(lineInfo{}))
+	getLineHandleIoctl   = ioc(iocRead|iocWrite, 0x03, unsafe.Sizeof(handleRequest{}))
+	getLineValuesIoctl   = ioc(iocRead|iocWrite, 0x08, unsafe.Sizeof(handleData{}))
+	setLineValuesIoctl   = ioc(iocRead|iocWrite, 0x09, unsafe.Sizeof(handleData{}))
+	setHandleConfigIoctl = ioc(iocRead|iocWrite, 0x0a, unsafe.Sizeof(handleConfig{}))
+)
+
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+func readChipInfo(file *os.File) (chipInfo, error) {
+	var info chipInfo
+	err := ioctl(file.Fd(), getChipInfoIoctl, unsafe.Pointer(&info))
+	return info, err
+}
+
+func readLineInfo(file *os.File, offset uint32) (lineInfo, error) {
+	info := lineInfo{Offset: offset}
+	err := ioctl(file.Fd(), getLineInfoIoctl, unsafe.Pointer(&info))
+	return info, err
+}
+
+// requestLine returns a file descriptor owning a single line of the chip.
+func requestLine(file *os.File, offset uint32, flags uint32, value bool, consumer string) (*os.File, error) {
+	return requestLines(file, []uint32{offset}, flags, []bool{value}, consumer)
+}
+
+// requestLines returns a file descriptor owning the given lines of the chip.
+func requestLines(file *os.File, offsets []uint32, flags uint32, values []bool, consumer string) (*os.File, error) {
+	request := handleRequest{Flags: flags, Lines: uint32(len(offsets))}
+	copy(request.LineOffsets[:], offsets)
+	for i, value := range values {
+		if value {
+			request.DefaultValues[i] = 1
+		}
+	}
+	copy(request.ConsumerLabel[:gpioMaxNameSize-1], consumer)
+
+	if err := ioctl(file.Fd(), getLineHandleIoctl, unsafe.Pointer(&request)); err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(request.Fd), file.Name()), nil
+}
+
+func getLineValue(handle *os.File) (bool, error) {
+	var data handleData
+	err := ioctl(handle.Fd(), getLineValuesIoctl, unsafe.Pointer(&data))
+	return data.Values[0] != 0, err
+}
+
+func setLineValue(handle *os.File, value bool) error {
+	return setLineValues(handle, []bool{value})
+}
+
+func setLineValues(handle *os.File, values []bool) error {
+	var data handleData
+	for i, value := range values {
+		if value {
+			data.Values[i] = 1
+		}
+	}
+	return ioctl(handle.Fd(), setLineValuesIoctl, unsafe.Pointer(&data))
+}
+
+func setLineConfig(handle *os.File, flags uint32, value bool) error {
+	config := handleConfig{Flags: flags}
+	if value {
+		config.DefaultValues[0] = 1
+	}
+	return ioctl(handle.Fd(), setHandleConfigIoctl, unsafe.Pointer(&config))
+}
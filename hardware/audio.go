@@ -0,0 +1,95 @@
+package hardware
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	asoundProc = "/proc/asound"
+	soundClass = "/sys/class/sound"
+)
+
+// PCM device nodes, e.g. pcmC0D1p for playback on card 0 device 1
+var pcmRegex = regexp.MustCompile(`^pcmC([0-9]+)D([0-9]+)([pc])$`)
+
+// pcmState returns "closed" or the state of the first substream, like
+// "RUNNING" or "PREPARED".
+func pcmState(card string, device string, stream string) string {
+	status := readSysfs(filepath.Join(asoundProc, "card"+card, fmt.Sprintf("pcm%s%s", device, stream), "sub0", "status"))
+	for _, line := range strings.Split(status, "\n") {
+		if strings.HasPrefix(line, "state:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "state:"))
+		}
+	}
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
+
+// pcmName reads the name of a PCM device from its info file.
+func pcmName(card string, device string, stream string) string {
+	info := readSysfs(filepath.Join(asoundProc, "card"+card, fmt.Sprintf("pcm%s%s", device, stream), "info"))
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, "name:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "name:"))
+		}
+	}
+	return ""
+}
+
+// cardName looks up the long name of a card in /proc/asound/cards, where
+// each card starts with a line like " 0 [Headphones ]: bcm2835 - bcm2835 Headphones".
+func cardName(card string) string {
+	for _, line := range strings.Split(readSysfs(filepath.Join(asoundProc, "cards")), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != card {
+			continue
+		}
+		if i := strings.Index(line, " - "); i >= 0 {
+			return strings.TrimSpace(line[i+3:])
+		}
+	}
+	return ""
+}
+
+// ListAudioDevices returns the ALSA PCM devices of all cards with their
+// direction, current state and the processes using them.
+func (d hardware) ListAudioDevices() ([]map[string]string, *dbus.Error) {
+	devices := []map[string]string{}
+
+	matches, _ := filepath.Glob(filepath.Join(soundClass, "pcmC*"))
+	for _, match := range matches {
+		node := filepath.Base(match)
+		parts := pcmRegex.FindStringSubmatch(node)
+		if parts == nil {
+			continue
+		}
+		card, device, stream := parts[1], parts[2], parts[3]
+
+		direction := "playback"
+		if stream == "c" {
+			direction = "capture"
+		}
+
+		cardPath := filepath.Join(soundClass, "card"+card)
+		info := usbIdentity(filepath.Join(cardPath, "device"))
+		info["card"] = card
+		info["card_id"] = readSysfs(filepath.Join(cardPath, "id"))
+		info["card_name"] = cardName(card)
+		info["driver"] = driverName(filepath.Join(cardPath, "device"))
+		info["device"] = device
+		info["direction"] = direction
+		info["name"] = pcmName(card, device, stream)
+		info["node"] = filepath.Join("/dev/snd", node)
+		info["state"] = pcmState(card, device, stream)
+		info["consumers"] = strings.Join(deviceConsumers(filepath.Join("/dev/snd", node)), ",")
+		devices = append(devices, info)
+	}
+	return devices, nil
+}
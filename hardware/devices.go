@@ -0,0 +1,124 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Helpers to describe device nodes from sysfs and procfs, shared by the
+// device listings.
+
+// ioctl passes a pointer, it is only converted to uintptr in the syscall
+// expression so the referenced memory stays alive.
+func ioctl(file *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlValue is ioctl for requests taking an integer argument.
+func ioctlValue(file *os.File, request uintptr, value uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request, value)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func readSysfs(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// usbIdentity walks up from a sysfs device to the USB device it belongs to.
+func usbIdentity(sysDevice string) map[string]string {
+	identity := map[string]string{}
+
+	path, err := filepath.EvalSymlinks(sysDevice)
+	if err != nil {
+		return identity
+	}
+	for ; path != "/" && path != "."; path = filepath.Dir(path) {
+		if _, err := os.Stat(filepath.Join(path, "idVendor")); err != nil {
+			continue
+		}
+		identity["vendor_id"] = readSysfs(filepath.Join(path, "idVendor"))
+		identity["product_id"] = readSysfs(filepath.Join(path, "idProduct"))
+		identity["manufacturer"] = readSysfs(filepath.Join(path, "manufacturer"))
+		identity["product"] = readSysfs(filepath.Join(path, "product"))
+		identity["serial"] = readSysfs(filepath.Join(path, "serial"))
+		break
+	}
+	return identity
+}
+
+// driverName returns the kernel driver bound to a sysfs device.
+func driverName(sysDevice string) string {
+	target, err := os.Readlink(filepath.Join(sysDevice, "driver"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// symlinksTo finds the links in a directory like /dev/serial/by-id pointing
+// to the given device node.
+func symlinksTo(dir string, device string) []string {
+	var links []string
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return links
+	}
+	for _, entry := range entries {
+		link := filepath.Join(dir, entry.Name())
+		if target, err := filepath.EvalSymlinks(link); err == nil && target == device {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// deviceConsumers lists the processes holding the device node open, as
+// "name (pid)".
+func deviceConsumers(devices ...string) []string {
+	consumers := []string{}
+
+	wanted := map[string]bool{}
+	for _, device := range devices {
+		wanted[device] = true
+	}
+
+	procs, _ := ioutil.ReadDir("/proc")
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && wanted[target] {
+				comm := readSysfs(filepath.Join("/proc", proc.Name(), "comm"))
+				consumers = append(consumers, fmt.Sprintf("%s (%d)", comm, pid))
+				break
+			}
+		}
+	}
+	return consumers
+}
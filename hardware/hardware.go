@@ -0,0 +1,133 @@
+package hardware
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Hardware"
+	ifaceName  = "io.hass.os.Hardware"
+)
+
+type hardware struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"ScanI2CBus":        {"bus", "devices"},
+	"ListSerialPorts":   {"ports"},
+	"ResetUSBDevice":    {"devpath", "success"},
+	"RescanPCIBus":      {"devices"},
+	"ListVideoDevices":  {"devices"},
+	"ListAudioDevices":  {"devices"},
+	"EnableSPI":         {"enabled", "success"},
+	"EnableCANOverlay":  {"overlay", "oscillator", "interrupt", "success"},
+	"DisableCANOverlay": {"overlay", "success"},
+	"ConfigureCAN":      {"interface", "bitrate", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := hardware{
+		conn: conn,
+	}
+
+	canConfig = loadCANConfig()
+
+	var signals []introspect.Signal
+	signals = append(signals, serialSignals...)
+	signals = append(signals, videoSignals...)
+	signals = append(signals, rngSignals...)
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"HardwareRNG": {
+				Value:    getHardwareRNG(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"HardwareRNGFeeding": {
+				Value:    getHardwareRNGFeeding(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"EntropyAvailable": {
+				Value:    getEntropyAvailable(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"SPIEnabled": {
+				Value:    getSPIEnabled(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"SPIDevices": {
+				Value:    getSPIDevices(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"CANInterfaces": {
+				Value:    getCANInterfaces(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"CRNGReady": {
+				Value:    getCRNGReady(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    signals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchSerialPorts()
+	go d.watchVideoDevices()
+	go d.watchCRNG()
+	go d.watchCANInterfaces()
+}
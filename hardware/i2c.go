@@ -0,0 +1,104 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/godbus/dbus/v5"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+// From linux/i2c-dev.h and linux/i2c.h
+const (
+	i2cSlave        = 0x0703
+	i2cFuncs        = 0x0705
+	i2cSMBus        = 0x0720
+	i2cSMBusRead    = 1
+	i2cSMBusWrite   = 0
+	i2cSMBusQuick   = 0
+	i2cSMBusByte    = 1
+	i2cFuncQuick    = 0x00010000
+	i2cFuncReadByte = 0x00020000
+	i2cFirstAddress = 0x08
+	i2cLastAddress  = 0x77
+	i2cDevices      = "/sys/bus/i2c/devices"
+)
+
+type i2cSMBusData struct {
+	ReadWrite uint8
+	Command   uint8
+	Size      uint32
+	Data      unsafe.Pointer
+}
+
+// probeI2CAddress checks for a device the way i2cdetect does by default: a
+// quick write could corrupt EEPROMs and write-only chips don't answer reads,
+// so the EEPROM ranges are read and everything else gets a quick write.
+func probeI2CAddress(file *os.File, address uint16, funcs uint32) bool {
+	read := (address >= 0x30 && address <= 0x37) || (address >= 0x50 && address <= 0x5f)
+	if funcs&i2cFuncQuick == 0 {
+		read = true
+	}
+	if read && funcs&i2cFuncReadByte == 0 {
+		return false
+	}
+
+	var buffer [34]byte
+	request := i2cSMBusData{ReadWrite: i2cSMBusWrite, Size: i2cSMBusQuick}
+	if read {
+		request = i2cSMBusData{ReadWrite: i2cSMBusRead, Size: i2cSMBusByte, Data: unsafe.Pointer(&buffer)}
+	}
+	return ioctl(file, i2cSMBus, unsafe.Pointer(&request)) == nil
+}
+
+func i2cDeviceName(bus uint32, address uint16) string {
+	data, err := ioutil.ReadFile(filepath.Join(i2cDevices, fmt.Sprintf("%d-%04x", bus, address), "name"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ScanI2CBus probes all regular addresses of /dev/i2c-<bus>. Addresses
+// claimed by a kernel driver are not touched and reported as "busy".
+func (d hardware) ScanI2CBus(bus uint32) ([]map[string]string, *dbus.Error) {
+	logging.Info.Printf("Scan I2C bus %d.", bus)
+
+	file, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't open I2C bus %d: %s", bus, err))
+	}
+	defer file.Close()
+
+	var funcs uint32
+	if err = ioctl(file, i2cFuncs, unsafe.Pointer(&funcs)); err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't get functionality of I2C bus %d: %s", bus, err))
+	}
+
+	devices := []map[string]string{}
+	for address := uint16(i2cFirstAddress); address <= i2cLastAddress; address++ {
+		status := "responding"
+
+		err = ioctlValue(file, i2cSlave, uintptr(address))
+		if err == syscall.EBUSY {
+			status = "busy"
+		} else if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Can't select address 0x%02x on I2C bus %d: %s", address, bus, err))
+		} else if !probeI2CAddress(file, address, funcs) {
+			continue
+		}
+
+		devices = append(devices, map[string]string{
+			"address": fmt.Sprintf("0x%02x", address),
+			"status":  status,
+			"name":    i2cDeviceName(bus, address),
+		})
+	}
+	return devices, nil
+}
@@ -0,0 +1,64 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	pciDevices    = "/sys/bus/pci/devices"
+	pciRescan     = "/sys/bus/pci/rescan"
+	pciRescanWait = 2 * time.Second
+)
+
+func listPCIDevices() map[string]bool {
+	devices := map[string]bool{}
+	entries, _ := ioutil.ReadDir(pciDevices)
+	for _, entry := range entries {
+		devices[entry.Name()] = true
+	}
+	return devices
+}
+
+func describePCIDevice(address string) map[string]string {
+	path := filepath.Join(pciDevices, address)
+	return map[string]string{
+		"address":   address,
+		"vendor_id": readSysfs(filepath.Join(path, "vendor")),
+		"device_id": readSysfs(filepath.Join(path, "device")),
+		"class":     readSysfs(filepath.Join(path, "class")),
+		"driver":    driverName(path),
+	}
+}
+
+// RescanPCIBus asks the kernel to rescan all PCI buses and returns the
+// devices which weren't present before, e.g. an NVMe drive which dropped
+// off the bus.
+func (d hardware) RescanPCIBus(sender dbus.Sender) ([]map[string]string, *dbus.Error) {
+	before := listPCIDevices()
+
+	logging.Info.Printf("Rescan PCI bus.")
+	if err := writeSysfs(pciRescan, "1"); err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Can't rescan PCI bus: %s", err))
+	}
+	// Give drivers time to bind
+	time.Sleep(pciRescanWait)
+
+	found := []map[string]string{}
+	for address := range listPCIDevices() {
+		if !before[address] {
+			found = append(found, describePCIDevice(address))
+		}
+	}
+
+	logging.Info.Printf("PCI rescan found %d new devices.", len(found))
+	audit.Record(sender, "Hardware.RescanPCIBus", "", len(found))
+	return found, nil
+}
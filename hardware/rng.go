@@ -0,0 +1,101 @@
+package hardware
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	hwRNGCurrent      = "/sys/class/misc/hw_random/rng_current"
+	hwRNGQuality      = "/sys/module/rng_core/parameters/current_quality"
+	entropyAvail      = "/proc/sys/kernel/random/entropy_avail"
+	randomDevice      = "/dev/random"
+	procUptime        = "/proc/uptime"
+	crngPollInterval  = 5 * time.Second
+	crngDelayedUptime = 30
+)
+
+var rngSignals = []introspect.Signal{
+	{
+		Name: "CRNGInitDelayed",
+		Args: []introspect.Arg{
+			{Name: "uptime", Type: "u"},
+		},
+	},
+}
+
+// getHardwareRNG returns the hardware RNG in use, empty if there is none.
+func getHardwareRNG() string {
+	current := readSysfs(hwRNGCurrent)
+	if current == "none" {
+		return ""
+	}
+	return current
+}
+
+// getHardwareRNGFeeding reports whether the hardware RNG credits entropy to
+// the kernel pool, which requires a quality above zero.
+func getHardwareRNGFeeding() bool {
+	if getHardwareRNG() == "" {
+		return false
+	}
+	quality, err := strconv.Atoi(readSysfs(hwRNGQuality))
+	return err == nil && quality > 0
+}
+
+func getEntropyAvailable() uint32 {
+	value, _ := strconv.ParseUint(readSysfs(entropyAvail), 10, 32)
+	return uint32(value)
+}
+
+// getCRNGReady checks whether the kernel CRNG is initialized: since Linux 5.6
+// /dev/random only blocks until then.
+func getCRNGReady() bool {
+	file, err := os.OpenFile(randomDevice, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 1)
+	_, err = syscall.Read(int(file.Fd()), buffer)
+	return err != syscall.EAGAIN
+}
+
+func getUptime() uint32 {
+	fields := strings.Fields(readSysfs(procUptime))
+	if len(fields) == 0 {
+		return 0
+	}
+	uptime, _ := strconv.ParseFloat(fields[0], 64)
+	return uint32(uptime)
+}
+
+// watchCRNG updates the entropy properties until the CRNG is ready and warns
+// once if that takes unusually long after boot.
+func (d hardware) watchCRNG() {
+	warned := false
+	for !getCRNGReady() {
+		d.props.SetMust(ifaceName, "EntropyAvailable", getEntropyAvailable())
+
+		if uptime := getUptime(); !warned && uptime > crngDelayedUptime {
+			logging.Warning.Printf("Kernel CRNG not initialized after %d seconds, hardware RNG: %t.", uptime, getHardwareRNGFeeding())
+			err := d.conn.Emit(objectPath, ifaceName+".CRNGInitDelayed", uptime)
+			if err != nil {
+				logging.Warning.Printf("Can't emit CRNGInitDelayed signal: %s", err)
+			}
+			warned = true
+		}
+		time.Sleep(crngPollInterval)
+	}
+
+	d.props.SetMust(ifaceName, "CRNGReady", true)
+	d.props.SetMust(ifaceName, "EntropyAvailable", getEntropyAvailable())
+}
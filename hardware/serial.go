@@ -0,0 +1,88 @@
+package hardware
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/uevent"
+)
+
+const (
+	ttyClass      = "/sys/class/tty"
+	serialByID    = "/dev/serial/by-id"
+	serialByPath  = "/dev/serial/by-path"
+	serialUnknown = "0"
+)
+
+var serialSignals = []introspect.Signal{
+	{
+		Name: "SerialPortsChanged",
+		Args: []introspect.Arg{
+			{Name: "action", Type: "s"},
+			{Name: "device", Type: "s"},
+		},
+	},
+}
+
+func listSerialPorts() []map[string]string {
+	ports := []map[string]string{}
+
+	entries, err := ioutil.ReadDir(ttyClass)
+	if err != nil {
+		return ports
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		sysDevice := filepath.Join(ttyClass, name, "device")
+
+		// Virtual terminals have no device, unused 8250 ports have type 0
+		driver := driverName(sysDevice)
+		if driver == "" {
+			continue
+		}
+		if driver == "serial8250" && readSysfs(filepath.Join(ttyClass, name, "type")) == serialUnknown {
+			continue
+		}
+
+		device := filepath.Join("/dev", name)
+		port := usbIdentity(sysDevice)
+		port["device"] = device
+		port["driver"] = driver
+		port["by_id"] = strings.Join(symlinksTo(serialByID, device), ",")
+		port["by_path"] = strings.Join(symlinksTo(serialByPath, device), ",")
+		port["consumers"] = strings.Join(deviceConsumers(device), ",")
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// ListSerialPorts returns the serial ports with their stable /dev/serial
+// links, USB identity, driver and the processes using them.
+func (d hardware) ListSerialPorts() ([]map[string]string, *dbus.Error) {
+	return listSerialPorts(), nil
+}
+
+func (d hardware) watchSerialPorts() {
+	events, err := uevent.Listen("tty")
+	if err != nil {
+		logging.Warning.Printf("Can't watch serial ports: %s", err)
+		return
+	}
+
+	for event := range events {
+		if event.DevName == "" || (event.Action != "add" && event.Action != "remove") {
+			continue
+		}
+
+		device := filepath.Join("/dev", event.DevName)
+		err = d.conn.Emit(objectPath, ifaceName+".SerialPortsChanged", event.Action, device)
+		if err != nil {
+			logging.Warning.Printf("Can't emit SerialPortsChanged signal: %s", err)
+		}
+	}
+}
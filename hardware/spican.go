@@ -0,0 +1,268 @@
+package hardware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/bootfile"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/uevent"
+)
+
+const (
+	bootConfig        = "/mnt/boot/config.txt"
+	canConfigFile     = "/etc/os-agent/can.json"
+	canModulesFile    = "/etc/modules-load.d/os-agent-can.conf"
+	spiModulesFile    = "/etc/modules-load.d/os-agent-spi.conf"
+	moduleLoadCommand = "/sbin/modprobe"
+	ipCommand         = "ip"
+	netClass          = "/sys/class/net"
+	canLinkType       = "280"
+)
+
+var (
+	canMutex sync.Mutex
+
+	// CAN interface name to bitrate
+	canConfig = map[string]uint32{}
+
+	dtparamFile = bootfile.Editor{FilePath: bootConfig, Delimiter: "="}
+	overlayFile = bootfile.Editor{FilePath: bootConfig, Delimiter: ","}
+
+	canOverlayRegex   = regexp.MustCompile(`^[a-z0-9-]+-can[0-9]*$`)
+	canInterfaceRegex = regexp.MustCompile(`^v?can[0-9]+$`)
+)
+
+func loadCANConfig() map[string]uint32 {
+	config := map[string]uint32{}
+
+	data, err := ioutil.ReadFile(canConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid CAN configuration in %s", canConfigFile)
+		return map[string]uint32{}
+	}
+	return config
+}
+
+func saveCANConfig(config map[string]uint32) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(canConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(canConfigFile, data, 0644)
+}
+
+// loadModules loads kernel modules now and on every boot.
+func loadModules(configFile string, modules ...string) error {
+	content := ""
+	for _, module := range modules {
+		cmd := exec.Command(moduleLoadCommand, module)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("Can't load module %s: %s, output %s", module, err, out)
+		}
+		content += module + "\n"
+	}
+	return managedfiles.Write(configFile, []byte(content), 0644, "hardware")
+}
+
+func getSPIDevices() []string {
+	devices, _ := filepath.Glob("/dev/spidev*")
+	if devices == nil {
+		devices = []string{}
+	}
+	return devices
+}
+
+func getSPIEnabled() bool {
+	value, _ := dtparamFile.ReadOption("dtparam=spi", "off")
+	return value == "on"
+}
+
+func getCANInterfaces() map[string]map[string]string {
+	interfaces := map[string]map[string]string{}
+
+	entries, err := ioutil.ReadDir(netClass)
+	if err != nil {
+		return interfaces
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if readSysfs(filepath.Join(netClass, name, "type")) != canLinkType {
+			continue
+		}
+
+		bitrate := ""
+		if value, ok := canConfig[name]; ok {
+			bitrate = strconv.FormatUint(uint64(value), 10)
+		}
+		interfaces[name] = map[string]string{
+			"state":   readSysfs(filepath.Join(netClass, name, "operstate")),
+			"bitrate": bitrate,
+		}
+	}
+	return interfaces
+}
+
+// setupCANInterface (re)configures the bitrate of a CAN interface and
+// brings it up.
+func setupCANInterface(name string, bitrate uint32) error {
+	commands := [][]string{
+		{"link", "set", name, "down"},
+		{"link", "set", name, "type", "can", "bitrate", strconv.FormatUint(uint64(bitrate), 10)},
+		{"link", "set", name, "up"},
+	}
+	for _, args := range commands {
+		cmd := exec.Command(ipCommand, args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %s, output %s", args, err, out)
+		}
+	}
+	return nil
+}
+
+// EnableSPI switches the SPI controller on or off in the boot config, a
+// reboot is needed for the change to take effect.
+func (d hardware) EnableSPI(sender dbus.Sender, enabled bool) (bool, *dbus.Error) {
+	value := "off"
+	if enabled {
+		value = "on"
+	}
+
+	old := getSPIEnabled()
+	if err := dtparamFile.SetOption("dtparam=spi", value); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't change SPI in boot config: %s", err))
+	}
+
+	if enabled {
+		if err := loadModules(spiModulesFile, "spidev"); err != nil {
+			logging.Warning.Printf("Can't load SPI modules: %s", err)
+		}
+	} else if err := managedfiles.Remove(spiModulesFile); err != nil {
+		logging.Warning.Printf("Can't remove %s: %s", spiModulesFile, err)
+	}
+
+	logging.Info.Printf("SPI set to %s, reboot required.", value)
+	audit.Record(sender, "Hardware.EnableSPI", old, enabled)
+	d.props.SetMust(ifaceName, "SPIEnabled", enabled)
+	return true, nil
+}
+
+// EnableCANOverlay adds a CAN controller overlay like "mcp2515-can0" with
+// its oscillator frequency and interrupt GPIO to the boot config, a reboot
+// is needed for the controller to appear.
+func (d hardware) EnableCANOverlay(sender dbus.Sender, overlay string, oscillator uint32, interrupt uint32) (bool, *dbus.Error) {
+	if !canOverlayRegex.MatchString(overlay) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid CAN overlay '%s'", overlay))
+	}
+
+	params := fmt.Sprintf("oscillator=%d,interrupt=%d", oscillator, interrupt)
+	if err := overlayFile.SetOption("dtoverlay="+overlay, params); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't add CAN overlay to boot config: %s", err))
+	}
+	if err := loadModules(canModulesFile, "can", "can_raw"); err != nil {
+		logging.Warning.Printf("Can't load CAN modules: %s", err)
+	}
+
+	logging.Info.Printf("CAN overlay %s enabled, reboot required.", overlay)
+	audit.Record(sender, "Hardware.EnableCANOverlay", "", overlay+","+params)
+	return true, nil
+}
+
+func (d hardware) DisableCANOverlay(sender dbus.Sender, overlay string) (bool, *dbus.Error) {
+	if !canOverlayRegex.MatchString(overlay) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid CAN overlay '%s'", overlay))
+	}
+
+	if err := overlayFile.DisableOption("dtoverlay=" + overlay); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't remove CAN overlay from boot config: %s", err))
+	}
+
+	logging.Info.Printf("CAN overlay %s disabled, reboot required.", overlay)
+	audit.Record(sender, "Hardware.DisableCANOverlay", overlay, "")
+	return true, nil
+}
+
+// ConfigureCAN sets the bitrate of a CAN interface and brings it up. The
+// bitrate is applied again whenever the interface appears.
+func (d hardware) ConfigureCAN(sender dbus.Sender, name string, bitrate uint32) (bool, *dbus.Error) {
+	if !canInterfaceRegex.MatchString(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid CAN interface '%s'", name))
+	}
+	if bitrate == 0 || bitrate > 1000000 {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid CAN bitrate %d", bitrate))
+	}
+
+	canMutex.Lock()
+	defer canMutex.Unlock()
+
+	if err := setupCANInterface(name, bitrate); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't configure CAN interface %s: %s", name, err))
+	}
+
+	old := canConfig[name]
+	canConfig[name] = bitrate
+	if err := saveCANConfig(canConfig); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save CAN configuration: %s", err))
+	}
+
+	logging.Info.Printf("CAN interface %s up with bitrate %d.", name, bitrate)
+	audit.Record(sender, "Hardware.ConfigureCAN", old, bitrate)
+	d.props.SetMust(ifaceName, "CANInterfaces", getCANInterfaces())
+	return true, nil
+}
+
+// watchCANInterfaces applies the saved bitrates when CAN interfaces appear,
+// e.g. once the controller driver probed after boot.
+func (d hardware) watchCANInterfaces() {
+	canMutex.Lock()
+	for name, bitrate := range canConfig {
+		if _, err := os.Stat(filepath.Join(netClass, name)); err == nil {
+			if err = setupCANInterface(name, bitrate); err != nil {
+				logging.Warning.Printf("Can't configure CAN interface %s: %s", name, err)
+			}
+		}
+	}
+	d.props.SetMust(ifaceName, "CANInterfaces", getCANInterfaces())
+	canMutex.Unlock()
+
+	events, err := uevent.Listen("net")
+	if err != nil {
+		logging.Warning.Printf("Can't watch CAN interfaces: %s", err)
+		return
+	}
+
+	for event := range events {
+		name := event.Env["INTERFACE"]
+		if !canInterfaceRegex.MatchString(name) {
+			continue
+		}
+
+		canMutex.Lock()
+		if bitrate, ok := canConfig[name]; ok && event.Action == "add" {
+			if err = setupCANInterface(name, bitrate); err != nil {
+				logging.Warning.Printf("Can't configure CAN interface %s: %s", name, err)
+			}
+		}
+		d.props.SetMust(ifaceName, "CANInterfaces", getCANInterfaces())
+		canMutex.Unlock()
+	}
+}
@@ -0,0 +1,145 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	blockClass   = "/sys/class/block"
+	mountInfo    = "/proc/self/mountinfo"
+	usbDevices   = "/sys/bus/usb/devices"
+	usbDriver    = "/sys/bus/usb/drivers/usb"
+	usbResetWait = 1 * time.Second
+)
+
+// USB device names in sysfs, bus-port[.port...]
+var usbDevpathRegex = regexp.MustCompile(`^[0-9]+-[0-9]+(\.[0-9]+)*$`)
+
+func writeSysfs(path string, value string) error {
+	return ioutil.WriteFile(path, []byte(value), 0200)
+}
+
+// togglePortPower disables and enables the hub port of a device, which cuts
+// VBUS on hubs supporting per-port power switching.
+func togglePortPower(devpath string) (bool, error) {
+	disable := filepath.Join(usbDevices, devpath, "port", "disable")
+	if _, err := os.Stat(disable); err != nil {
+		return false, nil
+	}
+
+	if err := writeSysfs(disable, "1"); err != nil {
+		return true, err
+	}
+	time.Sleep(usbResetWait)
+	return true, writeSysfs(disable, "0")
+}
+
+func rebindDevice(devpath string) error {
+	if err := writeSysfs(filepath.Join(usbDriver, "unbind"), devpath); err != nil {
+		return err
+	}
+	time.Sleep(usbResetWait)
+	return writeSysfs(filepath.Join(usbDriver, "bind"), devpath)
+}
+
+// mountedDevices returns the major:minor numbers of all mounted block
+// devices.
+func mountedDevices() (map[string]bool, error) {
+	data, err := ioutil.ReadFile(mountInfo)
+	if err != nil {
+		return nil, err
+	}
+	mounted := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		// ID, parent ID, major:minor, ...
+		if fields := strings.Fields(line); len(fields) > 2 {
+			mounted[fields[2]] = true
+		}
+	}
+	return mounted, nil
+}
+
+// blockDeviceInUse reports whether a block device, or a device mapper or
+// RAID device built on it, is mounted.
+func blockDeviceInUse(path string, mounted map[string]bool) bool {
+	if mounted[readSysfs(filepath.Join(path, "dev"))] {
+		return true
+	}
+	holders, _ := filepath.Glob(filepath.Join(path, "holders", "*"))
+	for _, holder := range holders {
+		if blockDeviceInUse(holder, mounted) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountedBelow returns a mounted block device (or partition) attached
+// through the USB device, including devices behind a hub.
+func mountedBelow(devpath string) (string, error) {
+	device, err := filepath.EvalSymlinks(filepath.Join(usbDevices, devpath))
+	if err != nil {
+		return "", err
+	}
+	mounted, err := mountedDevices()
+	if err != nil {
+		return "", err
+	}
+
+	blocks, _ := filepath.Glob(filepath.Join(blockClass, "*"))
+	for _, block := range blocks {
+		path, err := filepath.EvalSymlinks(block)
+		if err != nil || !strings.HasPrefix(path, device+"/") {
+			continue
+		}
+		if blockDeviceInUse(path, mounted) {
+			return filepath.Base(block), nil
+		}
+	}
+	return "", nil
+}
+
+// ResetUSBDevice re-enumerates a USB device given by its sysfs name, e.g.
+// "1-1.2". The port power is toggled if the hub supports it, otherwise the
+// device is unbound and bound again.
+func (d hardware) ResetUSBDevice(sender dbus.Sender, devpath string) (bool, *dbus.Error) {
+	if !usbDevpathRegex.MatchString(devpath) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid USB device '%s'", devpath))
+	}
+	if _, err := os.Stat(filepath.Join(usbDevices, devpath)); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("USB device %s not found", devpath))
+	}
+	// Resetting e.g. the data disk pulls it from under the running system
+	block, err := mountedBelow(devpath)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't check USB device %s for mounted disks: %s", devpath, err))
+	}
+	if block != "" {
+		return false, dbus.MakeFailedError(fmt.Errorf("USB device %s holds mounted block device %s, refusing to reset it", devpath, block))
+	}
+
+	method := "port power"
+	supported, err := togglePortPower(devpath)
+	if !supported {
+		method = "rebind"
+		err = rebindDevice(devpath)
+	}
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't reset USB device %s: %s", devpath, err))
+	}
+
+	logging.Info.Printf("Reset USB device %s using %s.", devpath, method)
+	audit.Record(sender, "Hardware.ResetUSBDevice", "", devpath)
+	return true, nil
+}
@@ -0,0 +1,169 @@
+package hardware
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/uevent"
+)
+
+// From linux/videodev2.h
+const (
+	vidiocQueryCap          = 0x80685600
+	vidiocEnumFmt           = 0xc0405602
+	v4l2BufTypeVideoCapture = 1
+	v4l2BufTypeCaptureMPlan = 9
+	v4l2CapDeviceCaps       = 0x80000000
+	videoClass              = "/sys/class/video4linux"
+	videoByID               = "/dev/v4l/by-id"
+	videoByPath             = "/dev/v4l/by-path"
+)
+
+var v4l2Capabilities = []struct {
+	flag uint32
+	name string
+}{
+	{0x00000001, "video_capture"},
+	{0x00000002, "video_output"},
+	{0x00001000, "video_capture_mplane"},
+	{0x00002000, "video_output_mplane"},
+	{0x00004000, "video_m2m_mplane"},
+	{0x00008000, "video_m2m"},
+	{0x00800000, "meta_capture"},
+	{0x01000000, "readwrite"},
+	{0x04000000, "streaming"},
+}
+
+type v4l2Capability struct {
+	Driver       [16]byte
+	Card         [32]byte
+	BusInfo      [32]byte
+	Version      uint32
+	Capabilities uint32
+	DeviceCaps   uint32
+	Reserved     [3]uint32
+}
+
+type v4l2FmtDesc struct {
+	Index       uint32
+	Type        uint32
+	Flags       uint32
+	Description [32]byte
+	PixelFormat uint32
+	MbusCode    uint32
+	Reserved    [3]uint32
+}
+
+var videoSignals = []introspect.Signal{
+	{
+		Name: "VideoDevicesChanged",
+		Args: []introspect.Arg{
+			{Name: "action", Type: "s"},
+			{Name: "device", Type: "s"},
+		},
+	},
+}
+
+func cString(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
+
+func fourCC(format uint32) string {
+	return string([]byte{byte(format), byte(format >> 8), byte(format >> 16), byte(format >> 24)})
+}
+
+func videoFormats(file *os.File, bufType uint32) []string {
+	var formats []string
+	for index := uint32(0); ; index++ {
+		desc := v4l2FmtDesc{Index: index, Type: bufType}
+		if ioctl(file, vidiocEnumFmt, unsafe.Pointer(&desc)) != nil {
+			break
+		}
+		formats = append(formats, strings.TrimSpace(fourCC(desc.PixelFormat)))
+	}
+	return formats
+}
+
+func describeVideoDevice(name string) map[string]string {
+	device := filepath.Join("/dev", name)
+	sysDevice := filepath.Join(videoClass, name, "device")
+
+	info := usbIdentity(sysDevice)
+	info["device"] = device
+	info["name"] = readSysfs(filepath.Join(videoClass, name, "name"))
+	info["by_id"] = strings.Join(symlinksTo(videoByID, device), ",")
+	info["by_path"] = strings.Join(symlinksTo(videoByPath, device), ",")
+	info["consumers"] = strings.Join(deviceConsumers(device), ",")
+
+	file, err := os.OpenFile(device, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		logging.Warning.Printf("Can't open video device %s: %s", device, err)
+		return info
+	}
+	defer file.Close()
+
+	var capability v4l2Capability
+	if err = ioctl(file, vidiocQueryCap, unsafe.Pointer(&capability)); err != nil {
+		return info
+	}
+	caps := capability.Capabilities
+	if caps&v4l2CapDeviceCaps != 0 {
+		caps = capability.DeviceCaps
+	}
+
+	var names []string
+	for _, c := range v4l2Capabilities {
+		if caps&c.flag != 0 {
+			names = append(names, c.name)
+		}
+	}
+
+	info["driver"] = cString(capability.Driver[:])
+	info["card"] = cString(capability.Card[:])
+	info["bus_info"] = cString(capability.BusInfo[:])
+	info["capabilities"] = strings.Join(names, ",")
+	info["formats"] = strings.Join(append(videoFormats(file, v4l2BufTypeVideoCapture), videoFormats(file, v4l2BufTypeCaptureMPlan)...), ",")
+	return info
+}
+
+// ListVideoDevices returns the V4L2 devices with their capabilities, capture
+// formats and USB identity.
+func (d hardware) ListVideoDevices() ([]map[string]string, *dbus.Error) {
+	devices := []map[string]string{}
+
+	matches, _ := filepath.Glob(filepath.Join(videoClass, "video*"))
+	for _, match := range matches {
+		devices = append(devices, describeVideoDevice(filepath.Base(match)))
+	}
+	return devices, nil
+}
+
+func (d hardware) watchVideoDevices() {
+	events, err := uevent.Listen("video4linux")
+	if err != nil {
+		logging.Warning.Printf("Can't watch video devices: %s", err)
+		return
+	}
+
+	for event := range events {
+		if event.DevName == "" || (event.Action != "add" && event.Action != "remove") {
+			continue
+		}
+
+		device := filepath.Join("/dev", event.DevName)
+		err = d.conn.Emit(objectPath, ifaceName+".VideoDevicesChanged", event.Action, device)
+		if err != nil {
+			logging.Warning.Printf("Can't emit VideoDevicesChanged signal: %s", err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package hostconfig
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/unixfd"
+)
+
+func addTarEntry(writer *tar.Writer, path string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = strings.TrimPrefix(path, "/")
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err = writer.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+func writeHostConfig(out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	writer := tar.NewWriter(gz)
+
+	for _, root := range hostConfigPaths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+			return addTarEntry(writer, path, info)
+		})
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExportHostConfig returns a file descriptor to a gzipped tarball of the host
+// configuration. Paths are stored relative to the root directory.
+func (d hostConfig) ExportHostConfig() (dbus.UnixFD, *dbus.Error) {
+	logging.Info.Printf("Export host configuration.")
+
+	file, err := ioutil.TempFile("", "os-agent-hostconfig-")
+	if err != nil {
+		return -1, dbus.MakeFailedError(err)
+	}
+	// Only the descriptor is handed out, the file itself is not needed.
+	os.Remove(file.Name())
+
+	if err = writeHostConfig(file); err != nil {
+		file.Close()
+		return -1, dbus.MakeFailedError(fmt.Errorf("Can't export host configuration: %s", err))
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return -1, dbus.MakeFailedError(err)
+	}
+
+	return unixfd.Export(file), nil
+}
@@ -0,0 +1,96 @@
+package hostconfig
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/HostConfig"
+	ifaceName  = "io.hass.os.HostConfig"
+)
+
+// hostConfigPaths are the files and directories making up the host
+// configuration managed through the agent.
+var hostConfigPaths = []string{
+	"/root/.ssh/authorized_keys",
+	"/etc/ssh/sshd_config.d/os-agent.conf",
+	"/etc/modules-load.d",
+	"/etc/modprobe.d",
+	"/etc/udev/rules.d",
+	"/etc/systemd/timesyncd.conf.d/os-agent.conf",
+	"/etc/tmpfiles.d/cpufreq-governor.conf",
+	"/etc/default/haos-swapfile",
+	"/etc/os-agent",
+	"/mnt/boot/config.txt",
+}
+
+type hostConfig struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"ExportHostConfig":    {"fd"},
+	"ImportHostConfig":    {"fd", "options", "files"},
+	"ListManagedFiles":    {"files"},
+	"ReconcileHostConfig": {"restored"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := hostConfig{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"DriftedFiles": {
+				Value:    managedfiles.Drifted(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchDrift()
+}
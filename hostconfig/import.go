@@ -0,0 +1,158 @@
+package hostconfig
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	importOptionDryRun = "dry-run"
+	importOptionPaths  = "paths"
+	maxImportFileSize  = 16 * 1024 * 1024
+)
+
+type importFile struct {
+	path string
+	mode os.FileMode
+	data []byte
+}
+
+// allowedRoot returns the entry of hostConfigPaths the path belongs to.
+func allowedRoot(path string) (string, bool) {
+	for _, root := range hostConfigPaths {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// readHostConfig validates a snapshot in full before anything is applied.
+func readHostConfig(in io.Reader) ([]importFile, error) {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	reader := tar.NewReader(gz)
+
+	var files []importFile
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Clean("/" + header.Name)
+		if _, ok := allowedRoot(path); !ok {
+			return nil, fmt.Errorf("Path '%s' is not part of the host configuration", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("Unsupported file type of '%s'", header.Name)
+		}
+
+		if header.Size > maxImportFileSize {
+			return nil, fmt.Errorf("File '%s' is too large", header.Name)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, importFile{path: path, mode: os.FileMode(header.Mode).Perm(), data: data})
+	}
+	return files, nil
+}
+
+func writeImportFile(file importFile) error {
+	err := os.MkdirAll(filepath.Dir(file.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(file.path), ".tmp."+filepath.Base(file.path))
+	if err = ioutil.WriteFile(tmpPath, file.data, file.mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, file.path)
+}
+
+// ImportHostConfig applies a snapshot created by ExportHostConfig. Options:
+// "dry-run" (b) only validates the snapshot, "paths" (as) restricts the
+// import to the given host configuration paths. Returns the files written,
+// or which would be written in dry-run mode.
+func (d hostConfig) ImportHostConfig(sender dbus.Sender, fd dbus.UnixFD, options map[string]dbus.Variant) ([]string, *dbus.Error) {
+	in := os.NewFile(uintptr(fd), "hostconfig")
+	defer in.Close()
+
+	dryRun := false
+	if value, ok := options[importOptionDryRun]; ok {
+		dryRun, _ = value.Value().(bool)
+	}
+
+	var selected []string
+	if value, ok := options[importOptionPaths]; ok {
+		selected, _ = value.Value().([]string)
+		for _, path := range selected {
+			if _, ok := allowedRoot(path); !ok {
+				return nil, dbus.MakeFailedError(fmt.Errorf("Path '%s' is not part of the host configuration", path))
+			}
+		}
+	}
+
+	logging.Info.Printf("Import host configuration (dry-run: %t).", dryRun)
+
+	files, err := readHostConfig(in)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("Invalid host configuration snapshot: %s", err))
+	}
+
+	var toWrite []importFile
+	for _, file := range files {
+		if len(selected) > 0 {
+			found := false
+			for _, path := range selected {
+				if file.path == path || strings.HasPrefix(file.path, path+"/") {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		toWrite = append(toWrite, file)
+	}
+
+	written := []string{}
+	for _, file := range toWrite {
+		if !dryRun {
+			if err = writeImportFile(file); err != nil {
+				return written, dbus.MakeFailedError(fmt.Errorf("Can't write '%s': %s", file.path, err))
+			}
+		}
+		written = append(written, file.path)
+	}
+
+	if !dryRun {
+		logging.Info.Printf("Imported %d host configuration files.", len(written))
+		audit.Record(sender, "HostConfig.ImportHostConfig", "", written)
+	}
+	return written, nil
+}
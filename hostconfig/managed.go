@@ -0,0 +1,49 @@
+package hostconfig
+
+import (
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+)
+
+const (
+	driftCheckInterval = 1 * time.Hour
+)
+
+// ListManagedFiles returns the files deposited by the agent with their owner
+// and whether they are "ok", "modified" or "missing".
+func (d hostConfig) ListManagedFiles() (map[string]map[string]string, *dbus.Error) {
+	return managedfiles.List(), nil
+}
+
+// ReconcileHostConfig restores managed files which were changed or removed,
+// e.g. manually or by an OS update, and returns their paths.
+func (d hostConfig) ReconcileHostConfig(sender dbus.Sender) ([]string, *dbus.Error) {
+	restored, err := managedfiles.Reconcile()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	if len(restored) > 0 {
+		logging.Info.Printf("Restored managed files %s.", restored)
+	}
+	audit.Record(sender, "HostConfig.ReconcileHostConfig", "", restored)
+	d.props.SetMust(ifaceName, "DriftedFiles", managedfiles.Drifted())
+	return restored, nil
+}
+
+func (d hostConfig) watchDrift() {
+	for {
+		time.Sleep(driftCheckInterval)
+
+		drifted := managedfiles.Drifted()
+		if len(drifted) > 0 {
+			logging.Warning.Printf("Managed files changed outside of the agent: %s", drifted)
+		}
+		d.props.SetMust(ifaceName, "DriftedFiles", drifted)
+	}
+}
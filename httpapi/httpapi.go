@@ -0,0 +1,232 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/busproxy"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	socketPath    = "/run/os-agent/api.sock"
+	tokenFile     = "/etc/os-agent/api-token"
+	eventsBufSize = 16
+	proxyClient   = "http-api"
+)
+
+type callRequest struct {
+	Object    string        `json:"object"`
+	Interface string        `json:"interface"`
+	Method    string        `json:"method"`
+	Args      []interface{} `json:"args"`
+}
+
+type event struct {
+	Object string        `json:"object"`
+	Signal string        `json:"signal"`
+	Args   []interface{} `json:"args"`
+}
+
+type server struct {
+	conn  *dbus.Conn
+	token string
+
+	subscribersLock sync.Mutex
+	subscribers     map[chan event]struct{}
+}
+
+func loadToken() (string, error) {
+	data, err := ioutil.ReadFile(tokenFile)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err = rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+
+	if err = os.MkdirAll(filepath.Dir(tokenFile), 0755); err != nil {
+		return "", err
+	}
+	return token, ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := map[string]interface{}{"name": busproxy.ErrorName(err), "message": err.Error()}
+	if details := apierror.Details(err); details != nil {
+		body["details"] = details
+	}
+	writeJSON(w, status, map[string]interface{}{"error": body})
+}
+
+func (s *server) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("Invalid token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *server) handleCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Use POST"))
+		return
+	}
+
+	var req callRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := busproxy.ProxyCall(s.conn, proxyClient, req.Object, req.Interface, req.Method, req.Args)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if busproxy.ErrorName(err) == "org.freedesktop.DBus.Error.AccessDenied" {
+			status = http.StatusForbidden
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+func (s *server) handleProperties(w http.ResponseWriter, r *http.Request) {
+	props, err := busproxy.GetAll(s.conn, r.URL.Query().Get("object"), r.URL.Query().Get("interface"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": props})
+}
+
+// handleEvents streams agent signals as JSON lines until the client
+// disconnects.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("Streaming not supported"))
+		return
+	}
+
+	events := make(chan event, eventsBufSize)
+	s.subscribersLock.Lock()
+	s.subscribers[events] = struct{}{}
+	s.subscribersLock.Unlock()
+
+	defer func() {
+		s.subscribersLock.Lock()
+		delete(s.subscribers, events)
+		s.subscribersLock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			if err := encoder.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *server) dispatchSignals() {
+	err := s.conn.AddMatchSignal(dbus.WithMatchPathNamespace(busproxy.RootPath))
+	if err != nil {
+		logging.Warning.Printf("Can't subscribe to agent signals: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, eventsBufSize)
+	s.conn.Signal(signals)
+
+	for signal := range signals {
+		if !busproxy.ValidObject(string(signal.Path)) {
+			continue
+		}
+
+		e := event{Object: string(signal.Path), Signal: signal.Name, Args: busproxy.Normalize(signal.Body).([]interface{})}
+		s.subscribersLock.Lock()
+		for subscriber := range s.subscribers {
+			// Drop events for clients not keeping up rather than blocking.
+			select {
+			case subscriber <- e:
+			default:
+			}
+		}
+		s.subscribersLock.Unlock()
+	}
+}
+
+// Start serves the agent API as HTTP+JSON on a unix socket for clients that
+// can't use D-Bus. Requests are forwarded to the agent over the bus and need
+// the bearer token stored in /etc/os-agent/api-token.
+func Start(conn *dbus.Conn) {
+	token, err := loadToken()
+	if err != nil {
+		logging.Error.Printf("Can't load API token, HTTP API disabled: %s", err)
+		return
+	}
+
+	os.MkdirAll(filepath.Dir(socketPath), 0755)
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		logging.Error.Printf("Can't listen on %s, HTTP API disabled: %s", socketPath, err)
+		return
+	}
+	os.Chmod(socketPath, 0660)
+
+	s := &server{
+		conn:        conn,
+		token:       token,
+		subscribers: map[chan event]struct{}{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/call", s.authorize(s.handleCall))
+	mux.HandleFunc("/v1/properties", s.authorize(s.handleProperties))
+	mux.HandleFunc("/v1/events", s.authorize(s.handleEvents))
+
+	go s.dispatchSignals()
+	go func() {
+		err := http.Serve(listener, mux)
+		logging.Error.Printf("HTTP API stopped: %s", err)
+	}()
+
+	logging.Info.Printf("Serving HTTP API on %s ...", socketPath)
+}
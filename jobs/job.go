@@ -0,0 +1,271 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	jobIfaceName = "io.hass.os.Job"
+	jobRetention = 10 * time.Minute
+
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+var jobSignals = []introspect.Signal{
+	{
+		Name: "Completed",
+		Args: []introspect.Arg{
+			{Name: "success", Type: "b"},
+			{Name: "message", Type: "s"},
+		},
+	},
+}
+
+// Job tracks a long running operation, exported as /io/hass/os/Jobs/N while
+// it runs and for a while after it finished so clients can pick up the
+// result.
+type Job struct {
+	path        dbus.ObjectPath
+	operation   string
+	cancellable bool
+	ctx         context.Context
+	cancel      context.CancelFunc
+	props       *prop.Properties
+
+	mutex sync.Mutex
+	state string
+}
+
+// jobObject is the D-Bus facing side of a Job.
+type jobObject struct {
+	job *Job
+}
+
+// Cancel requests a cancellable job to stop. The job reports the outcome
+// through its Completed signal once it actually stopped.
+func (o jobObject) Cancel(sender dbus.Sender) (bool, *dbus.Error) {
+	job := o.job
+	if !job.cancellable {
+		return false, apierror.Failed(apierror.New(apierror.CodeUnsupported, "Job %s can't be cancelled", job.path))
+	}
+
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if job.state != StateRunning {
+		return false, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Job %s is not running", job.path))
+	}
+
+	logging.Info.Printf("Cancel job %s (%s).", job.path, job.operation)
+	audit.Record(sender, "Jobs.Cancel", job.operation, string(job.path))
+	job.cancel()
+	return true, nil
+}
+
+var jobMethodArgNames = map[string][]string{
+	"Cancel": {"success"},
+}
+
+// Start creates and exports a job for operation. Cancellable jobs have to
+// watch Context and stop once it is done.
+func Start(operation string, cancellable bool) *Job {
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		path:        dbus.ObjectPath(fmt.Sprintf("%s/%d", objectPath, nextID)),
+		operation:   operation,
+		cancellable: cancellable,
+		ctx:         ctx,
+		cancel:      cancel,
+		state:       StateRunning,
+	}
+	nextID++
+
+	// Without a bus, e.g. in tests, jobs are only tracked in process.
+	if conn != nil {
+		if err := job.export(); err != nil {
+			logging.Warning.Printf("Can't export job %s: %s", job.path, err)
+		}
+	}
+
+	running[job.path] = job
+	updateJobs()
+
+	logging.Info.Printf("Started job %s (%s).", job.path, operation)
+	return job
+}
+
+func (j *Job) export() error {
+	propsSpec := map[string]map[string]*prop.Prop{
+		jobIfaceName: {
+			"Operation": {
+				Value:    j.operation,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"Cancellable": {
+				Value:    j.cancellable,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"State": {
+				Value:    StateRunning,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Stage": {
+				Value:    "",
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Progress": {
+				Value:    uint32(0),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Error": {
+				Value:    "",
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, j.path, propsSpec)
+	if err != nil {
+		return err
+	}
+	j.props = props
+
+	o := jobObject{job: j}
+	if err = recovery.Export(conn, o, j.path, jobIfaceName); err != nil {
+		return err
+	}
+
+	node := &introspect.Node{
+		Name: string(j.path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       jobIfaceName,
+				Methods:    introspection.Methods(o, jobMethodArgNames),
+				Signals:    jobSignals,
+				Properties: props.Introspection(jobIfaceName),
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), j.path, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return err
+	}
+
+	objectmanager.Register(j.path, props, jobIfaceName)
+	return nil
+}
+
+// remove drops the job from the bus once its result was kept long enough.
+func (j *Job) remove() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	delete(running, j.path)
+	updateJobs()
+
+	if j.props == nil {
+		return
+	}
+	objectmanager.Unregister(j.path)
+	conn.Export(nil, j.path, jobIfaceName)
+	conn.Export(nil, j.path, "org.freedesktop.DBus.Properties")
+	conn.Export(nil, j.path, "org.freedesktop.DBus.Introspectable")
+}
+
+// Path returns the object path of the job.
+func (j *Job) Path() dbus.ObjectPath {
+	return j.path
+}
+
+// Context is cancelled when a client cancels the job.
+func (j *Job) Context() context.Context {
+	return j.ctx
+}
+
+// SetProgress updates the progress in percent.
+func (j *Job) SetProgress(percentage uint32) {
+	if j.props != nil {
+		j.props.SetMust(jobIfaceName, "Progress", percentage)
+	}
+}
+
+// SetStage updates the human readable stage the job is in.
+func (j *Job) SetStage(stage string) {
+	if j.props != nil {
+		j.props.SetMust(jobIfaceName, "Stage", stage)
+	}
+}
+
+// Finish records the result of the job and emits Completed. A job failing
+// after it got cancelled counts as cancelled.
+func (j *Job) Finish(err error) {
+	j.mutex.Lock()
+	if j.state != StateRunning {
+		j.mutex.Unlock()
+		return
+	}
+	message := ""
+	switch {
+	case err == nil:
+		j.state = StateSucceeded
+	case j.ctx.Err() != nil:
+		j.state = StateCancelled
+		message = err.Error()
+	default:
+		j.state = StateFailed
+		message = err.Error()
+	}
+	state := j.state
+	j.mutex.Unlock()
+	j.cancel()
+
+	logging.Info.Printf("Job %s (%s) %s.", j.path, j.operation, state)
+
+	if j.props != nil {
+		if err == nil {
+			j.props.SetMust(jobIfaceName, "Progress", uint32(100))
+		}
+		j.props.SetMust(jobIfaceName, "Error", message)
+		j.props.SetMust(jobIfaceName, "State", state)
+
+		emitErr := conn.Emit(j.path, jobIfaceName+".Completed", err == nil, message)
+		if emitErr != nil {
+			logging.Warning.Printf("Can't emit Completed signal: %s", emitErr)
+		}
+	}
+
+	time.AfterFunc(jobRetention, j.remove)
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Jobs"
+	ifaceName  = "io.hass.os.Jobs"
+)
+
+var (
+	lock    sync.Mutex
+	conn    *dbus.Conn
+	props   *prop.Properties
+	nextID  uint64 = 1
+	running        = map[dbus.ObjectPath]*Job{}
+)
+
+type jobs struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// jobPaths returns the paths of all known jobs, lock must be held.
+func jobPaths() []dbus.ObjectPath {
+	paths := []dbus.ObjectPath{}
+	for path := range running {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths
+}
+
+// updateJobs refreshes the Jobs property, lock must be held.
+func updateJobs() {
+	if props != nil {
+		props.SetMust(ifaceName, "Jobs", jobPaths())
+	}
+}
+
+func InitializeDBus(c *dbus.Conn) {
+	d := jobs{
+		conn: c,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Jobs": {
+				Value:    []dbus.ObjectPath{},
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	p, err := recovery.ExportProps(c, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = p
+
+	err = recovery.Export(c, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, nil),
+				Properties: p.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = c.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	lock.Lock()
+	conn = c
+	props = p
+	lock.Unlock()
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, p, ifaceName)
+}
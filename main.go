@@ -1,5 +1,7 @@
 package main
 
+//go:generate go run ./cmd/schema-gen -o api-schema.json
+
 import (
 	"time"
 
@@ -10,16 +12,40 @@ import (
 	"github.com/godbus/dbus/v5/prop"
 
 	"github.com/home-assistant/os-agent/apparmor"
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/bluetooth"
 	"github.com/home-assistant/os-agent/boards"
+	"github.com/home-assistant/os-agent/boot"
 	"github.com/home-assistant/os-agent/cgroup"
 	"github.com/home-assistant/os-agent/datadisk"
+	"github.com/home-assistant/os-agent/diagnostics"
+	"github.com/home-assistant/os-agent/firewall"
+	"github.com/home-assistant/os-agent/firmware"
+	"github.com/home-assistant/os-agent/gpio"
+	"github.com/home-assistant/os-agent/hardware"
+	"github.com/home-assistant/os-agent/hostconfig"
+	"github.com/home-assistant/os-agent/httpapi"
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/network"
+	"github.com/home-assistant/os-agent/powersupply"
+	"github.com/home-assistant/os-agent/security"
+	"github.com/home-assistant/os-agent/supervisor"
 	"github.com/home-assistant/os-agent/system"
+	"github.com/home-assistant/os-agent/telemetry"
+	"github.com/home-assistant/os-agent/timedate"
+	"github.com/home-assistant/os-agent/updates"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/varlink"
+	"github.com/home-assistant/os-agent/watchdog"
 )
 
 const (
 	busName    = "io.hass.os"
 	objectPath = "/io/hass/os"
+	// Bumped whenever a versioned interface is added, e.g. io.hass.os.System2.
+	apiVersion = uint32(2)
 	sentryDsn  = "https://c74e811a96e4413a95caaaa5ae05f851@o427061.ingest.sentry.io/5710878"
 )
 
@@ -45,6 +71,8 @@ func main() {
 	defer sentry.Flush(2 * time.Second)
 	defer sentry.Recover()
 
+	tuneMemory()
+
 	// Connect DBus
 	conn, err := dbus.SystemBus()
 	if err != nil {
@@ -64,12 +92,33 @@ func main() {
 	InitializeDBus(conn)
 
 	logging.Info.Printf("Listening on service %s ...", busName)
+	jobs.InitializeDBus(conn)
 	datadisk.InitializeDBus(conn)
 	system.InitializeDBus(conn)
 	apparmor.InitializeDBus(conn)
 	cgroup.InitializeDBus(conn)
+	timedate.InitializeDBus(conn)
+	powersupply.InitializeDBus(conn)
+	security.InitializeDBus(conn)
+	firewall.InitializeDBus(conn)
+	audit.InitializeDBus(conn)
+	updates.InitializeDBus(conn)
+	boot.InitializeDBus(conn)
+	firmware.InitializeDBus(conn)
+	hostconfig.InitializeDBus(conn)
+	gpio.InitializeDBus(conn)
+	hardware.InitializeDBus(conn)
+	bluetooth.InitializeDBus(conn)
+	network.InitializeDBus(conn)
+	diagnostics.InitializeDBus(conn)
+	supervisor.InitializeDBus(conn)
+	telemetry.InitializeDBus(conn)
+	watchdog.InitializeDBus(conn)
 	boards.InitializeDBus(conn, board)
 
+	httpapi.Start(conn)
+	varlink.Start(conn, version)
+
 	_, err = daemon.SdNotify(false, daemon.SdNotifyReady)
 	if err != nil {
 		logging.Critical.Panic(err)
@@ -86,12 +135,19 @@ func InitializeDBus(conn *dbus.Conn) {
 				Emit:     prop.EmitInvalidates,
 				Callback: nil,
 			},
+			"APIVersion": {
+				Value:    apiVersion,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
 			"Diagnostics": {
 				Value:    enableCapture,
 				Writable: true,
 				Emit:     prop.EmitTrue,
 				Callback: func(c *prop.Change) *dbus.Error {
 					logging.Info.Printf("Diagnostics is now %t", c.Value)
+					audit.Record(recovery.Sender(c), "Diagnostics", enableCapture, c.Value)
 					enableCapture = c.Value.(bool)
 					return nil
 				},
@@ -99,16 +155,19 @@ func InitializeDBus(conn *dbus.Conn) {
 		},
 	}
 
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 
+	objectmanager.Export(conn, objectPath)
+
 	node := &introspect.Node{
 		Name: objectPath,
 		Interfaces: []introspect.Interface{
 			introspect.IntrospectData,
 			prop.IntrospectData,
+			objectmanager.IntrospectData,
 			{
 				Name:       busName,
 				Properties: props.Introspection(busName),
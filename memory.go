@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	smallBoardMemory  = 1024 * 1024 // kB
+	smallBoardGC      = 50
+	freeMemoryPeriod  = 5 * time.Minute
+	memInfoFile       = "/proc/meminfo"
+	memInfoTotalField = "MemTotal:"
+)
+
+func totalMemory() uint64 {
+	file, err := os.Open(memInfoFile)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == memInfoTotalField {
+			total, _ := strconv.ParseUint(fields[1], 10, 64)
+			return total
+		}
+	}
+	return 0
+}
+
+// tuneMemory keeps the agent small on boards with 1GB of memory or less: the
+// heap is collected more eagerly and memory freed after bursts, e.g. a large
+// journal export, is handed back to the kernel instead of being kept around.
+func tuneMemory() {
+	total := totalMemory()
+	if total == 0 || total > smallBoardMemory {
+		return
+	}
+
+	logging.Info.Printf("Small board with %d kB memory, reducing memory footprint.", total)
+	debug.SetGCPercent(smallBoardGC)
+
+	go func() {
+		for range time.Tick(freeMemoryPeriod) {
+			debug.FreeOSMemory()
+		}
+	}()
+}
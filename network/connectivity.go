@@ -0,0 +1,235 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	connectivityConfigFile = "/etc/os-agent/connectivity.json"
+	connectivityTimeout    = 20 * time.Second // below the 25 s D-Bus call timeout
+	dnsCheckHost           = "version.home-assistant.io"
+	ipCheckAddress         = "1.1.1.1:443"
+	captivePortalURL       = "http://checkonline.home-assistant.io/online.txt"
+	captivePortalContent   = "NetworkManager is online"
+)
+
+var (
+	connectivityMutex     sync.Mutex
+	connectivityEndpoints = defaultConnectivityEndpoints
+
+	defaultConnectivityEndpoints = []string{
+		"https://version.home-assistant.io",
+		"https://github.com",
+	}
+)
+
+func loadConnectivityEndpoints() []string {
+	var endpoints []string
+
+	data, err := ioutil.ReadFile(connectivityConfigFile)
+	if err != nil {
+		return defaultConnectivityEndpoints
+	}
+	if err = json.Unmarshal(data, &endpoints); err != nil || len(endpoints) == 0 {
+		logging.Error.Printf("Ignoring invalid connectivity endpoints in %s", connectivityConfigFile)
+		return defaultConnectivityEndpoints
+	}
+	return endpoints
+}
+
+func saveConnectivityEndpoints(endpoints []string) error {
+	data, err := json.Marshal(endpoints)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(connectivityConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(connectivityConfigFile, data, 0644)
+}
+
+func checkAddress(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", ipCheckAddress)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkDNS(ctx context.Context) error {
+	_, err := net.DefaultResolver.LookupHost(ctx, dnsCheckHost)
+	return err
+}
+
+// checkEndpoint treats any HTTP response as reachable, errors are returned
+// as message.
+func checkEndpoint(ctx context.Context, client *http.Client, endpoint string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return err.Error()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	return ""
+}
+
+// checkCaptivePortal fetches a known plain HTTP page, anything else than the
+// expected content means the request got intercepted.
+func checkCaptivePortal(ctx context.Context, client *http.Client) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, captivePortalURL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != captivePortalContent, nil
+}
+
+// CheckConnectivity runs DNS, HTTPS and captive portal checks from the host
+// network namespace, concurrently and under a common deadline. The "state"
+// entry summarizes the result as "online", "dns-failure", "captive-portal",
+// "limited" or "offline".
+func (d network) CheckConnectivity() (map[string]dbus.Variant, *dbus.Error) {
+	connectivityMutex.Lock()
+	endpoints := connectivityEndpoints
+	connectivityMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectivityTimeout)
+	defer cancel()
+
+	client := &http.Client{
+		// Redirects are a sign of captive portals, don't follow them
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	dnsError := ""
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := checkDNS(ctx); err != nil {
+			dnsError = err.Error()
+		}
+	}()
+
+	endpointErrors := map[string]string{}
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			message := checkEndpoint(ctx, client, endpoint)
+			mutex.Lock()
+			endpointErrors[endpoint] = message
+			mutex.Unlock()
+		}(endpoint)
+	}
+
+	var captive bool
+	var captiveErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		captive, captiveErr = checkCaptivePortal(ctx, client)
+	}()
+
+	// With broken DNS no endpoint is reachable by name, a plain connection
+	// to an address tells whether the host is online at all.
+	var ipReachable bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ipReachable = checkAddress(ctx) == nil
+	}()
+
+	wg.Wait()
+
+	reachable := 0
+	for _, message := range endpointErrors {
+		if message == "" {
+			reachable++
+		}
+	}
+
+	result := map[string]dbus.Variant{
+		"dns":            dbus.MakeVariant(dnsError == ""),
+		"dns_error":      dbus.MakeVariant(dnsError),
+		"endpoints":      dbus.MakeVariant(endpointErrors),
+		"captive_portal": dbus.MakeVariant(captive && captiveErr == nil),
+		"ip":             dbus.MakeVariant(ipReachable),
+	}
+
+	state := "online"
+	switch {
+	case !ipReachable && reachable == 0:
+		state = "offline"
+	case dnsError != "":
+		state = "dns-failure"
+	case captive && captiveErr == nil:
+		state = "captive-portal"
+	case reachable < len(endpoints):
+		state = "limited"
+	}
+	result["state"] = dbus.MakeVariant(state)
+
+	logging.Info.Printf("Connectivity check: %s.", state)
+	return result, nil
+}
+
+func (d network) SetConnectivityEndpoints(sender dbus.Sender, endpoints []string) (bool, *dbus.Error) {
+	if len(endpoints) == 0 {
+		return false, dbus.MakeFailedError(fmt.Errorf("At least one endpoint is required"))
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return false, dbus.MakeFailedError(fmt.Errorf("Invalid endpoint '%s'", endpoint))
+		}
+	}
+
+	connectivityMutex.Lock()
+	defer connectivityMutex.Unlock()
+
+	if err := saveConnectivityEndpoints(endpoints); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save connectivity endpoints: %s", err))
+	}
+
+	audit.Record(sender, "Network.SetConnectivityEndpoints", connectivityEndpoints, endpoints)
+	connectivityEndpoints = endpoints
+	d.props.SetMust(ifaceName, "ConnectivityEndpoints", endpoints)
+	return true, nil
+}
@@ -0,0 +1,260 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	fallbackConfigFile    = "/etc/os-agent/fallback-ip.json"
+	fallbackCheckInterval = 15 * time.Second
+	fallbackGracePeriod   = 60 * time.Second
+	fallbackDisabled      = "disabled"
+	fallbackLinkLocal     = "link-local"
+	fallbackStatic        = "static"
+)
+
+type fallbackSettings struct {
+	Interface string `json:"interface"`
+	Mode      string `json:"mode"`
+	Address   string `json:"address,omitempty"`
+}
+
+var (
+	fallbackMutex  sync.Mutex
+	fallbackConfig = fallbackSettings{Mode: fallbackDisabled}
+	fallbackWorker *worker.Worker
+
+	// Address currently added by the agent, empty if none
+	fallbackActive string
+)
+
+func loadFallbackConfig() fallbackSettings {
+	config := fallbackSettings{Mode: fallbackDisabled}
+
+	data, err := ioutil.ReadFile(fallbackConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid fallback IP configuration in %s", fallbackConfigFile)
+		return fallbackSettings{Mode: fallbackDisabled}
+	}
+	return config
+}
+
+func saveFallbackConfig(config fallbackSettings) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fallbackConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fallbackConfigFile, data, 0644)
+}
+
+// linkLocalAddress derives a stable 169.254.0.0/16 address from the MAC
+// address, skipping the reserved first and last /24.
+func linkLocalAddress(iface *net.Interface) string {
+	mac := iface.HardwareAddr
+	if len(mac) < 2 {
+		return "169.254.1.1/16"
+	}
+	third := int(mac[len(mac)-2])%254 + 1
+	return fmt.Sprintf("169.254.%d.%d/16", third, int(mac[len(mac)-1]))
+}
+
+func fallbackAddress(config fallbackSettings) (string, error) {
+	if config.Mode == fallbackStatic {
+		return config.Address, nil
+	}
+	iface, err := net.InterfaceByName(config.Interface)
+	if err != nil {
+		return "", err
+	}
+	return linkLocalAddress(iface), nil
+}
+
+// hasLeasedAddress checks for a routable IPv4 address not set by us.
+func hasLeasedAddress(name string, fallback string) bool {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return false
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+
+	fallbackIP, _, _ := net.ParseCIDR(fallback)
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil || ipnet.IP.IsLinkLocalUnicast() || ipnet.IP.Equal(fallbackIP) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// hasAddress checks whether address in CIDR notation is set on an interface.
+func hasAddress(name string, address string) bool {
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return false
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return false
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// adoptFallback takes over a fallback address still set on the interface,
+// e.g. from before an agent restart, so it gets removed once DHCP succeeds.
+func (d network) adoptFallback() {
+	fallbackMutex.Lock()
+	defer fallbackMutex.Unlock()
+
+	if fallbackConfig.Mode == fallbackDisabled || fallbackActive != "" {
+		return
+	}
+	address, err := fallbackAddress(fallbackConfig)
+	if err != nil || !hasAddress(fallbackConfig.Interface, address) {
+		return
+	}
+	logging.Info.Printf("Adopting fallback address %s on %s.", address, fallbackConfig.Interface)
+	fallbackActive = address
+	d.props.SetMust(ifaceName, "FallbackActive", address)
+}
+
+func changeAddress(action string, address string, name string) error {
+	cmd := exec.Command(ipCmd, "address", action, address, "dev", name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s, output %s", err, out)
+	}
+	return nil
+}
+
+// removeFallback drops the fallback address, call with fallbackMutex held.
+func (d network) removeFallback() {
+	if fallbackActive == "" {
+		return
+	}
+	if err := changeAddress("del", fallbackActive, fallbackConfig.Interface); err != nil {
+		logging.Warning.Printf("Can't remove fallback address %s: %s", fallbackActive, err)
+	}
+	logging.Info.Printf("Fallback address %s removed from %s.", fallbackActive, fallbackConfig.Interface)
+	fallbackActive = ""
+	d.props.SetMust(ifaceName, "FallbackActive", "")
+}
+
+// SetFallbackIP configures the address added to an interface when it got no
+// DHCP lease. Mode is "link-local", "static" with an address in CIDR
+// notation, or "disabled".
+func (d network) SetFallbackIP(sender dbus.Sender, name string, mode string, address string) (bool, *dbus.Error) {
+	config := fallbackSettings{Interface: name, Mode: mode}
+
+	switch mode {
+	case fallbackDisabled:
+		config.Interface = ""
+	case fallbackLinkLocal:
+	case fallbackStatic:
+		ip, _, err := net.ParseCIDR(address)
+		if err != nil || ip.To4() == nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Invalid IPv4 address '%s', use CIDR notation", address))
+		}
+		config.Address = address
+	default:
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid fallback mode '%s'", mode))
+	}
+	if mode != fallbackDisabled && !interfaceRegex.MatchString(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid interface '%s'", name))
+	}
+
+	fallbackMutex.Lock()
+	defer fallbackMutex.Unlock()
+
+	if err := saveFallbackConfig(config); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save fallback IP configuration: %s", err))
+	}
+
+	d.removeFallback()
+	audit.Record(sender, "Network.SetFallbackIP", fallbackConfig, config)
+	fallbackConfig = config
+	fallbackWorker.Set(config.Mode != fallbackDisabled)
+
+	d.props.SetMust(ifaceName, "FallbackInterface", config.Interface)
+	d.props.SetMust(ifaceName, "FallbackMode", config.Mode)
+	d.props.SetMust(ifaceName, "FallbackAddress", config.Address)
+	return true, nil
+}
+
+// watchFallback adds the fallback address once an interface with carrier
+// went without lease for the grace period, and removes it as soon as DHCP
+// succeeds. It only runs while a fallback is configured, see fallbackWorker.
+func (d network) watchFallback(ctx context.Context) {
+	var withoutLease time.Time
+
+	d.adoptFallback()
+	for worker.Sleep(ctx, fallbackCheckInterval) {
+		fallbackMutex.Lock()
+		config := fallbackConfig
+		if config.Mode == fallbackDisabled || readNetAttribute(config.Interface, "carrier") != "1" {
+			withoutLease = time.Time{}
+			fallbackMutex.Unlock()
+			continue
+		}
+
+		address, err := fallbackAddress(config)
+		if err != nil {
+			fallbackMutex.Unlock()
+			continue
+		}
+
+		if hasLeasedAddress(config.Interface, address) {
+			withoutLease = time.Time{}
+			d.removeFallback()
+		} else if fallbackActive == "" {
+			if withoutLease.IsZero() {
+				withoutLease = time.Now()
+			}
+			if time.Since(withoutLease) >= fallbackGracePeriod {
+				if err = changeAddress("add", address, config.Interface); err != nil {
+					logging.Warning.Printf("Can't add fallback address %s: %s", address, err)
+				} else {
+					logging.Warning.Printf("No DHCP lease on %s, added fallback address %s.", config.Interface, address)
+					fallbackActive = address
+					d.props.SetMust(ifaceName, "FallbackActive", address)
+				}
+			}
+		}
+		fallbackMutex.Unlock()
+	}
+}
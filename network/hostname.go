@@ -0,0 +1,85 @@
+package network
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	hostnamedBusName    = "org.freedesktop.hostname1"
+	hostnamedObjectPath = "/org/freedesktop/hostname1"
+	hostnamedIfaceName  = "org.freedesktop.hostname1"
+	avahiBusName        = "org.freedesktop.Avahi"
+	avahiObjectPath     = "/"
+	avahiIfaceName      = "org.freedesktop.Avahi.Server"
+	maxHostnameLength   = 63
+)
+
+// RFC 1123 host name label, used as is for mDNS names
+var hostnameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func validateHostname(name string) error {
+	if len(name) > maxHostnameLength || !hostnameRegex.MatchString(name) {
+		return fmt.Errorf("Invalid hostname '%s', use up to %d lower case letters, digits and hyphens", name, maxHostnameLength)
+	}
+	return nil
+}
+
+func readHostname(conn *dbus.Conn) (string, error) {
+	obj := conn.Object(hostnamedBusName, hostnamedObjectPath)
+	value, err := obj.GetProperty(hostnamedIfaceName + ".StaticHostname")
+	if err != nil {
+		return "", err
+	}
+	name, _ := value.Value().(string)
+	return name, nil
+}
+
+func getHostname(conn *dbus.Conn) string {
+	name, err := readHostname(conn)
+	if err != nil {
+		logging.Warning.Printf("Can't get hostname: %s", err)
+	}
+	return name
+}
+
+func (d network) GetHostname() (string, *dbus.Error) {
+	name, err := readHostname(d.conn)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("Can't get hostname: %s", err))
+	}
+	return name, nil
+}
+
+// SetHostname sets the static and transient hostname and updates the mDNS
+// name announced by Avahi, if it runs.
+func (d network) SetHostname(sender dbus.Sender, name string) (bool, *dbus.Error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if err := validateHostname(name); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	old := getHostname(d.conn)
+	obj := d.conn.Object(hostnamedBusName, hostnamedObjectPath)
+	for _, method := range []string{"SetStaticHostname", "SetHostname"} {
+		if err := obj.Call(hostnamedIfaceName+"."+method, 0, name, false).Err; err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't set hostname: %s", err))
+		}
+	}
+
+	avahi := d.conn.Object(avahiBusName, avahiObjectPath)
+	if err := avahi.Call(avahiIfaceName+".SetHostName", 0, name).Err; err != nil {
+		logging.Warning.Printf("Can't update mDNS hostname: %s", err)
+	}
+
+	logging.Info.Printf("Hostname changed from %s to %s.", old, name)
+	audit.Record(sender, "Network.SetHostname", old, name)
+	d.props.SetMust(ifaceName, "Hostname", name)
+	return true, nil
+}
@@ -0,0 +1,197 @@
+package network
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	linkHistorySize = 100
+	linkBufferSize  = 16384
+
+	rtmgrpLink = 0x1 // RTMGRP_LINK multicast group mask
+)
+
+type linkState struct {
+	Carrier bool
+	Speed   int32
+}
+
+type linkEvent struct {
+	Timestamp int64
+	Interface string
+	Carrier   bool
+	Speed     int32
+}
+
+var (
+	linkMutex   sync.Mutex
+	linkHistory []linkEvent
+
+	linkSignals = []introspect.Signal{
+		{
+			Name: "LinkChanged",
+			Args: []introspect.Arg{
+				{Name: "interface", Type: "s"},
+				{Name: "carrier", Type: "b"},
+				{Name: "speed", Type: "i"},
+			},
+		},
+	}
+)
+
+func readNetAttribute(name string, attribute string) string {
+	data, err := ioutil.ReadFile(filepath.Join(netClass, name, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readLinkState reads carrier and speed in Mb/s, -1 if unknown.
+func readLinkState(name string) linkState {
+	state := linkState{Carrier: readNetAttribute(name, "carrier") == "1", Speed: -1}
+	if !state.Carrier {
+		return state
+	}
+	if speed, err := strconv.Atoi(readNetAttribute(name, "speed")); err == nil {
+		state.Speed = int32(speed)
+	}
+	return state
+}
+
+func recordLinkEvent(event linkEvent) {
+	linkMutex.Lock()
+	defer linkMutex.Unlock()
+
+	linkHistory = append(linkHistory, event)
+	if len(linkHistory) > linkHistorySize {
+		linkHistory = linkHistory[len(linkHistory)-linkHistorySize:]
+	}
+}
+
+// GetLinkHistory returns the recent carrier and speed changes of the host
+// interfaces, oldest first.
+func (d network) GetLinkHistory() ([]linkEvent, *dbus.Error) {
+	linkMutex.Lock()
+	defer linkMutex.Unlock()
+
+	return append([]linkEvent{}, linkHistory...), nil
+}
+
+// openLinkSocket binds a rtnetlink socket to the link notifications.
+func openLinkSocket() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return -1, err
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpLink})
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// linkNames returns the interfaces named in the RTM_NEWLINK messages of a
+// rtnetlink datagram.
+func linkNames(data []byte) []string {
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for i := range messages {
+		if messages[i].Header.Type != syscall.RTM_NEWLINK {
+			continue
+		}
+		attributes, err := syscall.ParseNetlinkRouteAttr(&messages[i])
+		if err != nil {
+			continue
+		}
+		for _, attribute := range attributes {
+			if attribute.Attr.Type == syscall.IFLA_IFNAME {
+				names = append(names, strings.TrimRight(string(attribute.Value), "\x00"))
+			}
+		}
+	}
+	return names
+}
+
+// watchLinks follows carrier and speed of the physical interfaces. The
+// kernel sends a RTM_NEWLINK notification on every carrier change, the
+// state itself is read from sysfs.
+func (d network) watchLinks() {
+	fd, err := openLinkSocket()
+	if err != nil {
+		logging.Warning.Printf("Can't listen for link changes: %s", err)
+		return
+	}
+	defer syscall.Close(fd)
+
+	states := map[string]linkState{}
+	for _, name := range physicalInterfaces() {
+		states[name] = readLinkState(name)
+	}
+
+	buffer := make([]byte, linkBufferSize)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err == syscall.EINTR || err == syscall.ENOBUFS {
+			continue
+		} else if err != nil {
+			logging.Warning.Printf("Can't receive link changes: %s", err)
+			return
+		}
+
+		for _, name := range linkNames(buffer[:n]) {
+			previous, known := states[name]
+			if !known {
+				if !isPhysicalInterface(name) {
+					continue
+				}
+				states[name] = readLinkState(name)
+				continue
+			}
+			state := readLinkState(name)
+			if state == previous {
+				continue
+			}
+			states[name] = state
+
+			logging.Info.Printf("Link %s changed: carrier %t, speed %d Mb/s.", name, state.Carrier, state.Speed)
+			recordLinkEvent(linkEvent{
+				Timestamp: time.Now().Unix(),
+				Interface: name,
+				Carrier:   state.Carrier,
+				Speed:     state.Speed,
+			})
+
+			err := d.conn.Emit(objectPath, ifaceName+".LinkChanged", name, state.Carrier, state.Speed)
+			if err != nil {
+				logging.Warning.Printf("Can't emit LinkChanged signal: %s", err)
+			}
+		}
+	}
+}
+
+func isPhysicalInterface(name string) bool {
+	for _, physical := range physicalInterfaces() {
+		if physical == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"net"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	avahiStateSignal    = avahiIfaceName + ".StateChanged"
+	avahiStateRunning   = 2
+	avahiStateCollision = 3
+	avahiIfUnspec       = int32(-1)
+	avahiProtoUnspec    = int32(-1)
+	resolvedBusName     = "org.freedesktop.resolve1"
+	resolvedObjectPath  = "/org/freedesktop/resolve1"
+	resolvedIfaceName   = "org.freedesktop.resolve1.Manager"
+	resolvedFamilyAny   = int32(0)
+	resolvedFlagsMDNS   = uint64(0x08 | 0x10) // SD_RESOLVED_MDNS_IPV4 | SD_RESOLVED_MDNS_IPV6
+)
+
+var mdnsSignals = []introspect.Signal{
+	{
+		Name: "HostnameConflict",
+		Args: []introspect.Arg{
+			{Name: "hostname", Type: "s"},
+			{Name: "peer", Type: "s"},
+			{Name: "alternative", Type: "s"},
+		},
+	},
+}
+
+// getMDNSHostname returns the name announced by Avahi or, without Avahi, by
+// systemd-resolved, which uses the same name for LLMNR and mDNS.
+func getMDNSHostname(conn *dbus.Conn) string {
+	var name string
+	err := conn.Object(avahiBusName, avahiObjectPath).Call(avahiIfaceName+".GetHostName", 0).Store(&name)
+	if err == nil {
+		return name
+	}
+
+	value, err := conn.Object(resolvedBusName, resolvedObjectPath).GetProperty(resolvedIfaceName + ".LLMNRHostname")
+	if err != nil {
+		return ""
+	}
+	name, _ = value.Value().(string)
+	return name
+}
+
+// resolvePeerResolved looks up a .local name through systemd-resolved.
+func resolvePeerResolved(conn *dbus.Conn, name string) string {
+	var (
+		addresses []struct {
+			Ifindex int32
+			Family  int32
+			Address []byte
+		}
+		canonical string
+		flags     uint64
+	)
+	err := conn.Object(resolvedBusName, resolvedObjectPath).Call(resolvedIfaceName+".ResolveHostname", 0,
+		int32(0), name+".local", resolvedFamilyAny, resolvedFlagsMDNS).
+		Store(&addresses, &canonical, &flags)
+	if err != nil || len(addresses) == 0 {
+		logging.Warning.Printf("Can't resolve conflicting mDNS peer %s.local: %v", name, err)
+		return ""
+	}
+	return net.IP(addresses[0].Address).String()
+}
+
+// resolvePeer looks up which host answers for a .local name, through
+// systemd-resolved if Avahi doesn't run.
+func resolvePeer(conn *dbus.Conn, name string) string {
+	var (
+		iface, protocol, aprotocol int32
+		resolved, address          string
+		flags                      uint32
+	)
+	err := conn.Object(avahiBusName, avahiObjectPath).Call(avahiIfaceName+".ResolveHostName", 0,
+		avahiIfUnspec, avahiProtoUnspec, name+".local", avahiProtoUnspec, uint32(0)).
+		Store(&iface, &protocol, &resolved, &aprotocol, &address, &flags)
+	if err != nil {
+		return resolvePeerResolved(conn, name)
+	}
+	return address
+}
+
+// mdnsNameChanged tells whether a signal reports a possibly changed mDNS
+// name: an Avahi state change or a change of resolved's LLMNRHostname.
+func mdnsNameChanged(signal *dbus.Signal) bool {
+	switch {
+	case signal.Name == avahiStateSignal && len(signal.Body) >= 1:
+		state, _ := signal.Body[0].(int32)
+		return state == avahiStateRunning || state == avahiStateCollision
+	case signal.Name == propertiesIface+".PropertiesChanged" && signal.Path == resolvedObjectPath && len(signal.Body) >= 3:
+		if iface, _ := signal.Body[0].(string); iface != resolvedIfaceName {
+			return false
+		}
+		if changed, ok := signal.Body[1].(map[string]dbus.Variant); ok {
+			if _, ok = changed["LLMNRHostname"]; ok {
+				return true
+			}
+		}
+		invalidated, _ := signal.Body[2].([]string)
+		for _, property := range invalidated {
+			if property == "LLMNRHostname" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watchMDNSConflicts reports when the mDNS responder had to pick another
+// name, e.g. homeassistant-2.local, because a peer already uses ours. Avahi
+// reports this with a state change, systemd-resolved by changing its
+// LLMNRHostname.
+func (d network) watchMDNSConflicts() {
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(avahiObjectPath),
+		dbus.WithMatchInterface(avahiIfaceName),
+		dbus.WithMatchMember("StateChanged"),
+	)
+	if err == nil {
+		err = d.conn.AddMatchSignal(
+			dbus.WithMatchSender(resolvedBusName),
+			dbus.WithMatchObjectPath(resolvedObjectPath),
+			dbus.WithMatchInterface(propertiesIface),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchOption("arg0", resolvedIfaceName),
+		)
+	}
+	if err != nil {
+		logging.Warning.Printf("Can't watch mDNS conflicts: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+
+	// The checks call the responder, which blocks on the connection that delivers
+	// the signals, so they run in a worker. Pending checks are coalesced,
+	// each one reads the current state.
+	changes := make(chan struct{}, 1)
+	go d.checkMDNSConflicts(changes)
+
+	for signal := range signals {
+		if !mdnsNameChanged(signal) {
+			continue
+		}
+
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	close(changes)
+}
+
+func (d network) checkMDNSConflicts(changes <-chan struct{}) {
+	reported := ""
+	for range changes {
+		hostname := getHostname(d.conn)
+		current := getMDNSHostname(d.conn)
+		d.props.SetMust(ifaceName, "MDNSHostname", current)
+		if current == hostname {
+			reported = ""
+		}
+		if hostname == "" || current == "" || current == hostname || current == reported {
+			continue
+		}
+		reported = current
+
+		peer := resolvePeer(d.conn, hostname)
+		logging.Warning.Printf("mDNS name %s.local is used by %s, announcing %s.local instead.", hostname, peer, current)
+		err := d.conn.Emit(objectPath, ifaceName+".HostnameConflict", hostname, peer, current)
+		if err != nil {
+			logging.Warning.Printf("Can't emit HostnameConflict signal: %s", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package network
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	mmBusName       = "org.freedesktop.ModemManager1"
+	mmObjectPath    = "/org/freedesktop/ModemManager1"
+	mmModemIface    = "org.freedesktop.ModemManager1.Modem"
+	objectManager   = "org.freedesktop.DBus.ObjectManager"
+	propertiesIface = "org.freedesktop.DBus.Properties"
+)
+
+// MMModemState
+var modemStates = map[int32]string{
+	-1: "failed",
+	0:  "unknown",
+	1:  "initializing",
+	2:  "locked",
+	3:  "disabled",
+	4:  "disabling",
+	5:  "enabling",
+	6:  "enabled",
+	7:  "searching",
+	8:  "registered",
+	9:  "disconnecting",
+	10: "connecting",
+	11: "connected",
+}
+
+var modemSignals = []introspect.Signal{
+	{
+		Name: "ModemStateChanged",
+		Args: []introspect.Arg{
+			{Name: "modem", Type: "s"},
+			{Name: "state", Type: "s"},
+		},
+	},
+}
+
+func variantString(props map[string]dbus.Variant, name string) string {
+	value, _ := props[name].Value().(string)
+	return value
+}
+
+// getModems returns the modems known to ModemManager keyed by their index.
+func getModems(conn *dbus.Conn) map[string]map[string]string {
+	modems := map[string]map[string]string{}
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := conn.Object(mmBusName, mmObjectPath).Call(objectManager+".GetManagedObjects", 0).Store(&objects)
+	if err != nil {
+		return modems
+	}
+
+	for objectPath, ifaces := range objects {
+		props, ok := ifaces[mmModemIface]
+		if !ok {
+			continue
+		}
+
+		state, _ := props["State"].Value().(int32)
+		quality := ""
+		if signal, ok := props["SignalQuality"].Value().([]interface{}); ok && len(signal) > 0 {
+			if value, ok := signal[0].(uint32); ok {
+				quality = strconv.FormatUint(uint64(value), 10)
+			}
+		}
+
+		modems[path.Base(string(objectPath))] = map[string]string{
+			"imei":           variantString(props, "EquipmentIdentifier"),
+			"manufacturer":   variantString(props, "Manufacturer"),
+			"model":          variantString(props, "Model"),
+			"signal_quality": quality,
+			"state":          modemStates[state],
+		}
+	}
+	return modems
+}
+
+// watchModems refreshes the modem properties on ModemManager changes.
+func (d network) watchModems() {
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchSender(mmBusName),
+		dbus.WithMatchPathNamespace(mmObjectPath),
+	)
+	if err != nil {
+		logging.Warning.Printf("Can't watch modems: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+
+	owner := ""
+	modems := getModems(d.conn)
+	for signal := range signals {
+		switch signal.Name {
+		case propertiesIface + ".PropertiesChanged", objectManager + ".InterfacesAdded", objectManager + ".InterfacesRemoved":
+		default:
+			continue
+		}
+		// The channel gets all signals of the shared connection
+		if signal.Path != mmObjectPath && !strings.HasPrefix(string(signal.Path), mmObjectPath+"/") {
+			continue
+		}
+		if signal.Sender != owner {
+			// ModemManager restarted or another peer uses its paths
+			owner = ""
+			d.conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, mmBusName).Store(&owner)
+			if signal.Sender != owner {
+				continue
+			}
+		}
+
+		current := getModems(d.conn)
+		if reflect.DeepEqual(current, modems) {
+			continue
+		}
+		for name, modem := range current {
+			if previous, ok := modems[name]; ok && previous["state"] == modem["state"] {
+				continue
+			}
+			logging.Info.Printf("Modem %s is %s.", name, modem["state"])
+			err = d.conn.Emit(objectPath, ifaceName+".ModemStateChanged", name, modem["state"])
+			if err != nil {
+				logging.Warning.Printf("Can't emit ModemStateChanged signal: %s", err)
+			}
+		}
+		modems = current
+
+		d.props.SetMust(ifaceName, "Modems", current)
+		d.props.SetMust(ifaceName, "ModemPresent", len(current) > 0)
+	}
+}
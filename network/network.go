@@ -0,0 +1,185 @@
+package network
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/netboot"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	objectPath = "/io/hass/os/Network"
+	ifaceName  = "io.hass.os.Network"
+)
+
+type network struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"CheckConnectivity":        {"result"},
+	"SetConnectivityEndpoints": {"endpoints", "success"},
+	"GetHostname":              {"hostname"},
+	"SetHostname":              {"hostname", "success"},
+	"SetWakeOnLAN":             {"interface", "mode", "success"},
+	"GetLinkHistory":           {"events"},
+	"SetMTU":                   {"interface", "mtu", "success"},
+	"SetOffload":               {"interface", "feature", "enabled", "success"},
+	"SetFallbackIP":            {"interface", "mode", "address", "success"},
+	"SetRegulatoryDomain":      {"country", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := network{
+		conn: conn,
+	}
+
+	var signals []introspect.Signal
+	signals = append(signals, mdnsSignals...)
+	signals = append(signals, linkSignals...)
+	signals = append(signals, modemSignals...)
+
+	connectivityEndpoints = loadConnectivityEndpoints()
+	wolConfig = loadWoLConfig()
+	restoreWoL()
+	tuning = loadTuning()
+	fallbackConfig = loadFallbackConfig()
+	netbootType, netbootServer := netboot.Detect()
+	modems := getModems(conn)
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Hostname": {
+				Value:    getHostname(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"MDNSHostname": {
+				Value:    getMDNSHostname(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"WakeOnLAN": {
+				Value:    getWakeOnLAN(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"InterfaceTuning": {
+				Value:    getInterfaceTuning(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"FallbackInterface": {
+				Value:    fallbackConfig.Interface,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"FallbackMode": {
+				Value:    fallbackConfig.Mode,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"FallbackAddress": {
+				Value:    fallbackConfig.Address,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"FallbackActive": {
+				Value:    "",
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"NetworkBoot": {
+				Value:    netbootType,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"NetworkBootServer": {
+				Value:    netbootServer,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"RegulatoryDomain": {
+				Value:    getRegulatoryDomain(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ModemPresent": {
+				Value:    len(modems) > 0,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Modems": {
+				Value:    modems,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ConnectivityEndpoints": {
+				Value:    connectivityEndpoints,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+	fallbackWorker = worker.New(d.watchFallback)
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    signals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchMDNSConflicts()
+	go d.watchLinks()
+	go d.watchTuning()
+	fallbackWorker.Set(fallbackConfig.Mode != fallbackDisabled)
+	go d.watchModems()
+}
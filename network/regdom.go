@@ -0,0 +1,61 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+)
+
+const (
+	iwCmd          = "iw"
+	cfg80211Config = "/etc/modprobe.d/cfg80211.conf"
+)
+
+// ISO 3166-1 alpha-2 country code, or 00 for the world domain
+var countryRegex = regexp.MustCompile(`^([A-Z]{2}|00)$`)
+
+// getRegulatoryDomain returns the global country of "iw reg get".
+func getRegulatoryDomain() string {
+	out, err := exec.Command(iwCmd, "reg", "get").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "country ") {
+			return strings.TrimSuffix(strings.Fields(line)[1], ":")
+		}
+	}
+	return ""
+}
+
+// SetRegulatoryDomain sets the wireless regulatory country now and, through
+// the cfg80211 module option, on every boot.
+func (d network) SetRegulatoryDomain(sender dbus.Sender, country string) (bool, *dbus.Error) {
+	country = strings.ToUpper(country)
+	if !countryRegex.MatchString(country) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid country code '%s'", country))
+	}
+
+	old := getRegulatoryDomain()
+	cmd := exec.Command(iwCmd, "reg", "set", country)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set regulatory domain: %s, output %s", err, out))
+	}
+
+	content := fmt.Sprintf("options cfg80211 ieee80211_regdom=%s\n", country)
+	if err := managedfiles.Write(cfg80211Config, []byte(content), 0644, "network"); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't persist regulatory domain: %s", err))
+	}
+
+	logging.Info.Printf("Wireless regulatory domain set to %s.", country)
+	audit.Record(sender, "Network.SetRegulatoryDomain", old, country)
+	d.props.SetMust(ifaceName, "RegulatoryDomain", country)
+	return true, nil
+}
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/uevent"
+)
+
+const (
+	tuningConfigFile = "/etc/os-agent/interface-tuning.json"
+	ipCmd            = "ip"
+	minMTU           = 576
+	maxMTU           = 9000
+)
+
+type interfaceTuning struct {
+	MTU      uint32          `json:"mtu,omitempty"`
+	Offloads map[string]bool `json:"offloads,omitempty"`
+}
+
+var (
+	tuningMutex sync.Mutex
+	tuning      = map[string]interfaceTuning{}
+
+	// Offloads known to be broken on some NICs, by ethtool feature name to
+	// the short name ethtool -K expects.
+	offloadFeatures = map[string]string{
+		"tx-checksumming":              "tx",
+		"rx-checksumming":              "rx",
+		"scatter-gather":               "sg",
+		"tcp-segmentation-offload":     "tso",
+		"generic-segmentation-offload": "gso",
+		"generic-receive-offload":      "gro",
+		"large-receive-offload":        "lro",
+	}
+)
+
+func loadTuning() map[string]interfaceTuning {
+	config := map[string]interfaceTuning{}
+
+	data, err := ioutil.ReadFile(tuningConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid interface tuning in %s", tuningConfigFile)
+		return map[string]interfaceTuning{}
+	}
+	return config
+}
+
+func saveTuning(config map[string]interfaceTuning) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(tuningConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tuningConfigFile, data, 0644)
+}
+
+func getInterfaceTuning() map[string]map[string]string {
+	result := map[string]map[string]string{}
+	for name, settings := range tuning {
+		entry := map[string]string{}
+		if settings.MTU != 0 {
+			entry["mtu"] = strconv.FormatUint(uint64(settings.MTU), 10)
+		}
+		for feature, enabled := range settings.Offloads {
+			entry[feature] = strconv.FormatBool(enabled)
+		}
+		result[name] = entry
+	}
+	return result
+}
+
+func applyMTU(name string, mtu uint32) error {
+	cmd := exec.Command(ipCmd, "link", "set", "dev", name, "mtu", strconv.FormatUint(uint64(mtu), 10))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s, output %s", err, out)
+	}
+	return nil
+}
+
+func applyOffload(name string, feature string, enabled bool) error {
+	value := "off"
+	if enabled {
+		value = "on"
+	}
+	cmd := exec.Command(ethtoolCmd, "-K", name, offloadFeatures[feature], value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s, output %s", err, out)
+	}
+	return nil
+}
+
+// applyTuning applies the saved settings of an interface, call with
+// tuningMutex held.
+func applyTuning(name string) {
+	settings, ok := tuning[name]
+	if !ok {
+		return
+	}
+
+	if settings.MTU != 0 {
+		if err := applyMTU(name, settings.MTU); err != nil {
+			logging.Warning.Printf("Can't restore MTU of %s: %s", name, err)
+		}
+	}
+
+	features := make([]string, 0, len(settings.Offloads))
+	for feature := range settings.Offloads {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	for _, feature := range features {
+		if err := applyOffload(name, feature, settings.Offloads[feature]); err != nil {
+			logging.Warning.Printf("Can't restore %s of %s: %s", feature, name, err)
+		}
+	}
+}
+
+func (d network) SetMTU(sender dbus.Sender, name string, mtu uint32) (bool, *dbus.Error) {
+	if !interfaceRegex.MatchString(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid interface '%s'", name))
+	}
+	if mtu < minMTU || mtu > maxMTU {
+		return false, dbus.MakeFailedError(fmt.Errorf("MTU must be between %d and %d", minMTU, maxMTU))
+	}
+
+	tuningMutex.Lock()
+	defer tuningMutex.Unlock()
+
+	if err := applyMTU(name, mtu); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set MTU of %s: %s", name, err))
+	}
+
+	settings := tuning[name]
+	old := settings.MTU
+	settings.MTU = mtu
+	tuning[name] = settings
+	if err := saveTuning(tuning); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save interface tuning: %s", err))
+	}
+
+	logging.Info.Printf("MTU of %s set to %d.", name, mtu)
+	audit.Record(sender, "Network.SetMTU", old, mtu)
+	d.props.SetMust(ifaceName, "InterfaceTuning", getInterfaceTuning())
+	return true, nil
+}
+
+// SetOffload enables or disables one of the offloads in offloadFeatures.
+func (d network) SetOffload(sender dbus.Sender, name string, feature string, enabled bool) (bool, *dbus.Error) {
+	if !interfaceRegex.MatchString(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid interface '%s'", name))
+	}
+	if _, ok := offloadFeatures[feature]; !ok {
+		return false, dbus.MakeFailedError(fmt.Errorf("Offload '%s' can't be changed", feature))
+	}
+
+	tuningMutex.Lock()
+	defer tuningMutex.Unlock()
+
+	if err := applyOffload(name, feature, enabled); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set %s of %s: %s", feature, name, err))
+	}
+
+	settings := tuning[name]
+	if settings.Offloads == nil {
+		settings.Offloads = map[string]bool{}
+	}
+	settings.Offloads[feature] = enabled
+	tuning[name] = settings
+	if err := saveTuning(tuning); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save interface tuning: %s", err))
+	}
+
+	logging.Info.Printf("%s of %s set to %t.", feature, name, enabled)
+	audit.Record(sender, "Network.SetOffload", "", fmt.Sprintf("%s %s=%t", name, feature, enabled))
+	d.props.SetMust(ifaceName, "InterfaceTuning", getInterfaceTuning())
+	return true, nil
+}
+
+// watchTuning applies the saved settings on start and whenever an interface
+// appears, USB NICs lose them when replugged. The kernel announces a new
+// interface under its kernel name (eth0), udev renames it right after, which
+// the kernel reports as a move with the final name.
+func (d network) watchTuning() {
+	tuningMutex.Lock()
+	for name := range tuning {
+		if _, err := os.Stat(filepath.Join(netClass, name)); err == nil {
+			applyTuning(name)
+		}
+	}
+	tuningMutex.Unlock()
+
+	events, err := uevent.Listen("net")
+	if err != nil {
+		logging.Warning.Printf("Can't watch network interfaces: %s", err)
+		return
+	}
+
+	for event := range events {
+		if event.Action != "add" && event.Action != "move" {
+			continue
+		}
+		tuningMutex.Lock()
+		applyTuning(event.Env["INTERFACE"])
+		tuningMutex.Unlock()
+	}
+}
@@ -0,0 +1,170 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	wolConfigFile = "/etc/os-agent/wake-on-lan.json"
+	ethtoolCmd    = "ethtool"
+	netClass      = "/sys/class/net"
+)
+
+var (
+	wolMutex sync.Mutex
+
+	// Interface name to ethtool wolopts
+	wolConfig = map[string]string{}
+
+	// ethtool wol modes, "d" disables
+	wolModeRegex   = regexp.MustCompile(`^(d|[pumbags]+)$`)
+	interfaceRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,15}$`)
+)
+
+func loadWoLConfig() map[string]string {
+	config := map[string]string{}
+
+	data, err := ioutil.ReadFile(wolConfigFile)
+	if err != nil {
+		return config
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid Wake-on-LAN configuration in %s", wolConfigFile)
+		return map[string]string{}
+	}
+	return config
+}
+
+func saveWoLConfig(config map[string]string) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(wolConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(wolConfigFile, data, 0644)
+}
+
+// physicalInterfaces lists the interfaces backed by a device, skipping
+// bridges, veths and the like.
+func physicalInterfaces() []string {
+	var interfaces []string
+
+	entries, _ := ioutil.ReadDir(netClass)
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(netClass, entry.Name(), "device")); err == nil {
+			interfaces = append(interfaces, entry.Name())
+		}
+	}
+	return interfaces
+}
+
+// readWoL parses the "Supports Wake-on" and "Wake-on" lines of ethtool.
+func readWoL(name string) (string, string, error) {
+	out, err := exec.Command(ethtoolCmd, name).Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	supported, current := "", ""
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Supports Wake-on:") {
+			supported = strings.TrimSpace(strings.TrimPrefix(line, "Supports Wake-on:"))
+		} else if strings.HasPrefix(line, "Wake-on:") {
+			current = strings.TrimSpace(strings.TrimPrefix(line, "Wake-on:"))
+		}
+	}
+	return supported, current, nil
+}
+
+func applyWoL(name string, mode string) error {
+	cmd := exec.Command(ethtoolCmd, "-s", name, "wol", mode)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s, output %s", err, out)
+	}
+	return nil
+}
+
+func getWakeOnLAN() map[string]map[string]string {
+	result := map[string]map[string]string{}
+	for _, name := range physicalInterfaces() {
+		supported, current, err := readWoL(name)
+		if err != nil || supported == "" || supported == "d" {
+			continue
+		}
+		result[name] = map[string]string{
+			"supported": supported,
+			"current":   current,
+			"persisted": wolConfig[name],
+		}
+	}
+	return result
+}
+
+// SetWakeOnLAN sets the ethtool Wake-on-LAN modes of an interface, e.g. "g"
+// for magic packets or "d" to disable, and restores them on every start.
+func (d network) SetWakeOnLAN(sender dbus.Sender, name string, mode string) (bool, *dbus.Error) {
+	if !interfaceRegex.MatchString(name) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid interface '%s'", name))
+	}
+	if !wolModeRegex.MatchString(mode) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid Wake-on-LAN mode '%s'", mode))
+	}
+
+	supported, current, err := readWoL(name)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't read Wake-on-LAN of %s: %s", name, err))
+	}
+	for _, c := range mode {
+		// Disabling is always possible, ethtool doesn't list it everywhere
+		if c != 'd' && !strings.ContainsRune(supported, c) {
+			return false, dbus.MakeFailedError(fmt.Errorf("Interface %s doesn't support Wake-on-LAN mode '%c'", name, c))
+		}
+	}
+
+	wolMutex.Lock()
+	defer wolMutex.Unlock()
+
+	if err = applyWoL(name, mode); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set Wake-on-LAN of %s: %s", name, err))
+	}
+
+	wolConfig[name] = mode
+	if err = saveWoLConfig(wolConfig); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't save Wake-on-LAN configuration: %s", err))
+	}
+
+	logging.Info.Printf("Wake-on-LAN of %s set to %s.", name, mode)
+	audit.Record(sender, "Network.SetWakeOnLAN", name+":"+current, name+":"+mode)
+	d.props.SetMust(ifaceName, "WakeOnLAN", getWakeOnLAN())
+	return true, nil
+}
+
+// restoreWoL applies the saved settings, drivers reset them on boot.
+func restoreWoL() {
+	wolMutex.Lock()
+	defer wolMutex.Unlock()
+
+	for name, mode := range wolConfig {
+		if err := applyWoL(name, mode); err != nil {
+			logging.Warning.Printf("Can't restore Wake-on-LAN of %s: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package powersupply
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath          = "/io/hass/os/PowerSupply"
+	ifaceName           = "io.hass.os.PowerSupply"
+	powerSupplyClass    = "/sys/class/power_supply"
+	pollInterval        = 30 * time.Second
+	lowBatteryThreshold = 10
+)
+
+type powersupply struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+type supplyState struct {
+	Type     string
+	Online   bool
+	Capacity int32
+	Status   string
+}
+
+func readAttribute(supply string, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(powerSupplyClass, supply, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSupplies() map[string]supplyState {
+	supplies := map[string]supplyState{}
+
+	entries, err := ioutil.ReadDir(powerSupplyClass)
+	if err != nil {
+		return supplies
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		state := supplyState{
+			Type:     readAttribute(name, "type"),
+			Online:   readAttribute(name, "online") == "1",
+			Capacity: -1,
+			Status:   readAttribute(name, "status"),
+		}
+		if capacity, err := strconv.Atoi(readAttribute(name, "capacity")); err == nil {
+			state.Capacity = int32(capacity)
+		}
+		supplies[name] = state
+	}
+	return supplies
+}
+
+func toProperty(supplies map[string]supplyState) map[string]map[string]string {
+	result := map[string]map[string]string{}
+	for name, state := range supplies {
+		result[name] = map[string]string{
+			"type":     state.Type,
+			"online":   strconv.FormatBool(state.Online),
+			"capacity": strconv.Itoa(int(state.Capacity)),
+			"status":   state.Status,
+		}
+	}
+	return result
+}
+
+// onBattery is true when batteries exist but no mains/USB supply is online.
+func onBattery(supplies map[string]supplyState) bool {
+	battery := false
+	for _, state := range supplies {
+		switch state.Type {
+		case "Battery", "UPS":
+			if state.Status == "Discharging" {
+				battery = true
+			}
+		default:
+			if state.Online {
+				return false
+			}
+		}
+	}
+	return battery
+}
+
+func (d powersupply) watchSupplies() {
+	low := map[string]bool{}
+	for {
+		time.Sleep(pollInterval)
+		supplies := readSupplies()
+
+		d.props.SetMust(ifaceName, "Supplies", toProperty(supplies))
+		d.props.SetMust(ifaceName, "OnBattery", onBattery(supplies))
+		// Most rails are steady, don't signal every poll
+		if current := updateRails(); !reflect.DeepEqual(current, d.props.GetMust(ifaceName, "Rails")) {
+			d.props.SetMust(ifaceName, "Rails", current)
+		}
+
+		for name, state := range supplies {
+			isLow := state.Capacity >= 0 && state.Capacity <= lowBatteryThreshold && state.Status == "Discharging"
+			if isLow && !low[name] {
+				logging.Warning.Printf("Power supply %s is low: %d%%", name, state.Capacity)
+				err := d.conn.Emit(objectPath, ifaceName+".LowBattery", name, state.Capacity)
+				if err != nil {
+					logging.Warning.Printf("Can't emit LowBattery signal: %s", err)
+				}
+			}
+			low[name] = isLow
+		}
+	}
+}
+
+var methodArgNames = map[string][]string{
+	"ResetRails": {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := powersupply{
+		conn: conn,
+	}
+
+	supplies := readSupplies()
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Supplies": {
+				Value:    toProperty(supplies),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"OnBattery": {
+				Value:    onBattery(supplies),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Undervoltage": {
+				Value:    false,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"UndervoltageCount": {
+				Value:    uint32(0),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Rails": {
+				Value:    updateRails(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:    ifaceName,
+				Methods: introspection.Methods(d, methodArgNames),
+				Signals: []introspect.Signal{
+					{
+						Name: "LowBattery",
+						Args: []introspect.Arg{
+							{Name: "supply", Type: "s"},
+							{Name: "capacity", Type: "i"},
+						},
+					},
+					undervoltageSignal,
+				},
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchSupplies()
+
+	if alarmFile := findUndervoltageAlarm(); alarmFile != "" {
+		go d.watchUndervoltage(alarmFile)
+	}
+}
@@ -0,0 +1,192 @@
+package powersupply
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+)
+
+const (
+	regulatorClass = "/sys/class/regulator"
+	vcgencmdCmd    = "vcgencmd"
+	// Reading the PMIC goes through the firmware mailbox, so it's sampled
+	// less often than the other rails
+	pmicInterval = 5 * time.Minute
+)
+
+// Output of vcgencmd pmic_read_adc on the Raspberry Pi 5, e.g.
+// "     EXT5V_V volt(24)=5.15463000V"
+var pmicLineRegex = regexp.MustCompile(`^\s*(\S+)\s+(?:current|volt)\(\d+\)=([0-9.]+)([AV])$`)
+
+type railReading struct {
+	value float64
+	unit  string
+	// A configured value rather than a measurement, e.g. of a regulator
+	setpoint bool
+}
+
+type railStats struct {
+	railReading
+	min float64
+	max float64
+}
+
+var (
+	railsLock    sync.Mutex
+	rails        = map[string]*railStats{}
+	pmicReadings = map[string]railReading{}
+	pmicRead     time.Time
+)
+
+// readHwmonRails reads voltage (in*) and current (curr*) inputs of all hwmon
+// devices, reported in millivolts and milliamperes.
+func readHwmonRails(readings map[string]railReading) {
+	inputs, _ := filepath.Glob(filepath.Join(hwmonClass, "hwmon*", "*_input"))
+	for _, input := range inputs {
+		base := strings.TrimSuffix(filepath.Base(input), "_input")
+		unit := ""
+		if strings.HasPrefix(base, "in") {
+			unit = "V"
+		} else if strings.HasPrefix(base, "curr") {
+			unit = "A"
+		} else {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			continue
+		}
+
+		dir := filepath.Dir(input)
+		device, _ := ioutil.ReadFile(filepath.Join(dir, "name"))
+		label := base
+		if data, err = ioutil.ReadFile(filepath.Join(dir, base+"_label")); err == nil {
+			label = strings.TrimSpace(string(data))
+		}
+		readings[strings.TrimSpace(string(device))+":"+label] = railReading{value: value / 1000, unit: unit}
+	}
+}
+
+// readRegulators reads the configured output voltage of regulators, e.g. on
+// the Yellow carrier board. It only changes when the regulator is
+// reconfigured, so it isn't tracked.
+func readRegulators(readings map[string]railReading) {
+	regulators, _ := filepath.Glob(filepath.Join(regulatorClass, "regulator.*"))
+	for _, regulator := range regulators {
+		data, err := ioutil.ReadFile(filepath.Join(regulator, "microvolts"))
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			continue
+		}
+		name, _ := ioutil.ReadFile(filepath.Join(regulator, "name"))
+		readings["regulator:"+strings.TrimSpace(string(name))] = railReading{value: value / 1000000, unit: "V", setpoint: true}
+	}
+}
+
+// readPMICRails reads the rails of the Raspberry Pi 5 PMIC through the
+// firmware, if vcgencmd is available. Readings are reused for pmicInterval,
+// call with railsLock held.
+func readPMICRails(readings map[string]railReading) {
+	if time.Since(pmicRead) >= pmicInterval {
+		pmicRead = time.Now()
+		pmicReadings = map[string]railReading{}
+
+		out, err := exec.Command(vcgencmdCmd, "pmic_read_adc").Output()
+		if err != nil {
+			// Not a Raspberry Pi 5, or the firmware is too old
+			return
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			match := pmicLineRegex.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			value, err := strconv.ParseFloat(match[2], 64)
+			if err == nil {
+				pmicReadings["pmic:"+match[1]] = railReading{value: value, unit: match[3]}
+			}
+		}
+	}
+
+	for name, reading := range pmicReadings {
+		readings[name] = reading
+	}
+}
+
+// updateRails reads all rails and tracks the minimum and maximum of the
+// measured ones since the agent started or the last ResetRails.
+func updateRails() map[string]map[string]string {
+	railsLock.Lock()
+	defer railsLock.Unlock()
+
+	readings := map[string]railReading{}
+	readHwmonRails(readings)
+	readRegulators(readings)
+	readPMICRails(readings)
+
+	for name, reading := range readings {
+		stats, ok := rails[name]
+		if !ok {
+			rails[name] = &railStats{railReading: reading, min: reading.value, max: reading.value}
+			continue
+		}
+		stats.railReading = reading
+		if reading.value < stats.min {
+			stats.min = reading.value
+		}
+		if reading.value > stats.max {
+			stats.max = reading.value
+		}
+	}
+	return railsProperty()
+}
+
+// railsProperty formats the rails, call with railsLock held.
+func railsProperty() map[string]map[string]string {
+	result := map[string]map[string]string{}
+	for name, stats := range rails {
+		result[name] = map[string]string{
+			"value": strconv.FormatFloat(stats.value, 'f', 3, 64),
+			"unit":  stats.unit,
+		}
+		if !stats.setpoint {
+			result[name]["min"] = strconv.FormatFloat(stats.min, 'f', 3, 64)
+			result[name]["max"] = strconv.FormatFloat(stats.max, 'f', 3, 64)
+		}
+	}
+	return result
+}
+
+// ResetRails restarts the minimum and maximum tracking, e.g. after changing
+// the power supply or USB cable.
+func (d powersupply) ResetRails(sender dbus.Sender) (bool, *dbus.Error) {
+	railsLock.Lock()
+	rails = map[string]*railStats{}
+	pmicRead = time.Time{}
+	railsLock.Unlock()
+
+	audit.Record(sender, "PowerSupply.ResetRails", "", "")
+	d.props.SetMust(ifaceName, "Rails", updateRails())
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package powersupply
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	hwmonClass            = "/sys/class/hwmon"
+	rpiVoltHwmonName      = "rpi_volt"
+	undervoltagePollDelay = time.Second
+)
+
+var undervoltageSignal = introspect.Signal{
+	Name: "UndervoltageDetected",
+	Args: []introspect.Arg{
+		{Name: "timestamp", Type: "x"},
+		{Name: "duration_ms", Type: "x"},
+	},
+}
+
+// findUndervoltageAlarm returns the sysfs alarm file of the Raspberry Pi
+// firmware voltage sensor, or an empty string if the board has none.
+func findUndervoltageAlarm() string {
+	entries, _ := filepath.Glob(filepath.Join(hwmonClass, "hwmon*"))
+	for _, entry := range entries {
+		name, err := ioutil.ReadFile(filepath.Join(entry, "name"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(name)) == rpiVoltHwmonName {
+			return filepath.Join(entry, "in0_lcrit_alarm")
+		}
+	}
+	return ""
+}
+
+func (d powersupply) watchUndervoltage(alarmFile string) {
+	var count uint32
+	var start time.Time
+
+	for {
+		time.Sleep(undervoltagePollDelay)
+
+		data, err := ioutil.ReadFile(alarmFile)
+		if err != nil {
+			logging.Warning.Printf("Can't read undervoltage alarm: %s", err)
+			return
+		}
+		active := strings.TrimSpace(string(data)) == "1"
+
+		if active && start.IsZero() {
+			start = time.Now()
+			count++
+			logging.Warning.Printf("Undervoltage detected!")
+			d.props.SetMust(ifaceName, "Undervoltage", true)
+			d.props.SetMust(ifaceName, "UndervoltageCount", count)
+		} else if !active && !start.IsZero() {
+			duration := time.Since(start)
+			logging.Info.Printf("Undervoltage ended after %s", duration)
+			d.props.SetMust(ifaceName, "Undervoltage", false)
+
+			err = d.conn.Emit(objectPath, ifaceName+".UndervoltageDetected", start.Unix(), duration.Milliseconds())
+			if err != nil {
+				logging.Warning.Printf("Can't emit UndervoltageDetected signal: %s", err)
+			}
+			start = time.Time{}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package security
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	tpmEventLog       = "/sys/kernel/security/tpm0/binary_bios_measurements"
+	tpm2CreateEKCmd   = "tpm2_createek"
+	tpm2CreateAKCmd   = "tpm2_createak"
+	tpm2QuoteCmd      = "tpm2_quote"
+	maxPCRIndex       = 23
+	quotePCRAlgorithm = "sha256"
+)
+
+func runTPMCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s, output %s", name, err, out)
+	}
+	return nil
+}
+
+// Quote returns a TPM quote over the given PCRs signed by a freshly created
+// attestation key, together with the public part of that key and the
+// firmware event log needed to replay the measurements.
+func (d security) Quote(nonce []byte, pcrs []uint32) (map[string][]byte, *dbus.Error) {
+	logging.Info.Printf("Create TPM quote over PCRs %v.", pcrs)
+
+	if !getTPMStatus().present {
+		return nil, dbus.MakeFailedError(fmt.Errorf("No TPM present"))
+	}
+	if len(pcrs) == 0 {
+		return nil, dbus.MakeFailedError(fmt.Errorf("No PCRs selected"))
+	}
+
+	var selection []string
+	for _, pcr := range pcrs {
+		if pcr > maxPCRIndex {
+			return nil, dbus.MakeFailedError(fmt.Errorf("Invalid PCR index %d", pcr))
+		}
+		selection = append(selection, strconv.FormatUint(uint64(pcr), 10))
+	}
+
+	workDir, err := ioutil.TempDir("", "os-agent-quote")
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	defer os.RemoveAll(workDir)
+
+	file := func(name string) string {
+		return filepath.Join(workDir, name)
+	}
+
+	err = runTPMCommand(tpm2CreateEKCmd, "-c", file("ek.ctx"), "-G", "rsa", "-u", file("ek.pub"))
+	if err == nil {
+		err = runTPMCommand(tpm2CreateAKCmd, "-C", file("ek.ctx"), "-c", file("ak.ctx"),
+			"-G", "rsa", "-s", "rsassa", "-g", "sha256", "-u", file("ak.pub"), "-f", "pem")
+	}
+	if err == nil {
+		err = runTPMCommand(tpm2QuoteCmd, "-c", file("ak.ctx"),
+			"-l", quotePCRAlgorithm+":"+strings.Join(selection, ","),
+			"-q", hex.EncodeToString(nonce),
+			"-m", file("quote.msg"), "-s", file("quote.sig"), "-o", file("quote.pcrs"), "-g", "sha256")
+	}
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	result := map[string][]byte{}
+	for key, name := range map[string]string{
+		"quote":     file("quote.msg"),
+		"signature": file("quote.sig"),
+		"pcrs":      file("quote.pcrs"),
+		"ak":        file("ak.pub"),
+	} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, dbus.MakeFailedError(err)
+		}
+		result[key] = data
+	}
+
+	eventLog, err := ioutil.ReadFile(tpmEventLog)
+	if err != nil {
+		logging.Warning.Printf("Can't read TPM event log: %s", err)
+		eventLog = []byte{}
+	}
+	result["eventlog"] = eventLog
+
+	return result, nil
+}
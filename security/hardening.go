@@ -0,0 +1,55 @@
+package security
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	lockdownFile           = "/sys/kernel/security/lockdown"
+	cpuVulnerabilitiesDir  = "/sys/devices/system/cpu/vulnerabilities"
+	moduleSigEnforceFile   = "/sys/module/module/parameters/sig_enforce"
+	lockdownActivePattern  = `\[([a-z]+)\]`
+	lockdownUnknownDefault = "unknown"
+)
+
+var lockdownActiveRegex = regexp.MustCompile(lockdownActivePattern)
+
+// getKernelLockdown returns the active lockdown mode, the kernel marks it
+// with brackets: "none [integrity] confidentiality".
+func getKernelLockdown() string {
+	data, err := ioutil.ReadFile(lockdownFile)
+	if err != nil {
+		return lockdownUnknownDefault
+	}
+
+	found := lockdownActiveRegex.FindSubmatch(data)
+	if found == nil {
+		return lockdownUnknownDefault
+	}
+	return string(found[1])
+}
+
+func getCPUVulnerabilities() map[string]string {
+	result := map[string]string{}
+
+	files, _ := filepath.Glob(filepath.Join(cpuVulnerabilitiesDir, "*"))
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		result[filepath.Base(file)] = strings.TrimSpace(string(data))
+	}
+	return result
+}
+
+func getModuleSignatureEnforced() bool {
+	data, err := ioutil.ReadFile(moduleSigEnforceFile)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) == "Y"
+}
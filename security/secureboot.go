@@ -0,0 +1,48 @@
+package security
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+const (
+	efiVarsDirectory   = "/sys/firmware/efi/efivars"
+	efiGlobalVariable  = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+	shimLockVariable   = "605dab50-e046-4300-abb6-3dd810dd8b23"
+	efiVarAttributeLen = 4
+)
+
+// readEFIBool reads a single byte EFI variable, skipping the attribute header.
+func readEFIBool(name string, guid string) bool {
+	data, err := ioutil.ReadFile(efiVarsDirectory + "/" + name + "-" + guid)
+	if err != nil || len(data) <= efiVarAttributeLen {
+		return false
+	}
+	return data[efiVarAttributeLen] == 1
+}
+
+func getEFIBoot() bool {
+	_, err := os.Stat(efiVarsDirectory)
+	return err == nil
+}
+
+func getSecureBootEnabled() bool {
+	return readEFIBool("SecureBoot", efiGlobalVariable)
+}
+
+func getSecureBootSetupMode() bool {
+	return readEFIBool("SetupMode", efiGlobalVariable)
+}
+
+// getShimLoaded reports if the kernel was started by shim, which exports
+// the MOK list as runtime variable.
+func getShimLoaded() bool {
+	_, err := os.Stat(efiVarsDirectory + "/MokListRT-" + shimLockVariable)
+	return err == nil
+}
+
+// getBootChainVerified is true when firmware enforced signature checks on
+// the boot chain, meaning the running kernel was signed.
+func getBootChainVerified() bool {
+	return getSecureBootEnabled() && !getSecureBootSetupMode()
+}
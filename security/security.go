@@ -0,0 +1,154 @@
+package security
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath = "/io/hass/os/Security"
+	ifaceName  = "io.hass.os.Security"
+)
+
+type security struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+var methodArgNames = map[string][]string{
+	"ClearTPM":     {"success"},
+	"ProvisionTPM": {"owner_auth", "success"},
+	"Quote":        {"nonce", "pcrs", "quote"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := security{
+		conn: conn,
+	}
+
+	tpm := getTPMStatus()
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"TPMPresent": {
+				Value:    tpm.present,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"TPMManufacturer": {
+				Value:    tpm.manufacturer,
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"TPMOwned": {
+				Value:    tpm.owned,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"EFIBoot": {
+				Value:    getEFIBoot(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"SecureBoot": {
+				Value:    getSecureBootEnabled(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"SecureBootSetupMode": {
+				Value:    getSecureBootSetupMode(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"ShimLoaded": {
+				Value:    getShimLoaded(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"BootChainVerified": {
+				Value:    getBootChainVerified(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"KernelLockdown": {
+				Value:    getKernelLockdown(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"CPUVulnerabilities": {
+				Value:    getCPUVulnerabilities(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"ModuleSignatureEnforced": {
+				Value:    getModuleSignatureEnforced(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"SSHAuthFailures": {
+				Value:    uint32(0),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"SSHAutoBlock": {
+				Value:    sshAutoBlock,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setSSHAutoBlock,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    sshGuardSignals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go d.watchSSHFailures()
+}
@@ -0,0 +1,192 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	journalctlCmd     = "journalctl"
+	sshGuardTable     = "os_agent_sshguard"
+	sshGuardWindow    = 10 * time.Minute
+	sshGuardThreshold = 5
+	sshGuardBlockTime = "30m"
+	sshGuardMaxHosts  = 1024
+)
+
+var (
+	sshFailureRegex = regexp.MustCompile(`(?:Failed password for|Invalid user|Bad password attempt for|Login attempt for nonexistent user) .*from (?:\[([0-9a-fA-F:]+)\]|([0-9a-fA-F:]+) port|([0-9.]+))`)
+
+	sshGuardLock     sync.Mutex
+	sshFailures      = map[string][]time.Time{}
+	sshFailuresTotal uint32
+	sshAutoBlock     bool
+)
+
+var sshGuardSignals = []introspect.Signal{
+	{
+		Name: "SSHAuthFailure",
+		Args: []introspect.Arg{
+			{Name: "address", Type: "s"},
+			{Name: "count", Type: "u"},
+		},
+	},
+	{
+		Name: "SSHAddressBlocked",
+		Args: []introspect.Arg{
+			{Name: "address", Type: "s"},
+		},
+	},
+}
+
+func setSSHAutoBlock(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set SSH automatic blocking to %t", c.Value)
+
+	sshGuardLock.Lock()
+	defer sshGuardLock.Unlock()
+
+	audit.Record(recovery.Sender(c), "Security.SSHAutoBlock", sshAutoBlock, c.Value)
+	sshAutoBlock = c.Value.(bool)
+	return nil
+}
+
+func blockSSHAddress(address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("Invalid address '%s'", address)
+	}
+
+	set := "blocked6"
+	if ip.To4() != nil {
+		set = "blocked4"
+	}
+
+	ruleset := fmt.Sprintf(`table inet %[1]s {
+	set blocked4 { type ipv4_addr; flags timeout; }
+	set blocked6 { type ipv6_addr; flags timeout; }
+	chain input {
+		type filter hook input priority -1; policy accept;
+		ip saddr @blocked4 tcp dport { 22, 22222 } drop
+		ip6 saddr @blocked6 tcp dport { 22, 22222 } drop
+	}
+}
+add element inet %[1]s %[2]s { %[3]s timeout %[4]s }
+`, sshGuardTable, set, ip.String(), sshGuardBlockTime)
+
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(ruleset)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Can't block %s: %s, output %s", address, err, out)
+	}
+	return nil
+}
+
+// recentFailures returns the failures within the window, oldest first.
+func recentFailures(failures []time.Time, now time.Time) []time.Time {
+	var recent []time.Time
+	for _, t := range failures {
+		if now.Sub(t) < sshGuardWindow {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+// pruneSSHFailures forgets addresses without failures in the window. If
+// more than sshGuardMaxHosts remain, the ones with the oldest last failure
+// are dropped, so a scan from many addresses can't grow the map unbounded.
+func pruneSSHFailures(now time.Time) {
+	for address, failures := range sshFailures {
+		if recent := recentFailures(failures, now); len(recent) > 0 {
+			sshFailures[address] = recent
+		} else {
+			delete(sshFailures, address)
+		}
+	}
+
+	for len(sshFailures) > sshGuardMaxHosts {
+		var oldest string
+		for address, failures := range sshFailures {
+			if oldest == "" || failures[len(failures)-1].Before(sshFailures[oldest][len(sshFailures[oldest])-1]) {
+				oldest = address
+			}
+		}
+		delete(sshFailures, oldest)
+	}
+}
+
+func (d security) recordSSHFailure(address string) {
+	sshGuardLock.Lock()
+
+	now := time.Now()
+	recent := append(recentFailures(sshFailures[address], now), now)
+	sshFailures[address] = recent
+	pruneSSHFailures(now)
+	sshFailuresTotal++
+
+	total := sshFailuresTotal
+	block := sshAutoBlock && len(recent) == sshGuardThreshold
+	sshGuardLock.Unlock()
+
+	d.props.SetMust(ifaceName, "SSHAuthFailures", total)
+	err := d.conn.Emit(objectPath, ifaceName+".SSHAuthFailure", address, uint32(len(recent)))
+	if err != nil {
+		logging.Warning.Printf("Can't emit SSHAuthFailure signal: %s", err)
+	}
+
+	if block {
+		logging.Warning.Printf("Blocking SSH access from %s after %d failed logins", address, len(recent))
+		if err := blockSSHAddress(address); err != nil {
+			logging.Error.Printf("%s", err)
+			return
+		}
+		err = d.conn.Emit(objectPath, ifaceName+".SSHAddressBlocked", address)
+		if err != nil {
+			logging.Warning.Printf("Can't emit SSHAddressBlocked signal: %s", err)
+		}
+	}
+}
+
+// watchSSHFailures follows the journal of the host SSH daemons.
+func (d security) watchSSHFailures() {
+	cmd := exec.Command(journalctlCmd, "--follow", "--lines=0", "--output=cat",
+		"SYSLOG_IDENTIFIER=sshd", "+", "SYSLOG_IDENTIFIER=dropbear")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logging.Error.Printf("Can't follow SSH journal: %s", err)
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		logging.Error.Printf("Can't follow SSH journal: %s", err)
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		found := sshFailureRegex.FindStringSubmatch(scanner.Text())
+		if found == nil {
+			continue
+		}
+		// Only one of the address groups is set, depending on the daemon
+		d.recordSSHFailure(found[1] + found[2] + found[3])
+	}
+
+	if err = cmd.Wait(); err != nil {
+		logging.Warning.Printf("SSH journal follower stopped: %s", err)
+	}
+}
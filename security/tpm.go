@@ -0,0 +1,99 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	tpmDevice       = "/dev/tpmrm0"
+	tpm2GetCapCmd   = "tpm2_getcap"
+	tpm2ClearCmd    = "tpm2_clear"
+	tpm2ChangeAuth  = "tpm2_changeauth"
+	tpmAuthStdin    = "file:-"
+	tpmManufacturer = `TPM2_PT_MANUFACTURER:\s*\n\s*raw:.*\n\s*value:\s*"([^"]*)"`
+	tpmOwnerAuthSet = `ownerAuthSet:\s*1`
+)
+
+var (
+	tpmManufacturerRegex = regexp.MustCompile(tpmManufacturer)
+	tpmOwnerAuthSetRegex = regexp.MustCompile(tpmOwnerAuthSet)
+)
+
+type tpmStatus struct {
+	present      bool
+	manufacturer string
+	owned        bool
+}
+
+func getTPMStatus() tpmStatus {
+	status := tpmStatus{}
+
+	if _, err := os.Stat(tpmDevice); err != nil {
+		return status
+	}
+	status.present = true
+
+	out, err := exec.Command(tpm2GetCapCmd, "properties-fixed").CombinedOutput()
+	if err != nil {
+		logging.Warning.Printf("Can't read TPM fixed properties: %s", err)
+	} else if found := tpmManufacturerRegex.FindSubmatch(out); found != nil {
+		status.manufacturer = string(found[1])
+	}
+
+	out, err = exec.Command(tpm2GetCapCmd, "properties-variable").CombinedOutput()
+	if err != nil {
+		logging.Warning.Printf("Can't read TPM variable properties: %s", err)
+	} else {
+		status.owned = tpmOwnerAuthSetRegex.Match(out)
+	}
+
+	return status
+}
+
+func (d security) refreshTPMStatus() {
+	status := getTPMStatus()
+	d.props.SetMust(ifaceName, "TPMOwned", status.owned)
+}
+
+func (d security) ClearTPM(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Clear TPM.")
+
+	out, err := exec.Command(tpm2ClearCmd).CombinedOutput()
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't clear TPM: %s, output %s", err, out))
+	}
+
+	d.refreshTPMStatus()
+	audit.Record(sender, "Security.ClearTPM", "", "")
+	return true, nil
+}
+
+func (d security) ProvisionTPM(sender dbus.Sender, ownerAuth string) (bool, *dbus.Error) {
+	logging.Info.Printf("Provision TPM owner hierarchy.")
+
+	if !getTPMStatus().present {
+		return false, dbus.MakeFailedError(fmt.Errorf("No TPM present"))
+	}
+
+	// Pass the auth value on stdin, arguments are visible to everyone in
+	// /proc
+	cmd := exec.Command(tpm2ChangeAuth, "-c", "owner", tpmAuthStdin)
+	cmd.Stdin = strings.NewReader(ownerAuth)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't provision TPM: %s, output %s", err, out))
+	}
+
+	d.refreshTPMStatus()
+	audit.Record(sender, "Security.ProvisionTPM", "", "<redacted>")
+	return true, nil
+}
@@ -0,0 +1,85 @@
+package supervisor
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	objectPath = "/io/hass/os/Supervisor"
+	ifaceName  = "io.hass.os.Supervisor"
+)
+
+type supervisor struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := supervisor{
+		conn: conn,
+	}
+
+	// Init base value
+	watchdogEnabled = loadWatchdogEnabled()
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"WatchdogEnabled": {
+				Value:    watchdogEnabled,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setWatchdogEnabled,
+			},
+			"Responsive": {
+				Value:    true,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+	watchdogWorker = worker.New(d.watchdog)
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, nil),
+				Signals:    watchdogSignals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	watchdogWorker.Set(watchdogEnabled)
+}
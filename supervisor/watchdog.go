@@ -0,0 +1,188 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/docker"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/worker"
+)
+
+const (
+	watchdogConfigFile    = "/etc/os-agent/supervisor-watchdog.json"
+	supervisorContainer   = "hassio_supervisor"
+	supervisorPingURL     = "http://172.30.32.2/supervisor/ping"
+	watchdogInterval      = 1 * time.Minute
+	watchdogPingTimeout   = 15 * time.Second
+	watchdogFailureLimit  = 5
+	watchdogStartupGrace  = 10 * time.Minute
+	watchdogStopTimeout   = 30
+	watchdogRestartPeriod = 30 * time.Minute
+)
+
+var (
+	watchdogMutex   sync.Mutex
+	watchdogEnabled bool
+	watchdogWorker  *worker.Worker
+
+	pingClient = &http.Client{Timeout: watchdogPingTimeout}
+
+	watchdogSignals = []introspect.Signal{
+		{
+			Name: "SupervisorUnresponsive",
+			Args: []introspect.Arg{
+				{Name: "failures", Type: "u"},
+			},
+		},
+		{
+			Name: "SupervisorRestarted",
+			Args: []introspect.Arg{
+				{Name: "success", Type: "b"},
+				{Name: "message", Type: "s"},
+			},
+		},
+	}
+)
+
+func loadWatchdogEnabled() bool {
+	config := struct {
+		Enabled bool `json:"enabled"`
+	}{}
+
+	data, err := ioutil.ReadFile(watchdogConfigFile)
+	if err != nil {
+		return false
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid Supervisor watchdog config in %s", watchdogConfigFile)
+		return false
+	}
+	return config.Enabled
+}
+
+func saveWatchdogEnabled(enabled bool) error {
+	data, err := json.Marshal(map[string]bool{"enabled": enabled})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(watchdogConfigFile), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(watchdogConfigFile, data, 0644)
+}
+
+func setWatchdogEnabled(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set Supervisor watchdog to %t", c.Value)
+
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+
+	if err := saveWatchdogEnabled(c.Value.(bool)); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't persist Supervisor watchdog: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "Supervisor.WatchdogEnabled", watchdogEnabled, c.Value)
+	watchdogEnabled = c.Value.(bool)
+	watchdogWorker.Set(watchdogEnabled)
+	return nil
+}
+
+func pingSupervisor() error {
+	resp, err := pingClient.Get(supervisorPingURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// checkSupervisor returns whether a running Supervisor answers its health
+// check. A stopped container is the business of the Supervisor service unit,
+// a freshly started one gets some time to come up.
+func checkSupervisor() (bool, error) {
+	state, err := docker.InspectContainerState(supervisorContainer)
+	if err != nil {
+		return true, fmt.Errorf("Can't inspect Supervisor container: %s", err)
+	}
+	if !state.Running || state.Restarting {
+		return true, nil
+	}
+	if started, err := time.Parse(time.RFC3339Nano, state.StartedAt); err == nil && time.Since(started) < watchdogStartupGrace {
+		return true, nil
+	}
+
+	if err = pingSupervisor(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d supervisor) restartSupervisor() {
+	logging.Warning.Printf("Supervisor is unresponsive, restarting container %s.", supervisorContainer)
+
+	success := true
+	message := "Restarted unresponsive Supervisor"
+	if err := docker.RestartContainer(supervisorContainer, watchdogStopTimeout); err != nil {
+		logging.Error.Printf("Can't restart Supervisor: %s", err)
+		success = false
+		message = err.Error()
+	}
+
+	audit.Record("", "Supervisor.Restart", "", message)
+	err := d.conn.Emit(objectPath, ifaceName+".SupervisorRestarted", success, message)
+	if err != nil {
+		logging.Warning.Printf("Can't emit SupervisorRestarted signal: %s", err)
+	}
+}
+
+// watchdog only runs while the watchdog is enabled, see watchdogWorker.
+func (d supervisor) watchdog(ctx context.Context) {
+	var failures uint32
+	var lastRestart time.Time
+
+	for worker.Sleep(ctx, watchdogInterval) {
+		responsive, err := checkSupervisor()
+		if err != nil && responsive {
+			logging.Warning.Printf("Supervisor watchdog: %s", err)
+		}
+		d.props.SetMust(ifaceName, "Responsive", responsive)
+		if responsive {
+			failures = 0
+			continue
+		}
+
+		failures++
+		logging.Warning.Printf("Supervisor health check failed (%d/%d): %s", failures, watchdogFailureLimit, err)
+		err = d.conn.Emit(objectPath, ifaceName+".SupervisorUnresponsive", failures)
+		if err != nil {
+			logging.Warning.Printf("Can't emit SupervisorUnresponsive signal: %s", err)
+		}
+
+		// Don't keep a Supervisor from ever coming up by restarting it in a loop
+		if failures >= watchdogFailureLimit && time.Since(lastRestart) > watchdogRestartPeriod {
+			d.restartSupervisor()
+			lastRestart = time.Now()
+			failures = 0
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	// A runtime drop-in, so auto-login never survives a reboot
+	autoLoginDropIn      = "/run/systemd/system/getty@tty1.service.d/os-agent-autologin.conf"
+	autoLoginUnit        = "getty@tty1.service"
+	autoLoginTTY         = "tty1"
+	autoLoginMaxDuration = 60 * time.Minute
+	actionAutoLogin      = "io.hass.os.console-autologin"
+)
+
+var (
+	autoLoginMutex sync.Mutex
+	autoLoginTimer *time.Timer
+)
+
+func (d system) applyAutoLogin(enabled bool) error {
+	if enabled {
+		content := fmt.Sprintf("[Service]\nExecStart=\nExecStart=-/sbin/agetty -o '-p -f -- \\\\u' --noclear --autologin %s %%I $TERM\n", consoleUser)
+		if err := os.MkdirAll(filepath.Dir(autoLoginDropIn), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(autoLoginDropIn, []byte(content), 0644); err != nil {
+			return err
+		}
+	} else if err := os.Remove(autoLoginDropIn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	if err := obj.Call(systemdIfaceName+".Reload", 0).Err; err != nil {
+		return fmt.Errorf("Can't reload systemd: %s", err)
+	}
+	// A session logged in without password must not outlive the period,
+	// the restarted getty asks for the password again.
+	if !enabled {
+		if err := d.terminateConsoleSessions(); err != nil {
+			return err
+		}
+	}
+	if err := obj.Call(systemdIfaceName+".RestartUnit", 0, autoLoginUnit, "replace").Err; err != nil {
+		return fmt.Errorf("Can't restart %s: %s", autoLoginUnit, err)
+	}
+	return nil
+}
+
+// terminateConsoleSessions ends the logind sessions on the auto-login
+// console.
+func (d system) terminateConsoleSessions() error {
+	var sessions []struct {
+		ID   string
+		UID  uint32
+		User string
+		Seat string
+		Path dbus.ObjectPath
+	}
+
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	if err := obj.Call(logindIfaceName+".ListSessions", 0).Store(&sessions); err != nil {
+		return fmt.Errorf("Can't list login sessions: %s", err)
+	}
+
+	for _, session := range sessions {
+		tty, err := d.conn.Object(logindBusName, session.Path).GetProperty("org.freedesktop.login1.Session.TTY")
+		if err != nil || tty.Value() != autoLoginTTY {
+			continue
+		}
+
+		logging.Info.Printf("Terminate console session %s of %s.", session.ID, session.User)
+		if err = obj.Call(logindIfaceName+".TerminateSession", 0, session.ID).Err; err != nil {
+			return fmt.Errorf("Can't terminate session %s: %s", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// EnableConsoleAutoLogin logs in on the first console without a password for
+// a limited time, so a locked out user with physical access can recover.
+func (d system) EnableConsoleAutoLogin(sender dbus.Sender, minutes uint32) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionAutoLogin); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	duration := time.Duration(minutes) * time.Minute
+	if duration <= 0 || duration > autoLoginMaxDuration {
+		return false, dbus.MakeFailedError(fmt.Errorf("Auto-login duration must be between 1 and %d minutes", int(autoLoginMaxDuration.Minutes())))
+	}
+
+	autoLoginMutex.Lock()
+	defer autoLoginMutex.Unlock()
+
+	logging.Info.Printf("Enable console auto-login for %d minutes.", minutes)
+	if err := d.applyAutoLogin(true); err != nil {
+		d.applyAutoLogin(false)
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't enable console auto-login: %s", err))
+	}
+
+	if autoLoginTimer != nil {
+		autoLoginTimer.Stop()
+	}
+	autoLoginTimer = time.AfterFunc(duration, d.expireAutoLogin)
+
+	until := time.Now().Add(duration).Unix()
+	audit.Record(sender, "System.EnableConsoleAutoLogin", "", until)
+	d.props.SetMust(ifaceName, "ConsoleAutoLoginUntil", until)
+	return true, nil
+}
+
+func (d system) disableAutoLogin() error {
+	if autoLoginTimer != nil {
+		autoLoginTimer.Stop()
+		autoLoginTimer = nil
+	}
+	if err := d.applyAutoLogin(false); err != nil {
+		return err
+	}
+	d.props.SetMust(ifaceName, "ConsoleAutoLoginUntil", int64(0))
+	return nil
+}
+
+func (d system) expireAutoLogin() {
+	autoLoginMutex.Lock()
+	defer autoLoginMutex.Unlock()
+
+	logging.Info.Printf("Console auto-login expired.")
+	if err := d.disableAutoLogin(); err != nil {
+		logging.Error.Printf("Can't disable console auto-login: %s", err)
+	}
+	audit.Record("", "System.ConsoleAutoLoginExpired", "", "")
+}
+
+// DisableConsoleAutoLogin ends a console auto-login period early.
+func (d system) DisableConsoleAutoLogin(sender dbus.Sender) (bool, *dbus.Error) {
+	autoLoginMutex.Lock()
+	defer autoLoginMutex.Unlock()
+
+	logging.Info.Printf("Disable console auto-login.")
+	if err := d.disableAutoLogin(); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't disable console auto-login: %s", err))
+	}
+
+	audit.Record(sender, "System.DisableConsoleAutoLogin", "", "")
+	return true, nil
+}
+
+// clearStaleAutoLogin removes an auto-login left behind by a previous agent,
+// whose expiry timer is gone.
+func (d system) clearStaleAutoLogin() {
+	if _, err := os.Stat(autoLoginDropIn); err != nil {
+		return
+	}
+
+	logging.Warning.Printf("Removing stale console auto-login %s.", autoLoginDropIn)
+	if err := d.applyAutoLogin(false); err != nil {
+		logging.Error.Printf("Can't disable console auto-login: %s", err)
+	}
+}
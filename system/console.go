@@ -0,0 +1,172 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	consoleUser       = "root"
+	chpasswdCmd       = "chpasswd"
+	loadkeysCmd       = "loadkeys"
+	localedBusName    = "org.freedesktop.locale1"
+	localedObjectPath = "/org/freedesktop/locale1"
+	localedIfaceName  = "org.freedesktop.locale1"
+	vconsoleConfig    = "/etc/vconsole.conf"
+	localeConfig      = "/etc/locale.conf"
+	actionConsolePass = "io.hass.os.console-password"
+)
+
+var (
+	// Accept only modern crypt(3) hashes: yescrypt, sha512crypt and sha256crypt.
+	passwordHashRegex = regexp.MustCompile(`^\$(y|6|5)\$[./A-Za-z0-9$=]+$`)
+
+	keymapRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	localeRegex = regexp.MustCompile(`^([a-z]{2,3}(_[A-Z]{2})?(\.[A-Za-z0-9-]+)?(@[a-z]+)?|C(\.UTF-8)?|POSIX)$`)
+)
+
+func (d system) SetConsolePassword(sender dbus.Sender, hash string) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionConsolePass); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Set console password for user %s.", consoleUser)
+
+	if !passwordHashRegex.MatchString(hash) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Password must be a yescrypt, SHA-512 or SHA-256 crypt hash"))
+	}
+
+	cmd := exec.Command(chpasswdCmd, "--encrypted")
+	cmd.Stdin = strings.NewReader(consoleUser + ":" + hash + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't set console password: %s, output %s", err, out))
+	}
+
+	audit.Record(sender, "System.SetConsolePassword", "", "<redacted>")
+	return true, nil
+}
+
+// readEnvFile returns a value of a KEY=value file like /etc/vconsole.conf.
+func readEnvFile(path string, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, key+"=") {
+			return strings.Trim(strings.TrimPrefix(line, key+"="), `"`)
+		}
+	}
+	return ""
+}
+
+// updateEnvFile sets a value in a KEY=value file, keeping all other lines.
+func updateEnvFile(path string, key string, value string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line != "" && !strings.HasPrefix(strings.TrimSpace(line), key+"=") {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, key+"="+value)
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func getConsoleKeymap(conn *dbus.Conn) string {
+	obj := conn.Object(localedBusName, localedObjectPath)
+	value, err := obj.GetProperty(localedIfaceName + ".VConsoleKeymap")
+	if err == nil {
+		if keymap, ok := value.Value().(string); ok {
+			return keymap
+		}
+	}
+	return readEnvFile(vconsoleConfig, "KEYMAP")
+}
+
+func getLocale(conn *dbus.Conn) string {
+	obj := conn.Object(localedBusName, localedObjectPath)
+	value, err := obj.GetProperty(localedIfaceName + ".Locale")
+	if err == nil {
+		if settings, ok := value.Value().([]string); ok {
+			for _, setting := range settings {
+				if strings.HasPrefix(setting, "LANG=") {
+					return strings.TrimPrefix(setting, "LANG=")
+				}
+			}
+		}
+	}
+	return readEnvFile(localeConfig, "LANG")
+}
+
+// SetConsoleKeymap sets the keyboard layout of the console, e.g. "de" or
+// "fr-latin1".
+func (d system) SetConsoleKeymap(sender dbus.Sender, keymap string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set console keymap to %s.", keymap)
+
+	if !keymapRegex.MatchString(keymap) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid keymap '%s'", keymap))
+	}
+
+	old := getConsoleKeymap(d.conn)
+	obj := d.conn.Object(localedBusName, localedObjectPath)
+	err := obj.Call(localedIfaceName+".SetVConsoleKeyboard", 0, keymap, "", false, false).Err
+	if err != nil {
+		logging.Warning.Printf("Can't set keymap via localed, updating %s: %s", vconsoleConfig, err)
+
+		if err = updateEnvFile(vconsoleConfig, "KEYMAP", keymap); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't set console keymap: %s", err))
+		}
+		if out, err := exec.Command(loadkeysCmd, keymap).CombinedOutput(); err != nil {
+			logging.Warning.Printf("Can't load keymap %s: %s, output %s", keymap, err, out)
+		}
+	}
+
+	audit.Record(sender, "System.SetConsoleKeymap", old, keymap)
+	d.props.SetMust(ifaceName, "ConsoleKeymap", keymap)
+	return true, nil
+}
+
+// SetLocale sets the system locale, e.g. "de_DE.UTF-8".
+func (d system) SetLocale(sender dbus.Sender, locale string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set locale to %s.", locale)
+
+	if !localeRegex.MatchString(locale) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Invalid locale '%s'", locale))
+	}
+
+	old := getLocale(d.conn)
+	obj := d.conn.Object(localedBusName, localedObjectPath)
+	err := obj.Call(localedIfaceName+".SetLocale", 0, []string{"LANG=" + locale}, false).Err
+	if err != nil {
+		logging.Warning.Printf("Can't set locale via localed, updating %s: %s", localeConfig, err)
+
+		if err = updateEnvFile(localeConfig, "LANG", locale); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't set locale: %s", err))
+		}
+	}
+
+	audit.Record(sender, "System.SetLocale", old, locale)
+	d.props.SetMust(ifaceName, "Locale", locale)
+	return true, nil
+}
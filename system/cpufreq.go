@@ -0,0 +1,81 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	cpuFreqGovernorGlob     = "/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"
+	cpuFreqAvailableGovFile = "/sys/devices/system/cpu/cpu0/cpufreq/scaling_available_governors"
+	cpuFreqTmpfilesConfig   = "/etc/tmpfiles.d/cpufreq-governor.conf"
+)
+
+func getAvailableCPUGovernors() []string {
+	data, err := ioutil.ReadFile(cpuFreqAvailableGovFile)
+	if err != nil {
+		return []string{}
+	}
+	return strings.Fields(string(data))
+}
+
+func getCPUGovernor() string {
+	files, _ := filepath.Glob(cpuFreqGovernorGlob)
+	if len(files) == 0 {
+		return ""
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		logging.Warning.Printf("Can't read CPU governor: %s", err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func setCPUGovernor(c *prop.Change) *dbus.Error {
+	governor := c.Value.(string)
+	logging.Info.Printf("Set CPU frequency governor to %s", governor)
+
+	previous := getCPUGovernor()
+
+	valid := false
+	for _, available := range getAvailableCPUGovernors() {
+		if available == governor {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return dbus.MakeFailedError(fmt.Errorf("CPU governor '%s' is not available", governor))
+	}
+
+	files, _ := filepath.Glob(cpuFreqGovernorGlob)
+	for _, file := range files {
+		err := ioutil.WriteFile(file, []byte(governor), 0644)
+		if err != nil {
+			return dbus.MakeFailedError(fmt.Errorf("Can't set CPU governor on %s: %s", file, err))
+		}
+	}
+
+	// Let systemd-tmpfiles restore the governor on next boot
+	config := fmt.Sprintf("w %s - - - - %s\n", cpuFreqGovernorGlob, governor)
+	err := managedfiles.Write(cpuFreqTmpfilesConfig, []byte(config), 0644, "system")
+	if err != nil {
+		logging.Error.Printf("Failed to persist CPU governor to %s: %s", cpuFreqTmpfilesConfig, err)
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "System.CPUGovernor", previous, governor)
+	return nil
+}
@@ -0,0 +1,109 @@
+package system
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	machineIDFile         = "/etc/machine-id"
+	journaldUnit          = "systemd-journald.service"
+	networkManagerDir     = "/var/lib/NetworkManager"
+	actionManageIdentity  = "io.hass.os.manage-identity"
+	networkManagerKeyFile = "secret_key"
+)
+
+func getMachineID() string {
+	data, err := ioutil.ReadFile(machineIDFile)
+	if err != nil {
+		logging.Warning.Printf("Can't read %s: %s", machineIDFile, err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// newMachineID returns a random ID formatted like systemd's, a version 4
+// UUID without dashes.
+func newMachineID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return hex.EncodeToString(id), nil
+}
+
+// writeMachineID overwrites the machine ID in place. On the OS the file is a
+// bind mount from the overlay partition, so it can't be replaced by a rename.
+func writeMachineID(id string) error {
+	file, err := os.OpenFile(machineIDFile, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = file.WriteString(id + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// resetDHCPIdentity drops what NetworkManager derived from the old identity,
+// the DHCP leases carrying the client ID and the key for stable addresses.
+// NetworkManager recreates them on its next start.
+func resetDHCPIdentity() {
+	leases, _ := filepath.Glob(filepath.Join(networkManagerDir, "*.lease"))
+	for _, file := range append(leases, filepath.Join(networkManagerDir, networkManagerKeyFile)) {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logging.Warning.Printf("Can't remove %s: %s", file, err)
+		}
+	}
+}
+
+// RegenerateMachineID replaces the machine ID, e.g. of a cloned SD card or
+// VM image. The journal continues in a directory named after the new ID
+// right away, the network identity changes with the next reboot.
+func (d system) RegenerateMachineID(sender dbus.Sender) (string, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionManageIdentity); dbuserr != nil {
+		return "", dbuserr
+	}
+
+	old := getMachineID()
+	id, err := newMachineID()
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("Can't generate machine ID: %s", err))
+	}
+
+	logging.Info.Printf("Regenerate machine ID %s, new ID %s.", old, id)
+
+	if err = writeMachineID(id); err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("Can't write %s: %s", machineIDFile, err))
+	}
+
+	resetDHCPIdentity()
+
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	err = obj.Call(systemdIfaceName+".RestartUnit", 0, journaldUnit, "replace").Err
+	if err != nil {
+		logging.Warning.Printf("Can't restart %s: %s", journaldUnit, err)
+	}
+
+	audit.Record(sender, "System.RegenerateMachineID", old, id)
+	d.props.SetMust(ifaceName, "MachineID", id)
+	return id, nil
+}
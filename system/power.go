@@ -0,0 +1,263 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	logindBusName    = "org.freedesktop.login1"
+	logindObjectPath = "/org/freedesktop/login1"
+	logindIfaceName  = "org.freedesktop.login1.Manager"
+	propertiesSignal = "org.freedesktop.DBus.Properties.PropertiesChanged"
+)
+
+// Emitted before the agent asks logind to reboot or power off the host.
+var powerSignals = []introspect.Signal{
+	{
+		Name: "PrepareForShutdown",
+		Args: []introspect.Arg{
+			{Name: "reboot", Type: "b"},
+		},
+	},
+}
+
+type logindInhibitor struct {
+	What string
+	Who  string
+	Why  string
+	Mode string
+	UID  uint32
+	PID  uint32
+}
+
+func getShutdownInhibitors(conn *dbus.Conn) ([]logindInhibitor, error) {
+	var inhibitors []logindInhibitor
+
+	obj := conn.Object(logindBusName, logindObjectPath)
+	err := obj.Call(logindIfaceName+".ListInhibitors", 0).Store(&inhibitors)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocking []logindInhibitor
+	for _, inhibitor := range inhibitors {
+		if inhibitor.Mode != "block" {
+			continue
+		}
+		for _, what := range strings.Split(inhibitor.What, ":") {
+			if what == "shutdown" {
+				blocking = append(blocking, inhibitor)
+				break
+			}
+		}
+	}
+
+	return blocking, nil
+}
+
+func (d system) ShutdownInhibitors() ([]string, *dbus.Error) {
+	inhibitors, err := getShutdownInhibitors(d.conn)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	result := make([]string, len(inhibitors))
+	for i, inhibitor := range inhibitors {
+		result[i] = fmt.Sprintf("%s: %s", inhibitor.Who, inhibitor.Why)
+	}
+	return result, nil
+}
+
+func (d system) shutdown(sender dbus.Sender, reboot bool) (bool, *dbus.Error) {
+	inhibitors, err := getShutdownInhibitors(d.conn)
+	if err != nil {
+		logging.Warning.Printf("Can't read logind inhibitors: %s", err)
+	} else if len(inhibitors) > 0 {
+		var who []string
+		for _, inhibitor := range inhibitors {
+			who = append(who, fmt.Sprintf("%s (%s)", inhibitor.Who, inhibitor.Why))
+		}
+		return false, dbus.MakeFailedError(fmt.Errorf("Shutdown is blocked by: %s", strings.Join(who, ", ")))
+	}
+
+	// Both interfaces declare the signal, clients only match on one.
+	for _, iface := range []string{ifaceName, ifaceName2} {
+		err = d.conn.Emit(objectPath, iface+".PrepareForShutdown", reboot)
+		if err != nil {
+			logging.Warning.Printf("Can't emit PrepareForShutdown signal on %s: %s", iface, err)
+		}
+	}
+
+	method := "PowerOff"
+	if reboot {
+		method = "Reboot"
+	}
+
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	err = obj.Call(logindIfaceName+"."+method, 0, false).Err
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't %s host: %s", strings.ToLower(method), err))
+	}
+
+	audit.Record(sender, "System."+method, "", "")
+	return true, nil
+}
+
+func (d system) Reboot(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Reboot host.")
+	return d.shutdown(sender, true)
+}
+
+func (d system) PowerOff(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Power off host.")
+	return d.shutdown(sender, false)
+}
+
+func getScheduledReboot(conn *dbus.Conn) int64 {
+	var scheduled struct {
+		Type string
+		Usec uint64
+	}
+
+	obj := conn.Object(logindBusName, logindObjectPath)
+	err := obj.StoreProperty(logindIfaceName+".ScheduledShutdown", &scheduled)
+	if err != nil {
+		logging.Warning.Printf("Can't read scheduled shutdown from logind: %s", err)
+		return 0
+	}
+
+	if scheduled.Type != "reboot" || scheduled.Usec == 0 {
+		return 0
+	}
+	return int64(scheduled.Usec / uint64(time.Second/time.Microsecond))
+}
+
+// scheduledShutdownChanged tells whether a logind PropertiesChanged signal
+// touches ScheduledShutdown.
+func scheduledShutdownChanged(signal *dbus.Signal) bool {
+	if len(signal.Body) < 3 {
+		return false
+	}
+	if changed, ok := signal.Body[1].(map[string]dbus.Variant); ok {
+		if _, ok = changed["ScheduledShutdown"]; ok {
+			return true
+		}
+	}
+	invalidated, _ := signal.Body[2].([]string)
+	for _, name := range invalidated {
+		if name == "ScheduledShutdown" {
+			return true
+		}
+	}
+	return false
+}
+
+// watchScheduledReboot keeps ScheduledReboot in sync with logind when the
+// schedule changes behind our back, e.g. through shutdown -r or when
+// logind carries it out. A schedule not made by us has no known reason.
+func (d system) watchScheduledReboot() {
+	err := d.conn.AddMatchSignal(
+		dbus.WithMatchSender(logindBusName),
+		dbus.WithMatchObjectPath(logindObjectPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchOption("arg0", logindIfaceName),
+	)
+	if err != nil {
+		logging.Warning.Printf("Can't watch scheduled shutdown: %s", err)
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+
+	for signal := range signals {
+		// The channel gets all signals of the shared connection
+		if signal.Name != propertiesSignal || signal.Path != logindObjectPath || !scheduledShutdownChanged(signal) {
+			continue
+		}
+
+		scheduled := getScheduledReboot(d.conn)
+		if scheduled == d.props.GetMust(ifaceName, "ScheduledReboot").(int64) {
+			continue
+		}
+		logging.Info.Printf("Scheduled reboot changed to %d.", scheduled)
+		d.props.SetMust(ifaceName, "ScheduledReboot", scheduled)
+		d.props.SetMust(ifaceName, "ScheduledRebootReason", "")
+	}
+}
+
+func (d system) ScheduleReboot(sender dbus.Sender, timestamp int64, reason string) (bool, *dbus.Error) {
+	when := time.Unix(timestamp, 0)
+	if when.Before(time.Now()) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Reboot time %s is in the past", when))
+	}
+
+	logging.Info.Printf("Schedule reboot at %s: %s", when, reason)
+
+	usec := uint64(when.UnixNano() / int64(time.Microsecond))
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	err := obj.Call(logindIfaceName+".ScheduleShutdown", 0, "reboot", usec).Err
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't schedule reboot: %s", err))
+	}
+
+	d.props.SetMust(ifaceName, "ScheduledReboot", timestamp)
+	d.props.SetMust(ifaceName, "ScheduledRebootReason", reason)
+	audit.Record(sender, "System.ScheduleReboot", "", when)
+	return true, nil
+}
+
+func (d system) CancelScheduledReboot(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Cancel scheduled reboot.")
+
+	var cancelled bool
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	err := obj.Call(logindIfaceName+".CancelScheduledShutdown", 0).Store(&cancelled)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't cancel scheduled reboot: %s", err))
+	}
+
+	d.props.SetMust(ifaceName, "ScheduledReboot", int64(0))
+	d.props.SetMust(ifaceName, "ScheduledRebootReason", "")
+	audit.Record(sender, "System.CancelScheduledReboot", "", "")
+	return cancelled, nil
+}
+
+func getCanSuspend(conn *dbus.Conn) bool {
+	var result string
+
+	obj := conn.Object(logindBusName, logindObjectPath)
+	err := obj.Call(logindIfaceName+".CanSuspend", 0).Store(&result)
+	if err != nil {
+		logging.Warning.Printf("Can't read suspend capability from logind: %s", err)
+		return false
+	}
+
+	return result == "yes"
+}
+
+func (d system) Suspend(sender dbus.Sender) (bool, *dbus.Error) {
+	if !getCanSuspend(d.conn) {
+		return false, dbus.MakeFailedError(fmt.Errorf("Suspend is not supported on this host"))
+	}
+
+	logging.Info.Printf("Suspend host.")
+
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	err := obj.Call(logindIfaceName+".Suspend", 0, false).Err
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't suspend host: %s", err))
+	}
+
+	audit.Record(sender, "System.Suspend", "", "")
+	return true, nil
+}
@@ -0,0 +1,173 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	sshdDropInFile    = "/etc/ssh/sshd_config.d/os-agent.conf"
+	sshdCmd           = "sshd"
+	sshdUnit          = "sshd.service"
+	debugSSHUnit      = "dropbear.service"
+	defaultSSHPort    = 22
+	systemdBusName    = "org.freedesktop.systemd1"
+	systemdObjectPath = "/org/freedesktop/systemd1"
+	systemdIfaceName  = "org.freedesktop.systemd1.Manager"
+)
+
+type sshdConfig struct {
+	passwordAuthentication bool
+	port                   uint16
+}
+
+var (
+	sshdSettings = sshdConfig{passwordAuthentication: false, port: defaultSSHPort}
+)
+
+func readSSHDConfig() sshdConfig {
+	config := sshdConfig{passwordAuthentication: false, port: defaultSSHPort}
+
+	file, err := os.Open(sshdDropInFile)
+	if err != nil {
+		return config
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "PasswordAuthentication":
+			config.passwordAuthentication = fields[1] == "yes"
+		case "Port":
+			if port, err := strconv.ParseUint(fields[1], 10, 16); err == nil {
+				config.port = uint16(port)
+			}
+		}
+	}
+	return config
+}
+
+func renderSSHDConfig(config sshdConfig) []byte {
+	passwordAuth := "no"
+	if config.passwordAuthentication {
+		passwordAuth = "yes"
+	}
+	return []byte(fmt.Sprintf("PasswordAuthentication %s\nPort %d\n", passwordAuth, config.port))
+}
+
+// writeSSHDConfig writes the drop-in, validates it with sshd and reloads the
+// service. The previous drop-in is restored if validation fails.
+func writeSSHDConfig(conn *dbus.Conn, config sshdConfig) error {
+	_, statErr := os.Stat(sshdDropInFile)
+	previous := readSSHDConfig()
+
+	err := managedfiles.Write(sshdDropInFile, renderSSHDConfig(config), 0644, "system")
+	if err != nil {
+		logging.Error.Printf("Failed to write sshd configuration %s: %s", sshdDropInFile, err)
+		return err
+	}
+
+	out, err := exec.Command(sshdCmd, "-t").CombinedOutput()
+	if err != nil {
+		if statErr == nil {
+			managedfiles.Write(sshdDropInFile, renderSSHDConfig(previous), 0644, "system")
+		} else {
+			managedfiles.Remove(sshdDropInFile)
+		}
+		return fmt.Errorf("Invalid sshd configuration: %s, output %s", err, out)
+	}
+
+	obj := conn.Object(systemdBusName, systemdObjectPath)
+	err = obj.Call(systemdIfaceName+".TryRestartUnit", 0, sshdUnit, "replace").Err
+	if err != nil {
+		return fmt.Errorf("Can't restart %s: %s", sshdUnit, err)
+	}
+	return nil
+}
+
+func (d system) setSSHPasswordAuthentication(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set SSH password authentication to %t", c.Value)
+
+	config := sshdSettings
+	config.passwordAuthentication = c.Value.(bool)
+	if err := writeSSHDConfig(d.conn, config); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "System.SSHPasswordAuthentication", sshdSettings.passwordAuthentication, config.passwordAuthentication)
+	sshdSettings = config
+	return nil
+}
+
+func (d system) setSSHPort(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set SSH port to %d", c.Value)
+
+	port := c.Value.(uint16)
+	if port == 0 {
+		return dbus.MakeFailedError(fmt.Errorf("Invalid SSH port 0"))
+	}
+
+	config := sshdSettings
+	config.port = port
+	if err := writeSSHDConfig(d.conn, config); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	audit.Record(recovery.Sender(c), "System.SSHPort", sshdSettings.port, config.port)
+	sshdSettings = config
+	return nil
+}
+
+func getDebugSSH(conn *dbus.Conn) bool {
+	var state string
+
+	obj := conn.Object(systemdBusName, systemdObjectPath)
+	err := obj.Call(systemdIfaceName+".GetUnitFileState", 0, debugSSHUnit).Store(&state)
+	if err != nil {
+		return false
+	}
+	return state == "enabled"
+}
+
+func (d system) setDebugSSH(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set debug SSH to %t", c.Value)
+
+	var err error
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	units := []string{debugSSHUnit}
+	if c.Value.(bool) {
+		err = obj.Call(systemdIfaceName+".EnableUnitFiles", 0, units, false, true).Err
+		if err == nil {
+			err = obj.Call(systemdIfaceName+".StartUnit", 0, debugSSHUnit, "replace").Err
+		}
+	} else {
+		err = obj.Call(systemdIfaceName+".DisableUnitFiles", 0, units, false).Err
+		if err == nil {
+			err = obj.Call(systemdIfaceName+".StopUnit", 0, debugSSHUnit, "replace").Err
+		}
+	}
+
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't change %s: %s", debugSSHUnit, err))
+	}
+
+	audit.Record(recovery.Sender(c), "System.DebugSSH", !c.Value.(bool), c.Value)
+	return nil
+}
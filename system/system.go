@@ -2,18 +2,28 @@ package system
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/home-assistant/os-agent/audit"
 	"github.com/home-assistant/os-agent/udisks2"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/cmdline"
+	"github.com/home-assistant/os-agent/utils/introspection"
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/netboot"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
 )
 
 const (
@@ -22,7 +32,7 @@ const (
 	labelDataFileSystem      = "hassos-data"
 	labelOverlayFileSystem   = "hassos-overlay"
 	kernelCommandLine        = "/mnt/boot/cmdline.txt"
-	tmpKernelCommandLine     = "/mnt/boot/.tmp.cmdline.txt"
+	wipeArg                  = "haos.wipe=1"
 	sshAuthKeyFileName       = "/root/.ssh/authorized_keys"
 	modulesAutoloadDirectory = "/etc/modules-load.d/"
 	moduleLoadCommand        = "/sbin/modprobe"
@@ -30,6 +40,9 @@ const (
 
 var (
 	loadUSBIP bool
+
+	// Replaced by tests to inject a mock
+	newUDisks2 = func(conn *dbus.Conn) udisks2.Helper { return udisks2.NewUDisks2(conn) }
 )
 
 type system struct {
@@ -37,7 +50,7 @@ type system struct {
 	props *prop.Properties
 }
 
-func getAndCheckBusObjectFromLabel(udisks2helper udisks2.UDisks2Helper, label string) (dbus.BusObject, error) {
+func getAndCheckBusObjectFromLabel(udisks2helper udisks2.Helper, label string) (dbus.BusObject, error) {
 	dataBusObject, err := udisks2helper.GetBusObjectFromLabel(label)
 	if err != nil {
 		return nil, dbus.MakeFailedError(err)
@@ -56,62 +69,132 @@ func getAndCheckBusObjectFromLabel(udisks2helper udisks2.UDisks2Helper, label st
 	return dataBusObject, nil
 }
 
-func (d system) WipeDevice() (bool, *dbus.Error) {
+func getWipeScheduled() bool {
+	args, err := cmdline.Read(kernelCommandLine)
+	if err != nil {
+		return false
+	}
+	return args.Contains(wipeArg)
+}
+
+func getSSHAuthKeys() []string {
+	keys := []string{}
+
+	data, err := ioutil.ReadFile(sshAuthKeyFileName)
+	if err != nil {
+		return keys
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys
+}
+
+func (d system) wipeDevice() error {
 	logging.Info.Printf("Wipe device data.")
 
-	udisks2helper := udisks2.NewUDisks2(d.conn)
+	if bootType, server := netboot.Detect(); bootType != netboot.None {
+		return apierror.New(apierror.CodeUnsupported, "System booted via %s from %s, refusing to wipe network backed storage", bootType, server).
+			WithRemediation(apierror.RemedyUseLocalStorage)
+	}
+
+	udisks2helper := newUDisks2(d.conn)
 	dataBusObject, err := getAndCheckBusObjectFromLabel(udisks2helper, labelDataFileSystem)
 	if err != nil {
-		return false, dbus.MakeFailedError(err)
+		return err
 	}
 
 	overlayBusObject, err := getAndCheckBusObjectFromLabel(udisks2helper, labelOverlayFileSystem)
 	if err != nil {
-		return false, dbus.MakeFailedError(err)
+		return err
 	}
 
-	err = udisks2helper.FormatPartition(dataBusObject, "ext4", labelDataFileSystem)
-	if err != nil {
-		return false, dbus.MakeFailedError(err)
+	// The partitions are independent, on slow SD cards each mkfs takes
+	// tens of seconds.
+	partitions := map[string]dbus.BusObject{
+		labelDataFileSystem:    dataBusObject,
+		labelOverlayFileSystem: overlayBusObject,
 	}
-	err = udisks2helper.FormatPartition(overlayBusObject, "ext4", labelOverlayFileSystem)
-	if err != nil {
-		return false, dbus.MakeFailedError(err)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var failures []string
+	for label, busObject := range partitions {
+		wg.Add(1)
+		go func(label string, busObject dbus.BusObject) {
+			defer wg.Done()
+			if err := udisks2helper.FormatPartition(busObject, "ext4", label); err != nil {
+				mutex.Lock()
+				failures = append(failures, fmt.Sprintf("Can't format %s: %s", label, err))
+				mutex.Unlock()
+			}
+		}(label, busObject)
 	}
-	logging.Info.Printf("Successfully wiped device data.")
+	wg.Wait()
 
-	return true, nil
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return apierror.Wrap(apierror.CodeDeviceError, errors.New(strings.Join(failures, "; "))).
+			WithRemediation(apierror.RemedyCheckDevice)
+	}
+	logging.Info.Printf("Successfully wiped device data.")
+	return nil
 }
 
-func (d system) ScheduleWipeDevice() (bool, *dbus.Error) {
+func (d system) WipeDevice(sender dbus.Sender) (bool, *dbus.Error) {
+	// Shares the running flag with the background wipe on System2, so
+	// both never format at the same time.
+	wipeLock.Lock()
+	if wipeRunning {
+		wipeLock.Unlock()
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "Device wipe is already running").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+	wipeRunning = true
+	wipeLock.Unlock()
+
+	err := d.wipeDevice()
+
+	wipeLock.Lock()
+	wipeRunning = false
+	wipeLock.Unlock()
 
-	data, err := ioutil.ReadFile(kernelCommandLine)
 	if err != nil {
-		fmt.Println(err)
-		return false, dbus.MakeFailedError(err)
+		return false, apierror.Failed(err)
 	}
 
-	datastr := strings.TrimSpace(string(data))
-	datastr += " haos.wipe=1"
+	audit.Record(sender, "System.WipeDevice", "", "")
+	return true, nil
+}
 
-	err = ioutil.WriteFile(tmpKernelCommandLine, []byte(datastr), 0644)
-	if err != nil {
-		fmt.Println(err)
-		return false, dbus.MakeFailedError(err)
+func (d system) ScheduleWipeDevice(sender dbus.Sender) (bool, *dbus.Error) {
+	if bootType, server := netboot.Detect(); bootType != netboot.None {
+		return false, apierror.Failed(apierror.New(apierror.CodeUnsupported, "System booted via %s from %s, refusing to schedule a wipe of network backed storage", bootType, server).
+			WithRemediation(apierror.RemedyUseLocalStorage))
 	}
 
-	// Boot is mounted sync on Home Assistant OS, so just rename should be fine.
-	err = os.Rename(tmpKernelCommandLine, kernelCommandLine)
+	args, err := cmdline.Read(kernelCommandLine)
 	if err != nil {
-		fmt.Println(err)
 		return false, dbus.MakeFailedError(err)
 	}
 
+	if !args.Contains(wipeArg) {
+		args.Append(wipeArg)
+		if err = args.Write(kernelCommandLine); err != nil {
+			return false, dbus.MakeFailedError(err)
+		}
+	}
+
 	logging.Info.Printf("Device will get wiped on next reboot!")
+	audit.Record(sender, "System.ScheduleWipeDevice", "", wipeArg)
+	d.props.SetMust(ifaceName, "WipeScheduled", true)
 	return true, nil
 }
 
-func (d system) AddSSHAuthKey(newKey string) *dbus.Error {
+func (d system) AddSSHAuthKey(sender dbus.Sender, newKey string) *dbus.Error {
 
 	file, err := os.OpenFile(sshAuthKeyFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -128,15 +211,61 @@ func (d system) AddSSHAuthKey(newKey string) *dbus.Error {
 
 	logging.Info.Printf("New SSH authentication key added for user root.")
 
+	audit.Record(sender, "System.AddSSHAuthKey", "", newKey)
+	d.props.SetMust(ifaceName, "SSHAuthKeys", getSSHAuthKeys())
 	return nil
 }
 
-func (d system) ClearSSHAuthKeys() *dbus.Error {
+// RemoveSSHAuthKey removes the lines matching the key, comments and all
+// other lines of the file are kept as they are.
+func (d system) RemoveSSHAuthKey(sender dbus.Sender, key string) (bool, *dbus.Error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return false, nil
+	}
+
+	data, err := ioutil.ReadFile(sshAuthKeyFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	content := ""
+	for _, line := range lines {
+		if strings.TrimSpace(line) != key {
+			content += line
+		}
+	}
+	if len(content) == len(data) {
+		return false, nil
+	}
+
+	tmpFileName := sshAuthKeyFileName + ".tmp"
+	if err := ioutil.WriteFile(tmpFileName, []byte(content), 0644); err != nil {
+		logging.Error.Printf("Failed to write SSH authentication file: %s.", err)
+		return false, dbus.MakeFailedError(err)
+	}
+	if err := os.Rename(tmpFileName, sshAuthKeyFileName); err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	logging.Info.Printf("SSH authentication key removed for user root.")
+
+	audit.Record(sender, "System.RemoveSSHAuthKey", key, "")
+	d.props.SetMust(ifaceName, "SSHAuthKeys", getSSHAuthKeys())
+	return true, nil
+}
+
+func (d system) ClearSSHAuthKeys(sender dbus.Sender) *dbus.Error {
 	if err := os.Remove(sshAuthKeyFileName); err != nil && os.IsNotExist(err) {
 		logging.Error.Printf("Failed to delete SSH authentication file %s: %s", sshAuthKeyFileName, err)
 		return dbus.MakeFailedError(err)
 	}
 
+	audit.Record(sender, "System.ClearSSHAuthKeys", "", "")
+	d.props.SetMust(ifaceName, "SSHAuthKeys", []string{})
 	return nil
 }
 
@@ -152,6 +281,7 @@ func getDriverStatus() bool {
 
 func LoadKernelDriver(c *prop.Change) *dbus.Error {
 	logging.Info.Printf("Loading usbip driver: %t", c.Value)
+	audit.Record(recovery.Sender(c), "System.LoadUSBIP", loadUSBIP, c.Value)
 	loadUSBIP = c.Value.(bool)
 
 	var err error
@@ -169,31 +299,143 @@ func LoadKernelDriver(c *prop.Change) *dbus.Error {
 	return nil
 }
 
+var methodArgNames = map[string][]string{
+	"WipeDevice":              {"success"},
+	"ScheduleWipeDevice":      {"success"},
+	"AddSSHAuthKey":           {"key"},
+	"RemoveSSHAuthKey":        {"key", "removed"},
+	"ClearSSHAuthKeys":        {},
+	"ShutdownInhibitors":      {"inhibitors"},
+	"Reboot":                  {"success"},
+	"PowerOff":                {"success"},
+	"ScheduleReboot":          {"timestamp", "reason", "success"},
+	"CancelScheduledReboot":   {"cancelled"},
+	"Suspend":                 {"success"},
+	"SetConsolePassword":      {"hash", "success"},
+	"RegenerateMachineID":     {"machine_id"},
+	"SetConsoleKeymap":        {"keymap", "success"},
+	"SetLocale":               {"locale", "success"},
+	"EnableConsoleAutoLogin":  {"minutes", "success"},
+	"DisableConsoleAutoLogin": {"success"},
+}
+
 func InitializeDBus(conn *dbus.Conn) {
 	d := system{
 		conn: conn,
 	}
 
 	loadUSBIP = getDriverStatus()
+	sshdSettings = readSSHDConfig()
+	d.clearStaleAutoLogin()
 
 	propsSpec := map[string]map[string]*prop.Prop{
 		ifaceName: {
+			"MachineID": {
+				Value:    getMachineID(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ConsoleKeymap": {
+				Value:    getConsoleKeymap(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Locale": {
+				Value:    getLocale(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ConsoleAutoLoginUntil": {
+				Value:    int64(0),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
 			"LoadUSBIP": {
 				Value:    loadUSBIP,
 				Writable: true,
 				Emit:     prop.EmitTrue,
 				Callback: LoadKernelDriver,
 			},
+			"WipeScheduled": {
+				Value:    getWipeScheduled(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"SSHAuthKeys": {
+				Value:    getSSHAuthKeys(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ScheduledReboot": {
+				Value:    getScheduledReboot(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"ScheduledRebootReason": {
+				Value:    "",
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"CanSuspend": {
+				Value:    getCanSuspend(conn),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"CPUGovernor": {
+				Value:    getCPUGovernor(),
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setCPUGovernor,
+			},
+			"AvailableCPUGovernors": {
+				Value:    getAvailableCPUGovernors(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"SSHPasswordAuthentication": {
+				Value:    sshdSettings.passwordAuthentication,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: d.setSSHPasswordAuthentication,
+			},
+			"SSHPort": {
+				Value:    sshdSettings.port,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: d.setSSHPort,
+			},
+			"DebugSSH": {
+				Value:    getDebugSSH(conn),
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: d.setDebugSSH,
+			},
 		},
 	}
 
-	props, err := prop.Export(conn, objectPath, propsSpec)
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
 	d.props = props
 
-	err = conn.Export(d, objectPath, ifaceName)
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	d2 := system2{system: d}
+	err = recovery.Export(conn, d2, objectPath, ifaceName2)
 	if err != nil {
 		logging.Critical.Panic(err)
 	}
@@ -204,8 +446,15 @@ func InitializeDBus(conn *dbus.Conn) {
 			introspect.IntrospectData,
 			prop.IntrospectData,
 			{
-				Name:    ifaceName,
-				Methods: introspect.Methods(d),
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    powerSignals,
+				Properties: props.Introspection(ifaceName),
+			},
+			{
+				Name:    ifaceName2,
+				Methods: introspection.Methods(d2, methodArgNames),
+				Signals: append(powerSignals, wipeSignals...),
 			},
 		},
 	}
@@ -216,4 +465,8 @@ func InitializeDBus(conn *dbus.Conn) {
 	}
 
 	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName2)
+	objectmanager.Register(objectPath, props, ifaceName, ifaceName2)
+
+	go d.watchScheduledReboot()
 }
@@ -0,0 +1,85 @@
+package system
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/jobs"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+// Interfaces with breaking changes get a new name with the major version as
+// suffix and are exported next to the old one on the same object. The legacy
+// interface keeps its behavior until no supported Supervisor uses it anymore.
+// Properties stay on the legacy interface.
+const (
+	ifaceName2 = "io.hass.os.System2"
+)
+
+var (
+	wipeLock    sync.Mutex
+	wipeRunning bool
+)
+
+var wipeSignals = []introspect.Signal{
+	{
+		Name: "WipeFinished",
+		Args: []introspect.Arg{
+			{Name: "success", Type: "b"},
+			{Name: "error", Type: "s"},
+		},
+	},
+}
+
+// system2 implements io.hass.os.System2, all methods not overridden here
+// behave like on io.hass.os.System.
+type system2 struct {
+	system
+}
+
+// WipeDevice formats the data and overlay partitions in the background and
+// reports the result through the WipeFinished signal and a wipe job.
+func (d system2) WipeDevice(sender dbus.Sender) (bool, *dbus.Error) {
+	wipeLock.Lock()
+	defer wipeLock.Unlock()
+
+	if wipeRunning {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy, "Device wipe is already running").
+			WithRemediation(apierror.RemedyWaitForOperation))
+	}
+	wipeRunning = true
+
+	// Formatting can't be interrupted half way without leaving the device
+	// unusable, so the job isn't cancellable.
+	job := jobs.Start("wipe", false)
+	job.SetStage("format")
+
+	go func() {
+		err := d.wipeDevice()
+		job.Finish(err)
+
+		wipeLock.Lock()
+		wipeRunning = false
+		wipeLock.Unlock()
+
+		success := err == nil
+		message := ""
+		if !success {
+			logging.Error.Printf("Can't wipe device data: %s", err)
+			message = err.Error()
+		} else {
+			audit.Record(sender, "System.WipeDevice", "", "")
+		}
+
+		err = d.conn.Emit(objectPath, ifaceName2+".WipeFinished", success, message)
+		if err != nil {
+			logging.Warning.Printf("Can't emit WipeFinished signal: %s", err)
+		}
+	}()
+
+	return true, nil
+}
@@ -0,0 +1,162 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/udisks2"
+	"github.com/home-assistant/os-agent/udisks2/mocks"
+	"github.com/home-assistant/os-agent/utils/timing/timingtest"
+)
+
+// mountPointsObject returns a block object whose filesystem is mounted at
+// the given mount points.
+func mountPointsObject(mountPoints ...string) *mocks.MockBusObject {
+	body := [][]byte{}
+	for _, mountPoint := range mountPoints {
+		body = append(body, []byte(mountPoint+"\x00"))
+	}
+	return &mocks.MockBusObject{
+		CallWithContextFunc: func(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+			return &dbus.Call{Body: []interface{}{body}}
+		},
+	}
+}
+
+func mockUDisks2(t *testing.T, helper *mocks.MockHelper) {
+	original := newUDisks2
+	newUDisks2 = func(conn *dbus.Conn) udisks2.Helper { return helper }
+	t.Cleanup(func() { newUDisks2 = original })
+}
+
+func TestGetAndCheckBusObjectFromLabelMissingLabel(t *testing.T) {
+	helper := &mocks.MockHelper{
+		GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+			return nil, errors.New("Expected single block device with file system label")
+		},
+	}
+
+	if _, err := getAndCheckBusObjectFromLabel(helper, labelDataFileSystem); err == nil {
+		t.Fatal("expected an error for a missing label")
+	}
+}
+
+func TestGetAndCheckBusObjectFromLabelMounted(t *testing.T) {
+	helper := &mocks.MockHelper{
+		GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+			return mountPointsObject("/mnt/data"), nil
+		},
+	}
+
+	if _, err := getAndCheckBusObjectFromLabel(helper, labelDataFileSystem); err == nil {
+		t.Fatal("expected an error for a mounted filesystem")
+	}
+}
+
+func TestGetAndCheckBusObjectFromLabelUnmounted(t *testing.T) {
+	object := mountPointsObject()
+	helper := &mocks.MockHelper{
+		GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+			return object, nil
+		},
+	}
+
+	busObject, err := getAndCheckBusObjectFromLabel(helper, labelDataFileSystem)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if busObject != object {
+		t.Fatal("expected the resolved bus object")
+	}
+}
+
+func TestWipeDeviceFormatFailure(t *testing.T) {
+	var mutex sync.Mutex
+	formatted := map[string]bool{}
+	mockUDisks2(t, &mocks.MockHelper{
+		GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+			return mountPointsObject(), nil
+		},
+		FormatPartitionFunc: func(object dbus.BusObject, fsType string, label string) error {
+			mutex.Lock()
+			formatted[label] = true
+			mutex.Unlock()
+			if label == labelDataFileSystem {
+				return errors.New("Error formatting: Device or resource busy")
+			}
+			return nil
+		},
+	})
+
+	if err := (system{}).wipeDevice(); err == nil {
+		t.Fatal("expected the format error")
+	}
+	if !formatted[labelDataFileSystem] || !formatted[labelOverlayFileSystem] {
+		t.Fatalf("expected both partitions to be formatted, formatted %v", formatted)
+	}
+}
+
+func TestWipeDeviceFormatsConcurrently(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	mockUDisks2(t, &mocks.MockHelper{
+		GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+			return mountPointsObject(), nil
+		},
+		FormatPartitionFunc: func(object dbus.BusObject, fsType string, label string) error {
+			started <- label
+			<-release
+			return nil
+		},
+	})
+
+	done := make(chan error)
+	go func() { done <- (system{}).wipeDevice() }()
+
+	// Both formats have to start before either of them finishes
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("partitions are not formatted concurrently")
+		}
+	}
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Upper bound for the agent's own overhead of a wipe, UDisks2 is mocked.
+const wipeThreshold = 500 * time.Microsecond
+
+func BenchmarkWipeDevice(b *testing.B) {
+	original := newUDisks2
+	defer func() { newUDisks2 = original }()
+	newUDisks2 = func(conn *dbus.Conn) udisks2.Helper {
+		return &mocks.MockHelper{
+			GetBusObjectFromLabelFunc: func(label string) (dbus.BusObject, error) {
+				return mountPointsObject(), nil
+			},
+			FormatPartitionFunc: func(object dbus.BusObject, fsType string, label string) error {
+				return nil
+			},
+		}
+	}
+
+	for i := 0; i < b.N; i++ {
+		if err := (system{}).wipeDevice(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWipeDeviceBudget(t *testing.T) {
+	timingtest.CheckBudget(t, BenchmarkWipeDevice, wipeThreshold)
+}
@@ -0,0 +1,154 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+	"github.com/home-assistant/os-agent/utils/usage"
+)
+
+const (
+	objectPath      = "/io/hass/os/Telemetry"
+	ifaceName       = "io.hass.os.Telemetry"
+	telemetryConfig = "/etc/os-agent/telemetry.json"
+)
+
+var (
+	telemetryMutex sync.Mutex
+	countersOptIn  bool
+)
+
+type telemetry struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func loadCountersOptIn() bool {
+	config := struct {
+		UsageCounters bool `json:"usage_counters"`
+	}{}
+
+	data, err := ioutil.ReadFile(telemetryConfig)
+	if err != nil {
+		return false
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		logging.Error.Printf("Ignoring invalid telemetry config in %s", telemetryConfig)
+		return false
+	}
+	return config.UsageCounters
+}
+
+func saveCountersOptIn(enabled bool) error {
+	data, err := json.Marshal(map[string]bool{"usage_counters": enabled})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(telemetryConfig), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(telemetryConfig, data, 0644)
+}
+
+func setUsageCountersEnabled(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set usage counters to %t", c.Value)
+
+	telemetryMutex.Lock()
+	defer telemetryMutex.Unlock()
+
+	if err := saveCountersOptIn(c.Value.(bool)); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't persist usage counters setting: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "Telemetry.UsageCountersEnabled", countersOptIn, c.Value)
+	countersOptIn = c.Value.(bool)
+	usage.SetEnabled(countersOptIn)
+	return nil
+}
+
+// GetUsageCounters returns how often each method was called and how often
+// it failed since the agent started, keyed by interface and method name.
+// The Supervisor submits them with the OS analytics if the user opted in
+// there too.
+func (d telemetry) GetUsageCounters() (map[string]map[string]uint64, *dbus.Error) {
+	return usage.Counters(), nil
+}
+
+func (d telemetry) ResetUsageCounters(sender dbus.Sender) (bool, *dbus.Error) {
+	audit.Record(sender, "Telemetry.ResetUsageCounters", "", "")
+	usage.Reset()
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"GetUsageCounters":   {"counters"},
+	"ResetUsageCounters": {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := telemetry{
+		conn: conn,
+	}
+
+	// Init base value
+	countersOptIn = loadCountersOptIn()
+	usage.SetEnabled(countersOptIn)
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"UsageCountersEnabled": {
+				Value:    countersOptIn,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: setUsageCountersEnabled,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
@@ -0,0 +1,50 @@
+package timedate
+
+import (
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	clockJumpInterval  = 5 * time.Second
+	clockJumpThreshold = 2 * time.Second
+)
+
+var clockSignals = []introspect.Signal{
+	{
+		Name: "ClockJumped",
+		Args: []introspect.Arg{
+			{Name: "delta_ms", Type: "x"},
+			{Name: "timestamp", Type: "x"},
+		},
+	},
+}
+
+// watchClockJumps compares wall clock and monotonic clock progress and
+// emits a signal whenever the wall clock was stepped.
+func watchClockJumps(conn *dbus.Conn) {
+	last := time.Now()
+	for {
+		time.Sleep(clockJumpInterval)
+		now := time.Now()
+
+		// Round(0) strips the monotonic reading, leaving the wall clock
+		wall := now.Round(0).Sub(last.Round(0))
+		delta := wall - now.Sub(last)
+		last = now
+
+		if delta < clockJumpThreshold && delta > -clockJumpThreshold {
+			continue
+		}
+
+		logging.Info.Printf("System clock jumped by %s", delta)
+		err := conn.Emit(objectPath, ifaceName+".ClockJumped", delta.Milliseconds(), now.Unix())
+		if err != nil {
+			logging.Warning.Printf("Can't emit ClockJumped signal: %s", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package timedate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	timesyncdBusName    = "org.freedesktop.timesync1"
+	timesyncdObjectPath = "/org/freedesktop/timesync1"
+	timesyncdIfaceName  = "org.freedesktop.timesync1.Manager"
+	timesyncdUnit       = "systemd-timesyncd.service"
+	timesyncdDropIn     = "/etc/systemd/timesyncd.conf.d/os-agent.conf"
+	systemdBusName      = "org.freedesktop.systemd1"
+	systemdObjectPath   = "/org/freedesktop/systemd1"
+	systemdIfaceName    = "org.freedesktop.systemd1.Manager"
+)
+
+func getTimedatedBool(conn *dbus.Conn, name string) bool {
+	obj := conn.Object(timedatedBusName, timedatedObjectPath)
+	value, err := obj.GetProperty(timedatedIfaceName + "." + name)
+	if err != nil {
+		logging.Warning.Printf("Can't read %s from timedated: %s", name, err)
+		return false
+	}
+
+	result, _ := value.Value().(bool)
+	return result
+}
+
+func getNTPServers(conn *dbus.Conn) []string {
+	obj := conn.Object(timesyncdBusName, timesyncdObjectPath)
+	value, err := obj.GetProperty(timesyncdIfaceName + ".SystemNTPServers")
+	if err != nil {
+		logging.Warning.Printf("Can't read NTP servers from timesyncd: %s", err)
+		return []string{}
+	}
+
+	servers, ok := value.Value().([]string)
+	if !ok {
+		return []string{}
+	}
+	return servers
+}
+
+func (d timedate) setNTPEnabled(c *prop.Change) *dbus.Error {
+	logging.Info.Printf("Set NTP to %t", c.Value)
+
+	obj := d.conn.Object(timedatedBusName, timedatedObjectPath)
+	err := obj.Call(timedatedIfaceName+".SetNTP", 0, c.Value.(bool), false).Err
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't set NTP: %s", err))
+	}
+
+	audit.Record(recovery.Sender(c), "Time.NTPEnabled", !c.Value.(bool), c.Value)
+	return nil
+}
+
+func (d timedate) SetNTPServers(sender dbus.Sender, servers []string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set NTP servers to %s.", servers)
+
+	for _, server := range servers {
+		if server == "" || strings.ContainsAny(server, " \t\n\r") {
+			return false, dbus.MakeFailedError(fmt.Errorf("Invalid NTP server '%s'", server))
+		}
+	}
+
+	var err error
+	if len(servers) == 0 {
+		// Go back to the OS defaults
+		err = managedfiles.Remove(timesyncdDropIn)
+	} else {
+		config := fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))
+		err = managedfiles.Write(timesyncdDropIn, []byte(config), 0644, "timedate")
+	}
+	if err != nil {
+		logging.Error.Printf("Failed to write timesyncd configuration %s: %s", timesyncdDropIn, err)
+		return false, dbus.MakeFailedError(err)
+	}
+
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	err = obj.Call(systemdIfaceName+".TryRestartUnit", 0, timesyncdUnit, "replace").Err
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't restart %s: %s", timesyncdUnit, err))
+	}
+
+	audit.Record(sender, "Time.SetNTPServers", d.props.GetMust(ifaceName, "NTPServers"), servers)
+	d.props.SetMust(ifaceName, "NTPServers", servers)
+	return true, nil
+}
+
+func (d timedate) ReloadNTPStatus() (bool, *dbus.Error) {
+	d.props.SetMust(ifaceName, "NTPSynchronized", getTimedatedBool(d.conn, "NTPSynchronized"))
+	d.props.SetMust(ifaceName, "NTPServers", getNTPServers(d.conn))
+	return true, nil
+}
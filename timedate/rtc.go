@@ -0,0 +1,68 @@
+package timedate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	rtcSysfsDirectory = "/sys/class/rtc/rtc0"
+	hwclockCmd        = "hwclock"
+)
+
+func getRTCPresent() bool {
+	_, err := os.Stat(rtcSysfsDirectory)
+	return err == nil
+}
+
+func readRTC() (int64, error) {
+	data, err := ioutil.ReadFile(rtcSysfsDirectory + "/since_epoch")
+	if err != nil {
+		return 0, fmt.Errorf("Can't read hardware clock: %s", err)
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (d timedate) ReadRTC() (int64, *dbus.Error) {
+	rtcTime, err := readRTC()
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	return rtcTime, nil
+}
+
+func (d timedate) GetRTCDrift() (int64, *dbus.Error) {
+	rtcTime, err := readRTC()
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	return rtcTime - time.Now().Unix(), nil
+}
+
+func (d timedate) WriteRTC(sender dbus.Sender) (bool, *dbus.Error) {
+	logging.Info.Printf("Write system time to hardware clock.")
+
+	if !getRTCPresent() {
+		return false, dbus.MakeFailedError(fmt.Errorf("No hardware clock present"))
+	}
+
+	cmd := exec.Command(hwclockCmd, "--systohc", "--utc")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't write hardware clock: %s, output %s", err, out))
+	}
+
+	audit.Record(sender, "Time.WriteRTC", "", "")
+	return true, nil
+}
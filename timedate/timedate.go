@@ -0,0 +1,177 @@
+package timedate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath          = "/io/hass/os/Time"
+	ifaceName           = "io.hass.os.Time"
+	timedatedBusName    = "org.freedesktop.timedate1"
+	timedatedObjectPath = "/org/freedesktop/timedate1"
+	timedatedIfaceName  = "org.freedesktop.timedate1"
+	zoneInfoDirectory   = "/usr/share/zoneinfo"
+	localTimeFile       = "/etc/localtime"
+)
+
+type timedate struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func getTimezone(conn *dbus.Conn) string {
+	obj := conn.Object(timedatedBusName, timedatedObjectPath)
+	value, err := obj.GetProperty(timedatedIfaceName + ".Timezone")
+	if err == nil {
+		if tz, ok := value.Value().(string); ok {
+			return tz
+		}
+	}
+
+	// Fall back to the /etc/localtime symlink
+	target, err := os.Readlink(localTimeFile)
+	if err != nil {
+		logging.Warning.Printf("Can't read timezone: %s", err)
+		return ""
+	}
+	return strings.TrimPrefix(target, zoneInfoDirectory+"/")
+}
+
+func validateTimezone(tz string) error {
+	if tz == "" || strings.HasPrefix(tz, "/") || strings.Contains(tz, "..") {
+		return fmt.Errorf("Invalid timezone '%s'", tz)
+	}
+
+	info, err := os.Stat(filepath.Join(zoneInfoDirectory, tz))
+	if err != nil || info.IsDir() {
+		return fmt.Errorf("Timezone '%s' not found in zoneinfo database", tz)
+	}
+	return nil
+}
+
+func (d timedate) SetTimezone(sender dbus.Sender, tz string) (bool, *dbus.Error) {
+	logging.Info.Printf("Set timezone to %s.", tz)
+
+	err := validateTimezone(tz)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	obj := d.conn.Object(timedatedBusName, timedatedObjectPath)
+	err = obj.Call(timedatedIfaceName+".SetTimezone", 0, tz, false).Err
+	if err != nil {
+		logging.Warning.Printf("Can't set timezone via timedated, updating %s: %s", localTimeFile, err)
+
+		tmpLink := localTimeFile + ".tmp"
+		os.Remove(tmpLink)
+		err = os.Symlink(filepath.Join(zoneInfoDirectory, tz), tmpLink)
+		if err == nil {
+			err = os.Rename(tmpLink, localTimeFile)
+		}
+		if err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't set timezone '%s': %s", tz, err))
+		}
+	}
+
+	audit.Record(sender, "Time.SetTimezone", d.props.GetMust(ifaceName, "Timezone"), tz)
+	d.props.SetMust(ifaceName, "Timezone", tz)
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"SetTimezone":     {"timezone", "success"},
+	"SetNTPServers":   {"servers", "success"},
+	"ReloadNTPStatus": {"success"},
+	"ReadRTC":         {"timestamp"},
+	"GetRTCDrift":     {"drift_ms"},
+	"WriteRTC":        {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := timedate{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Timezone": {
+				Value:    getTimezone(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"NTPEnabled": {
+				Value:    getTimedatedBool(conn, "NTP"),
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: d.setNTPEnabled,
+			},
+			"NTPSynchronized": {
+				Value:    getTimedatedBool(conn, "NTPSynchronized"),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"NTPServers": {
+				Value:    getNTPServers(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"RTCPresent": {
+				Value:    getRTCPresent(),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Signals:    clockSignals,
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+
+	go watchClockJumps(conn)
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/utils/timing"
 )
 
 var noOptions = map[string]dbus.Variant{}
@@ -37,6 +39,8 @@ func (f *Filesystem) GetMountPointsString(ctx context.Context) ([]string, error)
 }
 
 func (m *Manager) ResolveDeviceFromLabel(label string) (*dbus.ObjectPath, error) {
+	defer timing.Track("Resolving label "+label, timing.LabelResolveBudget)()
+
 	devspec := map[string]dbus.Variant{"label": dbus.MakeVariant(label)}
 	blockObjects, err := m.ResolveDevice(context.Background(), devspec, noOptions)
 
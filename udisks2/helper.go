@@ -7,6 +7,7 @@ import (
 	"github.com/godbus/dbus/v5"
 
 	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/timing"
 )
 
 type UDisks2Helper struct {
@@ -57,7 +58,28 @@ func (u UDisks2Helper) GetRootDeviceFromLabel(label string) (*string, error) {
 	return parentBlock.GetDeviceString(context.Background())
 }
 
+func (u UDisks2Helper) GetDriveFromLabel(label string) (dbus.BusObject, error) {
+
+	busObject, err := u.manager.ResolveDeviceFromLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	block := NewBlock(u.conn.Object("org.freedesktop.UDisks2", *busObject))
+	drive, err := block.GetDrive(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if drive == "/" {
+		return nil, fmt.Errorf("No drive found for label \"%s\"", label)
+	}
+
+	return u.conn.Object("org.freedesktop.UDisks2", drive), nil
+}
+
 func (u UDisks2Helper) FormatPartition(blockObjectPath dbus.BusObject, fsType string, label string) error {
+	defer timing.Track("Formatting "+label, timing.FormatBudget)()
+
 	parentBlock := NewBlock(blockObjectPath)
 	formatOptions := map[string]dbus.Variant{"label": dbus.MakeVariant(label)}
 	err := parentBlock.Format(context.Background(), fsType, formatOptions)
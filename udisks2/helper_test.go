@@ -0,0 +1,63 @@
+package udisks2_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/udisks2"
+	"github.com/home-assistant/os-agent/udisks2/mocks"
+	"github.com/home-assistant/os-agent/utils/timing/timingtest"
+)
+
+// Upper bounds for the agent's own overhead per operation, UDisks2 itself is
+// mocked. A regression beyond them means extra round trips or retries.
+const (
+	labelResolveThreshold = 100 * time.Microsecond
+	formatThreshold       = 100 * time.Microsecond
+)
+
+func resolvingManager() *udisks2.Manager {
+	return udisks2.NewManager(&mocks.MockBusObject{
+		CallWithContextFunc: func(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+			return &dbus.Call{Body: []interface{}{[]dbus.ObjectPath{"/org/freedesktop/UDisks2/block_devices/sda8"}}}
+		},
+	})
+}
+
+func formattingBlock() *udisks2.Block {
+	return udisks2.NewBlock(&mocks.MockBusObject{
+		CallWithContextFunc: func(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+			return &dbus.Call{Body: []interface{}{}}
+		},
+	})
+}
+
+func BenchmarkResolveDeviceFromLabel(b *testing.B) {
+	manager := resolvingManager()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.ResolveDeviceFromLabel("hassos-data"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	block := formattingBlock()
+	options := map[string]dbus.Variant{"label": dbus.MakeVariant("hassos-data")}
+	for i := 0; i < b.N; i++ {
+		if err := block.Format(context.Background(), "ext4", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestResolveDeviceFromLabelBudget(t *testing.T) {
+	timingtest.CheckBudget(t, BenchmarkResolveDeviceFromLabel, labelResolveThreshold)
+}
+
+func TestFormatBudget(t *testing.T) {
+	timingtest.CheckBudget(t, BenchmarkFormat, formatThreshold)
+}
@@ -0,0 +1,42 @@
+package udisks2
+
+//go:generate go run ../cmd/mock-gen -source interfaces.go -destination mocks/mocks.go
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Helper is the part of UDisks2Helper the agent modules use. Modules depend
+// on it instead of the concrete helper, so tests can substitute a mock.
+type Helper interface {
+	GetBusObjectFromLabel(label string) (dbus.BusObject, error)
+	GetRootDeviceFromLabel(label string) (*string, error)
+	GetDriveFromLabel(label string) (dbus.BusObject, error)
+	FormatPartition(blockObjectPath dbus.BusObject, fsType string, label string) error
+	FormatPartitionFromDevicePath(devicePath string, fsType string, label string) error
+	PartitionDeviceWithSinglePartition(devicePath string, uuid string, name string) error
+}
+
+// BusObject has the method set of dbus.BusObject, declared here so a mock
+// can be generated for it. Mocks of it can be passed to the generated
+// bindings, e.g. NewFilesystem.
+type BusObject interface {
+	Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+	CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+	Go(method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call
+	GoWithContext(ctx context.Context, method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call
+	AddMatchSignal(iface, member string, options ...dbus.MatchOption) *dbus.Call
+	RemoveMatchSignal(iface, member string, options ...dbus.MatchOption) *dbus.Call
+	GetProperty(p string) (dbus.Variant, error)
+	StoreProperty(p string, value interface{}) error
+	SetProperty(p string, v interface{}) error
+	Destination() string
+	Path() dbus.ObjectPath
+}
+
+var (
+	_ Helper         = UDisks2Helper{}
+	_ dbus.BusObject = BusObject(nil)
+)
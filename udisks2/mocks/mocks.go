@@ -0,0 +1,153 @@
+// Code generated by mock-gen from interfaces.go. DO NOT EDIT.
+
+package mocks
+
+import (
+	"context"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// MockHelper is a mock of udisks2.Helper.
+type MockHelper struct {
+	GetBusObjectFromLabelFunc              func(label string) (dbus.BusObject, error)
+	GetRootDeviceFromLabelFunc             func(label string) (*string, error)
+	GetDriveFromLabelFunc                  func(label string) (dbus.BusObject, error)
+	FormatPartitionFunc                    func(blockObjectPath dbus.BusObject, fsType string, label string) error
+	FormatPartitionFromDevicePathFunc      func(devicePath string, fsType string, label string) error
+	PartitionDeviceWithSinglePartitionFunc func(devicePath string, uuid string, name string) error
+}
+
+func (m *MockHelper) GetBusObjectFromLabel(label string) (dbus.BusObject, error) {
+	if m.GetBusObjectFromLabelFunc == nil {
+		panic("MockHelper.GetBusObjectFromLabel called but not set")
+	}
+	return m.GetBusObjectFromLabelFunc(label)
+}
+
+func (m *MockHelper) GetRootDeviceFromLabel(label string) (*string, error) {
+	if m.GetRootDeviceFromLabelFunc == nil {
+		panic("MockHelper.GetRootDeviceFromLabel called but not set")
+	}
+	return m.GetRootDeviceFromLabelFunc(label)
+}
+
+func (m *MockHelper) GetDriveFromLabel(label string) (dbus.BusObject, error) {
+	if m.GetDriveFromLabelFunc == nil {
+		panic("MockHelper.GetDriveFromLabel called but not set")
+	}
+	return m.GetDriveFromLabelFunc(label)
+}
+
+func (m *MockHelper) FormatPartition(blockObjectPath dbus.BusObject, fsType string, label string) error {
+	if m.FormatPartitionFunc == nil {
+		panic("MockHelper.FormatPartition called but not set")
+	}
+	return m.FormatPartitionFunc(blockObjectPath, fsType, label)
+}
+
+func (m *MockHelper) FormatPartitionFromDevicePath(devicePath string, fsType string, label string) error {
+	if m.FormatPartitionFromDevicePathFunc == nil {
+		panic("MockHelper.FormatPartitionFromDevicePath called but not set")
+	}
+	return m.FormatPartitionFromDevicePathFunc(devicePath, fsType, label)
+}
+
+func (m *MockHelper) PartitionDeviceWithSinglePartition(devicePath string, uuid string, name string) error {
+	if m.PartitionDeviceWithSinglePartitionFunc == nil {
+		panic("MockHelper.PartitionDeviceWithSinglePartition called but not set")
+	}
+	return m.PartitionDeviceWithSinglePartitionFunc(devicePath, uuid, name)
+}
+
+// MockBusObject is a mock of udisks2.BusObject.
+type MockBusObject struct {
+	CallFunc              func(method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+	CallWithContextFunc   func(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+	GoFunc                func(method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call
+	GoWithContextFunc     func(ctx context.Context, method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call
+	AddMatchSignalFunc    func(iface string, member string, options ...dbus.MatchOption) *dbus.Call
+	RemoveMatchSignalFunc func(iface string, member string, options ...dbus.MatchOption) *dbus.Call
+	GetPropertyFunc       func(p string) (dbus.Variant, error)
+	StorePropertyFunc     func(p string, value interface{}) error
+	SetPropertyFunc       func(p string, v interface{}) error
+	DestinationFunc       func() string
+	PathFunc              func() dbus.ObjectPath
+}
+
+func (m *MockBusObject) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	if m.CallFunc == nil {
+		panic("MockBusObject.Call called but not set")
+	}
+	return m.CallFunc(method, flags, args...)
+}
+
+func (m *MockBusObject) CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	if m.CallWithContextFunc == nil {
+		panic("MockBusObject.CallWithContext called but not set")
+	}
+	return m.CallWithContextFunc(ctx, method, flags, args...)
+}
+
+func (m *MockBusObject) Go(method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call {
+	if m.GoFunc == nil {
+		panic("MockBusObject.Go called but not set")
+	}
+	return m.GoFunc(method, flags, ch, args...)
+}
+
+func (m *MockBusObject) GoWithContext(ctx context.Context, method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call {
+	if m.GoWithContextFunc == nil {
+		panic("MockBusObject.GoWithContext called but not set")
+	}
+	return m.GoWithContextFunc(ctx, method, flags, ch, args...)
+}
+
+func (m *MockBusObject) AddMatchSignal(iface string, member string, options ...dbus.MatchOption) *dbus.Call {
+	if m.AddMatchSignalFunc == nil {
+		panic("MockBusObject.AddMatchSignal called but not set")
+	}
+	return m.AddMatchSignalFunc(iface, member, options...)
+}
+
+func (m *MockBusObject) RemoveMatchSignal(iface string, member string, options ...dbus.MatchOption) *dbus.Call {
+	if m.RemoveMatchSignalFunc == nil {
+		panic("MockBusObject.RemoveMatchSignal called but not set")
+	}
+	return m.RemoveMatchSignalFunc(iface, member, options...)
+}
+
+func (m *MockBusObject) GetProperty(p string) (dbus.Variant, error) {
+	if m.GetPropertyFunc == nil {
+		panic("MockBusObject.GetProperty called but not set")
+	}
+	return m.GetPropertyFunc(p)
+}
+
+func (m *MockBusObject) StoreProperty(p string, value interface{}) error {
+	if m.StorePropertyFunc == nil {
+		panic("MockBusObject.StoreProperty called but not set")
+	}
+	return m.StorePropertyFunc(p, value)
+}
+
+func (m *MockBusObject) SetProperty(p string, v interface{}) error {
+	if m.SetPropertyFunc == nil {
+		panic("MockBusObject.SetProperty called but not set")
+	}
+	return m.SetPropertyFunc(p, v)
+}
+
+func (m *MockBusObject) Destination() string {
+	if m.DestinationFunc == nil {
+		panic("MockBusObject.Destination called but not set")
+	}
+	return m.DestinationFunc()
+}
+
+func (m *MockBusObject) Path() dbus.ObjectPath {
+	if m.PathFunc == nil {
+		panic("MockBusObject.Path called but not set")
+	}
+	return m.PathFunc()
+}
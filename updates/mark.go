@@ -0,0 +1,64 @@
+package updates
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	actionManageSlots = "io.hass.os.manage-slots"
+)
+
+func (d updates) markSlot(state string, slot string) (string, error) {
+	var result struct {
+		SlotName string
+		Message  string
+	}
+
+	obj := d.conn.Object(raucBusName, raucObjectPath)
+	err := obj.Call(raucIfaceName+".Mark", 0, state, slot).Store(&result)
+	if err != nil {
+		return "", fmt.Errorf("Can't mark slot '%s' %s: %s", slot, state, err)
+	}
+
+	logging.Info.Printf("RAUC: %s", result.Message)
+	return result.SlotName, nil
+}
+
+func (d updates) MarkSlotGood(sender dbus.Sender) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionManageSlots); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Mark booted slot as good.")
+	slotName, err := d.markSlot("good", "booted")
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	audit.Record(sender, "Updates.MarkSlotGood", "", slotName)
+	d.ReloadSlotStatus()
+	return true, nil
+}
+
+// MarkSlotBad accepts "booted", "other" or a RAUC slot name.
+func (d updates) MarkSlotBad(sender dbus.Sender, slot string) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionManageSlots); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Mark slot '%s' as bad.", slot)
+	slotName, err := d.markSlot("bad", slot)
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	audit.Record(sender, "Updates.MarkSlotBad", "", slotName)
+	d.ReloadSlotStatus()
+	return true, nil
+}
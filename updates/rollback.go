@@ -0,0 +1,82 @@
+package updates
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/polkit"
+)
+
+const (
+	logindBusName    = "org.freedesktop.login1"
+	logindObjectPath = "/org/freedesktop/login1"
+	logindIfaceName  = "org.freedesktop.login1.Manager"
+	rollbackDelay    = 5 * time.Second
+)
+
+// getScheduledShutdown returns the type of the shutdown scheduled with
+// logind, empty if none.
+func getScheduledShutdown(conn *dbus.Conn) (string, error) {
+	var scheduled struct {
+		Type string
+		Usec uint64
+	}
+
+	obj := conn.Object(logindBusName, logindObjectPath)
+	if err := obj.StoreProperty(logindIfaceName+".ScheduledShutdown", &scheduled); err != nil {
+		return "", err
+	}
+	if scheduled.Usec == 0 {
+		return "", nil
+	}
+	return scheduled.Type, nil
+}
+
+// RollbackOSSlot switches back to the other OS slot: it becomes primary, the
+// booted slot is marked bad and a reboot is scheduled shortly after so the
+// caller still gets a reply. logind keeps a single schedule, so the rollback
+// is refused while another shutdown is scheduled.
+func (d updates) RollbackOSSlot(sender dbus.Sender) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionManageSlots); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Roll back to the previous OS slot.")
+
+	scheduled, err := getScheduledShutdown(d.conn)
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't read scheduled shutdown from logind: %s", err))
+	}
+	if scheduled != "" {
+		return false, apierror.Failed(apierror.New(apierror.CodeBusy,
+			"A %s is already scheduled, cancel it before rolling back", scheduled))
+	}
+
+	// Activate the other slot first, so a failure leaves the booted slot usable.
+	otherSlot, err := d.markSlot("active", "other")
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+
+	bootedSlot, err := d.markSlot("bad", "booted")
+	if err != nil {
+		return false, dbus.MakeFailedError(err)
+	}
+	d.ReloadSlotStatus()
+
+	when := time.Now().Add(rollbackDelay)
+	usec := uint64(when.UnixNano() / int64(time.Microsecond))
+	obj := d.conn.Object(logindBusName, logindObjectPath)
+	err = obj.Call(logindIfaceName+".ScheduleShutdown", 0, "reboot", usec).Err
+	if err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't schedule reboot: %s", err))
+	}
+
+	audit.Record(sender, "Updates.RollbackOSSlot", bootedSlot, otherSlot)
+	return true, nil
+}
@@ -0,0 +1,202 @@
+package updates
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/bootenv"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath      = "/io/hass/os/Updates"
+	ifaceName       = "io.hass.os.Updates"
+	raucBusName     = "de.pengutronix.rauc"
+	raucObjectPath  = "/"
+	raucIfaceName   = "de.pengutronix.rauc.Installer"
+	raucBootedSlot  = "BootSlot"
+	raucCompatible  = "Compatible"
+	bootSlotA       = "A"
+	bootSlotB       = "B"
+	uBootAttemptVar = "BOOT_%s_LEFT"
+	grubAttemptVar  = "%s_TRY"
+)
+
+type updates struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+type slotStatus struct {
+	Name   string
+	Status map[string]dbus.Variant
+}
+
+func getRaucString(conn *dbus.Conn, name string) string {
+	obj := conn.Object(raucBusName, raucObjectPath)
+	value, err := obj.GetProperty(raucIfaceName + "." + name)
+	if err != nil {
+		logging.Warning.Printf("Can't read %s from RAUC: %s", name, err)
+		return ""
+	}
+
+	result, _ := value.Value().(string)
+	return result
+}
+
+func getPrimarySlot(conn *dbus.Conn) string {
+	var primary string
+
+	obj := conn.Object(raucBusName, raucObjectPath)
+	err := obj.Call(raucIfaceName+".GetPrimary", 0).Store(&primary)
+	if err != nil {
+		logging.Warning.Printf("Can't read primary slot from RAUC: %s", err)
+		return ""
+	}
+	return primary
+}
+
+// getSlots flattens the RAUC slot status into string values.
+func getSlots(conn *dbus.Conn) map[string]map[string]string {
+	var slots []slotStatus
+	result := map[string]map[string]string{}
+
+	obj := conn.Object(raucBusName, raucObjectPath)
+	err := obj.Call(raucIfaceName+".GetSlotStatus", 0).Store(&slots)
+	if err != nil {
+		logging.Warning.Printf("Can't read slot status from RAUC: %s", err)
+		return result
+	}
+
+	for _, slot := range slots {
+		status := map[string]string{}
+		for key, value := range slot.Status {
+			status[key] = fmt.Sprint(value.Value())
+		}
+		result[slot.Name] = status
+	}
+	return result
+}
+
+// getBootAttempts reads the remaining boot attempts per boot slot from the
+// bootloader environment.
+func getBootAttempts() map[string]int32 {
+	result := map[string]int32{}
+
+	env, err := bootenv.List()
+	if err != nil {
+		logging.Warning.Printf("Can't read bootloader environment: %s", err)
+		return result
+	}
+
+	pattern := uBootAttemptVar
+	if bootenv.Detect() == bootenv.GRUB {
+		pattern = grubAttemptVar
+	}
+
+	for _, slot := range []string{bootSlotA, bootSlotB} {
+		value, ok := env[fmt.Sprintf(pattern, slot)]
+		if !ok {
+			continue
+		}
+		if attempts, err := strconv.Atoi(value); err == nil {
+			result[slot] = int32(attempts)
+		}
+	}
+	return result
+}
+
+func (d updates) ReloadSlotStatus() (bool, *dbus.Error) {
+	d.props.SetMust(ifaceName, "BootSlot", getRaucString(d.conn, raucBootedSlot))
+	d.props.SetMust(ifaceName, "PrimarySlot", getPrimarySlot(d.conn))
+	d.props.SetMust(ifaceName, "Slots", getSlots(d.conn))
+	d.props.SetMust(ifaceName, "BootAttempts", getBootAttempts())
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"ReloadSlotStatus": {"success"},
+	"MarkSlotGood":     {"success"},
+	"MarkSlotBad":      {"slot", "success"},
+	"RollbackOSSlot":   {"success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := updates{
+		conn: conn,
+	}
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"BootSlot": {
+				Value:    getRaucString(conn, raucBootedSlot),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"PrimarySlot": {
+				Value:    getPrimarySlot(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Compatible": {
+				Value:    getRaucString(conn, raucCompatible),
+				Writable: false,
+				Emit:     prop.EmitInvalidates,
+				Callback: nil,
+			},
+			"Slots": {
+				Value:    getSlots(conn),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"BootAttempts": {
+				Value:    getBootAttempts(),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}
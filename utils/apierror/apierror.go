@@ -0,0 +1,120 @@
+// Package apierror attaches machine readable details to errors returned over
+// D-Bus, so clients can show localized and actionable messages instead of
+// the English message of the agent.
+//
+// The D-Bus error body holds the message first, like dbus.MakeFailedError,
+// followed by a dictionary (a{ss}) with the keys code, device and
+// remediation. Clients only reading the message keep working.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const failedErrorName = "org.freedesktop.DBus.Error.Failed"
+
+// Error codes
+const (
+	CodeFailed          = "failed"
+	CodeInvalidArgument = "invalid_argument"
+	CodeNotFound        = "not_found"
+	CodeBusy            = "busy"
+	CodeNotAuthorized   = "not_authorized"
+	CodeUnsupported     = "unsupported"
+	CodeDeviceError     = "device_error"
+	CodeInternal        = "internal"
+)
+
+// Remediation keys, suggestions the frontend can map to a localized text
+const (
+	RemedyChooseOtherDevice = "choose_other_device"
+	RemedyCheckDevice       = "check_device_connection"
+	RemedyWaitForOperation  = "wait_for_operation"
+	RemedyUseLocalStorage   = "use_local_storage"
+	RemedyCheckPermissions  = "check_permissions"
+	RemedyReportIssue       = "report_issue"
+)
+
+// Error is an error with details for clients.
+type Error struct {
+	Code        string
+	Device      string
+	Remediation string
+	Err         error
+}
+
+// New returns an error with the given code.
+func New(code string, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap adds a code to an existing error.
+func Wrap(code string, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDevice records the device the error is about.
+func (e *Error) WithDevice(device string) *Error {
+	e.Device = device
+	return e
+}
+
+// WithRemediation records a remediation key.
+func (e *Error) WithRemediation(key string) *Error {
+	e.Remediation = key
+	return e
+}
+
+// Details returns the details as carried in the D-Bus error body.
+func (e *Error) Details() map[string]string {
+	details := map[string]string{"code": e.Code}
+	if e.Device != "" {
+		details["device"] = e.Device
+	}
+	if e.Remediation != "" {
+		details["remediation"] = e.Remediation
+	}
+	return details
+}
+
+// DBus returns e as D-Bus error with the given name.
+func (e *Error) DBus(name string) *dbus.Error {
+	return dbus.NewError(name, []interface{}{e.Error(), e.Details()})
+}
+
+// Failed converts err like dbus.MakeFailedError. If err is or wraps an
+// Error its details are added, other errors get the generic failed code.
+func Failed(err error) *dbus.Error {
+	var detailed *Error
+	if !errors.As(err, &detailed) {
+		detailed = Wrap(CodeFailed, err)
+	}
+	return dbus.NewError(failedErrorName, []interface{}{err.Error(), detailed.Details()})
+}
+
+// Details returns the details of a D-Bus error, or nil if it has none.
+func Details(err error) map[string]string {
+	var body []interface{}
+	switch dbusErr := err.(type) {
+	case dbus.Error:
+		body = dbusErr.Body
+	case *dbus.Error:
+		body = dbusErr.Body
+	}
+	if len(body) < 2 {
+		return nil
+	}
+	details, _ := body[1].(map[string]string)
+	return details
+}
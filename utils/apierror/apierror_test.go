@@ -0,0 +1,23 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFailedCarriesDetails(t *testing.T) {
+	err := New(CodeBusy, "Clone already running").WithDevice("/dev/sda").WithRemediation(RemedyWaitForOperation)
+
+	details := Details(Failed(fmt.Errorf("Can't clone: %w", err)))
+	if details["code"] != CodeBusy || details["device"] != "/dev/sda" || details["remediation"] != RemedyWaitForOperation {
+		t.Errorf("unexpected details %v", details)
+	}
+}
+
+func TestFailedPlainError(t *testing.T) {
+	details := Details(Failed(errors.New("plain")))
+	if len(details) != 1 || details["code"] != CodeFailed {
+		t.Errorf("expected only the failed code, got %v", details)
+	}
+}
@@ -0,0 +1,126 @@
+package bootenv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	fwPrintEnvCmd  = "fw_printenv"
+	fwSetEnvCmd    = "fw_setenv"
+	grubEditEnvCmd = "grub-editenv"
+	grubEnvFile    = "/mnt/boot/EFI/BOOT/grubenv"
+	fwEnvConfig    = "/etc/fw_env.config"
+)
+
+// Bootloader types with an environment we can access.
+const (
+	Unknown = "unknown"
+	UBoot   = "u-boot"
+	GRUB    = "grub"
+)
+
+var ErrNoBootloaderEnv = errors.New("No supported bootloader environment found")
+
+// Detect returns the bootloader whose environment is available on this system.
+func Detect() string {
+	if _, err := os.Stat(grubEnvFile); err == nil {
+		return GRUB
+	}
+	if _, err := os.Stat(fwEnvConfig); err == nil {
+		return UBoot
+	}
+	return Unknown
+}
+
+func parseEnv(data []byte) map[string]string {
+	env := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// List returns all variables of the bootloader environment.
+func List() (map[string]string, error) {
+	var cmd *exec.Cmd
+	switch Detect() {
+	case GRUB:
+		cmd = exec.Command(grubEditEnvCmd, grubEnvFile, "list")
+	case UBoot:
+		cmd = exec.Command(fwPrintEnvCmd)
+	default:
+		return nil, ErrNoBootloaderEnv
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseEnv(out), nil
+}
+
+// Get returns a single variable, or defaultValue if it is not set.
+func Get(name string, defaultValue string) (string, error) {
+	env, err := List()
+	if err != nil {
+		return defaultValue, err
+	}
+
+	if value, ok := env[name]; ok {
+		return value, nil
+	}
+	return defaultValue, nil
+}
+
+// Set writes a single variable to the bootloader environment.
+func Set(name string, value string) error {
+	var cmd *exec.Cmd
+	switch Detect() {
+	case GRUB:
+		cmd = exec.Command(grubEditEnvCmd, grubEnvFile, "set", name+"="+value)
+	case UBoot:
+		cmd = exec.Command(fwSetEnvCmd, name, value)
+	default:
+		return ErrNoBootloaderEnv
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Can't set bootloader variable %s: %s, output %s", name, err, out)
+	}
+	return nil
+}
+
+// Unset removes a variable from the bootloader environment.
+func Unset(name string) error {
+	var cmd *exec.Cmd
+	switch Detect() {
+	case GRUB:
+		cmd = exec.Command(grubEditEnvCmd, grubEnvFile, "unset", name)
+	case UBoot:
+		cmd = exec.Command(fwSetEnvCmd, name)
+	default:
+		return ErrNoBootloaderEnv
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Can't unset bootloader variable %s: %s, output %s", name, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package busproxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+)
+
+const (
+	busName    = "io.hass.os"
+	RootPath   = "/io/hass/os"
+	propsIface = "org.freedesktop.DBus.Properties"
+)
+
+// polkitGated lists the methods which authorize their caller with polkit.
+// Proxied calls reach the agent from its own connection, which polkit always
+// authorizes, so these methods are only available on the bus.
+var polkitGated = map[string]bool{
+	"io.hass.os.Boards.FlashSPIBootloader":      true,
+	"io.hass.os.Boards.Yellow.ResetZigbee":      true,
+	"io.hass.os.Boards.Yellow.ZigbeeBootloader": true,
+	"io.hass.os.GPIO.ClaimLine":                 true,
+	"io.hass.os.System.EnableConsoleAutoLogin":  true,
+	"io.hass.os.System.SetConsolePassword":      true,
+	"io.hass.os.System.RegenerateMachineID":     true,
+	"io.hass.os.System2.EnableConsoleAutoLogin": true,
+	"io.hass.os.System2.SetConsolePassword":     true,
+	"io.hass.os.System2.RegenerateMachineID":    true,
+	"io.hass.os.Updates.MarkSlotBad":            true,
+	"io.hass.os.Updates.MarkSlotGood":           true,
+	"io.hass.os.Updates.RollbackOSSlot":         true,
+	"io.hass.os.Watchdog.SetPolicy":             true,
+}
+
+// ValidObject is true for objects of the agent.
+func ValidObject(object string) bool {
+	return object == RootPath || strings.HasPrefix(object, RootPath+"/")
+}
+
+// inputSignature looks up the input argument types of a method from the
+// introspection data of the object.
+func inputSignature(conn *dbus.Conn, object, iface, method string) ([]string, error) {
+	node, err := introspect.Call(conn.Object(busName, dbus.ObjectPath(object)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range node.Interfaces {
+		if i.Name != iface {
+			continue
+		}
+		for _, m := range i.Methods {
+			if m.Name != method {
+				continue
+			}
+			var sigs []string
+			for _, arg := range m.Args {
+				if arg.Direction != "out" {
+					sigs = append(sigs, arg.Type)
+				}
+			}
+			return sigs, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown method %s.%s on %s", iface, method, object)
+}
+
+// Call invokes an agent method over the bus with arguments decoded from JSON,
+// converting them to the types the method expects.
+func Call(conn *dbus.Conn, object, iface, method string, args []interface{}) ([]interface{}, error) {
+	if !ValidObject(object) {
+		return nil, fmt.Errorf("Invalid object '%s'", object)
+	}
+
+	sigs, err := inputSignature(conn, object, iface, method)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := convertArgs(args, sigs)
+	if err != nil {
+		return nil, err
+	}
+
+	call := conn.Object(busName, dbus.ObjectPath(object)).Call(iface+"."+method, 0, converted...)
+	if call.Err != nil {
+		return nil, call.Err
+	}
+	return Normalize(call.Body).([]interface{}), nil
+}
+
+// ProxyCall forwards a call on behalf of a client of one of the proxy
+// servers and records it in the audit journal under the client's name, as
+// the agent only sees its own connection as caller. Methods gated by polkit
+// are refused.
+func ProxyCall(conn *dbus.Conn, client string, object, iface, method string, args []interface{}) ([]interface{}, error) {
+	if polkitGated[iface+"."+method] {
+		return nil, apierror.New(apierror.CodeNotAuthorized, "%s.%s requires polkit authorization and is only available on D-Bus", iface, method).
+			WithRemediation(apierror.RemedyCheckPermissions).
+			DBus("org.freedesktop.DBus.Error.AccessDenied")
+	}
+
+	result, err := Call(conn, object, iface, method, args)
+	if err != nil {
+		return nil, err
+	}
+	audit.Record(dbus.Sender(client), iface+"."+method, object, fmt.Sprint(args))
+	return result, nil
+}
+
+// GetAll returns all properties of an agent interface.
+func GetAll(conn *dbus.Conn, object, iface string) (interface{}, error) {
+	if !ValidObject(object) {
+		return nil, fmt.Errorf("Invalid object '%s'", object)
+	}
+
+	var props map[string]dbus.Variant
+	err := conn.Object(busName, dbus.ObjectPath(object)).Call(propsIface+".GetAll", 0, iface).Store(&props)
+	if err != nil {
+		return nil, err
+	}
+	return Normalize(props), nil
+}
+
+// ErrorName returns the D-Bus error name of err.
+func ErrorName(err error) string {
+	switch dbusErr := err.(type) {
+	case dbus.Error:
+		return dbusErr.Name
+	case *dbus.Error:
+		return dbusErr.Name
+	}
+	return "io.hass.os.Error.Failed"
+}
@@ -0,0 +1,208 @@
+package busproxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var basicTypes = map[byte]reflect.Type{
+	'b': reflect.TypeOf(false),
+	'y': reflect.TypeOf(byte(0)),
+	'n': reflect.TypeOf(int16(0)),
+	'q': reflect.TypeOf(uint16(0)),
+	'i': reflect.TypeOf(int32(0)),
+	'u': reflect.TypeOf(uint32(0)),
+	'x': reflect.TypeOf(int64(0)),
+	't': reflect.TypeOf(uint64(0)),
+	'd': reflect.TypeOf(float64(0)),
+	's': reflect.TypeOf(""),
+	'o': reflect.TypeOf(dbus.ObjectPath("")),
+	'g': reflect.TypeOf(""),
+	'v': reflect.TypeOf(dbus.Variant{}),
+}
+
+// typeFor returns the Go type of the first complete type in sig and the
+// remaining signature.
+func typeFor(sig string) (reflect.Type, string, error) {
+	if sig == "" {
+		return nil, "", fmt.Errorf("Empty signature")
+	}
+
+	if t, ok := basicTypes[sig[0]]; ok {
+		return t, sig[1:], nil
+	}
+
+	switch sig[0] {
+	case 'a':
+		if len(sig) > 1 && sig[1] == '{' {
+			key, rest, err := typeFor(sig[2:])
+			if err != nil {
+				return nil, "", err
+			}
+			value, rest, err := typeFor(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			if rest == "" || rest[0] != '}' {
+				return nil, "", fmt.Errorf("Invalid dict signature '%s'", sig)
+			}
+			return reflect.MapOf(key, value), rest[1:], nil
+		}
+		elem, rest, err := typeFor(sig[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		return reflect.SliceOf(elem), rest, nil
+	case '(':
+		var fields []reflect.StructField
+		rest := sig[1:]
+		for rest != "" && rest[0] != ')' {
+			var t reflect.Type
+			var err error
+			t, rest, err = typeFor(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			fields = append(fields, reflect.StructField{Name: fmt.Sprintf("Field%d", len(fields)), Type: t})
+		}
+		if rest == "" {
+			return nil, "", fmt.Errorf("Invalid struct signature '%s'", sig)
+		}
+		return reflect.StructOf(fields), rest[1:], nil
+	}
+	return nil, "", fmt.Errorf("Unsupported type '%c'", sig[0])
+}
+
+// convert turns a decoded JSON value into a value of type t.
+func convert(value interface{}, t reflect.Type) (reflect.Value, error) {
+	if t == reflect.TypeOf(dbus.Variant{}) {
+		return reflect.ValueOf(dbus.MakeVariant(value)), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v, ok := value.(bool); ok {
+			return reflect.ValueOf(v), nil
+		}
+	case reflect.Uint8, reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64, reflect.Float64:
+		if v, ok := value.(float64); ok {
+			return reflect.ValueOf(v).Convert(t), nil
+		}
+	case reflect.String:
+		if v, ok := value.(string); ok {
+			return reflect.ValueOf(v).Convert(t), nil
+		}
+	case reflect.Slice:
+		// Byte arrays are passed base64 encoded, like encoding/json does.
+		if v, ok := value.(string); ok && t.Elem().Kind() == reflect.Uint8 {
+			data, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(data), nil
+		}
+		if v, ok := value.([]interface{}); ok {
+			slice := reflect.MakeSlice(t, 0, len(v))
+			for _, item := range v {
+				elem, err := convert(item, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				slice = reflect.Append(slice, elem)
+			}
+			return slice, nil
+		}
+	case reflect.Map:
+		if v, ok := value.(map[string]interface{}); ok {
+			m := reflect.MakeMapWithSize(t, len(v))
+			for key, item := range v {
+				var keyValue interface{} = key
+				if t.Key().Kind() != reflect.String {
+					var f float64
+					if _, err := fmt.Sscan(key, &f); err != nil {
+						return reflect.Value{}, err
+					}
+					keyValue = f
+				}
+				k, err := convert(keyValue, t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				elem, err := convert(item, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				m.SetMapIndex(k, elem)
+			}
+			return m, nil
+		}
+	case reflect.Struct:
+		if v, ok := value.([]interface{}); ok && len(v) == t.NumField() {
+			s := reflect.New(t).Elem()
+			for i, item := range v {
+				field, err := convert(item, t.Field(i).Type)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				s.Field(i).Set(field)
+			}
+			return s, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("Can't convert %v to %s", value, t)
+}
+
+// convertArgs converts JSON arguments according to the input arguments of a
+// method as found in the introspection data.
+func convertArgs(args []interface{}, sigs []string) ([]interface{}, error) {
+	if len(args) != len(sigs) {
+		return nil, fmt.Errorf("Expected %d arguments, got %d", len(sigs), len(args))
+	}
+
+	result := make([]interface{}, len(args))
+	for i, sig := range sigs {
+		t, rest, err := typeFor(sig)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("Invalid signature '%s'", sig)
+		}
+		value, err := convert(args[i], t)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %d: %s", i, err)
+		}
+		result[i] = value.Interface()
+	}
+	return result, nil
+}
+
+// Normalize replaces variants by their values so replies encode to plain
+// JSON.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case dbus.Variant:
+		return Normalize(v.Value())
+	case []interface{}:
+		for i := range v {
+			v[i] = Normalize(v[i])
+		}
+		return v
+	case map[string]dbus.Variant:
+		m := map[string]interface{}{}
+		for key, item := range v {
+			m[key] = Normalize(item.Value())
+		}
+		return m
+	case []map[string]dbus.Variant:
+		list := []interface{}{}
+		for _, item := range v {
+			list = append(list, Normalize(item))
+		}
+		return list
+	}
+	return value
+}
@@ -0,0 +1,229 @@
+// Package cmdline reads and edits kernel command lines, e.g. /proc/cmdline
+// or cmdline.txt on the boot partition. It tokenizes like the kernel does,
+// so quoted values containing spaces stay intact, and writes arguments it
+// doesn't touch back byte for byte.
+package cmdline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/home-assistant/os-agent/utils/timing"
+)
+
+// Everything after this argument is passed to init, not the kernel.
+const initSeparator = "--"
+
+type token struct {
+	// Whitespace preceding the argument
+	space string
+	raw   string
+}
+
+// CommandLine is a parsed kernel command line. Arguments keep their order
+// and duplicates, as the kernel passes all of them on.
+type CommandLine struct {
+	tokens   []token
+	trailing string
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// Parse splits a command line into arguments. Whitespace inside double
+// quotes doesn't end an argument, like in the kernel's next_arg().
+func Parse(line string) *CommandLine {
+	c := &CommandLine{}
+
+	i := 0
+	for i < len(line) {
+		start := i
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			c.trailing = line[start:]
+			break
+		}
+
+		argStart := i
+		inQuote := false
+		for i < len(line) && (inQuote || !isSpace(line[i])) {
+			if line[i] == '"' {
+				inQuote = !inQuote
+			}
+			i++
+		}
+		c.tokens = append(c.tokens, token{space: line[start:argStart], raw: line[argStart:i]})
+	}
+	return c
+}
+
+// Read parses the command line stored in a file.
+func Read(path string) (*CommandLine, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data)), nil
+}
+
+// Write stores the command line in a file, replacing it by rename.
+func (c *CommandLine) Write(path string) error {
+	defer timing.Track("Rewriting "+path, timing.CmdlineRewriteBudget)()
+
+	tmpPath := filepath.Join(filepath.Dir(path), ".tmp."+filepath.Base(path))
+	if err := ioutil.WriteFile(tmpPath, []byte(c.String()), 0644); err != nil {
+		return err
+	}
+
+	// Boot is mounted sync on Home Assistant OS, so just rename should be fine.
+	return os.Rename(tmpPath, path)
+}
+
+// String returns the command line, unchanged arguments and spacing exactly
+// as they were parsed.
+func (c *CommandLine) String() string {
+	var b strings.Builder
+	for _, t := range c.tokens {
+		b.WriteString(t.space)
+		b.WriteString(t.raw)
+	}
+	b.WriteString(c.trailing)
+	return b.String()
+}
+
+// Args returns all arguments as written, including quotes.
+func (c *CommandLine) Args() []string {
+	args := make([]string, len(c.tokens))
+	for i, t := range c.tokens {
+		args[i] = t.raw
+	}
+	return args
+}
+
+// kernelEnd returns the index of the init separator, or the number of
+// arguments if there is none.
+func (c *CommandLine) kernelEnd() int {
+	for i, t := range c.tokens {
+		if t.raw == initSeparator {
+			return i
+		}
+	}
+	return len(c.tokens)
+}
+
+// split returns the key and unquoted value of an argument, following the
+// kernel's rules: a fully quoted argument and a quoted value are unquoted.
+func split(raw string) (string, string, bool) {
+	arg := raw
+	if strings.HasPrefix(arg, `"`) {
+		arg = strings.TrimSuffix(arg[1:], `"`)
+	}
+
+	index := strings.Index(arg, "=")
+	if index < 0 {
+		return arg, "", false
+	}
+
+	value := arg[index+1:]
+	if strings.HasPrefix(value, `"`) {
+		value = strings.TrimSuffix(value[1:], `"`)
+	}
+	return arg[:index], value, true
+}
+
+// Contains returns whether a kernel argument exactly matches arg.
+func (c *CommandLine) Contains(arg string) bool {
+	for _, t := range c.tokens[:c.kernelEnd()] {
+		if t.raw == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the unquoted value of a kernel parameter. When given multiple
+// times, the last one wins.
+func (c *CommandLine) Get(key string) (string, bool) {
+	values := c.GetAll(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAll returns the unquoted values of all occurrences of a kernel
+// parameter, e.g. multiple console= arguments. A flag without value, like
+// "quiet", yields an empty string.
+func (c *CommandLine) GetAll(key string) []string {
+	var values []string
+	for _, t := range c.tokens[:c.kernelEnd()] {
+		if k, value, _ := split(t.raw); k == key {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (c *CommandLine) insert(index int, arg string) {
+	t := token{space: " ", raw: arg}
+	if index == 0 && len(c.tokens) > 0 {
+		// Keep leading whitespace in front of the command line
+		t.space = c.tokens[0].space
+		c.tokens[0].space = " "
+	} else if len(c.tokens) == 0 {
+		t.space = ""
+	}
+
+	c.tokens = append(c.tokens, token{})
+	copy(c.tokens[index+1:], c.tokens[index:])
+	c.tokens[index] = t
+}
+
+// Append adds a kernel argument, in front of arguments for init.
+func (c *CommandLine) Append(arg string) {
+	c.insert(c.kernelEnd(), arg)
+}
+
+// Prepend adds a kernel argument at the start of the command line.
+func (c *CommandLine) Prepend(arg string) {
+	c.insert(0, arg)
+}
+
+func (c *CommandLine) removeIf(match func(raw string) bool) int {
+	end := c.kernelEnd()
+	var tokens []token
+	removed := 0
+	for i, t := range c.tokens {
+		if i < end && match(t.raw) {
+			if len(tokens) == 0 && i+1 < len(c.tokens) {
+				// Keep leading whitespace in front of the command line
+				c.tokens[i+1].space = t.space
+			}
+			removed++
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	c.tokens = tokens
+	return removed
+}
+
+// Remove deletes all kernel arguments exactly matching arg and returns how
+// many were removed.
+func (c *CommandLine) Remove(arg string) int {
+	return c.removeIf(func(raw string) bool { return raw == arg })
+}
+
+// RemoveKey deletes all occurrences of a kernel parameter, with or without
+// value, and returns how many were removed.
+func (c *CommandLine) RemoveKey(key string) int {
+	return c.removeIf(func(raw string) bool {
+		k, _, _ := split(raw)
+		return k == key
+	})
+}
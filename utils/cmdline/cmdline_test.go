@@ -0,0 +1,148 @@
+package cmdline
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/home-assistant/os-agent/utils/timing/timingtest"
+)
+
+// Upper bound for rewriting cmdline.txt on a tmpfs, as done when scheduling
+// a wipe or safe mode boot.
+const rewriteThreshold = 2 * time.Millisecond
+
+const haosCommandLine = `zram.enabled=1 zram.num_devices=3 rootwait cgroup_enable=memory fsck.repair=yes ` +
+	`console=tty1 dyndbg="file drivers/usb/* +p" root=PARTUUID=8d3d53e3-6d49-4c38-8349-aff6859e82fd rootfstype=squashfs ro`
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"   ",
+		haosCommandLine,
+		haosCommandLine + "\n",
+		"  quiet\tloglevel=3  \n",
+		`"key=value with spaces" rootwait`,
+		`dyndbg="unterminated quote`,
+		"quiet -- init.arg",
+	} {
+		if got := Parse(line).String(); got != line {
+			t.Errorf("Parse(%q).String() = %q", line, got)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+	}{
+		{"quiet rootwait", []string{"quiet", "rootwait"}},
+		{`console=tty1 dyndbg="file drivers/usb/* +p" ro`, []string{"console=tty1", `dyndbg="file drivers/usb/* +p"`, "ro"}},
+		{`"key=value with spaces" ro`, []string{`"key=value with spaces"`, "ro"}},
+		{" \t quiet\n", []string{"quiet"}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		if got := Parse(test.line).Args(); !reflect.DeepEqual(got, test.args) {
+			t.Errorf("Parse(%q).Args() = %q, expected %q", test.line, got, test.args)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		line  string
+		key   string
+		value string
+		found bool
+		all   []string
+	}{
+		{`dyndbg="file drivers/usb/* +p" ro`, "dyndbg", "file drivers/usb/* +p", true, []string{"file drivers/usb/* +p"}},
+		{`"key=value with spaces" ro`, "key", "value with spaces", true, []string{"value with spaces"}},
+		{"console=tty1 console=ttyS0,115200", "console", "ttyS0,115200", true, []string{"tty1", "ttyS0,115200"}},
+		{"quiet ro", "quiet", "", true, []string{""}},
+		{"quiet -- loglevel=7", "loglevel", "", false, nil},
+		{"loglevel=3", "log", "", false, nil},
+	}
+	for _, test := range tests {
+		args := Parse(test.line)
+		if value, found := args.Get(test.key); value != test.value || found != test.found {
+			t.Errorf("Get(%q) on %q = %q, %t, expected %q, %t", test.key, test.line, value, found, test.value, test.found)
+		}
+		if all := args.GetAll(test.key); !reflect.DeepEqual(all, test.all) {
+			t.Errorf("GetAll(%q) on %q = %q, expected %q", test.key, test.line, all, test.all)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		edit     func(c *CommandLine) int
+		expected string
+		removed  int
+	}{
+		{"prepend keeps leading space", "  quiet\tro\n", func(c *CommandLine) int { c.Prepend("console=ttyS0"); return 0 }, "  console=ttyS0 quiet\tro\n", 0},
+		{"prepend to empty", "", func(c *CommandLine) int { c.Prepend("quiet"); return 0 }, "quiet", 0},
+		{"append before init args", "ro -- single", func(c *CommandLine) int { c.Append("haos.wipe=1"); return 0 }, "ro haos.wipe=1 -- single", 0},
+		{"remove first keeps leading space", "  quiet\tro\n", func(c *CommandLine) int { return c.Remove("quiet") }, "  ro\n", 1},
+		{"remove keeps other spacing", "ro\tquiet  rootwait\n", func(c *CommandLine) int { return c.Remove("quiet") }, "ro  rootwait\n", 1},
+		{"remove duplicates", "quiet ro quiet", func(c *CommandLine) int { return c.Remove("quiet") }, "ro", 2},
+		{"remove ignores init args", "quiet -- quiet", func(c *CommandLine) int { return c.Remove("quiet") }, "-- quiet", 1},
+		{"remove key with and without value", "loglevel loglevel=3 ro loglevel=7", func(c *CommandLine) int { return c.RemoveKey("loglevel") }, "ro", 3},
+		{"remove quoted key", `"key=a b" ro`, func(c *CommandLine) int { return c.RemoveKey("key") }, "ro", 1},
+	}
+	for _, test := range tests {
+		args := Parse(test.line)
+		removed := test.edit(args)
+		if got := args.String(); got != test.expected || removed != test.removed {
+			t.Errorf("%s: got %q (%d removed), expected %q (%d removed)", test.name, got, removed, test.expected, test.removed)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	args := Parse(`quiet "key=a b" -- single`)
+	if !args.Contains("quiet") || !args.Contains(`"key=a b"`) {
+		t.Error("expected kernel arguments to be found")
+	}
+	if args.Contains("single") || args.Contains("--") {
+		t.Error("init arguments aren't kernel arguments")
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Parse(haosCommandLine)
+	}
+}
+
+func BenchmarkRewrite(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "cmdline.txt")
+	if err := Parse(haosCommandLine).Write(path); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		args, err := Read(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !args.Contains("haos.wipe=1") {
+			args.Append("haos.wipe=1")
+		} else {
+			args.Remove("haos.wipe=1")
+		}
+		if err = args.Write(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRewriteBudget(t *testing.T) {
+	timingtest.CheckBudget(t, BenchmarkRewrite, rewriteThreshold)
+}
@@ -0,0 +1,498 @@
+// Package copier copies directory trees like cp -a --one-file-system, with a
+// SHA-256 checksum recorded for every regular file so the copy can be
+// verified, and a state file so an interrupted copy resumes where it
+// stopped instead of starting over.
+package copier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/natefinch/atomic"
+)
+
+const (
+	blockSize         = 1024 * 1024
+	stateSaveInterval = 10 * time.Second
+	maxReportedErrors = 5
+
+	// lseek whence values to skip holes of sparse files
+	seekData = 3
+	seekHole = 4
+)
+
+type fileState struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	SHA256  string `json:"sha256"`
+}
+
+type copyState struct {
+	Files map[string]fileState `json:"files"`
+}
+
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+type directory struct {
+	source string
+	target string
+	info   os.FileInfo
+}
+
+// Engine copies Source to Target, which has to exist.
+type Engine struct {
+	Source    string
+	Target    string
+	StateFile string
+	// Progress is called with the percentage of bytes copied whenever it
+	// changes.
+	Progress func(percentage uint32)
+
+	state      copyState
+	saved      time.Time
+	device     uint64
+	links      map[inode]string
+	buffer     []byte
+	total      uint64
+	copied     uint64
+	percentage uint32
+}
+
+// New returns an engine copying source to target, keeping its state in
+// stateFile. The state file is never copied itself.
+func New(source string, target string, stateFile string) *Engine {
+	return &Engine{
+		Source:    source,
+		Target:    target,
+		StateFile: stateFile,
+		state:     copyState{Files: map[string]fileState{}},
+	}
+}
+
+func (e *Engine) loadState() {
+	data, err := ioutil.ReadFile(e.StateFile)
+	if err != nil {
+		return
+	}
+	var state copyState
+	if err = json.Unmarshal(data, &state); err != nil || state.Files == nil {
+		return
+	}
+	e.state = state
+}
+
+func (e *Engine) saveState(force bool) error {
+	if !force && time.Since(e.saved) < stateSaveInterval {
+		return nil
+	}
+	data, err := json.Marshal(e.state)
+	if err != nil {
+		return err
+	}
+	e.saved = time.Now()
+	return atomic.WriteFile(e.StateFile, strings.NewReader(string(data)))
+}
+
+// RemoveState drops the state file, e.g. once the copy was verified.
+func (e *Engine) RemoveState() error {
+	err := os.Remove(e.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (e *Engine) advance(n uint64) {
+	e.copied += n
+	if e.total == 0 || e.Progress == nil {
+		return
+	}
+	percentage := uint32(e.copied * 100 / e.total)
+	if percentage > 100 {
+		percentage = 100
+	}
+	if percentage != e.percentage {
+		e.percentage = percentage
+		e.Progress(percentage)
+	}
+}
+
+// walk calls fn for all entries of Source on the same file system.
+func (e *Engine) walk(fn func(rel string, path string, info os.FileInfo, stat *syscall.Stat_t) error) error {
+	return filepath.Walk(e.Source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(e.Source, path)
+		if err != nil {
+			return err
+		}
+		if filepath.Join(e.Target, rel) == e.StateFile {
+			return nil
+		}
+
+		stat := info.Sys().(*syscall.Stat_t)
+		err = fn(rel, path, info, stat)
+		// Like --one-file-system, mount points are created but not entered
+		if err == nil && info.IsDir() && uint64(stat.Dev) != e.device {
+			return filepath.SkipDir
+		}
+		return err
+	})
+}
+
+func (e *Engine) size() (uint64, error) {
+	var total uint64
+	seen := map[inode]bool{}
+	err := e.walk(func(rel string, path string, info os.FileInfo, stat *syscall.Stat_t) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		key := inode{uint64(stat.Dev), uint64(stat.Ino)}
+		if stat.Nlink > 1 && seen[key] {
+			return nil
+		}
+		seen[key] = true
+		total += uint64(info.Size())
+		return nil
+	})
+	return total, err
+}
+
+// Copy copies the tree. Files recorded in the state with unchanged size and
+// modification time are skipped, so calling it again after an interruption
+// resumes the copy.
+func (e *Engine) Copy(ctx context.Context) error {
+	var root syscall.Stat_t
+	if err := syscall.Lstat(e.Source, &root); err != nil {
+		return err
+	}
+	e.device = uint64(root.Dev)
+	e.links = map[inode]string{}
+	e.buffer = make([]byte, blockSize)
+	e.loadState()
+
+	total, err := e.size()
+	if err != nil {
+		return err
+	}
+	e.total = total
+
+	// Directory metadata is applied last, writing their contents changes
+	// the modification time and read-only directories couldn't be filled.
+	var directories []directory
+	err = e.walk(func(rel string, path string, info os.FileInfo, stat *syscall.Stat_t) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target := filepath.Join(e.Target, rel)
+		if info.IsDir() {
+			directories = append(directories, directory{path, target, info})
+			if err := os.Mkdir(target, 0700); err != nil && !os.IsExist(err) {
+				return err
+			}
+			return nil
+		}
+		if err := e.copyEntry(ctx, rel, path, target, info, stat); err != nil {
+			return fmt.Errorf("Can't copy %s: %s", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		if saveErr := e.saveState(true); saveErr != nil {
+			return fmt.Errorf("%s, can't save copy state: %s", err, saveErr)
+		}
+		return err
+	}
+
+	if err = e.prune(ctx); err != nil {
+		return fmt.Errorf("Can't remove deleted files: %s", err)
+	}
+
+	for i := len(directories) - 1; i >= 0; i-- {
+		dir := directories[i]
+		if err = applyMetadata(dir.source, dir.target, dir.info); err != nil {
+			return fmt.Errorf("Can't copy metadata of %s: %s", dir.target, err)
+		}
+	}
+	return e.saveState(true)
+}
+
+// prune removes what was deleted from Source since an interrupted copy, both
+// from Target and the state, so it doesn't pass verification. A stale
+// database journal would otherwise be replayed on the copy.
+func (e *Engine) prune(ctx context.Context) error {
+	err := filepath.Walk(e.Target, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(e.Target, path)
+		if err != nil {
+			return err
+		}
+		// Created by mkfs on the target
+		if rel == "." || rel == "lost+found" || path == e.StateFile {
+			return nil
+		}
+		if _, err = os.Lstat(filepath.Join(e.Source, rel)); !os.IsNotExist(err) {
+			return err
+		}
+
+		if err = os.RemoveAll(path); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for rel := range e.state.Files {
+		if _, err = os.Lstat(filepath.Join(e.Source, rel)); os.IsNotExist(err) {
+			delete(e.state.Files, rel)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) copyEntry(ctx context.Context, rel string, source string, target string, info os.FileInfo, stat *syscall.Stat_t) error {
+	mode := info.Mode()
+	switch {
+	case mode&os.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		os.Remove(target)
+		if err = os.Symlink(link, target); err != nil {
+			return err
+		}
+		return os.Lchown(target, int(stat.Uid), int(stat.Gid))
+
+	case mode&(os.ModeDevice|os.ModeNamedPipe) != 0:
+		os.Remove(target)
+		if err := syscall.Mknod(target, stat.Mode, int(stat.Rdev)); err != nil {
+			return err
+		}
+		return applyMetadata(source, target, info)
+
+	case !mode.IsRegular():
+		// Sockets are recreated by their owners
+		return nil
+	}
+
+	if stat.Nlink > 1 {
+		key := inode{uint64(stat.Dev), uint64(stat.Ino)}
+		if first, ok := e.links[key]; ok {
+			os.Remove(target)
+			return os.Link(first, target)
+		}
+		e.links[key] = target
+	}
+
+	if recorded, ok := e.state.Files[rel]; ok && recorded.Size == info.Size() && recorded.ModTime == info.ModTime().UnixNano() {
+		if copied, err := os.Lstat(target); err == nil && copied.Size() == info.Size() {
+			e.advance(uint64(info.Size()))
+			return nil
+		}
+	}
+
+	sum, err := e.copyFile(ctx, source, target, info.Size())
+	if err != nil {
+		return err
+	}
+	if err = applyMetadata(source, target, info); err != nil {
+		return err
+	}
+
+	e.state.Files[rel] = fileState{Size: info.Size(), ModTime: info.ModTime().UnixNano(), SHA256: sum}
+	return e.saveState(false)
+}
+
+// copyFile copies the content of a regular file, holes of sparse files stay
+// holes. The checksum covers the content as read, holes as zeros.
+func (e *Engine) copyFile(ctx context.Context, source string, target string, size int64) (string, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	sum := sha256.New()
+	var offset int64
+	for offset < size {
+		data, err := in.Seek(offset, seekData)
+		if errors.Is(err, syscall.ENXIO) || (err == nil && data > size) {
+			data = size
+		} else if err != nil {
+			// No hole support, copy everything
+			data = offset
+		}
+		if data > offset {
+			hashZeros(sum, data-offset)
+			e.advance(uint64(data - offset))
+			offset = data
+			continue
+		}
+
+		// A hole at offset means no data follows, copy up to size rather than
+		// looping on an empty range
+		end, err := in.Seek(offset, seekHole)
+		if err != nil || end > size || end <= offset {
+			end = size
+		}
+		for offset < end {
+			if err = ctx.Err(); err != nil {
+				return "", err
+			}
+			length := end - offset
+			if length > blockSize {
+				length = blockSize
+			}
+			n, err := in.ReadAt(e.buffer[:length], offset)
+			if err == io.EOF {
+				return "", fmt.Errorf("File shrank during copy")
+			} else if err != nil {
+				return "", err
+			}
+			if _, err = out.WriteAt(e.buffer[:n], offset); err != nil {
+				return "", err
+			}
+			sum.Write(e.buffer[:n])
+			offset += int64(n)
+			e.advance(uint64(n))
+		}
+	}
+
+	// Extends the file over a trailing hole
+	if err = out.Truncate(size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), out.Sync()
+}
+
+func hashZeros(sum hash.Hash, n int64) {
+	zeros := make([]byte, blockSize)
+	for n > 0 {
+		length := n
+		if length > blockSize {
+			length = blockSize
+		}
+		sum.Write(zeros[:length])
+		n -= length
+	}
+}
+
+func applyMetadata(source string, target string, info os.FileInfo) error {
+	stat := info.Sys().(*syscall.Stat_t)
+	if err := os.Lchown(target, int(stat.Uid), int(stat.Gid)); err != nil {
+		return err
+	}
+	// After chown, which clears setuid bits
+	if err := os.Chmod(target, info.Mode()); err != nil {
+		return err
+	}
+	if err := copyXattrs(source, target); err != nil {
+		return err
+	}
+	atime := time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+	return os.Chtimes(target, atime, info.ModTime())
+}
+
+// copyXattrs copies extended attributes, e.g. the overlay attributes of
+// Docker image layers or file capabilities.
+func copyXattrs(source string, target string) error {
+	size, err := syscall.Listxattr(source, nil)
+	if err != nil || size == 0 {
+		// Not supported by the source file system
+		return nil
+	}
+	list := make([]byte, size)
+	if size, err = syscall.Listxattr(source, list); err != nil {
+		return err
+	}
+
+	for _, attr := range strings.Split(strings.TrimRight(string(list[:size]), "\x00"), "\x00") {
+		length, err := syscall.Getxattr(source, attr, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, length)
+		if length, err = syscall.Getxattr(source, attr, value); err != nil {
+			return err
+		}
+		if err = syscall.Setxattr(target, attr, value[:length], 0); err != nil {
+			return fmt.Errorf("Can't set %s: %s", attr, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks the checksums of all copied files on the target, e.g. after
+// a copy or to check if an interrupted one is usable.
+func (e *Engine) Verify(ctx context.Context) error {
+	e.loadState()
+
+	var failed []string
+	for rel, recorded := range e.state.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sum, err := checksum(filepath.Join(e.Target, rel))
+		if err != nil || sum != recorded.SHA256 {
+			failed = append(failed, rel)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	sort.Strings(failed)
+	count := len(failed)
+	if count > maxReportedErrors {
+		failed = append(failed[:maxReportedErrors], "...")
+	}
+	return fmt.Errorf("%d files don't match their checksum: %s", count, strings.Join(failed, ", "))
+}
+
+func checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sum := sha256.New()
+	if _, err = io.Copy(sum, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
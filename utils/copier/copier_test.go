@@ -0,0 +1,157 @@
+package copier
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func makeTree(t *testing.T) (string, string) {
+	source, err := ioutil.TempDir("", "copier-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := ioutil.TempDir("", "copier-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(source)
+		os.RemoveAll(target)
+	})
+
+	os.MkdirAll(filepath.Join(source, "docker", "volumes"), 0755)
+	ioutil.WriteFile(filepath.Join(source, "docker", "config.json"), []byte(`{"a":1}`), 0600)
+	os.Link(filepath.Join(source, "docker", "config.json"), filepath.Join(source, "docker", "volumes", "linked.json"))
+	os.Symlink("config.json", filepath.Join(source, "docker", "current.json"))
+
+	// 8MB file with data only at the end
+	sparse, err := os.Create(filepath.Join(source, "sparse.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse.WriteAt([]byte("tail"), 8*1024*1024-4)
+	sparse.Close()
+
+	// 8MB file with data only at the start, ending in a hole
+	trailing, err := os.Create(filepath.Join(source, "trailing.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailing.WriteAt([]byte("head"), 0)
+	trailing.Truncate(8 * 1024 * 1024)
+	trailing.Close()
+
+	return source, target
+}
+
+func TestCopy(t *testing.T) {
+	source, target := makeTree(t)
+	var progress []uint32
+	engine := New(source, target, filepath.Join(target, ".copy-state.json"))
+	engine.Progress = func(percentage uint32) { progress = append(progress, percentage) }
+
+	if err := engine.Copy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Verify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 100 {
+		t.Errorf("expected progress up to 100%%, got %v", progress)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(target, "docker", "config.json"))
+	if err != nil || string(data) != `{"a":1}` {
+		t.Errorf("unexpected content %q: %v", data, err)
+	}
+	if link, _ := os.Readlink(filepath.Join(target, "docker", "current.json")); link != "config.json" {
+		t.Errorf("expected symlink to config.json, got %q", link)
+	}
+
+	var original, linked syscall.Stat_t
+	syscall.Stat(filepath.Join(target, "docker", "config.json"), &original)
+	syscall.Stat(filepath.Join(target, "docker", "volumes", "linked.json"), &linked)
+	if original.Ino != linked.Ino {
+		t.Error("expected hard link to be preserved")
+	}
+
+	var sparse syscall.Stat_t
+	syscall.Stat(filepath.Join(target, "sparse.img"), &sparse)
+	if sparse.Size != 8*1024*1024 || sparse.Blocks*512 >= sparse.Size {
+		t.Errorf("expected sparse copy, got size %d with %d blocks", sparse.Size, sparse.Blocks)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(target, "sparse.img"))
+	if !bytes.HasSuffix(data, []byte("tail")) {
+		t.Error("expected data at end of sparse file")
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(target, "trailing.img"))
+	if len(data) != 8*1024*1024 || !bytes.HasPrefix(data, []byte("head")) {
+		t.Errorf("expected data at start of trailing hole file, got size %d", len(data))
+	}
+}
+
+func TestCopyResumesAndVerifies(t *testing.T) {
+	source, target := makeTree(t)
+	stateFile := filepath.Join(target, ".copy-state.json")
+
+	if err := New(source, target, stateFile).Copy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recorded files with unchanged size and time are not copied again, a
+	// corrupted one is only caught by verification.
+	corrupted := filepath.Join(target, "docker", "config.json")
+	ioutil.WriteFile(corrupted, []byte(`{"b":1}`), 0600)
+
+	engine := New(source, target, stateFile)
+	if err := engine.Copy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(corrupted); string(data) != `{"b":1}` {
+		t.Errorf("expected unchanged file to be skipped, got %q", data)
+	}
+	if err := engine.Verify(context.Background()); err == nil {
+		t.Error("expected verification to fail")
+	}
+}
+
+func TestCopyRemovesDeletedFiles(t *testing.T) {
+	source, target := makeTree(t)
+	stateFile := filepath.Join(target, ".copy-state.json")
+
+	ioutil.WriteFile(filepath.Join(source, "home-assistant_v2.db-wal"), []byte("wal"), 0600)
+	if err := New(source, target, stateFile).Copy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(source, "home-assistant_v2.db-wal"))
+	os.RemoveAll(filepath.Join(source, "docker"))
+	engine := New(source, target, stateFile)
+	if err := engine.Copy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"home-assistant_v2.db-wal", "docker"} {
+		if _, err := os.Lstat(filepath.Join(target, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed from the copy", name)
+		}
+	}
+	if err := engine.Verify(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyCancelled(t *testing.T) {
+	source, target := makeTree(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := New(source, target, filepath.Join(target, ".copy-state.json")).Copy(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
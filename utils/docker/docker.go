@@ -0,0 +1,91 @@
+// Package docker talks to the container runtime over its API socket, for the
+// few things the agent needs from it.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	socketPath     = "/run/docker.sock"
+	apiBase        = "http://docker"
+	requestTimeout = 2 * time.Minute
+)
+
+var client = &http.Client{
+	Timeout: requestTimeout,
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	},
+}
+
+func request(ctx context.Context, method string, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, apiBase+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with %s: %s", method, path, resp.Status, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Get performs a GET request on the API and decodes the JSON response into out.
+func Get(path string, out interface{}) error {
+	return request(context.Background(), http.MethodGet, path, out)
+}
+
+// GetContext is Get with a context limiting the request.
+func GetContext(ctx context.Context, path string, out interface{}) error {
+	return request(ctx, http.MethodGet, path, out)
+}
+
+// Post performs a POST request on the API without a body.
+func Post(path string) error {
+	return request(context.Background(), http.MethodPost, path, nil)
+}
+
+// ContainerState is the state of a container as reported by inspect.
+type ContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	Restarting bool   `json:"Restarting"`
+	StartedAt  string `json:"StartedAt"`
+}
+
+// InspectContainerState returns the state of a container by name or ID.
+func InspectContainerState(name string) (ContainerState, error) {
+	var container struct {
+		State ContainerState `json:"State"`
+	}
+	err := Get("/containers/"+name+"/json", &container)
+	return container.State, err
+}
+
+// RestartContainer restarts a container, killing it after timeout seconds.
+func RestartContainer(name string, timeout int) error {
+	return Post(fmt.Sprintf("/containers/%s/restart?t=%d", name, timeout))
+}
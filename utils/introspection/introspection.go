@@ -0,0 +1,46 @@
+package introspection
+
+import (
+	"github.com/godbus/dbus/v5/introspect"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+// Methods returns the introspection data for the methods of v like
+// introspect.Methods, with argument names taken from names. Names are listed
+// per method, input arguments first, then output arguments. Methods without
+// arguments need no entry, names may be nil if no method has any.
+//
+// Missing, miscounted or stale names are programming errors and panic, so
+// they show up on the first start instead of as unnamed arguments in
+// generated bindings.
+func Methods(v interface{}, names map[string][]string) []introspect.Method {
+	methods := introspect.Methods(v)
+
+	known := map[string]bool{}
+	for i, method := range methods {
+		known[method.Name] = true
+		if len(method.Args) == 0 {
+			continue
+		}
+
+		argNames, ok := names[method.Name]
+		if !ok {
+			logging.Critical.Panicf("No argument names for method %s", method.Name)
+		}
+		if len(argNames) != len(method.Args) {
+			logging.Critical.Panicf("Method %s has %d arguments, but %d names", method.Name, len(method.Args), len(argNames))
+		}
+
+		for j := range method.Args {
+			methods[i].Args[j].Name = argNames[j]
+		}
+	}
+
+	for name := range names {
+		if !known[name] {
+			logging.Critical.Panicf("Argument names for unknown method %s", name)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,45 @@
+package introspection
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type object struct{}
+
+func (o object) Ping() *dbus.Error                                       { return nil }
+func (o object) Echo(sender dbus.Sender, s string) (string, *dbus.Error) { return s, nil }
+
+func TestMethodsNamesArguments(t *testing.T) {
+	found := false
+	for _, method := range Methods(object{}, map[string][]string{"Echo": {"input", "output"}}) {
+		if method.Name != "Echo" {
+			continue
+		}
+		found = true
+		if len(method.Args) != 2 || method.Args[0].Name != "input" || method.Args[1].Name != "output" {
+			t.Errorf("unexpected arguments %v", method.Args)
+		}
+	}
+	if !found {
+		t.Error("method Echo not found")
+	}
+}
+
+func TestMethodsPanicsOnMismatch(t *testing.T) {
+	for name, names := range map[string]map[string][]string{
+		"missing":    nil,
+		"miscounted": {"Echo": {"input"}},
+		"stale":      {"Echo": {"input", "output"}, "Gone": {}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %s names", name)
+				}
+			}()
+			Methods(object{}, names)
+		}()
+	}
+}
@@ -0,0 +1,211 @@
+// Package managedfiles deposits configuration files on the host on behalf
+// of the agent and keeps track of them, so changes made behind the agent's
+// back can be detected and reverted.
+package managedfiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/natefinch/atomic"
+)
+
+const (
+	registryFile = "/etc/os-agent/managed-files.json"
+	contentDir   = "/etc/os-agent/managed"
+	markerFormat = "# Managed by os-agent (%s), local changes will be reverted.\n"
+)
+
+// File states
+const (
+	StateOK       = "ok"
+	StateModified = "modified"
+	StateMissing  = "missing"
+)
+
+// Files in these formats allow a leading comment with the ownership marker.
+var commentSuffixes = []string{".conf", ".rules", ".network", ".link", ".netdev"}
+
+type entry struct {
+	Owner  string      `json:"owner"`
+	SHA256 string      `json:"sha256"`
+	Mode   os.FileMode `json:"mode"`
+}
+
+var (
+	lock     sync.Mutex
+	registry map[string]entry
+)
+
+func load() map[string]entry {
+	if registry != nil {
+		return registry
+	}
+
+	registry = map[string]entry{}
+	data, err := ioutil.ReadFile(registryFile)
+	if err == nil {
+		json.Unmarshal(data, &registry)
+	}
+	return registry
+}
+
+func save() error {
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(registryFile), 0755); err != nil {
+		return err
+	}
+	return atomic.WriteFile(registryFile, strings.NewReader(string(data)))
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func withMarker(path string, owner string, content []byte) []byte {
+	for _, suffix := range commentSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return append([]byte(fmt.Sprintf(markerFormat, owner)), content...)
+		}
+	}
+	return content
+}
+
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := atomic.WriteFile(path, strings.NewReader(string(data))); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// Write deposits a file owned by a part of the agent, e.g. "network", and
+// keeps a copy to restore it from.
+func Write(path string, content []byte, mode os.FileMode, owner string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	data := withMarker(path, owner, content)
+	sum := checksum(data)
+
+	if err := writeFile(filepath.Join(contentDir, sum), data, 0644); err != nil {
+		return err
+	}
+	if err := writeFile(path, data, mode); err != nil {
+		return err
+	}
+
+	old, ok := load()[path]
+	registry[path] = entry{Owner: owner, SHA256: sum, Mode: mode}
+	if ok && old.SHA256 != sum {
+		removeContent(old.SHA256)
+	}
+	return save()
+}
+
+// Remove deletes a managed file and stops tracking it.
+func Remove(path string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	old, ok := load()[path]
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	delete(registry, path)
+	removeContent(old.SHA256)
+	return save()
+}
+
+// removeContent drops a stored copy no other file uses anymore.
+func removeContent(sum string) {
+	for _, e := range registry {
+		if e.SHA256 == sum {
+			return
+		}
+	}
+	os.Remove(filepath.Join(contentDir, sum))
+}
+
+func state(path string, e entry) string {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return StateMissing
+	} else if err != nil || checksum(data) != e.SHA256 {
+		return StateModified
+	}
+	return StateOK
+}
+
+// List returns the managed files with their owner and state.
+func List() map[string]map[string]string {
+	lock.Lock()
+	defer lock.Unlock()
+
+	files := map[string]map[string]string{}
+	for path, e := range load() {
+		files[path] = map[string]string{
+			"owner": e.Owner,
+			"state": state(path, e),
+		}
+	}
+	return files
+}
+
+// Drifted returns the managed files which were modified or deleted.
+func Drifted() []string {
+	lock.Lock()
+	defer lock.Unlock()
+
+	drifted := []string{}
+	for path, e := range load() {
+		if state(path, e) != StateOK {
+			drifted = append(drifted, path)
+		}
+	}
+	sort.Strings(drifted)
+	return drifted
+}
+
+// Reconcile restores all drifted files from their stored copies and returns
+// the restored paths.
+func Reconcile() ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	restored := []string{}
+	for path, e := range load() {
+		if state(path, e) == StateOK {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(contentDir, e.SHA256))
+		if err != nil {
+			return restored, fmt.Errorf("Can't read stored copy of %s: %s", path, err)
+		}
+		if err = writeFile(path, data, e.Mode); err != nil {
+			return restored, fmt.Errorf("Can't restore %s: %s", path, err)
+		}
+		restored = append(restored, path)
+	}
+	sort.Strings(restored)
+	return restored, nil
+}
@@ -0,0 +1,76 @@
+// Package netboot detects systems booted from the network, where the
+// "local" disks may be backed by a remote server.
+package netboot
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/fntlnz/mountinfo"
+
+	"github.com/home-assistant/os-agent/utils/cmdline"
+)
+
+const (
+	kernelCmdline = "/proc/cmdline"
+	iscsiSessions = "/sys/class/iscsi_session"
+)
+
+// Boot types
+const (
+	None  = ""
+	NFS   = "nfs"
+	ISCSI = "iscsi"
+	PXE   = "pxe"
+)
+
+// serverOf extracts the host of "server:/path" or "iscsi:server:..." specs.
+func serverOf(spec string) string {
+	spec = strings.TrimPrefix(spec, "iscsi:")
+	spec = strings.TrimPrefix(spec, "nfs:")
+	if i := strings.Index(spec, ":"); i >= 0 {
+		return spec[:i]
+	}
+	return ""
+}
+
+// Detect returns how the system was booted from the network and the server
+// involved, None if it booted from a local disk.
+func Detect() (string, string) {
+	minfo, err := mountinfo.GetMountInfo("/proc/self/mountinfo")
+	if err == nil {
+		for _, info := range minfo {
+			if info.MountPoint != "/" && info.MountPoint != "/mnt/data" {
+				continue
+			}
+			if strings.HasPrefix(info.FilesystemType, "nfs") {
+				return NFS, serverOf(info.MountSource)
+			}
+		}
+	}
+
+	args, err := cmdline.Read(kernelCmdline)
+	if err != nil {
+		args = cmdline.Parse("")
+	}
+	if nfsroot, ok := args.Get("nfsroot"); ok {
+		return NFS, serverOf(nfsroot)
+	}
+	if netroot, ok := args.Get("netroot"); ok && strings.HasPrefix(netroot, "iscsi:") {
+		return ISCSI, serverOf(netroot)
+	}
+	if entries, err := ioutil.ReadDir(iscsiSessions); err == nil && len(entries) > 0 {
+		return ISCSI, ""
+	}
+	// pxelinux and iPXE pass the boot interface as BOOTIF=01-<mac>, the
+	// server may follow in ip=<client>:<server>:...
+	if _, ok := args.Get("BOOTIF"); ok {
+		server := ""
+		ip, _ := args.Get("ip")
+		if fields := strings.Split(ip, ":"); len(fields) > 1 {
+			server = fields[1]
+		}
+		return PXE, server
+	}
+	return None, ""
+}
@@ -0,0 +1,139 @@
+package objectmanager
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	ifaceName = "org.freedesktop.DBus.ObjectManager"
+)
+
+type object struct {
+	props  *prop.Properties
+	ifaces []string
+}
+
+type objectManager struct{}
+
+var (
+	lock     sync.Mutex
+	conn     *dbus.Conn
+	root     dbus.ObjectPath
+	registry = map[dbus.ObjectPath]object{}
+
+	// IntrospectData is the introspection data of the ObjectManager interface.
+	IntrospectData = introspect.Interface{
+		Name: ifaceName,
+		Methods: []introspect.Method{
+			{
+				Name: "GetManagedObjects",
+				Args: []introspect.Arg{
+					{Name: "objects", Type: "a{oa{sa{sv}}}", Direction: "out"},
+				},
+			},
+		},
+		Signals: []introspect.Signal{
+			{
+				Name: "InterfacesAdded",
+				Args: []introspect.Arg{
+					{Name: "object", Type: "o"},
+					{Name: "interfaces", Type: "a{sa{sv}}"},
+				},
+			},
+			{
+				Name: "InterfacesRemoved",
+				Args: []introspect.Arg{
+					{Name: "object", Type: "o"},
+					{Name: "interfaces", Type: "as"},
+				},
+			},
+		},
+	}
+)
+
+func (o object) interfaces() map[string]map[string]dbus.Variant {
+	result := map[string]map[string]dbus.Variant{
+		"org.freedesktop.DBus.Introspectable": {},
+		"org.freedesktop.DBus.Properties":     {},
+	}
+	for _, iface := range o.ifaces {
+		props := map[string]dbus.Variant{}
+		if o.props != nil {
+			if all, err := o.props.GetAll(iface); err == nil {
+				props = all
+			}
+		}
+		result[iface] = props
+	}
+	return result
+}
+
+func (m objectManager) GetManagedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	result := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{}
+	for path, o := range registry {
+		result[path] = o.interfaces()
+	}
+	return result, nil
+}
+
+// Register adds an exported object to the ObjectManager. props may be nil for
+// objects without properties.
+func Register(path dbus.ObjectPath, props *prop.Properties, ifaces ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	o := object{props: props, ifaces: ifaces}
+	registry[path] = o
+
+	if conn != nil {
+		err := conn.Emit(root, ifaceName+".InterfacesAdded", path, o.interfaces())
+		if err != nil {
+			logging.Warning.Printf("Can't emit InterfacesAdded signal: %s", err)
+		}
+	}
+}
+
+// Unregister removes an object from the ObjectManager again.
+func Unregister(path dbus.ObjectPath) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	o, ok := registry[path]
+	if !ok {
+		return
+	}
+	delete(registry, path)
+
+	if conn != nil {
+		err := conn.Emit(root, ifaceName+".InterfacesRemoved", path, o.ifaces)
+		if err != nil {
+			logging.Warning.Printf("Can't emit InterfacesRemoved signal: %s", err)
+		}
+	}
+}
+
+// Export exposes the ObjectManager on the given root object. The caller is
+// responsible for adding IntrospectData to the introspection of the root.
+func Export(c *dbus.Conn, path dbus.ObjectPath) {
+	err := recovery.Export(c, objectManager{}, path, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	lock.Lock()
+	conn = c
+	root = path
+	lock.Unlock()
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", path, ifaceName)
+}
@@ -0,0 +1,56 @@
+package polkit
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/utils/apierror"
+)
+
+const (
+	polkitBusName    = "org.freedesktop.PolicyKit1"
+	polkitObjectPath = "/org/freedesktop/PolicyKit1/Authority"
+	polkitIfaceName  = "org.freedesktop.PolicyKit1.Authority"
+
+	// Don't prompt, the agent has no interactive callers
+	checkAuthorizationFlags = uint32(0)
+)
+
+type subject struct {
+	Kind    string
+	Details map[string]dbus.Variant
+}
+
+type authorizationResult struct {
+	IsAuthorized bool
+	IsChallenge  bool
+	Details      map[string]string
+}
+
+// CheckAuthorization asks polkit whether the D-Bus caller is allowed to
+// perform the given action. It returns a D-Bus error ready to hand back to
+// the caller if not.
+func CheckAuthorization(conn *dbus.Conn, sender dbus.Sender, actionID string) *dbus.Error {
+	caller := subject{
+		Kind: "system-bus-name",
+		Details: map[string]dbus.Variant{
+			"name": dbus.MakeVariant(string(sender)),
+		},
+	}
+
+	var result authorizationResult
+	obj := conn.Object(polkitBusName, polkitObjectPath)
+	err := obj.Call(polkitIfaceName+".CheckAuthorization", 0,
+		caller, actionID, map[string]string{}, checkAuthorizationFlags, "").Store(&result)
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("Can't check authorization for %s: %s", actionID, err))
+	}
+
+	if !result.IsAuthorized {
+		return apierror.New(apierror.CodeNotAuthorized, "Not authorized to perform %s", actionID).
+			WithRemediation(apierror.RemedyCheckPermissions).
+			DBus("org.freedesktop.DBus.Error.AccessDenied")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package recovery
+
+import (
+	"reflect"
+	"runtime/debug"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/utils/apierror"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/usage"
+)
+
+// InternalErrorName is the D-Bus error returned by a method that panicked.
+const InternalErrorName = "io.hass.os.Error.Internal"
+
+var dbusErrorType = reflect.TypeOf((*dbus.Error)(nil))
+
+var (
+	propSendersMutex sync.Mutex
+	// Caller of the property change in progress per object
+	propSenders = map[*prop.Properties]dbus.Sender{}
+)
+
+// InternalError returns the error reported to the caller of a method that
+// panicked with value r.
+func InternalError(method string, r interface{}) *dbus.Error {
+	return apierror.New(apierror.CodeInternal, "Internal error in %s: %v", method, r).
+		WithRemediation(apierror.RemedyReportIssue).
+		DBus(InternalErrorName)
+}
+
+// Export exports the methods of v like conn.Export, but recovers from panics
+// in them. A panicking method logs its stack trace and returns an
+// InternalError, the agent and all other methods stay available.
+func Export(conn *dbus.Conn, v interface{}, path dbus.ObjectPath, iface string) error {
+	if v == nil {
+		return conn.Export(nil, path, iface)
+	}
+	return conn.ExportMethodTable(Methods(v, iface), path, iface)
+}
+
+// Methods returns the D-Bus callable methods of v, wrapped by Wrap.
+func Methods(v interface{}, iface string) map[string]interface{} {
+	value := reflect.ValueOf(v)
+	methods := map[string]interface{}{}
+	for i := 0; i < value.NumMethod(); i++ {
+		fn := value.Method(i)
+		out := fn.Type().NumOut()
+		if out == 0 || fn.Type().Out(out-1) != dbusErrorType {
+			continue
+		}
+		name := value.Type().Method(i).Name
+		methods[name] = Wrap(iface+"."+name, fn.Interface())
+	}
+	return methods
+}
+
+// Wrap returns fn, a function whose last result is a *dbus.Error, with a
+// recover layer turning panics into an InternalError. Calls are counted for
+// the opt-in usage statistics.
+func Wrap(method string, fn interface{}) interface{} {
+	value := reflect.ValueOf(fn)
+	typ := value.Type()
+
+	return reflect.MakeFunc(typ, func(args []reflect.Value) (results []reflect.Value) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				usage.Record(method, !results[len(results)-1].IsNil())
+				return
+			}
+			usage.Record(method, true)
+			logging.Error.Printf("Panic in %s: %v\n%s", method, r, debug.Stack())
+			sentry.CurrentHub().Recover(r)
+
+			results = make([]reflect.Value, typ.NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(typ.Out(i))
+			}
+			results[len(results)-1] = reflect.ValueOf(InternalError(method, r))
+		}()
+		return value.Call(args)
+	}).Interface()
+}
+
+// Sender returns the caller which requested a property change, for the
+// audit journal. It is empty for changes not made over the bus.
+func Sender(c *prop.Change) dbus.Sender {
+	propSendersMutex.Lock()
+	defer propSendersMutex.Unlock()
+	return propSenders[c.Props]
+}
+
+func setSender(props *prop.Properties, sender dbus.Sender) {
+	propSendersMutex.Lock()
+	defer propSendersMutex.Unlock()
+	if sender == "" {
+		delete(propSenders, props)
+	} else {
+		propSenders[props] = sender
+	}
+}
+
+// properties replaces the Properties interface exported by prop.Export to
+// learn the caller of Set, which godbus doesn't pass to the callbacks.
+type properties struct {
+	props *prop.Properties
+	mutex *sync.Mutex
+}
+
+func (p properties) Get(iface, property string) (dbus.Variant, *dbus.Error) {
+	return p.props.Get(iface, property)
+}
+
+func (p properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	return p.props.GetAll(iface)
+}
+
+func (p properties) Set(sender dbus.Sender, iface, property string, value dbus.Variant) *dbus.Error {
+	// One change at a time per object, so Sender sees the right caller
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	setSender(p.props, sender)
+	defer setSender(p.props, "")
+	return p.props.Set(iface, property, value)
+}
+
+// ExportProps exports properties like prop.Export, with the callbacks of
+// writable properties wrapped by Wrap. Callbacks get the caller with Sender.
+func ExportProps(conn *dbus.Conn, path dbus.ObjectPath, props prop.Map) (*prop.Properties, error) {
+	for iface, spec := range props {
+		for name, p := range spec {
+			if p.Callback != nil {
+				p.Callback = Wrap(iface+"."+name, p.Callback).(func(*prop.Change) *dbus.Error)
+			}
+		}
+	}
+
+	exported, err := prop.Export(conn, path, props)
+	if err != nil {
+		return nil, err
+	}
+	handler := properties{props: exported, mutex: &sync.Mutex{}}
+	if err = conn.Export(handler, path, "org.freedesktop.DBus.Properties"); err != nil {
+		return nil, err
+	}
+	return exported, nil
+}
@@ -0,0 +1,45 @@
+package recovery
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type handler struct {
+	value *string
+}
+
+func (h handler) Get() (string, *dbus.Error) {
+	return *h.value, nil
+}
+
+func (h handler) Helper() string {
+	return "not exported on the bus"
+}
+
+func TestMethodsRecoversPanic(t *testing.T) {
+	methods := Methods(handler{}, "io.hass.os.Test")
+	if len(methods) != 1 {
+		t.Fatalf("expected only Get to be exported, got %d methods", len(methods))
+	}
+
+	get := methods["Get"].(func() (string, *dbus.Error))
+	value, err := get()
+	if err == nil || err.Name != InternalErrorName {
+		t.Fatalf("expected %s, got %v", InternalErrorName, err)
+	}
+	if value != "" {
+		t.Errorf("expected zero value, got %q", value)
+	}
+}
+
+func TestMethodsPassesResults(t *testing.T) {
+	value := "ok"
+	get := Methods(handler{value: &value}, "io.hass.os.Test")["Get"].(func() (string, *dbus.Error))
+
+	result, err := get()
+	if err != nil || result != "ok" {
+		t.Fatalf("expected ok, got %q, %v", result, err)
+	}
+}
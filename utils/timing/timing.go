@@ -0,0 +1,29 @@
+// Package timing measures operations on the onboarding and reset paths
+// against a time budget, so regressions on slow hardware show up in the logs.
+package timing
+
+import (
+	"time"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+// Budgets of the instrumented operations, generous enough for SD cards.
+const (
+	LabelResolveBudget   = 2 * time.Second
+	FormatBudget         = 2 * time.Minute
+	CmdlineRewriteBudget = 1 * time.Second
+)
+
+// Track starts timing an operation, the returned function stops it and
+// warns if the operation took longer than its budget:
+//
+//	defer timing.Track("Formatting", timing.FormatBudget)()
+func Track(operation string, budget time.Duration) func() {
+	start := time.Now()
+	return func() {
+		if elapsed := time.Since(start); elapsed > budget {
+			logging.Warning.Printf("%s took %s, exceeding its budget of %s", operation, elapsed, budget)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Package timingtest checks benchmarks against performance budgets in tests.
+package timingtest
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// BudgetsEnv enables the budget checks. They measure wall-clock time and
+// fail on loaded or slow machines, so they only run on request:
+//
+//	OS_AGENT_CHECK_BUDGETS=1 go test ./...
+const BudgetsEnv = "OS_AGENT_CHECK_BUDGETS"
+
+// CheckBudget runs a benchmark and fails the test if an operation took
+// longer than threshold on average.
+func CheckBudget(t *testing.T, benchmark func(*testing.B), threshold time.Duration) {
+	t.Helper()
+	if os.Getenv(BudgetsEnv) == "" {
+		t.Skipf("skipping performance budget, set %s=1 to check it", BudgetsEnv)
+	}
+
+	result := testing.Benchmark(benchmark)
+	if perOp := time.Duration(result.NsPerOp()); perOp > threshold {
+		t.Errorf("took %s per operation, exceeding the threshold of %s", perOp, threshold)
+	}
+}
@@ -0,0 +1,152 @@
+// Package uevent listens to kernel device events on the uevent netlink
+// socket, so hotplug can be watched without udev bindings.
+package uevent
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"syscall"
+
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	kernelGroup = 1
+	bufferSize  = 64 * 1024
+	queueSize   = 64
+	dropLogRate = 100
+)
+
+// Event is a single kernel uevent.
+type Event struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	DevName   string
+	Env       map[string]string
+}
+
+func parse(data []byte) (Event, bool) {
+	fields := bytes.Split(data, []byte{0})
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		return Event{}, false
+	}
+
+	event := Event{Env: map[string]string{}}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(string(field), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		event.Env[parts[0]] = parts[1]
+	}
+	event.Action = event.Env["ACTION"]
+	event.DevPath = event.Env["DEVPATH"]
+	event.Subsystem = event.Env["SUBSYSTEM"]
+	event.DevName = event.Env["DEVNAME"]
+	return event, true
+}
+
+type subscriber struct {
+	wanted  map[string]bool
+	events  chan Event
+	dropped uint64
+}
+
+var (
+	lock        sync.Mutex
+	subscribers []*subscriber
+)
+
+// deliver queues an event without blocking, a slow listener must not hold
+// up the others or let the socket overflow. Events it can't take are
+// dropped and counted.
+func (s *subscriber) deliver(event Event) {
+	select {
+	case s.events <- event:
+	default:
+		s.dropped++
+		if s.dropped%dropLogRate == 1 {
+			logging.Warning.Printf("uevent listener is lagging, dropped %d events so far", s.dropped)
+		}
+	}
+}
+
+// open binds the uevent socket shared by all listeners.
+func open() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return -1, err
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: kernelGroup})
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// receive dispatches the events of the shared socket to all listeners. On
+// errors all listeners are closed, the next Listen opens a new socket.
+func receive(fd int) {
+	defer syscall.Close(fd)
+
+	buffer := make([]byte, bufferSize)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err == syscall.EINTR || err == syscall.ENOBUFS {
+			continue
+		} else if err != nil {
+			logging.Warning.Printf("Can't receive uevent: %s", err)
+			break
+		}
+
+		event, ok := parse(buffer[:n])
+		if !ok {
+			continue
+		}
+
+		lock.Lock()
+		current := subscribers
+		lock.Unlock()
+		for _, s := range current {
+			if len(s.wanted) == 0 || s.wanted[event.Subsystem] {
+				s.deliver(event)
+			}
+		}
+	}
+
+	lock.Lock()
+	for _, s := range subscribers {
+		close(s.events)
+	}
+	subscribers = nil
+	lock.Unlock()
+}
+
+// Listen returns a channel receiving the events of the given subsystems, or
+// of all subsystems if none is given. The socket is only opened with the
+// first listener and shared by all of them.
+func Listen(subsystems ...string) (<-chan Event, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if subscribers == nil {
+		fd, err := open()
+		if err != nil {
+			return nil, err
+		}
+		go receive(fd)
+	}
+
+	wanted := map[string]bool{}
+	for _, subsystem := range subsystems {
+		wanted[subsystem] = true
+	}
+
+	events := make(chan Event, queueSize)
+	subscribers = append(subscribers, &subscriber{wanted: wanted, events: events})
+	return events, nil
+}
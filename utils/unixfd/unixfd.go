@@ -0,0 +1,21 @@
+// Package unixfd hands out open files as D-Bus file descriptors.
+package unixfd
+
+import (
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// closeDelay keeps our copy of an exported file descriptor open until the
+// reply carrying it has surely been sent. godbus duplicates the descriptor
+// only when sending the reply, after the method returned.
+const closeDelay = 30 * time.Second
+
+// Export returns the descriptor of file for a method reply and closes the
+// file once the reply is out. The caller must not use file afterwards.
+func Export(file *os.File) dbus.UnixFD {
+	time.AfterFunc(closeDelay, func() { file.Close() })
+	return dbus.UnixFD(file.Fd())
+}
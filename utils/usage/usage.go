@@ -0,0 +1,68 @@
+// Package usage counts invocations and failures of agent features while the
+// user opted in. Only method and property names are counted, nothing about
+// the device or its users.
+package usage
+
+import (
+	"sync"
+)
+
+type counter struct {
+	calls    uint64
+	failures uint64
+}
+
+var (
+	lock     sync.Mutex
+	enabled  bool
+	counters = map[string]*counter{}
+)
+
+// SetEnabled turns counting on or off. Disabling drops all counts.
+func SetEnabled(value bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	enabled = value
+	if !enabled {
+		counters = map[string]*counter{}
+	}
+}
+
+// Record counts an invocation of feature, e.g. io.hass.os.DataDisk.ChangeDevice.
+func Record(feature string, failed bool) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !enabled {
+		return
+	}
+	c, ok := counters[feature]
+	if !ok {
+		c = &counter{}
+		counters[feature] = c
+	}
+	c.calls++
+	if failed {
+		c.failures++
+	}
+}
+
+// Counters returns the calls and failures per feature.
+func Counters() map[string]map[string]uint64 {
+	lock.Lock()
+	defer lock.Unlock()
+
+	result := map[string]map[string]uint64{}
+	for feature, c := range counters {
+		result[feature] = map[string]uint64{"calls": c.calls, "failures": c.failures}
+	}
+	return result
+}
+
+// Reset drops all counts.
+func Reset() {
+	lock.Lock()
+	defer lock.Unlock()
+	counters = map[string]*counter{}
+}
@@ -0,0 +1,28 @@
+package usage
+
+import (
+	"testing"
+)
+
+func TestRecordOnlyWhenEnabled(t *testing.T) {
+	SetEnabled(false)
+	Record("io.hass.os.Test", false)
+	if len(Counters()) != 0 {
+		t.Fatalf("counted while disabled: %v", Counters())
+	}
+
+	SetEnabled(true)
+	defer SetEnabled(false)
+	Record("io.hass.os.Test", false)
+	Record("io.hass.os.Test", true)
+
+	got := Counters()["io.hass.os.Test"]
+	if got["calls"] != 2 || got["failures"] != 1 {
+		t.Errorf("unexpected counters %v", got)
+	}
+
+	SetEnabled(false)
+	if len(Counters()) != 0 {
+		t.Errorf("disabling kept counters: %v", Counters())
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Worker runs a background loop only while the feature it serves is
+// enabled, so disabled features don't keep goroutines, timers and their
+// memory around on small boards.
+type Worker struct {
+	mutex  sync.Mutex
+	run    func(ctx context.Context)
+	cancel context.CancelFunc
+}
+
+// New returns a stopped worker for run. run has to return once ctx is done.
+func New(run func(ctx context.Context)) *Worker {
+	return &Worker{run: run}
+}
+
+// Set starts or stops the worker, calls matching its state are no-ops.
+func (w *Worker) Set(enabled bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if enabled && w.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		w.cancel = cancel
+		go w.run(ctx)
+	} else if !enabled && w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+}
+
+// Sleep waits for d and returns false if ctx got done before.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,172 @@
+package varlink
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/godbus/dbus/v5"
+
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/busproxy"
+	logging "github.com/home-assistant/os-agent/utils/log"
+)
+
+const (
+	interfaceName = "io.hass.os"
+	serviceName   = "org.varlink.service"
+	proxyClient   = "varlink"
+)
+
+const interfaceDescription = `# Home Assistant OS Agent
+interface io.hass.os
+
+# Call a method of an agent object, arguments and result as in the D-Bus API.
+# Methods which authorize their caller with polkit are refused.
+method Call(object: string, interface: string, method: string, args: []object) -> (result: []object)
+
+# Get all properties of an interface of an agent object.
+method GetProperties(object: string, interface: string) -> (properties: object)
+
+# The D-Bus call failed. code, device and remediation are machine readable
+# details, if the agent provided them.
+error Failed(name: string, message: string, code: ?string, device: ?string, remediation: ?string)
+`
+
+type request struct {
+	Method     string          `json:"method"`
+	Parameters json.RawMessage `json:"parameters"`
+	Oneway     bool            `json:"oneway"`
+}
+
+type reply struct {
+	Parameters interface{} `json:"parameters,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type service struct {
+	conn    *dbus.Conn
+	version string
+}
+
+func (s service) handle(req request) reply {
+	switch req.Method {
+	case serviceName + ".GetInfo":
+		return reply{Parameters: map[string]interface{}{
+			"vendor":     "Home Assistant",
+			"product":    "OS Agent",
+			"version":    s.version,
+			"url":        "https://github.com/home-assistant/os-agent",
+			"interfaces": []string{serviceName, interfaceName},
+		}}
+	case serviceName + ".GetInterfaceDescription":
+		var params struct {
+			Interface string `json:"interface"`
+		}
+		json.Unmarshal(req.Parameters, &params)
+		if params.Interface != interfaceName {
+			return reply{Error: serviceName + ".InterfaceNotFound", Parameters: map[string]string{"interface": params.Interface}}
+		}
+		return reply{Parameters: map[string]string{"description": interfaceDescription}}
+	case interfaceName + ".Call":
+		var params struct {
+			Object    string        `json:"object"`
+			Interface string        `json:"interface"`
+			Method    string        `json:"method"`
+			Args      []interface{} `json:"args"`
+		}
+		if err := json.Unmarshal(req.Parameters, &params); err != nil {
+			return reply{Error: serviceName + ".InvalidParameter", Parameters: map[string]string{"parameter": "args"}}
+		}
+		result, err := busproxy.ProxyCall(s.conn, proxyClient, params.Object, params.Interface, params.Method, params.Args)
+		if err != nil {
+			return failed(err)
+		}
+		return reply{Parameters: map[string]interface{}{"result": result}}
+	case interfaceName + ".GetProperties":
+		var params struct {
+			Object    string `json:"object"`
+			Interface string `json:"interface"`
+		}
+		if err := json.Unmarshal(req.Parameters, &params); err != nil {
+			return reply{Error: serviceName + ".InvalidParameter", Parameters: map[string]string{"parameter": "object"}}
+		}
+		props, err := busproxy.GetAll(s.conn, params.Object, params.Interface)
+		if err != nil {
+			return failed(err)
+		}
+		return reply{Parameters: map[string]interface{}{"properties": props}}
+	}
+	return reply{Error: serviceName + ".MethodNotFound", Parameters: map[string]string{"method": req.Method}}
+}
+
+func failed(err error) reply {
+	parameters := map[string]string{"name": busproxy.ErrorName(err), "message": err.Error()}
+	for key, value := range apierror.Details(err) {
+		parameters[key] = value
+	}
+	return reply{Error: interfaceName + ".Failed", Parameters: parameters}
+}
+
+// serve handles one client connection. Varlink messages are JSON objects
+// terminated by a NUL byte.
+func (s service) serve(client net.Conn) {
+	defer client.Close()
+
+	reader := bufio.NewReader(client)
+	for {
+		data, err := reader.ReadBytes(0)
+		if err != nil {
+			return
+		}
+
+		var req request
+		if err = json.Unmarshal(data[:len(data)-1], &req); err != nil {
+			logging.Warning.Printf("Invalid varlink request: %s", err)
+			return
+		}
+
+		rep := s.handle(req)
+		if req.Oneway {
+			continue
+		}
+
+		out, err := json.Marshal(rep)
+		if err != nil {
+			return
+		}
+		if _, err = client.Write(append(out, 0)); err != nil {
+			return
+		}
+	}
+}
+
+// Start serves the varlink interface on sockets passed by systemd socket
+// activation. Without such a socket varlink stays disabled.
+func Start(conn *dbus.Conn, version string) {
+	listeners, err := activation.Listeners()
+	if err != nil {
+		logging.Warning.Printf("Can't get activated sockets: %s", err)
+		return
+	}
+
+	s := service{conn: conn, version: version}
+	for _, listener := range listeners {
+		if listener == nil {
+			continue
+		}
+
+		logging.Info.Printf("Serving varlink interface %s on %s ...", interfaceName, listener.Addr())
+		go func(listener net.Listener) {
+			for {
+				client, err := listener.Accept()
+				if err != nil {
+					logging.Error.Printf("Varlink listener stopped: %s", err)
+					return
+				}
+				go s.serve(client)
+			}
+		}(listener)
+	}
+}
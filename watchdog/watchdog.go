@@ -0,0 +1,232 @@
+package watchdog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/home-assistant/os-agent/audit"
+	"github.com/home-assistant/os-agent/utils/apierror"
+	"github.com/home-assistant/os-agent/utils/introspection"
+	logging "github.com/home-assistant/os-agent/utils/log"
+	"github.com/home-assistant/os-agent/utils/managedfiles"
+	"github.com/home-assistant/os-agent/utils/objectmanager"
+	"github.com/home-assistant/os-agent/utils/polkit"
+	"github.com/home-assistant/os-agent/utils/recovery"
+)
+
+const (
+	objectPath        = "/io/hass/os/Watchdog"
+	ifaceName         = "io.hass.os.Watchdog"
+	watchdogClass     = "/sys/class/watchdog"
+	procPath          = "/proc"
+	systemdDropIn     = "/etc/systemd/system.conf.d/os-agent-watchdog.conf"
+	systemdBusName    = "org.freedesktop.systemd1"
+	systemdObjectPath = "/org/freedesktop/systemd1"
+	systemdIfaceName  = "org.freedesktop.systemd1.Manager"
+	rebootTimeout     = "10min"
+	maxTimeout        = 600
+	actionSetPolicy   = "io.hass.os.watchdog"
+)
+
+var policyLock sync.Mutex
+
+type watchdog struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func readAttribute(device string, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(watchdogClass, device, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// getFeeders returns the processes holding each watchdog device open, by
+// device name. Opening the device arms it, so the holder is the one
+// feeding it.
+func getFeeders() map[string][]string {
+	feeders := map[string][]string{}
+
+	fds, _ := filepath.Glob(filepath.Join(procPath, "[0-9]*", "fd", "*"))
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(target, "/dev/watchdog") {
+			continue
+		}
+
+		device := filepath.Base(target)
+		if device == "watchdog" {
+			// Legacy node, an alias of watchdog0
+			device = "watchdog0"
+		}
+		pidDir := filepath.Dir(filepath.Dir(fd))
+		comm, _ := ioutil.ReadFile(filepath.Join(pidDir, "comm"))
+		feeder := fmt.Sprintf("%s (%s)", strings.TrimSpace(string(comm)), filepath.Base(pidDir))
+		feeders[device] = append(feeders[device], feeder)
+	}
+	return feeders
+}
+
+// getRuntimeTimeout returns the watchdog timeout systemd uses, 0 if it
+// doesn't feed a hardware watchdog.
+func getRuntimeTimeout(conn *dbus.Conn) uint32 {
+	obj := conn.Object(systemdBusName, systemdObjectPath)
+	value, err := obj.GetProperty(systemdIfaceName + ".RuntimeWatchdogUSec")
+	if err != nil {
+		logging.Warning.Printf("Can't read watchdog timeout from systemd: %s", err)
+		return 0
+	}
+
+	usec, _ := value.Value().(uint64)
+	if usec == 0 || usec == ^uint64(0) {
+		return 0
+	}
+	return uint32(time.Duration(usec) * time.Microsecond / time.Second)
+}
+
+func (d watchdog) ListDevices() ([]map[string]string, *dbus.Error) {
+	paths, _ := filepath.Glob(filepath.Join(watchdogClass, "watchdog*"))
+	feeders := getFeeders()
+
+	devices := []map[string]string{}
+	for _, path := range paths {
+		device := filepath.Base(path)
+		devices = append(devices, map[string]string{
+			"device":      filepath.Join("/dev", device),
+			"identity":    readAttribute(device, "identity"),
+			"state":       readAttribute(device, "state"),
+			"timeout":     readAttribute(device, "timeout"),
+			"max_timeout": readAttribute(device, "max_timeout"),
+			"bootstatus":  readAttribute(device, "bootstatus"),
+			"nowayout":    readAttribute(device, "nowayout"),
+			"fed_by":      strings.Join(feeders[device], ","),
+		})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i]["device"] < devices[j]["device"] })
+	return devices, nil
+}
+
+// SetPolicy makes systemd feed the hardware watchdog with the given timeout
+// in seconds, so a hung host reboots. Disabling removes the policy again,
+// leaving the watchdog to the OS defaults. systemd reloads its manager
+// configuration to apply it.
+func (d watchdog) SetPolicy(sender dbus.Sender, enabled bool, timeout uint32) (bool, *dbus.Error) {
+	if dbuserr := polkit.CheckAuthorization(d.conn, sender, actionSetPolicy); dbuserr != nil {
+		return false, dbuserr
+	}
+
+	logging.Info.Printf("Set host watchdog to %t with timeout %ds.", enabled, timeout)
+
+	if enabled && (timeout == 0 || timeout > maxTimeout) {
+		return false, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Invalid watchdog timeout %d, must be 1-%d", timeout, maxTimeout))
+	}
+	if enabled {
+		if limit, err := strconv.ParseUint(readAttribute("watchdog0", "max_timeout"), 10, 32); err == nil && uint64(timeout) > limit {
+			return false, apierror.Failed(apierror.New(apierror.CodeInvalidArgument, "Watchdog timeout %d exceeds the hardware limit of %d", timeout, limit).
+				WithDevice("/dev/watchdog0"))
+		}
+	}
+
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	if enabled {
+		config := fmt.Sprintf("[Manager]\nRuntimeWatchdogSec=%d\nRebootWatchdogSec=%s\n", timeout, rebootTimeout)
+		if err := managedfiles.Write(systemdDropIn, []byte(config), 0644, "watchdog"); err != nil {
+			return false, dbus.MakeFailedError(fmt.Errorf("Can't write watchdog configuration: %s", err))
+		}
+	} else if err := managedfiles.Remove(systemdDropIn); err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't remove watchdog configuration: %s", err))
+	}
+
+	obj := d.conn.Object(systemdBusName, systemdObjectPath)
+	// Reload re-reads system.conf.d and replies, unlike Reexecute which
+	// drops the bus connection
+	if err := obj.Call(systemdIfaceName+".Reload", 0).Err; err != nil {
+		return false, dbus.MakeFailedError(fmt.Errorf("Can't reload systemd: %s", err))
+	}
+
+	if !enabled {
+		timeout = 0
+	}
+	audit.Record(sender, "Watchdog.SetPolicy", d.props.GetMust(ifaceName, "Timeout"), timeout)
+	d.props.SetMust(ifaceName, "Enabled", enabled)
+	d.props.SetMust(ifaceName, "Timeout", timeout)
+	return true, nil
+}
+
+var methodArgNames = map[string][]string{
+	"ListDevices": {"devices"},
+	"SetPolicy":   {"enabled", "timeout", "success"},
+}
+
+func InitializeDBus(conn *dbus.Conn) {
+	d := watchdog{
+		conn: conn,
+	}
+
+	// Init base value
+	timeout := getRuntimeTimeout(conn)
+
+	propsSpec := map[string]map[string]*prop.Prop{
+		ifaceName: {
+			"Enabled": {
+				Value:    timeout > 0,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+			"Timeout": {
+				Value:    timeout,
+				Writable: false,
+				Emit:     prop.EmitTrue,
+				Callback: nil,
+			},
+		},
+	}
+
+	props, err := recovery.ExportProps(conn, objectPath, propsSpec)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+	d.props = props
+
+	err = recovery.Export(conn, d, objectPath, ifaceName)
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       ifaceName,
+				Methods:    introspection.Methods(d, methodArgNames),
+				Properties: props.Introspection(ifaceName),
+			},
+		},
+	}
+
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		logging.Critical.Panic(err)
+	}
+
+	logging.Info.Printf("Exposing object %s with interface %s ...", objectPath, ifaceName)
+	objectmanager.Register(objectPath, props, ifaceName)
+}